@@ -43,6 +43,6 @@ func main() {
 		panic(fmt.Errorf("error validating config: %w", err))
 	}
 
-	app := app.NewApp(conf)
+	app := app.NewApp(conf, configPath)
 	app.Start()
 }
@@ -0,0 +1,165 @@
+package mocks
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// OIDCIssuerMock is a stand-in OIDC issuer. It serves its JWKS, signs tokens and answers the
+// token endpoint of the authorization code flow. It must be closed after used.
+type OIDCIssuerMock struct {
+	Server       *httptest.Server
+	mu           sync.Mutex
+	jwksRequests int
+	jwksDelay    time.Duration
+	key          *rsa.PrivateKey
+	keyID        string
+	keysCreated  int
+	codes        map[string]jwt.MapClaims
+}
+
+func NewOIDCIssuerMock() *OIDCIssuerMock {
+	mock := &OIDCIssuerMock{codes: make(map[string]jwt.MapClaims)}
+	mock.RotateKey()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, _ *http.Request) {
+		mock.mu.Lock()
+		mock.jwksRequests++
+		delay := mock.jwksDelay
+		mock.mu.Unlock()
+		time.Sleep(delay)
+		_, _ = w.Write(mock.JWKS())
+	})
+	mux.HandleFunc("/token", mock.answerToken)
+	mock.Server = httptest.NewServer(mux)
+
+	return mock
+}
+
+func (mock *OIDCIssuerMock) Close() {
+	mock.Server.Close()
+}
+
+func (mock *OIDCIssuerMock) JWKSURL() string {
+	return mock.Server.URL + "/jwks"
+}
+
+func (mock *OIDCIssuerMock) TokenURL() string {
+	return mock.Server.URL + "/token"
+}
+
+func (mock *OIDCIssuerMock) AuthorizationURL() string {
+	return mock.Server.URL + "/authorize"
+}
+
+// JWKSRequests returns how many times the JWKS was fetched
+func (mock *OIDCIssuerMock) JWKSRequests() int {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	return mock.jwksRequests
+}
+
+// SetJWKSDelay makes the JWKS be served only after the delay
+func (mock *OIDCIssuerMock) SetJWKSDelay(delay time.Duration) {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	mock.jwksDelay = delay
+}
+
+// RotateKey replaces the signing key by a new one, with a new id
+func (mock *OIDCIssuerMock) RotateKey() {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	mock.keysCreated++
+	mock.key = key
+	mock.keyID = "key-" + strconv.Itoa(mock.keysCreated)
+}
+
+// JWKS returns the JWKS with the current signing key
+func (mock *OIDCIssuerMock) JWKS() []byte {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+
+	document := map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": mock.keyID,
+			"use": "sig",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(mock.key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(mock.key.E)).Bytes()),
+		}},
+	}
+
+	data, err := json.Marshal(document)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+// Token returns a token with the claims, signed by the current key
+func (mock *OIDCIssuerMock) Token(claims jwt.MapClaims) string {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = mock.keyID
+	signed, err := token.SignedString(mock.key)
+	if err != nil {
+		panic(err)
+	}
+	return signed
+}
+
+// NewCode returns a code that the token endpoint trades for an ID token with the claims
+func (mock *OIDCIssuerMock) NewCode(claims jwt.MapClaims) string {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+
+	code := "code-" + strconv.Itoa(len(mock.codes)+1)
+	mock.codes[code] = claims
+	return code
+}
+
+func (mock *OIDCIssuerMock) answerToken(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	mock.mu.Lock()
+	claims, ok := mock.codes[r.PostForm.Get("code")]
+	delete(mock.codes, r.PostForm.Get("code"))
+	mock.mu.Unlock()
+
+	if !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"error":"invalid_grant"}`))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]string{
+		"access_token": "access-token",
+		"token_type":   "Bearer",
+		"id_token":     mock.Token(claims),
+	})
+}
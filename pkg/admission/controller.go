@@ -0,0 +1,267 @@
+package admission
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/jademcosta/graviola/pkg/config"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	reasonQueueFull    = "queue_full"
+	reasonQueueTimeout = "queue_timeout"
+	reasonCancelled    = "cancelled"
+)
+
+var ErrQueueFull = errors.New("too many queries waiting to be executed")
+var ErrQueueTimeout = errors.New("query waited too long to be executed")
+
+var runOnceO11y sync.Once
+var queueLength *prometheus.GaugeVec
+var runningQueries *prometheus.GaugeVec
+var queueWaitTime *prometheus.HistogramVec
+var rejectedTotal *prometheus.CounterVec
+
+// Controller decides when queries are executed. Each priority has its own pool of slots, so
+// queries of one priority never wait for the ones of another. When all the slots of a priority
+// are in use, queries wait on a bounded queue, and are executed taking turns between the
+// tenants (or clients) that are waiting, so one of them can't starve the others.
+type Controller struct {
+	logg            *slog.Logger
+	pools           map[string]*pool
+	defaultPriority string
+	maxQueueLength  int
+	maxQueueTime    time.Duration
+}
+
+func NewController(logg *slog.Logger, metricz *prometheus.Registry, conf config.AdmissionConfig) *Controller {
+	runOnceO11y.Do(func() {
+		queueLength = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "graviola",
+			Subsystem: "admission",
+			Name:      "queue_length",
+			Help:      "Number of queries waiting for a free slot, by priority.",
+		},
+			[]string{"priority"})
+
+		runningQueries = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "graviola",
+			Subsystem: "admission",
+			Name:      "running_queries",
+			Help:      "Number of queries using a slot, by priority.",
+		},
+			[]string{"priority"})
+
+		queueWaitTime = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "graviola",
+			Subsystem: "admission",
+			Name:      "queue_wait_seconds",
+			Help:      "Time queries waited for a free slot, by priority.",
+			Buckets:   []float64{0.001, 0.01, 0.05, 0.1, 0.5, 1, 2.5, 5, 10, 30, 60},
+		},
+			[]string{"priority"})
+
+		rejectedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "graviola",
+			Subsystem: "admission",
+			Name:      "rejected_total",
+			Help:      "Counter of queries that were not executed, by priority and reason (queue_full, queue_timeout or cancelled).",
+		},
+			[]string{"priority", "reason"})
+
+		if metricz != nil {
+			metricz.MustRegister(queueLength, runningQueries, queueWaitTime, rejectedTotal)
+		}
+	})
+
+	pools := make(map[string]*pool, len(conf.Priorities))
+	for _, priority := range conf.Priorities {
+		pools[priority.Name] = newPool(priority.Name, priority.MaxConcurrent)
+	}
+
+	return &Controller{
+		logg:            logg.With("component", "admission"),
+		pools:           pools,
+		defaultPriority: conf.DefaultPriority,
+		maxQueueLength:  conf.MaxQueueLengthValue(),
+		maxQueueTime:    conf.MaxQueueTimeDuration(),
+	}
+}
+
+// Acquire blocks until the query can be executed, returning the function that frees its slot,
+// which must be called when the query finishes. Unknown priorities are replaced by the default
+// one. The tenant is used to take turns between the queries waiting.
+func (ctrl *Controller) Acquire(ctx context.Context, priority string, tenant string) (func(), error) {
+	pl, ok := ctrl.pools[priority]
+	if !ok {
+		pl = ctrl.pools[ctrl.defaultPriority]
+	}
+
+	start := time.Now()
+	waiting, err := pl.enqueue(tenant, ctrl.maxQueueLength)
+	if err != nil {
+		rejectedTotal.WithLabelValues(pl.name, reasonQueueFull).Inc()
+		return nil, err
+	}
+
+	if waiting == nil {
+		queueWaitTime.WithLabelValues(pl.name).Observe(0)
+		return pl.release, nil
+	}
+
+	timer := time.NewTimer(ctrl.maxQueueTime)
+	defer timer.Stop()
+
+	select {
+	case <-waiting.ready:
+		queueWaitTime.WithLabelValues(pl.name).Observe(time.Since(start).Seconds())
+		return pl.release, nil
+	case <-timer.C:
+		err = ErrQueueTimeout
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	if pl.dequeue(waiting) {
+		queueWaitTime.WithLabelValues(pl.name).Observe(time.Since(start).Seconds())
+		return pl.release, nil
+	}
+
+	if errors.Is(err, ErrQueueTimeout) {
+		rejectedTotal.WithLabelValues(pl.name, reasonQueueTimeout).Inc()
+	} else {
+		rejectedTotal.WithLabelValues(pl.name, reasonCancelled).Inc()
+	}
+	return nil, err
+}
+
+type waiter struct {
+	ready   chan struct{}
+	tenant  string
+	element *list.Element
+}
+
+type pool struct {
+	name          string
+	maxConcurrent int
+
+	mu      sync.Mutex
+	running int
+	queued  int
+	queues  map[string]*list.List
+	// tenants is the order in which the tenants with queued queries take turns
+	tenants []string
+	next    int
+}
+
+func newPool(name string, maxConcurrent int) *pool {
+	return &pool{
+		name:          name,
+		maxConcurrent: maxConcurrent,
+		queues:        make(map[string]*list.List),
+	}
+}
+
+// enqueue takes a slot if there is one free, returning a nil waiter. Otherwise the query is put
+// on the queue of its tenant.
+func (pl *pool) enqueue(tenant string, maxQueueLength int) (*waiter, error) {
+	pl.mu.Lock()
+	defer pl.mu.Unlock()
+
+	if pl.running < pl.maxConcurrent && pl.queued == 0 {
+		pl.running++
+		runningQueries.WithLabelValues(pl.name).Set(float64(pl.running))
+		return nil, nil
+	}
+
+	if pl.queued >= maxQueueLength {
+		return nil, ErrQueueFull
+	}
+
+	queue, ok := pl.queues[tenant]
+	if !ok {
+		queue = list.New()
+		pl.queues[tenant] = queue
+		pl.tenants = append(pl.tenants, tenant)
+	}
+
+	waiting := &waiter{ready: make(chan struct{}), tenant: tenant}
+	waiting.element = queue.PushBack(waiting)
+	pl.queued++
+	queueLength.WithLabelValues(pl.name).Set(float64(pl.queued))
+
+	return waiting, nil
+}
+
+// dequeue removes the waiter from the queue. It returns true if the waiter was given a slot
+// before it could be removed, in which case the slot belongs to it.
+func (pl *pool) dequeue(waiting *waiter) bool {
+	pl.mu.Lock()
+	defer pl.mu.Unlock()
+
+	select {
+	case <-waiting.ready:
+		return true
+	default:
+	}
+
+	pl.removeFromQueue(waiting)
+	return false
+}
+
+func (pl *pool) release() {
+	pl.mu.Lock()
+	defer pl.mu.Unlock()
+
+	pl.running--
+	if pl.queued > 0 {
+		waiting := pl.nextWaiter()
+		pl.removeFromQueue(waiting)
+		pl.running++
+		close(waiting.ready)
+	}
+
+	runningQueries.WithLabelValues(pl.name).Set(float64(pl.running))
+}
+
+// nextWaiter returns the oldest query of the tenant whose turn it is
+func (pl *pool) nextWaiter() *waiter {
+	if pl.next >= len(pl.tenants) {
+		pl.next = 0
+	}
+
+	tenant := pl.tenants[pl.next]
+	pl.next++
+
+	waiting, _ := pl.queues[tenant].Front().Value.(*waiter)
+	return waiting
+}
+
+func (pl *pool) removeFromQueue(waiting *waiter) {
+	queue := pl.queues[waiting.tenant]
+	queue.Remove(waiting.element)
+	pl.queued--
+	queueLength.WithLabelValues(pl.name).Set(float64(pl.queued))
+
+	if queue.Len() > 0 {
+		return
+	}
+
+	delete(pl.queues, waiting.tenant)
+	for idx, tenant := range pl.tenants {
+		if tenant != waiting.tenant {
+			continue
+		}
+
+		pl.tenants = append(pl.tenants[:idx], pl.tenants[idx+1:]...)
+		if idx < pl.next {
+			pl.next--
+		}
+		break
+	}
+}
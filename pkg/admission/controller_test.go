@@ -0,0 +1,146 @@
+package admission_test
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jademcosta/graviola/pkg/admission"
+	"github.com/jademcosta/graviola/pkg/config"
+	"github.com/jademcosta/graviola/pkg/graviolalog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var logg *slog.Logger = graviolalog.NewLogger(config.LogConfig{Level: "error"})
+
+func newSut(maxQueueLength int, maxQueueTime string, priorities ...config.PriorityConfig) *admission.Controller {
+	conf := config.AdmissionConfig{
+		Enabled:        true,
+		MaxQueueLength: &maxQueueLength,
+		MaxQueueTime:   maxQueueTime,
+		Priorities:     priorities,
+	}.FillDefaults(1)
+
+	return admission.NewController(logg, nil, conf)
+}
+
+func TestRejectsQueriesWhenTheQueueIsFull(t *testing.T) {
+	sut := newSut(1, "1m", config.PriorityConfig{Name: "default", MaxConcurrent: 1})
+	ctx := context.Background()
+
+	release, err := sut.Acquire(ctx, "", "a")
+	require.NoError(t, err, "should take the free slot")
+
+	acquired := make(chan struct{})
+	go func() {
+		releaseQueued, err := sut.Acquire(ctx, "", "a")
+		assert.NoError(t, err, "should wait on the queue")
+		close(acquired)
+		releaseQueued()
+	}()
+
+	// Gives time for the query to be queued
+	time.Sleep(20 * time.Millisecond)
+
+	_, err = sut.Acquire(ctx, "", "b")
+	assert.ErrorIs(t, err, admission.ErrQueueFull, "should reject queries when the queue is full")
+
+	release()
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		assert.Fail(t, "the queued query should get the freed slot")
+	}
+}
+
+func TestRejectsQueriesThatWaitTooLong(t *testing.T) {
+	sut := newSut(10, "50ms", config.PriorityConfig{Name: "default", MaxConcurrent: 1})
+	ctx := context.Background()
+
+	release, err := sut.Acquire(ctx, "", "a")
+	require.NoError(t, err, "should take the free slot")
+	defer release()
+
+	start := time.Now()
+	_, err = sut.Acquire(ctx, "", "a")
+	assert.ErrorIs(t, err, admission.ErrQueueTimeout, "should reject queries that wait more than the max queue time")
+	assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond, "should have waited the max queue time")
+}
+
+func TestStopsWaitingWhenTheContextIsDone(t *testing.T) {
+	sut := newSut(1, "1m", config.PriorityConfig{Name: "default", MaxConcurrent: 1})
+
+	release, err := sut.Acquire(context.Background(), "", "a")
+	require.NoError(t, err, "should take the free slot")
+	defer release()
+
+	ctx, cancelFn := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancelFn()
+	_, err = sut.Acquire(ctx, "", "a")
+	assert.ErrorIs(t, err, context.DeadlineExceeded, "should return the context error")
+
+	ctx, cancelFn = context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancelFn()
+	_, err = sut.Acquire(ctx, "", "a")
+	assert.ErrorIs(t, err, context.DeadlineExceeded, "should have freed the queue spot of the cancelled query")
+}
+
+func TestPrioritiesHaveSeparatePools(t *testing.T) {
+	sut := newSut(10, "50ms",
+		config.PriorityConfig{Name: "alerting", MaxConcurrent: 1},
+		config.PriorityConfig{Name: "adhoc", MaxConcurrent: 1},
+	)
+	ctx := context.Background()
+
+	releaseAdhoc, err := sut.Acquire(ctx, "adhoc", "a")
+	require.NoError(t, err, "should take the adhoc slot")
+	defer releaseAdhoc()
+
+	releaseAlerting, err := sut.Acquire(ctx, "alerting", "a")
+	require.NoError(t, err, "should not wait for the slots of other priorities")
+	defer releaseAlerting()
+
+	_, err = sut.Acquire(ctx, "unknown", "a")
+	assert.ErrorIs(t, err, admission.ErrQueueTimeout, "should use the default priority (the last one) when unknown")
+}
+
+func TestQueuedQueriesTakeTurnsBetweenTenants(t *testing.T) {
+	sut := newSut(10, "1m", config.PriorityConfig{Name: "default", MaxConcurrent: 1})
+	ctx := context.Background()
+
+	release, err := sut.Acquire(ctx, "", "blocker")
+	require.NoError(t, err, "should take the free slot")
+
+	var mu sync.Mutex
+	order := make([]string, 0)
+	var wg sync.WaitGroup
+
+	enqueue := func(name string, tenant string) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			releaseQueued, err := sut.Acquire(ctx, "", tenant)
+			assert.NoError(t, err, "should wait on the queue")
+			mu.Lock()
+			order = append(order, name)
+			mu.Unlock()
+			releaseQueued()
+		}()
+		// Gives time for the query to be queued, so the arrival order is known
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	enqueue("a1", "a")
+	enqueue("a2", "a")
+	enqueue("a3", "a")
+	enqueue("b1", "b")
+
+	release()
+	wg.Wait()
+
+	assert.Equal(t, []string{"a1", "b1", "a2", "a3"}, order,
+		"should take turns between tenants instead of following the arrival order")
+}
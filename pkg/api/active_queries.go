@@ -0,0 +1,54 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jademcosta/graviola/pkg/querytracker"
+)
+
+// apiResponse follows the format of the responses of Prometheus API
+type apiResponse struct {
+	Status    string `json:"status"`
+	Data      any    `json:"data,omitempty"`
+	ErrorType string `json:"errorType,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+func (api *GraviolaAPI) listActiveQueries(w http.ResponseWriter, _ *http.Request) {
+	api.writeJSON(w, http.StatusOK, apiResponse{Status: "success", Data: api.activeQueries.List()})
+}
+
+func (api *GraviolaAPI) cancelActiveQuery(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		api.writeJSON(w, http.StatusBadRequest,
+			apiResponse{Status: "error", ErrorType: "bad_data", Error: "query id must be a number"})
+		return
+	}
+
+	err = api.activeQueries.Cancel(id)
+	switch {
+	case errors.Is(err, querytracker.ErrQueryNotFound):
+		api.writeJSON(w, http.StatusNotFound, apiResponse{Status: "error", ErrorType: "not_found", Error: err.Error()})
+	case err != nil:
+		api.writeJSON(w, http.StatusUnprocessableEntity,
+			apiResponse{Status: "error", ErrorType: "execution", Error: err.Error()})
+	default:
+		api.logger.Info("active query cancelled", "id", id)
+		api.writeJSON(w, http.StatusOK, apiResponse{Status: "success"})
+	}
+}
+
+func (api *GraviolaAPI) writeJSON(w http.ResponseWriter, statusCode int, response apiResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+
+	err := json.NewEncoder(w).Encode(response)
+	if err != nil {
+		api.logger.Error("error writing response", "error", err)
+	}
+}
@@ -0,0 +1,93 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/jademcosta/graviola/pkg/config"
+	"github.com/jademcosta/graviola/pkg/graviolalog"
+	"github.com/jademcosta/graviola/pkg/http/httpmiddleware"
+	"github.com/jademcosta/graviola/pkg/querytracker"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestActiveQueriesCanBeListedAndCancelled(t *testing.T) {
+	logger := graviolalog.NewLogger(config.LogConfig{Level: "error"})
+	tracker := querytracker.NewGraviolaQueryTracker(logger, 5, "")
+
+	queryCtx, cancelFn := querytracker.WithCancel(context.Background())
+	defer cancelFn()
+	id, err := tracker.Insert(queryCtx, "up")
+	require.NoError(t, err, "should insert the query")
+
+	sut := NewGraviolaAPI(config.APIConfig{}, logger, prometheus.NewRegistry(), &dummyRegisterer{}, tracker, nil, nil, nil, nil, nil)
+
+	recorder := httptest.NewRecorder()
+	sut.router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/v1/status/active_queries", nil))
+	require.Equal(t, http.StatusOK, recorder.Code, "should list the active queries")
+
+	var listed struct {
+		Status string                     `json:"status"`
+		Data   []querytracker.ActiveQuery `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &listed), "should answer with JSON")
+	assert.Equal(t, "success", listed.Status, "should answer with success")
+	require.Len(t, listed.Data, 1, "should list the active query")
+	assert.Equal(t, "up", listed.Data[0].Query, "should list the query text")
+
+	recorder = httptest.NewRecorder()
+	sut.router.ServeHTTP(recorder, httptest.NewRequest(http.MethodDelete, "/api/v1/status/active_queries/12345", nil))
+	assert.Equal(t, http.StatusNotFound, recorder.Code, "should answer 404 for unknown queries")
+
+	recorder = httptest.NewRecorder()
+	sut.router.ServeHTTP(recorder, httptest.NewRequest(http.MethodDelete, "/api/v1/status/active_queries/abc", nil))
+	assert.Equal(t, http.StatusBadRequest, recorder.Code, "should answer 400 for invalid ids")
+
+	recorder = httptest.NewRecorder()
+	sut.router.ServeHTTP(recorder, httptest.NewRequest(http.MethodDelete,
+		"/api/v1/status/active_queries/"+strconv.Itoa(id), nil))
+	assert.Equal(t, http.StatusOK, recorder.Code, "should cancel the query")
+	assert.Error(t, queryCtx.Err(), "should have cancelled the query context")
+}
+
+func TestActiveQueriesAreOnlyAllowedToTheDebugKeys(t *testing.T) {
+	logger := graviolalog.NewLogger(config.LogConfig{Level: "error"})
+	tracker := querytracker.NewGraviolaQueryTracker(logger, 5, "")
+
+	queryCtx, cancelFn := querytracker.WithCancel(context.Background())
+	defer cancelFn()
+	id, err := tracker.Insert(queryCtx, "up")
+	require.NoError(t, err, "should insert the query")
+
+	sut := NewGraviolaAPI(config.APIConfig{}, logger, prometheus.NewRegistry(), &dummyRegisterer{}, tracker, nil,
+		httpmiddleware.NewAPIKeyMiddleware(logger, nil, apiKeys(t),
+			config.APIKeysConfig{Enabled: true, DebugKeyNames: []string{"oncall"}}),
+		nil, nil, nil)
+
+	serveAs := func(method string, path string, key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", "Bearer "+key)
+		recorder := httptest.NewRecorder()
+		sut.router.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	recorder := serveAs(http.MethodGet, "/api/v1/status/active_queries", "grafana-secret")
+	assert.Equal(t, http.StatusForbidden, recorder.Code, "should forbid listing to keys not allowed on /debug")
+
+	recorder = serveAs(http.MethodDelete, "/api/v1/status/active_queries/"+strconv.Itoa(id), "grafana-secret")
+	assert.Equal(t, http.StatusForbidden, recorder.Code, "should forbid cancelling to keys not allowed on /debug")
+	assert.NoError(t, queryCtx.Err(), "should not have cancelled the query context")
+
+	recorder = serveAs(http.MethodGet, "/api/v1/status/active_queries", "oncall-secret")
+	assert.Equal(t, http.StatusOK, recorder.Code, "should allow listing to the keys listed on debug_key_names")
+
+	recorder = serveAs(http.MethodDelete, "/api/v1/status/active_queries/"+strconv.Itoa(id), "oncall-secret")
+	assert.Equal(t, http.StatusOK, recorder.Code, "should allow cancelling to the keys listed on debug_key_names")
+}
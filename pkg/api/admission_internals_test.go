@@ -0,0 +1,68 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jademcosta/graviola/pkg/admission"
+	"github.com/jademcosta/graviola/pkg/config"
+	"github.com/jademcosta/graviola/pkg/graviolalog"
+	"github.com/jademcosta/graviola/pkg/http/httpmiddleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/route"
+	"github.com/stretchr/testify/assert"
+)
+
+type blockingRegisterer struct {
+	unblock chan struct{}
+}
+
+func (b *blockingRegisterer) Register(router *route.Router) {
+	router.Get("/query", func(w http.ResponseWriter, _ *http.Request) {
+		<-b.unblock
+		w.WriteHeader(http.StatusOK)
+	})
+	router.Get("/labels", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+func TestQueriesAreRejectedWhenTheAdmissionQueueIsFull(t *testing.T) {
+	logger := graviolalog.NewLogger(config.LogConfig{Level: "error"})
+	noQueue := 0
+	admissionConf := config.AdmissionConfig{
+		Enabled:         true,
+		MaxQueueLength:  &noQueue,
+		MaxQueueTime:    "1s",
+		PriorityHeader:  config.DefaultAdmissionPriorityHeader,
+		DefaultPriority: "default",
+		Priorities:      []config.PriorityConfig{{Name: "default", MaxConcurrent: 1}},
+	}
+
+	registerer := &blockingRegisterer{unblock: make(chan struct{})}
+	sut := NewGraviolaAPI(config.APIConfig{}, logger, prometheus.NewRegistry(), registerer, nil,
+		httpmiddleware.NewAdmissionMiddleware(
+			admission.NewController(logger, nil, admissionConf), admissionConf.PriorityHeader), nil, nil, nil, nil)
+
+	firstDone := make(chan int)
+	go func() {
+		recorder := httptest.NewRecorder()
+		sut.router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/v1/query", nil))
+		firstDone <- recorder.Code
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	recorder := httptest.NewRecorder()
+	sut.router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/v1/query", nil))
+	assert.Equal(t, http.StatusTooManyRequests, recorder.Code, "should answer 429 when the queue is full")
+	assert.NotEmpty(t, recorder.Header().Get("Retry-After"), "should inform when to retry")
+
+	recorder = httptest.NewRecorder()
+	sut.router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/v1/labels", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code, "should not queue requests that are not queries")
+
+	close(registerer.unblock)
+	assert.Equal(t, http.StatusOK, <-firstDone, "should execute the admitted query")
+}
@@ -2,15 +2,19 @@ package api
 
 import (
 	"context"
-	"fmt"
 	"log/slog"
+	"net"
 	"net/http"
-	"time"
+	"strconv"
+	"sync/atomic"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/jademcosta/graviola/pkg/config"
+	"github.com/jademcosta/graviola/pkg/graviolalog"
 	"github.com/jademcosta/graviola/pkg/http/httpmiddleware"
+	"github.com/jademcosta/graviola/pkg/http/httptls"
+	"github.com/jademcosta/graviola/pkg/querytracker"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/prometheus/common/route"
@@ -20,13 +24,33 @@ type registerer interface {
 	Register(*route.Router)
 }
 
+type activeQueriesTracker interface {
+	List() []querytracker.ActiveQuery
+	Cancel(id int) error
+	StopAccepting()
+	Drain(ctx context.Context) error
+	CancelAll() int
+}
+
 type GraviolaAPI struct {
 	conf                config.APIConfig
 	logger              *slog.Logger
 	metricRegistry      *prometheus.Registry
 	prometheusNativeAPI registerer
+	activeQueries       activeQueriesTracker
+	queryAdmission      func(next http.Handler) http.Handler
+	accessControl       func(next http.Handler) http.Handler
+	rateLimit           func(next http.Handler) http.Handler
+	logLevels           *graviolalog.Levels
+	reloadConfig        func() error
+	accessLogger        *slog.Logger
 	srv                 *http.Server
+	adminSrv            *http.Server
 	router              *chi.Mux
+	// stopping is set when Stop is called, and makes /ready fail
+	stopping atomic.Bool
+	// draining is set when new queries stop being accepted, after the pre-stop delay
+	draining atomic.Bool
 }
 
 func NewGraviolaAPI(
@@ -34,12 +58,25 @@ func NewGraviolaAPI(
 	logger *slog.Logger,
 	metricRegistry *prometheus.Registry,
 	prometheusNativeAPI registerer,
+	activeQueries activeQueriesTracker,
+	queryAdmission func(next http.Handler) http.Handler,
+	accessControl func(next http.Handler) http.Handler,
+	rateLimit func(next http.Handler) http.Handler,
+	logLevels *graviolalog.Levels,
+	reloadConfig func() error,
 ) *GraviolaAPI {
 	api := &GraviolaAPI{
 		conf:                conf,
 		logger:              logger.With("component", "api"),
 		metricRegistry:      metricRegistry,
 		prometheusNativeAPI: prometheusNativeAPI,
+		activeQueries:       activeQueries,
+		queryAdmission:      queryAdmission,
+		accessControl:       accessControl,
+		rateLimit:           rateLimit,
+		logLevels:           logLevels,
+		reloadConfig:        reloadConfig,
+		accessLogger:        logger.With("component", "access"),
 	}
 
 	api.createRoutes()
@@ -47,45 +84,140 @@ func NewGraviolaAPI(
 	return api
 }
 
+// Start serves the API, and the admin listener when it is enabled. It returns when any of them
+// stops.
 func (api *GraviolaAPI) Start() error {
-	return api.srv.ListenAndServe()
-}
+	errs := make(chan error, 2)
+
+	if api.adminSrv != nil {
+		go func() {
+			errs <- api.adminSrv.ListenAndServe()
+		}()
+	}
 
-func (api *GraviolaAPI) Stop() {
-	//TODO: should this timeout be a config on its own? Maybe even tie it with the running queries,
-	// waiting for them to finish
-	ctx, cancelFn := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancelFn()
+	go func() {
+		errs <- api.serve()
+	}()
 
-	api.logger.Debug("stopping called")
+	return <-errs
+}
+
+func (api *GraviolaAPI) serve() error {
+	if !api.conf.TLSConf.Enabled() {
+		return api.srv.ListenAndServe()
+	}
 
-	err := api.srv.Shutdown(ctx)
+	tlsConf, err := httptls.NewServerConfig(api.logger, api.conf.TLSConf)
 	if err != nil {
-		api.logger.Error("error when stopping", "error", err)
+		return err
 	}
+	api.srv.TLSConfig = tlsConf
 
-	api.logger.Info("stopped")
+	return api.srv.ListenAndServeTLS("", "")
 }
 
 func (api *GraviolaAPI) createRoutes() {
 	router := chi.NewRouter()
 
-	router.Use(httpmiddleware.NewLoggingMiddleware(api.logger))
+	router.Use(httpmiddleware.NewTracingMiddleware())
+	router.Use(httpmiddleware.NewBodyLimitMiddleware(api.conf.ServerConf.MaxBodyBytes))
+	router.Use(httpmiddleware.NewClientInfoMiddleware())
+	if api.accessControl != nil {
+		router.Use(api.accessControl)
+	}
+	router.Use(httpmiddleware.NewCancellationMiddleware())
+	router.Use(httpmiddleware.NewQueryStatsMiddleware())
+	router.Use(httpmiddleware.NewRouteMiddleware())
+	router.Use(httpmiddleware.NewLoggingMiddleware(api.accessLogger))
 	router.Use(httpmiddleware.NewMetricsMiddleware(api.metricRegistry))
 	router.Use(middleware.Recoverer)
+	if api.rateLimit != nil {
+		router.Use(api.rateLimit)
+	}
+	if api.queryAdmission != nil {
+		router.Use(api.queryAdmission)
+	}
 
-	router.Get("/metrics", promhttp.HandlerFor(api.metricRegistry, promhttp.HandlerOpts{Registry: api.metricRegistry}).ServeHTTP)
 	router.Get("/healthy", alwaysSuccessfulHandler)
-	router.Get("/ready", alwaysSuccessfulHandler)
-	router.Mount("/debug", middleware.Profiler())
+	router.Get("/ready", api.readyHandler)
+	if api.conf.AdminConf.Enabled {
+		api.adminSrv = api.newServer(api.conf.AdminConf.ListenAddress, api.conf.AdminConf.Port, api.adminRoutes())
+	} else {
+		api.registerAdminRoutes(router)
+	}
 
-	subRouter := route.New()
+	if api.activeQueries != nil {
+		router.Get("/api/v1/status/active_queries", api.listActiveQueries)
+		router.Delete("/api/v1/status/active_queries/{id}", api.cancelActiveQuery)
+	}
+
+	subRouter := route.New().WithInstrumentation(httpmiddleware.RouteInstrumentation("/api/v1"))
 	subRouter = subRouter.WithPrefix("/api/v1")
 	api.prometheusNativeAPI.Register(subRouter)
-	router.Handle("/*", subRouter)
+	router.Handle("/*", api.rejectWhenDraining(subRouter))
 
 	api.router = router
-	api.srv = &http.Server{Addr: fmt.Sprintf(":%d", api.conf.Port), Handler: router}
+
+	var handler http.Handler = router
+	if prefix := api.conf.RoutePrefix; prefix != "" && prefix != "/" {
+		handler = http.StripPrefix(prefix, router)
+	}
+	api.srv = api.newServer(api.conf.ListenAddress, api.conf.Port, handler)
+}
+
+// adminRoutes are served on the admin listener. /metrics, /healthy and /ready don't require
+// authentication there, while /debug and /-/reload go through the same access control as the API
+func (api *GraviolaAPI) adminRoutes() *chi.Mux {
+	router := chi.NewRouter()
+	router.Use(middleware.Recoverer)
+
+	router.Get("/healthy", alwaysSuccessfulHandler)
+	router.Get("/ready", api.readyHandler)
+	api.registerMetricsRoute(router)
+
+	router.Group(func(protected chi.Router) {
+		protected.Use(httpmiddleware.NewClientInfoMiddleware())
+		if api.accessControl != nil {
+			protected.Use(api.accessControl)
+		}
+		api.registerDebugRoutes(protected)
+	})
+
+	return router
+}
+
+func (api *GraviolaAPI) registerAdminRoutes(router chi.Router) {
+	api.registerMetricsRoute(router)
+	api.registerDebugRoutes(router)
+}
+
+func (api *GraviolaAPI) registerMetricsRoute(router chi.Router) {
+	router.Get("/metrics", promhttp.HandlerFor(api.metricRegistry, promhttp.HandlerOpts{Registry: api.metricRegistry}).ServeHTTP)
+}
+
+func (api *GraviolaAPI) registerDebugRoutes(router chi.Router) {
+	if api.logLevels != nil {
+		router.Get("/debug/log_level", api.getLogLevels)
+		router.Put("/debug/log_level", api.setLogLevel)
+		router.Delete("/debug/log_level", api.resetLogLevel)
+	}
+	if api.reloadConfig != nil {
+		router.Post("/-/reload", api.reload)
+	}
+	router.Mount("/debug", middleware.Profiler())
+}
+
+func (api *GraviolaAPI) newServer(address string, port int, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              net.JoinHostPort(address, strconv.Itoa(port)),
+		Handler:           handler,
+		ReadHeaderTimeout: api.conf.ServerConf.ReadHeaderTimeoutDuration(),
+		ReadTimeout:       api.conf.ServerConf.ReadTimeoutDuration(),
+		WriteTimeout:      api.conf.ServerConf.WriteTimeoutDuration(),
+		IdleTimeout:       api.conf.ServerConf.IdleTimeoutDuration(),
+		MaxHeaderBytes:    api.conf.ServerConf.MaxHeaderBytes,
+		ErrorLog:          slog.NewLogLogger(api.logger.Handler(), slog.LevelWarn),
+	}
 }
 
 func alwaysSuccessfulHandler(w http.ResponseWriter, _ *http.Request) {
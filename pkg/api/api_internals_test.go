@@ -36,7 +36,7 @@ func TestIntegrationAnswers500OnPanic(t *testing.T) {
 	}
 
 	sut := NewGraviolaAPI(
-		conf.APIConf, graviolalog.NewLogger(conf.LogConf), prometheus.NewRegistry(), &dummyRegisterer{})
+		conf.APIConf, graviolalog.NewLogger(conf.LogConf), prometheus.NewRegistry(), &dummyRegisterer{}, nil, nil, nil, nil, nil, nil)
 
 	sut.router.Get("/boom", func(_ http.ResponseWriter, _ *http.Request) {
 		panic("panic boooooooommmmm!")
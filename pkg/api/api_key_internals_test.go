@@ -0,0 +1,146 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jademcosta/graviola/pkg/auth"
+	"github.com/jademcosta/graviola/pkg/clientinfo"
+	"github.com/jademcosta/graviola/pkg/config"
+	"github.com/jademcosta/graviola/pkg/graviolalog"
+	"github.com/jademcosta/graviola/pkg/http/httpmiddleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func apiKeys(t *testing.T) *auth.APIKeys {
+	t.Helper()
+	keys, err := auth.ParseAPIKeys([]byte("keys:\n" +
+		"  - name: grafana\n" +
+		"    sha256: " + auth.HashAPIKey("grafana-secret") + "\n" +
+		"  - name: oncall\n" +
+		"    sha256: " + auth.HashAPIKey("oncall-secret") + "\n"))
+	require.NoError(t, err, "should parse the keys")
+	return keys
+}
+
+func newAPIWithKeys(t *testing.T, conf config.APIKeysConfig) *GraviolaAPI {
+	t.Helper()
+	keys := apiKeys(t)
+
+	logger := graviolalog.NewLogger(config.LogConfig{Level: "error"})
+	sut := NewGraviolaAPI(config.APIConfig{}, logger, prometheus.NewRegistry(), &dummyRegisterer{}, nil, nil,
+		httpmiddleware.NewAPIKeyMiddleware(logger, nil, keys, conf), nil, nil, nil)
+
+	sut.router.Get("/whoami", func(w http.ResponseWriter, r *http.Request) {
+		info, _ := clientinfo.FromContext(r.Context())
+		_, _ = w.Write([]byte(info.Principal))
+	})
+
+	return sut
+}
+
+func serve(sut *GraviolaAPI, path string, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	recorder := httptest.NewRecorder()
+	sut.router.ServeHTTP(recorder, req)
+	return recorder
+}
+
+func TestAPIKeysAreRequired(t *testing.T) {
+	sut := newAPIWithKeys(t, config.APIKeysConfig{Enabled: true})
+
+	recorder := serve(sut, "/whoami", nil)
+	assert.Equal(t, http.StatusUnauthorized, recorder.Code, "should reject requests without a key")
+	assert.Equal(t, "Bearer", recorder.Header().Get("WWW-Authenticate"), "should tell how to authenticate")
+
+	recorder = serve(sut, "/whoami", map[string]string{"Authorization": "Bearer wrong-secret"})
+	assert.Equal(t, http.StatusUnauthorized, recorder.Code, "should reject requests with an unknown key")
+
+	recorder = serve(sut, "/whoami", map[string]string{"Authorization": "Bearer grafana-secret"})
+	assert.Equal(t, http.StatusOK, recorder.Code, "should accept requests with a known key")
+	assert.Equal(t, "grafana", recorder.Body.String(), "should identify the client by the key name")
+}
+
+func TestAPIKeysCanBeSentOnACustomHeader(t *testing.T) {
+	sut := newAPIWithKeys(t, config.APIKeysConfig{Enabled: true, Header: "X-API-Key"})
+
+	recorder := serve(sut, "/whoami", map[string]string{"Authorization": "Bearer grafana-secret"})
+	assert.Equal(t, http.StatusUnauthorized, recorder.Code, "should only look for the key on the configured header")
+	assert.Empty(t, recorder.Header().Get("WWW-Authenticate"), "should not ask for a bearer token")
+
+	recorder = serve(sut, "/whoami", map[string]string{"X-API-Key": "oncall-secret"})
+	assert.Equal(t, http.StatusOK, recorder.Code, "should accept the key on the configured header")
+	assert.Equal(t, "oncall", recorder.Body.String(), "should identify the client by the key name")
+}
+
+func TestOperationalRoutesCanBeExemptFromAPIKeys(t *testing.T) {
+	sut := newAPIWithKeys(t, config.APIKeysConfig{Enabled: true})
+	for _, path := range []string{"/healthy", "/ready", "/metrics"} {
+		assert.Equal(t, http.StatusUnauthorized, serve(sut, path, nil).Code,
+			"should require a key on %s when it is not exempt", path)
+	}
+
+	sut = newAPIWithKeys(t, config.APIKeysConfig{Enabled: true, ExemptOperationalRoutes: true})
+	for _, path := range []string{"/healthy", "/ready", "/metrics"} {
+		assert.Equal(t, http.StatusOK, serve(sut, path, nil).Code, "should not require a key on %s", path)
+	}
+	assert.Equal(t, http.StatusUnauthorized, serve(sut, "/whoami", nil).Code,
+		"should still require a key on the other routes")
+}
+
+func TestDebugRoutesAreOnlyAllowedToSomeKeys(t *testing.T) {
+	sut := newAPIWithKeys(t, config.APIKeysConfig{Enabled: true, DebugKeyNames: []string{"oncall"}})
+
+	recorder := serve(sut, "/debug/pprof/", map[string]string{"Authorization": "Bearer grafana-secret"})
+	assert.Equal(t, http.StatusForbidden, recorder.Code, "should forbid keys not allowed on /debug")
+
+	recorder = serve(sut, "/debug/pprof/", map[string]string{"Authorization": "Bearer oncall-secret"})
+	assert.Equal(t, http.StatusOK, recorder.Code, "should allow the keys listed on debug_key_names")
+
+	recorder = serve(sut, "/debug/pprof/", nil)
+	assert.Equal(t, http.StatusUnauthorized, recorder.Code, "should require a key on /debug")
+
+	recorder = serve(sut, "/-/reload", map[string]string{"Authorization": "Bearer grafana-secret"})
+	assert.Equal(t, http.StatusForbidden, recorder.Code, "should forbid keys not allowed on /debug to reload")
+}
+
+func TestDebugRoutesKeepTheAccessControlOnTheAdminListener(t *testing.T) {
+	logger := graviolalog.NewLogger(config.LogConfig{Level: "error"})
+	conf := config.APIConfig{AdminConf: config.APIAdminConfig{Enabled: true}}.FillDefaults()
+	sut := NewGraviolaAPI(conf, logger, prometheus.NewRegistry(), &dummyRegisterer{}, nil, nil,
+		httpmiddleware.NewAPIKeyMiddleware(logger, nil, apiKeys(t),
+			config.APIKeysConfig{Enabled: true, DebugKeyNames: []string{"oncall"}}),
+		nil, nil, nil)
+	require.NotNil(t, sut.adminSrv, "should have an admin listener")
+
+	serveAdmin := func(path string, headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		recorder := httptest.NewRecorder()
+		sut.adminSrv.Handler.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	for _, path := range []string{"/metrics", "/healthy", "/ready"} {
+		recorder := serveAdmin(path, nil)
+		assert.Equal(t, http.StatusOK, recorder.Code, "should not require a key on %s", path)
+	}
+
+	recorder := serveAdmin("/debug/pprof/", nil)
+	assert.Equal(t, http.StatusUnauthorized, recorder.Code, "should require a key on /debug")
+
+	recorder = serveAdmin("/debug/pprof/", map[string]string{"Authorization": "Bearer grafana-secret"})
+	assert.Equal(t, http.StatusForbidden, recorder.Code, "should forbid keys not allowed on /debug")
+
+	recorder = serveAdmin("/debug/pprof/", map[string]string{"Authorization": "Bearer oncall-secret"})
+	assert.Equal(t, http.StatusOK, recorder.Code, "should allow the keys listed on debug_key_names")
+}
@@ -0,0 +1,52 @@
+package api
+
+import (
+	"net/http"
+)
+
+// logLevelsResponse is the default log level along with the components that have one of their own
+type logLevelsResponse struct {
+	Level      string            `json:"level"`
+	Components map[string]string `json:"components"`
+}
+
+func (api *GraviolaAPI) getLogLevels(w http.ResponseWriter, _ *http.Request) {
+	api.writeLogLevels(w)
+}
+
+// setLogLevel changes the level of the component given, or the default level when no component
+// is given
+func (api *GraviolaAPI) setLogLevel(w http.ResponseWriter, r *http.Request) {
+	level := r.FormValue("level")
+	component := r.FormValue("component")
+
+	err := api.logLevels.Set(component, level)
+	if err != nil {
+		api.writeJSON(w, http.StatusBadRequest, apiResponse{Status: "error", ErrorType: "bad_data", Error: err.Error()})
+		return
+	}
+
+	api.logger.Info("log level changed", "level", level, "log_component", component)
+	api.writeLogLevels(w)
+}
+
+// resetLogLevel makes the component given use the default level again
+func (api *GraviolaAPI) resetLogLevel(w http.ResponseWriter, r *http.Request) {
+	component := r.FormValue("component")
+	if component == "" {
+		api.writeJSON(w, http.StatusBadRequest,
+			apiResponse{Status: "error", ErrorType: "bad_data", Error: "component cannot be empty"})
+		return
+	}
+
+	api.logLevels.Unset(component)
+	api.logger.Info("log level reset to the default", "log_component", component)
+	api.writeLogLevels(w)
+}
+
+func (api *GraviolaAPI) writeLogLevels(w http.ResponseWriter) {
+	api.writeJSON(w, http.StatusOK, apiResponse{Status: "success", Data: logLevelsResponse{
+		Level:      api.logLevels.Default(),
+		Components: api.logLevels.Components(),
+	}})
+}
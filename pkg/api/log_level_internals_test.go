@@ -0,0 +1,139 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jademcosta/graviola/pkg/auth"
+	"github.com/jademcosta/graviola/pkg/config"
+	"github.com/jademcosta/graviola/pkg/graviolalog"
+	"github.com/jademcosta/graviola/pkg/http/httpmiddleware"
+	"github.com/jademcosta/graviola/pkg/querystats"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type logLevelsAnswer struct {
+	Status string            `json:"status"`
+	Data   logLevelsResponse `json:"data"`
+}
+
+func sendLogLevelRequest(t *testing.T, sut *GraviolaAPI, method string, form url.Values) (int, logLevelsAnswer) {
+	req := httptest.NewRequest(method, "/debug/log_level?"+form.Encode(), nil)
+	recorder := httptest.NewRecorder()
+	sut.router.ServeHTTP(recorder, req)
+
+	var answer logLevelsAnswer
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &answer), "should answer with JSON")
+	return recorder.Code, answer
+}
+
+func TestLogLevelsCanBeChangedAtRuntime(t *testing.T) {
+	logConf := config.LogConfig{Level: "info", ComponentLevels: map[string]string{"remote": "debug"}}
+	levels := graviolalog.NewLevels(logConf)
+	sut := NewGraviolaAPI(config.APIConfig{}, graviolalog.NewNoopLogger(), prometheus.NewRegistry(),
+		&dummyRegisterer{}, nil, nil, nil, nil, levels, nil)
+
+	status, answer := sendLogLevelRequest(t, sut, http.MethodGet, url.Values{})
+	require.Equal(t, http.StatusOK, status, "should list the log levels")
+	assert.Equal(t, "info", answer.Data.Level, "should list the default level")
+	assert.Equal(t, map[string]string{"remote": "debug"}, answer.Data.Components,
+		"should list the levels of the components")
+
+	status, answer = sendLogLevelRequest(t, sut, http.MethodPut, url.Values{"level": {"warn"}})
+	require.Equal(t, http.StatusOK, status, "should change the default level")
+	assert.Equal(t, "warn", answer.Data.Level, "should answer with the new default level")
+	assert.Equal(t, slog.LevelWarn, levels.Level("api"), "should apply the new default level")
+
+	status, _ = sendLogLevelRequest(t, sut, http.MethodPut, url.Values{"level": {"error"}, "component": {"access"}})
+	require.Equal(t, http.StatusOK, status, "should change the level of a component")
+	assert.Equal(t, slog.LevelError, levels.Level("access"), "should apply the level of the component")
+
+	status, answer = sendLogLevelRequest(t, sut, http.MethodDelete, url.Values{"component": {"remote"}})
+	require.Equal(t, http.StatusOK, status, "should reset the level of a component")
+	assert.Equal(t, map[string]string{"access": "error"}, answer.Data.Components,
+		"should not list the component that was reset")
+	assert.Equal(t, slog.LevelWarn, levels.Level("remote"), "should use the default level on the component reset")
+
+	status, _ = sendLogLevelRequest(t, sut, http.MethodPut, url.Values{"level": {"verbose"}})
+	assert.Equal(t, http.StatusBadRequest, status, "should reject unsupported levels")
+	assert.Equal(t, slog.LevelWarn, levels.Level(""), "should keep the level when the new one is rejected")
+
+	status, _ = sendLogLevelRequest(t, sut, http.MethodDelete, url.Values{})
+	assert.Equal(t, http.StatusBadRequest, status, "should require the component to be reset")
+}
+
+func TestLogLevelsAreOnlyChangedByTheKeysAllowedOnDebug(t *testing.T) {
+	keys, err := auth.ParseAPIKeys([]byte("keys:\n" +
+		"  - name: grafana\n" +
+		"    sha256: " + auth.HashAPIKey("grafana-secret") + "\n" +
+		"  - name: oncall\n" +
+		"    sha256: " + auth.HashAPIKey("oncall-secret") + "\n"))
+	require.NoError(t, err, "should parse the keys")
+
+	logger := graviolalog.NewNoopLogger()
+	levels := graviolalog.NewLevels(config.LogConfig{Level: "info"})
+	keysConf := config.APIKeysConfig{Enabled: true, DebugKeyNames: []string{"oncall"}}
+	sut := NewGraviolaAPI(config.APIConfig{}, logger, prometheus.NewRegistry(), &dummyRegisterer{}, nil, nil,
+		httpmiddleware.NewAPIKeyMiddleware(logger, nil, keys, keysConf), nil, levels, nil)
+
+	for key, expectedStatus := range map[string]int{
+		"":               http.StatusUnauthorized,
+		"grafana-secret": http.StatusForbidden,
+		"oncall-secret":  http.StatusOK,
+	} {
+		req := httptest.NewRequest(http.MethodPut, "/debug/log_level?level=debug", nil)
+		if key != "" {
+			req.Header.Set("Authorization", "Bearer "+key)
+		}
+		recorder := httptest.NewRecorder()
+		sut.router.ServeHTTP(recorder, req)
+		assert.Equal(t, expectedStatus, recorder.Code, "should protect the log levels like the other debug routes")
+	}
+
+	assert.Equal(t, slog.LevelDebug, levels.Level(""), "should apply the level changed by the allowed key")
+}
+
+func TestAccessLogHasTheQueryAndTheRemotesContacted(t *testing.T) {
+	var output bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&output, nil))
+	sut := NewGraviolaAPI(config.APIConfig{}, logger, prometheus.NewRegistry(), &dummyRegisterer{},
+		nil, nil, nil, nil, nil, nil)
+
+	start := time.Unix(1700000000, 0).UTC()
+	sut.router.Get("/fake_query", func(w http.ResponseWriter, r *http.Request) {
+		collector := querystats.FromContext(r.Context())
+		collector.RecordQuery(querystats.QueryInfo{
+			Query: "up", Start: start, End: start.Add(time.Hour), Step: time.Minute})
+		collector.RecordRemoteRequest("remote-1", time.Second, 10)
+		w.WriteHeader(http.StatusUnprocessableEntity)
+	})
+
+	sut.router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/fake_query", nil))
+
+	var accessLine map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(output.String()), "\n") {
+		var entry map[string]any
+		require.NoError(t, json.Unmarshal([]byte(line), &entry), "should log JSON")
+		if entry["component"] == "access" {
+			accessLine = entry
+		}
+	}
+
+	require.NotNil(t, accessLine, "should write the access log with the access component")
+	assert.Equal(t, "/fake_query", accessLine["route"], "should log the route")
+	assert.Equal(t, 422.0, accessLine["status"], "should log the status")
+	assert.Equal(t, "rejected", accessLine["outcome"], "should log the 4xx as rejected")
+	assert.Equal(t, "up", accessLine["query"], "should log the query")
+	assert.Equal(t, start.Format(time.RFC3339), accessLine["start"], "should log the query start")
+	assert.Equal(t, "1m0s", accessLine["step"], "should log the query step")
+	assert.Equal(t, []any{"remote-1"}, accessLine["remotes"], "should log the remotes contacted")
+}
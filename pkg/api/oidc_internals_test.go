@@ -0,0 +1,187 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/jademcosta/graviola/internal/mocks"
+	"github.com/jademcosta/graviola/pkg/auth"
+	"github.com/jademcosta/graviola/pkg/clientinfo"
+	"github.com/jademcosta/graviola/pkg/config"
+	"github.com/jademcosta/graviola/pkg/graviolalog"
+	"github.com/jademcosta/graviola/pkg/http/httpmiddleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newAPIWithOIDC(
+	t *testing.T, issuerMock *mocks.OIDCIssuerMock, conf config.OIDCConfig,
+) *GraviolaAPI {
+	t.Helper()
+	logger := graviolalog.NewLogger(config.LogConfig{Level: "error"})
+
+	conf.Enabled = true
+	conf.Issuer = "https://issuer.example.com"
+	conf.Audience = "graviola"
+	conf.JWKSURL = issuerMock.JWKSURL()
+	conf.LoginConf.ClientID = "graviola"
+	conf.LoginConf.AuthorizationURL = issuerMock.AuthorizationURL()
+	conf.LoginConf.TokenURL = issuerMock.TokenURL()
+	conf.LoginConf.RedirectURL = "http://graviola.example.com/auth/callback"
+	conf = conf.FillDefaults()
+	require.NoError(t, conf.IsValid(), "should be a valid config")
+
+	keys := auth.NewURLKeySet(logger, conf.JWKSURL, http.DefaultClient, time.Hour)
+	verifier := auth.NewTokenVerifier(keys, conf)
+
+	var login *auth.LoginFlow
+	if conf.LoginConf.Enabled {
+		var err error
+		login, err = auth.NewLoginFlow(conf.LoginConf, verifier, http.DefaultClient)
+		require.NoError(t, err, "should create the login flow")
+	}
+
+	sut := NewGraviolaAPI(config.APIConfig{}, logger, prometheus.NewRegistry(), &dummyRegisterer{}, nil, nil,
+		httpmiddleware.NewOIDCMiddleware(logger, nil, verifier, login, conf, false), nil, nil, nil)
+
+	sut.router.Get("/whoami", func(w http.ResponseWriter, r *http.Request) {
+		info, _ := clientinfo.FromContext(r.Context())
+		_, _ = w.Write([]byte(info.Principal + " " + strings.Join(info.Groups, ",")))
+	})
+
+	return sut
+}
+
+func userClaims(groups ...string) jwt.MapClaims {
+	return jwt.MapClaims{
+		"iss":    "https://issuer.example.com",
+		"aud":    "graviola",
+		"email":  "someone@example.com",
+		"groups": groups,
+		"exp":    time.Now().Add(time.Hour).Unix(),
+	}
+}
+
+func TestOIDCTokensAreRequired(t *testing.T) {
+	issuerMock := mocks.NewOIDCIssuerMock()
+	defer issuerMock.Close()
+	sut := newAPIWithOIDC(t, issuerMock, config.OIDCConfig{})
+
+	recorder := serve(sut, "/whoami", nil)
+	assert.Equal(t, http.StatusUnauthorized, recorder.Code, "should reject requests without a token")
+
+	expired := userClaims()
+	expired["exp"] = time.Now().Add(-time.Hour).Unix()
+	recorder = serve(sut, "/whoami", map[string]string{"Authorization": "Bearer " + issuerMock.Token(expired)})
+	assert.Equal(t, http.StatusUnauthorized, recorder.Code, "should reject expired tokens")
+
+	recorder = serve(sut, "/whoami",
+		map[string]string{"Authorization": "Bearer " + issuerMock.Token(userClaims("sre", "dev"))})
+	assert.Equal(t, http.StatusOK, recorder.Code, "should accept valid tokens")
+	assert.Equal(t, "someone@example.com sre,dev", recorder.Body.String(),
+		"should identify the client by the claims of the token")
+}
+
+func TestDebugRoutesAreOnlyAllowedToSomeGroups(t *testing.T) {
+	issuerMock := mocks.NewOIDCIssuerMock()
+	defer issuerMock.Close()
+	sut := newAPIWithOIDC(t, issuerMock, config.OIDCConfig{DebugGroups: []string{"sre"}})
+
+	recorder := serve(sut, "/debug/pprof/",
+		map[string]string{"Authorization": "Bearer " + issuerMock.Token(userClaims("dev"))})
+	assert.Equal(t, http.StatusForbidden, recorder.Code, "should forbid groups not allowed on /debug")
+
+	recorder = serve(sut, "/debug/pprof/",
+		map[string]string{"Authorization": "Bearer " + issuerMock.Token(userClaims("dev", "sre"))})
+	assert.Equal(t, http.StatusOK, recorder.Code, "should allow the groups listed on debug_groups")
+}
+
+func TestBrowsersCanLogInWithOIDC(t *testing.T) {
+	issuerMock := mocks.NewOIDCIssuerMock()
+	defer issuerMock.Close()
+	sut := newAPIWithOIDC(t, issuerMock, config.OIDCConfig{LoginConf: config.OIDCLoginConfig{Enabled: true}})
+
+	recorder := serve(sut, "/auth/login?return_to=/whoami", nil)
+	require.Equal(t, http.StatusFound, recorder.Code, "should send the browser to the issuer")
+	authURL, err := url.Parse(recorder.Header().Get("Location"))
+	require.NoError(t, err, "should redirect to an URL")
+	assert.True(t, strings.HasPrefix(authURL.String(), issuerMock.AuthorizationURL()),
+		"should redirect to the authorization URL")
+	state := authURL.Query().Get("state")
+	require.NotEmpty(t, state, "should send a state")
+
+	stateCookie := recorder.Result().Cookies()[0]
+
+	req := httptest.NewRequest(http.MethodGet,
+		"/auth/callback?state=wrong&code="+issuerMock.NewCode(userClaims()), nil)
+	req.AddCookie(stateCookie)
+	recorder = httptest.NewRecorder()
+	sut.router.ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusBadRequest, recorder.Code, "should reject callbacks with another state")
+
+	req = httptest.NewRequest(http.MethodGet,
+		"/auth/callback?state="+state+"&code="+issuerMock.NewCode(userClaims("sre")), nil)
+	req.AddCookie(stateCookie)
+	recorder = httptest.NewRecorder()
+	sut.router.ServeHTTP(recorder, req)
+	require.Equal(t, http.StatusFound, recorder.Code, "should finish the login")
+	assert.Equal(t, "/whoami", recorder.Header().Get("Location"), "should go back to where the login started")
+
+	var sessionCookie *http.Cookie
+	for _, cookie := range recorder.Result().Cookies() {
+		if cookie.Name == config.DefaultOIDCSessionCookieName {
+			sessionCookie = cookie
+		}
+	}
+	require.NotNil(t, sessionCookie, "should set the session cookie")
+	assert.True(t, sessionCookie.HttpOnly, "should not let scripts read the session cookie")
+
+	req = httptest.NewRequest(http.MethodGet, "/whoami", nil)
+	req.AddCookie(sessionCookie)
+	recorder = httptest.NewRecorder()
+	sut.router.ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusOK, recorder.Code, "should accept requests with the session cookie")
+	assert.Equal(t, "someone@example.com sre", recorder.Body.String(),
+		"should identify the client by the claims of the token")
+}
+
+func TestOIDCCanBeCombinedWithAPIKeys(t *testing.T) {
+	issuerMock := mocks.NewOIDCIssuerMock()
+	defer issuerMock.Close()
+	logger := graviolalog.NewLogger(config.LogConfig{Level: "error"})
+
+	oidcConf := config.OIDCConfig{
+		Enabled: true, Issuer: "https://issuer.example.com", Audience: "graviola", JWKSURL: issuerMock.JWKSURL(),
+	}.FillDefaults()
+	verifier := auth.NewTokenVerifier(
+		auth.NewURLKeySet(logger, oidcConf.JWKSURL, http.DefaultClient, time.Hour), oidcConf)
+	keys, err := auth.ParseAPIKeys([]byte("keys:\n  - name: grafana\n    sha256: " +
+		auth.HashAPIKey("grafana-secret") + "\n"))
+	require.NoError(t, err, "should parse the keys")
+
+	oidc := httpmiddleware.NewOIDCMiddleware(logger, nil, verifier, nil, oidcConf, true)
+	apiKeys := httpmiddleware.NewAPIKeyMiddleware(logger, nil, keys, config.APIKeysConfig{Enabled: true})
+	sut := NewGraviolaAPI(config.APIConfig{}, logger, prometheus.NewRegistry(), &dummyRegisterer{}, nil, nil,
+		func(next http.Handler) http.Handler { return oidc(apiKeys(next)) }, nil, nil, nil)
+	sut.router.Get("/whoami", func(w http.ResponseWriter, r *http.Request) {
+		info, _ := clientinfo.FromContext(r.Context())
+		_, _ = w.Write([]byte(info.Principal))
+	})
+
+	recorder := serve(sut, "/whoami", map[string]string{"Authorization": "Bearer " + issuerMock.Token(userClaims())})
+	assert.Equal(t, http.StatusOK, recorder.Code, "should accept OIDC tokens")
+	assert.Equal(t, "someone@example.com", recorder.Body.String(), "should identify the client by the token")
+
+	recorder = serve(sut, "/whoami", map[string]string{"Authorization": "Bearer grafana-secret"})
+	assert.Equal(t, http.StatusOK, recorder.Code, "should accept API keys")
+	assert.Equal(t, "grafana", recorder.Body.String(), "should identify the client by the key name")
+
+	recorder = serve(sut, "/whoami", nil)
+	assert.Equal(t, http.StatusUnauthorized, recorder.Code, "should reject requests without credentials")
+}
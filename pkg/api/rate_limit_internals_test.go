@@ -0,0 +1,90 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/jademcosta/graviola/pkg/config"
+	"github.com/jademcosta/graviola/pkg/graviolalog"
+	"github.com/jademcosta/graviola/pkg/http/httpmiddleware"
+	"github.com/jademcosta/graviola/pkg/ratelimit"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+func newAPIWithRateLimit(
+	conf config.RateLimitConfig, accessControl func(next http.Handler) http.Handler,
+) *GraviolaAPI {
+	logger := graviolalog.NewLogger(config.LogConfig{Level: "error"})
+	conf = conf.FillDefaults()
+	limiter := ratelimit.NewLimiter(prometheus.NewRegistry(), conf, time.Now)
+
+	sut := NewGraviolaAPI(config.APIConfig{}, logger, prometheus.NewRegistry(), &dummyRegisterer{}, nil, nil,
+		accessControl, httpmiddleware.NewRateLimitMiddleware(limiter, conf.Key), nil, nil)
+
+	ok := func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusOK) }
+	sut.router.Get("/api/v1/query", ok)
+	sut.router.Get("/api/v1/labels", ok)
+	sut.router.Get("/api/v1/label/{name}/values", ok)
+
+	return sut
+}
+
+func TestRateLimitedClientsReceive429(t *testing.T) {
+	sut := newAPIWithRateLimit(config.RateLimitConfig{
+		Enabled:      true,
+		QueryConf:    config.RateLimitBudgetConfig{RequestsPerSecond: 0.1, Burst: 1},
+		MetadataConf: config.RateLimitBudgetConfig{RequestsPerSecond: 0.5, Burst: 1},
+	}, nil)
+
+	recorder := serve(sut, "/api/v1/query", nil)
+	assert.Equal(t, http.StatusOK, recorder.Code, "should allow requests within the budget")
+
+	recorder = serve(sut, "/api/v1/query", nil)
+	assert.Equal(t, http.StatusTooManyRequests, recorder.Code, "should reject requests over the budget")
+	assert.Equal(t, "10", recorder.Header().Get("Retry-After"), "should tell when the client can try again")
+
+	recorder = serve(sut, "/api/v1/labels", nil)
+	assert.Equal(t, http.StatusOK, recorder.Code, "metadata requests should have their own budget")
+	recorder = serve(sut, "/api/v1/label/job/values", nil)
+	assert.Equal(t, http.StatusTooManyRequests, recorder.Code, "label values should use the metadata budget")
+	assert.Equal(t, "2", recorder.Header().Get("Retry-After"), "should tell when the client can try again")
+
+	recorder = serve(sut, "/metrics", nil)
+	assert.Equal(t, http.StatusOK, recorder.Code, "should not limit operational routes")
+}
+
+func TestRateLimitsCanBeByTenant(t *testing.T) {
+	sut := newAPIWithRateLimit(config.RateLimitConfig{
+		Enabled:   true,
+		Key:       config.RateLimitKeyTenant,
+		QueryConf: config.RateLimitBudgetConfig{RequestsPerSecond: 0.1, Burst: 1},
+	}, httpmiddleware.NewAPIKeyMiddleware(graviolalog.NewNoopLogger(), nil, apiKeys(t), config.APIKeysConfig{}))
+
+	teamA := map[string]string{"Authorization": "Bearer grafana-secret", "X-Scope-OrgID": "team-a"}
+	teamB := map[string]string{"Authorization": "Bearer grafana-secret", "X-Scope-OrgID": "team-b"}
+
+	recorder := serve(sut, "/api/v1/query", teamA)
+	assert.Equal(t, http.StatusOK, recorder.Code, "should allow requests within the budget")
+	recorder = serve(sut, "/api/v1/query", teamA)
+	assert.Equal(t, http.StatusTooManyRequests, recorder.Code, "should reject requests over the budget")
+
+	recorder = serve(sut, "/api/v1/query", teamB)
+	assert.Equal(t, http.StatusOK, recorder.Code, "other tenants should have their own budget")
+}
+
+func TestRateLimitsDoNotTrustTheTenantOfUnauthenticatedClients(t *testing.T) {
+	sut := newAPIWithRateLimit(config.RateLimitConfig{
+		Enabled:   true,
+		Key:       config.RateLimitKeyTenant,
+		QueryConf: config.RateLimitBudgetConfig{RequestsPerSecond: 0.1, Burst: 1},
+	}, nil)
+
+	recorder := serve(sut, "/api/v1/query", map[string]string{"X-Scope-OrgID": "team-a"})
+	assert.Equal(t, http.StatusOK, recorder.Code, "should allow requests within the budget")
+
+	recorder = serve(sut, "/api/v1/query", map[string]string{"X-Scope-OrgID": "team-b"})
+	assert.Equal(t, http.StatusTooManyRequests, recorder.Code,
+		"should not give a new budget to clients that only changed the tenant header")
+}
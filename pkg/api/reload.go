@@ -0,0 +1,17 @@
+package api
+
+import (
+	"net/http"
+)
+
+// reload loads the config file again. When it fails, the current config is kept.
+func (api *GraviolaAPI) reload(w http.ResponseWriter, _ *http.Request) {
+	err := api.reloadConfig()
+	if err != nil {
+		api.writeJSON(w, http.StatusInternalServerError,
+			apiResponse{Status: "error", ErrorType: "internal", Error: err.Error()})
+		return
+	}
+
+	api.writeJSON(w, http.StatusOK, apiResponse{Status: "success"})
+}
@@ -0,0 +1,49 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jademcosta/graviola/pkg/config"
+	"github.com/jademcosta/graviola/pkg/graviolalog"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReloadEndpointReloadsTheConfig(t *testing.T) {
+	var reloadErr error
+	reloads := 0
+	reloadConfig := func() error {
+		reloads++
+		return reloadErr
+	}
+
+	sut := NewGraviolaAPI(config.APIConfig{}, graviolalog.NewNoopLogger(), prometheus.NewRegistry(),
+		&dummyRegisterer{}, nil, nil, nil, nil, nil, reloadConfig)
+
+	recorder := httptest.NewRecorder()
+	sut.router.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/-/reload", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code, "should answer 200 when the config is reloaded")
+
+	reloadErr = errors.New("invalid config")
+	recorder = httptest.NewRecorder()
+	sut.router.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/-/reload", nil))
+	assert.Equal(t, http.StatusInternalServerError, recorder.Code, "should answer 500 when the reload fails")
+	assert.Contains(t, recorder.Body.String(), "invalid config", "should answer with the reason of the failure")
+
+	recorder = httptest.NewRecorder()
+	sut.router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/-/reload", nil))
+	assert.NotEqual(t, http.StatusOK, recorder.Code, "should only reload on POST")
+	assert.Equal(t, 2, reloads, "should reload the config on each POST")
+}
+
+func TestReloadEndpointIsOnlyServedWhenTheConfigCanBeReloaded(t *testing.T) {
+	sut := NewGraviolaAPI(config.APIConfig{}, graviolalog.NewNoopLogger(), prometheus.NewRegistry(),
+		&dummyRegisterer{}, nil, nil, nil, nil, nil, nil)
+
+	recorder := httptest.NewRecorder()
+	sut.router.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/-/reload", nil))
+	assert.NotEqual(t, http.StatusOK, recorder.Code, "should not reload without a config file")
+}
@@ -0,0 +1,97 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jademcosta/graviola/pkg/config"
+	"github.com/jademcosta/graviola/pkg/graviolalog"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newAPIWithConfig(conf config.APIConfig) *GraviolaAPI {
+	logger := graviolalog.NewLogger(config.LogConfig{Level: "error"})
+	return NewGraviolaAPI(conf.FillDefaults(), logger, prometheus.NewRegistry(), &dummyRegisterer{},
+		nil, nil, nil, nil, nil, nil)
+}
+
+func serveHandler(handler http.Handler, method string, path string, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+	return recorder
+}
+
+func TestServerUsesTheConfiguredLimits(t *testing.T) {
+	sut := newAPIWithConfig(config.APIConfig{
+		ListenAddress: "127.0.0.1",
+		ServerConf:    config.APIServerConfig{WriteTimeout: "3m", MaxHeaderBytes: 4096},
+	})
+
+	assert.Equal(t, "127.0.0.1:9197", sut.srv.Addr, "should listen on the configured address")
+	assert.Equal(t, 3*time.Minute, sut.srv.WriteTimeout, "should use the configured write timeout")
+	assert.Equal(t, 10*time.Second, sut.srv.ReadHeaderTimeout, "should use the default read header timeout")
+	assert.Equal(t, time.Minute, sut.srv.ReadTimeout, "should use the default read timeout")
+	assert.Equal(t, 2*time.Minute, sut.srv.IdleTimeout, "should use the default idle timeout")
+	assert.Equal(t, 4096, sut.srv.MaxHeaderBytes, "should use the configured max header bytes")
+}
+
+func TestRejectsBodiesBiggerThanTheLimit(t *testing.T) {
+	sut := newAPIWithConfig(config.APIConfig{ServerConf: config.APIServerConfig{MaxBodyBytes: 10}})
+	sut.router.Post("/echo", func(w http.ResponseWriter, r *http.Request) {
+		err := r.ParseForm()
+		if err != nil {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+
+	recorder := serveHandler(sut.srv.Handler, http.MethodPost, "/echo", "a=1")
+	assert.Equal(t, http.StatusOK, recorder.Code, "should accept bodies within the limit")
+
+	recorder = serveHandler(sut.srv.Handler, http.MethodPost, "/echo", "query=up&a=1234567890")
+	assert.Equal(t, http.StatusRequestEntityTooLarge, recorder.Code, "should fail to read bodies over the limit")
+}
+
+func TestRoutesCanBeServedUnderAPrefix(t *testing.T) {
+	sut := newAPIWithConfig(config.APIConfig{ExternalURL: "https://example.com/prometheus/"})
+
+	recorder := serveHandler(sut.srv.Handler, http.MethodGet, "/prometheus/healthy", "")
+	assert.Equal(t, http.StatusOK, recorder.Code, "should serve the routes under the prefix of the external URL")
+
+	recorder = serveHandler(sut.srv.Handler, http.MethodGet, "/healthy", "")
+	assert.Equal(t, http.StatusNotFound, recorder.Code, "should not serve the routes out of the prefix")
+
+	sut = newAPIWithConfig(config.APIConfig{ExternalURL: "https://example.com/prometheus/", RoutePrefix: "/"})
+	recorder = serveHandler(sut.srv.Handler, http.MethodGet, "/healthy", "")
+	assert.Equal(t, http.StatusOK, recorder.Code, "should prefer the route prefix over the external URL")
+}
+
+func TestAdminRoutesCanHaveAListenerOfTheirOwn(t *testing.T) {
+	sut := newAPIWithConfig(config.APIConfig{})
+	assert.Nil(t, sut.adminSrv, "should not have an admin listener by default")
+	recorder := serveHandler(sut.srv.Handler, http.MethodGet, "/metrics", "")
+	assert.Equal(t, http.StatusOK, recorder.Code, "should serve /metrics on the API by default")
+
+	sut = newAPIWithConfig(config.APIConfig{AdminConf: config.APIAdminConfig{Enabled: true, ListenAddress: "127.0.0.1"}})
+	require.NotNil(t, sut.adminSrv, "should have an admin listener")
+	assert.Equal(t, "127.0.0.1:9198", sut.adminSrv.Addr, "should listen on the admin address")
+
+	for _, path := range []string{"/metrics", "/debug/pprof/"} {
+		recorder = serveHandler(sut.srv.Handler, http.MethodGet, path, "")
+		assert.Equal(t, http.StatusNotFound, recorder.Code, "should not serve %s on the API", path)
+
+		recorder = serveHandler(sut.adminSrv.Handler, http.MethodGet, path, "")
+		assert.Equal(t, http.StatusOK, recorder.Code, "should serve %s on the admin listener", path)
+	}
+
+	recorder = serveHandler(sut.srv.Handler, http.MethodGet, "/healthy", "")
+	assert.Equal(t, http.StatusOK, recorder.Code, "should keep serving /healthy on the API")
+}
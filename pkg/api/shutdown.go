@@ -0,0 +1,91 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// shutdownGracePeriod is how long the requests have to be answered after their queries are
+// cancelled by the end of the drain timeout
+const shutdownGracePeriod = 5 * time.Second
+
+// Stop shuts the API down gracefully. /ready starts failing and, after the pre-stop delay, new
+// queries stop being accepted. The running queries have until the drain timeout to finish,
+// before being cancelled.
+func (api *GraviolaAPI) Stop() {
+	api.stopping.Store(true)
+	preStopDelay := api.conf.ServerConf.PreStopDelayDuration()
+	api.logger.Info("stopping, /ready is now failing", "pre_stop_delay", preStopDelay)
+	time.Sleep(preStopDelay)
+
+	drainTimeout := api.conf.ServerConf.DrainTimeoutDuration()
+	ctx, cancelFn := context.WithTimeout(context.Background(), drainTimeout+shutdownGracePeriod)
+	defer cancelFn()
+
+	// Shutdown stops listening right away, and then waits for the requests being served
+	api.draining.Store(true)
+	shutdownErr := make(chan error, 1)
+	go func() {
+		shutdownErr <- api.srv.Shutdown(ctx)
+	}()
+
+	api.drainQueries(drainTimeout)
+
+	err := <-shutdownErr
+	if err != nil {
+		api.logger.Error("error when stopping", "error", err)
+	}
+
+	if api.adminSrv != nil {
+		err = api.adminSrv.Shutdown(ctx)
+		if err != nil {
+			api.logger.Error("error when stopping the admin listener", "error", err)
+		}
+	}
+
+	api.logger.Info("stopped")
+}
+
+// drainQueries waits for the running queries to finish, cancelling the ones still running when
+// the timeout is reached
+func (api *GraviolaAPI) drainQueries(timeout time.Duration) {
+	if api.activeQueries == nil {
+		return
+	}
+
+	api.activeQueries.StopAccepting()
+	api.logger.Info("waiting for the running queries to finish", "running", len(api.activeQueries.List()),
+		"drain_timeout", timeout)
+
+	ctx, cancelFn := context.WithTimeout(context.Background(), timeout)
+	defer cancelFn()
+
+	err := api.activeQueries.Drain(ctx)
+	if err != nil {
+		cancelled := api.activeQueries.CancelAll()
+		api.logger.Warn("cancelled the queries still running after the drain timeout", "cancelled", cancelled)
+	}
+}
+
+func (api *GraviolaAPI) readyHandler(w http.ResponseWriter, _ *http.Request) {
+	if api.stopping.Load() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// rejectWhenDraining answers 503 to the requests arriving after new queries stop being accepted,
+// so clients know they can retry them on another instance
+func (api *GraviolaAPI) rejectWhenDraining(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if api.draining.Load() {
+			w.Header().Set("Connection", "close")
+			api.writeJSON(w, http.StatusServiceUnavailable,
+				apiResponse{Status: "error", ErrorType: "unavailable", Error: "graviola is shutting down"})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
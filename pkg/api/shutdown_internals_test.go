@@ -0,0 +1,86 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jademcosta/graviola/pkg/config"
+	"github.com/jademcosta/graviola/pkg/graviolalog"
+	"github.com/jademcosta/graviola/pkg/querytracker"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newStoppableAPI(serverConf config.APIServerConfig) (*GraviolaAPI, *querytracker.GraviolaQueryTracker) {
+	logger := graviolalog.NewNoopLogger()
+	tracker := querytracker.NewGraviolaQueryTracker(logger, 5, "")
+	sut := NewGraviolaAPI(config.APIConfig{ServerConf: serverConf}, logger, prometheus.NewRegistry(),
+		&dummyRegisterer{}, tracker, nil, nil, nil, nil, nil)
+	return sut, tracker
+}
+
+func statusOf(sut *GraviolaAPI, path string) int {
+	recorder := httptest.NewRecorder()
+	sut.router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+	return recorder.Code
+}
+
+func TestStopFailsReadinessAndWaitsForTheRunningQueries(t *testing.T) {
+	sut, tracker := newStoppableAPI(config.APIServerConfig{PreStopDelay: "200ms", DrainTimeout: "10s"})
+	require.Equal(t, http.StatusOK, statusOf(sut, "/ready"), "should be ready before stopping")
+
+	queryCtx, cancelFn := querytracker.WithCancel(context.Background())
+	defer cancelFn()
+	id, err := tracker.Insert(queryCtx, "up")
+	require.NoError(t, err, "should insert the query")
+
+	stopped := make(chan struct{})
+	go func() {
+		sut.Stop()
+		close(stopped)
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, http.StatusServiceUnavailable, statusOf(sut, "/ready"), "should fail readiness while stopping")
+	assert.Equal(t, http.StatusNotFound, statusOf(sut, "/api/v1/query"),
+		"should still accept queries during the pre-stop delay")
+
+	time.Sleep(200 * time.Millisecond)
+	assert.Equal(t, http.StatusServiceUnavailable, statusOf(sut, "/api/v1/query"),
+		"should reject new queries after the pre-stop delay")
+	_, err = tracker.Insert(context.Background(), "up")
+	assert.ErrorIs(t, err, querytracker.ErrShuttingDown, "should stop accepting queries on the tracker")
+
+	select {
+	case <-stopped:
+		assert.Fail(t, "should wait for the running query to finish")
+	default:
+	}
+
+	tracker.Delete(id)
+	select {
+	case <-stopped:
+	case <-time.After(2 * time.Second):
+		assert.Fail(t, "should stop when the running query finishes")
+	}
+	assert.NoError(t, queryCtx.Err(), "should not cancel the query that finished in time")
+}
+
+func TestStopCancelsTheQueriesStillRunningAfterTheDrainTimeout(t *testing.T) {
+	sut, tracker := newStoppableAPI(config.APIServerConfig{DrainTimeout: "100ms"})
+
+	queryCtx, cancelFn := querytracker.WithCancel(context.Background())
+	defer cancelFn()
+	_, err := tracker.Insert(queryCtx, "up")
+	require.NoError(t, err, "should insert the query")
+
+	start := time.Now()
+	sut.Stop()
+
+	assert.ErrorIs(t, queryCtx.Err(), context.Canceled, "should cancel the query still running")
+	assert.Less(t, time.Since(start), 2*time.Second, "should not wait more than the drain timeout for the queries")
+}
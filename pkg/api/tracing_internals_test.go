@@ -0,0 +1,49 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/jademcosta/graviola/internal/mocks"
+	"github.com/jademcosta/graviola/pkg/config"
+	"github.com/jademcosta/graviola/pkg/http/httpmiddleware"
+	"github.com/jademcosta/graviola/pkg/tracing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestsContinueTheTraceOfTheCaller(t *testing.T) {
+	recorder, restore := mocks.RecordSpans()
+	defer restore()
+
+	sut := newAPIWithConfig(config.APIConfig{})
+
+	traceID := "4bf92f3577b34da6a3ce929d0e0e4736"
+	response := serve(sut, "/healthy",
+		map[string]string{"traceparent": "00-" + traceID + "-00f067aa0ba902b7-01"})
+	assert.Equal(t, http.StatusOK, response.Code, "should answer the request")
+	assert.Equal(t, traceID, response.Header().Get(tracing.TraceIDHeader), "should answer with the trace ID")
+
+	span := mocks.SpanNamed(recorder, "GET /healthy")
+	require.NotNil(t, span, "should record a span of the request")
+	assert.Equal(t, traceID, span.SpanContext().TraceID().String(), "should be part of the trace of the caller")
+	assert.Equal(t, "00f067aa0ba902b7", span.Parent().SpanID().String(), "should be a child of the span of the caller")
+
+	response = serve(sut, "/healthy", nil)
+	assert.Len(t, response.Header().Get(tracing.TraceIDHeader), 32, "should start a trace when the caller has none")
+}
+
+func TestRequestSpansAreNamedAfterTheRoute(t *testing.T) {
+	recorder, restore := mocks.RecordSpans()
+	defer restore()
+
+	sut := newAPIWithConfig(config.APIConfig{})
+	sut.router.Get("/things/{id}", func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusOK) })
+
+	serve(sut, "/things/42", nil)
+	assert.NotNil(t, mocks.SpanNamed(recorder, "GET /things/{id}"), "should name the span after the route template")
+
+	serve(sut, "/not/a/route", nil)
+	assert.NotNil(t, mocks.SpanNamed(recorder, "GET "+httpmiddleware.UnmatchedRoute),
+		"should not name the span after the path of unmatched requests")
+}
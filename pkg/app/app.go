@@ -2,51 +2,115 @@ package app
 
 import (
 	"context"
+	"fmt"
 	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
+	"reflect"
 	"regexp"
 	"syscall"
 	"time"
 
 	grafanaregexp "github.com/grafana/regexp"
+	"github.com/jademcosta/graviola/pkg/admission"
 	"github.com/jademcosta/graviola/pkg/api"
+	"github.com/jademcosta/graviola/pkg/auth"
+	"github.com/jademcosta/graviola/pkg/authz"
 	"github.com/jademcosta/graviola/pkg/config"
+	"github.com/jademcosta/graviola/pkg/configreload"
 	"github.com/jademcosta/graviola/pkg/graviolalog"
+	"github.com/jademcosta/graviola/pkg/guardrails"
+	"github.com/jademcosta/graviola/pkg/http/httpmiddleware"
 	"github.com/jademcosta/graviola/pkg/o11y"
 	"github.com/jademcosta/graviola/pkg/queryengine"
+	"github.com/jademcosta/graviola/pkg/querylog"
+	"github.com/jademcosta/graviola/pkg/querystats"
+	"github.com/jademcosta/graviola/pkg/ratelimit"
 	"github.com/jademcosta/graviola/pkg/remotestorage"
 	"github.com/jademcosta/graviola/pkg/remotestoragegroup"
+	"github.com/jademcosta/graviola/pkg/resultscache"
 	"github.com/jademcosta/graviola/pkg/storageproxy"
+	"github.com/jademcosta/graviola/pkg/tracing"
 	"github.com/oklog/run"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/collectors"
 	"github.com/prometheus/common/version"
+	"github.com/prometheus/prometheus/promql"
 	"github.com/prometheus/prometheus/storage"
 	"github.com/prometheus/prometheus/util/notifications"
 	"github.com/prometheus/prometheus/web"
 	api_v1 "github.com/prometheus/prometheus/web/api/v1"
 )
 
+// authHTTPClientTimeout is the timeout of the requests sent to the OIDC issuer
+const authHTTPClientTimeout = 10 * time.Second
+
+// tracingShutdownTimeout is how long the pending spans have to be exported when stopping
+const tracingShutdownTimeout = 5 * time.Second
+
 type App struct {
-	api       *api.GraviolaAPI
-	logger    *slog.Logger
-	metricz   *prometheus.Registry
-	conf      config.GraviolaConfig // TODO: this is needed due to the api server configs
-	cancelCtx context.CancelFunc
+	api         *api.GraviolaAPI
+	logger      *slog.Logger
+	logging     *graviolalog.Logging
+	metricz     *prometheus.Registry
+	storage     *storageproxy.GraviolaStorage
+	reloader    *configreload.Reloader
+	conf        config.GraviolaConfig // TODO: this is needed due to the api server configs
+	cancelCtx   context.CancelFunc
+	stopTracing func(context.Context) error
 }
 
-func NewApp(conf config.GraviolaConfig) *App {
-	logger := graviolalog.NewLogger(conf.LogConf)
+// NewApp creates Graviola with the config given. When the path of the config file is given,
+// the config can be reloaded while Graviola runs.
+func NewApp(conf config.GraviolaConfig, configPath string) *App {
+	logging, err := graviolalog.NewLogging(conf.LogConf)
+	if err != nil {
+		panic(fmt.Errorf("error creating the logger: %w", err))
+	}
+	logger := logging.Logger
 	metricRegistry := prometheus.NewRegistry()
 
-	eng := queryengine.NewGraviolaQueryEngine(logger, metricRegistry, conf)
+	stopTracing, err := tracing.Setup(conf.TracingConf)
+	if err != nil {
+		panic(fmt.Errorf("error setting up tracing: %w", err))
+	}
+
+	graviolaEngine := queryengine.NewGraviolaQueryEngine(logger, metricRegistry, conf, time.Now)
+	if conf.QueryLogConf.Path != "" {
+		queryLogger, err := querylog.NewLogger(conf.QueryLogConf)
+		if err != nil {
+			panic(fmt.Errorf("error creating the query log: %w", err))
+		}
+		graviolaEngine.SetQueryLogger(queryLogger)
+	}
+	var eng promql.QueryEngine = graviolaEngine
+	if conf.CacheConf.Enabled {
+		eng = resultscache.NewCachingEngine(
+			logger,
+			metricRegistry,
+			eng,
+			resultscache.NewInMemoryLRUBackend(metricRegistry, conf.CacheConf.MaxSizeBytes),
+			conf.CacheConf.MaxFreshnessDuration(),
+			time.Now,
+		)
+	}
+	eng = guardrails.NewEngine(logger, metricRegistry, eng, conf.QueryConf.GuardrailsConf)
+
+	var policies *authz.Policies
+	if conf.AuthzConf.Enabled {
+		var err error
+		policies, err = authz.NewPolicies(conf.AuthzConf)
+		if err != nil {
+			panic(fmt.Errorf("error creating the authorization policies: %w", err))
+		}
+	}
 
 	storageGroups := initializeRemoteGroups(
 		logger, metricRegistry, conf.StoragesConf.Groups, conf.QueryConf.TimeoutDuration())
-	mainMergeStrategy := remotestoragegroup.MergeStrategyFactory(conf.StoragesConf.MergeConf.Strategy)
-	graviolaStorage := storageproxy.NewGraviolaStorage(logger, storageGroups, mainMergeStrategy)
+	mainMergeStrategy := remotestoragegroup.MergeStrategyFactory(conf.StoragesConf.MergeConf, metricRegistry)
+	graviolaStorage := storageproxy.NewGraviolaStorage(
+		logger, storageGroups, mainMergeStrategy, conf.QueryConf.LimitsConf.MaxLabelValues, policies)
 
 	apiV1 := createPrometheusAPI(eng, graviolaStorage, logger, metricRegistry, conf)
 
@@ -58,14 +122,46 @@ func NewApp(conf config.GraviolaConfig) *App {
 		),
 	)
 
-	graviolaAPI := api.NewGraviolaAPI(conf.APIConf, logger, metricRegistry, apiV1)
+	var queryAdmission func(next http.Handler) http.Handler
+	if conf.QueryConf.AdmissionConf.Enabled {
+		queryAdmission = httpmiddleware.NewAdmissionMiddleware(
+			admission.NewController(logger, metricRegistry, conf.QueryConf.AdmissionConf),
+			conf.QueryConf.AdmissionConf.PriorityHeader,
+		)
+	}
+
+	accessControl := createAccessControl(logger, metricRegistry, conf.APIConf.AuthConf, policies)
+
+	var rateLimit func(next http.Handler) http.Handler
+	if conf.APIConf.RateLimitConf.Enabled {
+		rateLimit = httpmiddleware.NewRateLimitMiddleware(
+			ratelimit.NewLimiter(metricRegistry, conf.APIConf.RateLimitConf, time.Now),
+			conf.APIConf.RateLimitConf.Key,
+		)
+	}
+
+	app := &App{
+		logger:      logger,
+		logging:     logging,
+		metricz:     metricRegistry,
+		storage:     graviolaStorage,
+		conf:        conf,
+		stopTracing: stopTracing,
+	}
 
-	return &App{
-		api:     graviolaAPI,
-		logger:  logger,
-		metricz: metricRegistry,
-		conf:    conf,
+	var reloadConfig func() error
+	if configPath != "" {
+		app.reloader = configreload.NewReloader(logger, metricRegistry, configPath, app.applyConfig, time.Now)
+		if conf.ReloadConf.EnableEndpoint {
+			reloadConfig = app.reloader.Reload
+		}
 	}
+
+	app.api = api.NewGraviolaAPI(
+		conf.APIConf, logger, metricRegistry, apiV1, graviolaEngine.QueryTracker(), queryAdmission, accessControl,
+		rateLimit, logging.Levels, reloadConfig)
+
+	return app
 }
 
 func (app *App) Start() {
@@ -74,6 +170,8 @@ func (app *App) Start() {
 
 	g := run.Group{}
 
+	// On a signal, the API stops gracefully first, draining the running queries, and only then
+	// the other actors are interrupted
 	g.Add(func() error {
 		return app.api.Start()
 	}, func(_ error) {
@@ -95,11 +193,56 @@ func (app *App) Start() {
 		cancelCtx()
 	})
 
+	if app.reloader != nil {
+		g.Add(func() error {
+			reloadCh := make(chan os.Signal, 1)
+			signal.Notify(reloadCh, syscall.SIGHUP)
+			defer signal.Stop(reloadCh)
+
+			for {
+				select {
+				case <-reloadCh:
+					_ = app.reloader.Reload()
+				case <-appCtx.Done():
+					return nil
+				}
+			}
+		}, func(_ error) {
+			cancelCtx()
+		})
+
+		if reloadConf := app.conf.ReloadConf; reloadConf.WatchFile {
+			g.Add(func() error {
+				app.reloader.Watch(appCtx, reloadConf.WatchIntervalDuration())
+				return nil
+			}, func(_ error) {
+				cancelCtx()
+			})
+		}
+	}
+
 	app.logger.Info("starting Graviola")
 	err := g.Run()
 	if err != nil {
 		app.logger.Error("error after start", "error", err)
 	}
+
+	err = app.storage.Close()
+	if err != nil {
+		app.logger.Error("error closing the remotes", "error", err)
+	}
+
+	tracingCtx, cancelTracingCtx := context.WithTimeout(context.Background(), tracingShutdownTimeout)
+	defer cancelTracingCtx()
+	err = app.stopTracing(tracingCtx)
+	if err != nil {
+		app.logger.Error("error stopping tracing", "error", err)
+	}
+
+	err = app.logging.Close()
+	if err != nil {
+		app.logger.Error("error closing the log output", "error", err)
+	}
 }
 
 func (app *App) Stop() {
@@ -111,6 +254,36 @@ func (app *App) Stop() {
 	}
 }
 
+// applyConfig replaces the groups and remotes by the ones of the new config. The queries already
+// running finish on the old ones. The other sections of the config are only applied on a restart.
+func (app *App) applyConfig(newConf config.GraviolaConfig) error {
+	running := app.conf.FillDefaults()
+	running.StoragesConf = newConf.StoragesConf
+
+	// The new storages have to be valid along with the sections that are kept, like the
+	// authorization policies
+	err := running.IsValid()
+	if err != nil {
+		return err
+	}
+
+	if !reflect.DeepEqual(running, newConf) {
+		app.logger.Warn("only the storages are reloaded, the other changes of the config need a restart")
+	}
+
+	groups := initializeRemoteGroups(
+		app.logger, app.metricz, newConf.StoragesConf.Groups, app.conf.QueryConf.TimeoutDuration())
+	mergeStrategy := remotestoragegroup.MergeStrategyFactory(newConf.StoragesConf.MergeConf, app.metricz)
+
+	err = app.storage.ReplaceGroups(groups, mergeStrategy)
+	if err != nil {
+		app.logger.Error("error closing the replaced remotes", "error", err)
+	}
+	app.conf.StoragesConf = newConf.StoragesConf
+
+	return nil
+}
+
 func alwaysReadyHandler(f http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		f(w, r)
@@ -124,17 +297,23 @@ func initializeRemoteGroups(
 	groups := make([]storage.Querier, 0, len(groupsConf))
 
 	for _, groupConf := range groupsConf {
-		failureStrategy := remotestoragegroup.QueryFailureStrategyFactory(groupConf.OnQueryFailStrategy)
-		//FIXME: allow to configure this
-		mergeStrategy := remotestoragegroup.MergeStrategyFactory(config.MergeStrategyAlwaysMerge)
+		failureStrategy := remotestoragegroup.QueryFailureStrategyFactory(groupConf)
+		mergeStrategy := remotestoragegroup.MergeStrategyFactory(groupConf.MergeConf.FillDefaults(), metricz)
 
-		group := remotestoragegroup.NewRemoteGroup(
+		reader := remotestoragegroup.GroupReaderFactory(
 			logger,
-			groupConf.Name,
+			metricz,
+			groupConf,
 			initializeRemotes(logger, metricz, groupConf.Servers, defaultQueryTimeout),
-			failureStrategy,
 			mergeStrategy,
 		)
+
+		group := remotestoragegroup.NewRemoteGroupWithReader(
+			logger,
+			groupConf.Name,
+			reader,
+			failureStrategy,
+		)
 		groups = append(groups, o11y.NewQuerierO11y(metricz, groupConf.Name, "group", group))
 	}
 
@@ -155,13 +334,77 @@ func initializeRemotes(
 	return remotes
 }
 
+// createAccessControl returns the middleware that authenticates the API requests and finds their
+// authorization policy, or nil when neither is enabled. When both OIDC and API keys are enabled,
+// requests without an OIDC token are checked for an API key.
+func createAccessControl(
+	logger *slog.Logger, metricRegistry *prometheus.Registry, authConf config.APIAuthConfig,
+	policies *authz.Policies,
+) func(next http.Handler) http.Handler {
+	middlewares := make([]func(next http.Handler) http.Handler, 0)
+
+	if authConf.OIDCConf.Enabled {
+		httpClient := &http.Client{Timeout: authHTTPClientTimeout}
+
+		var keys *auth.KeySet
+		if authConf.OIDCConf.JWKSFile != "" {
+			var err error
+			keys, err = auth.NewFileKeySet(authConf.OIDCConf.JWKSFile)
+			if err != nil {
+				panic(fmt.Errorf("error loading the oidc jwks: %w", err))
+			}
+		} else {
+			keys = auth.NewURLKeySet(
+				logger, authConf.OIDCConf.JWKSURL, httpClient, authConf.OIDCConf.JWKSRefreshIntervalDuration())
+		}
+		verifier := auth.NewTokenVerifier(keys, authConf.OIDCConf)
+
+		var login *auth.LoginFlow
+		if authConf.OIDCConf.LoginConf.Enabled {
+			var err error
+			login, err = auth.NewLoginFlow(authConf.OIDCConf.LoginConf, verifier, httpClient)
+			if err != nil {
+				panic(fmt.Errorf("error creating the oidc login: %w", err))
+			}
+		}
+
+		middlewares = append(middlewares, httpmiddleware.NewOIDCMiddleware(
+			logger, metricRegistry, verifier, login, authConf.OIDCConf, authConf.APIKeysConf.Enabled))
+	}
+
+	if authConf.APIKeysConf.Enabled {
+		apiKeys, err := auth.LoadAPIKeys(authConf.APIKeysConf.KeysFile)
+		if err != nil {
+			panic(fmt.Errorf("error loading the api keys: %w", err))
+		}
+		middlewares = append(middlewares,
+			httpmiddleware.NewAPIKeyMiddleware(logger, metricRegistry, apiKeys, authConf.APIKeysConf))
+	}
+
+	if policies != nil {
+		middlewares = append(middlewares, httpmiddleware.NewAuthorizationMiddleware(policies))
+	}
+
+	if len(middlewares) == 0 {
+		return nil
+	}
+
+	return func(next http.Handler) http.Handler {
+		for i := len(middlewares) - 1; i >= 0; i-- {
+			next = middlewares[i](next)
+		}
+		return next
+	}
+}
+
 func createPrometheusAPI(
-	queryEngine *queryengine.GraviolaQueryEngine,
+	queryEngine promql.QueryEngine,
 	graviolaStorage *storageproxy.GraviolaStorage,
 	logger *slog.Logger,
 	metricRegistry *prometheus.Registry,
 	conf config.GraviolaConfig,
 ) *api_v1.API {
+	corsOrigin := grafanaregexp.MustCompile("^(?:" + conf.APIConf.CORSOrigin + ")$")
 
 	//TODO: avoid all nils in the functions below. To avoid `panic`s
 	return api_v1.NewAPI(
@@ -180,13 +423,13 @@ func createPrometheusAPI(
 		"",                         // dbDir string
 		false,                      // enableAdmin bool
 		logger,
-		nil,                             // func(context.Context) RulesRetriever
-		100,                             //TODO: allow config (remoteReadSampleLimit)
-		10,                              //TODO: allow config (remoteReadConcurrencyLimit)
-		1024,                            //TODO: allow config (remoteReadMaxBytesInFrame) (currently 1KB)
-		false,                           // isAgent bool - If this is set to true the query endpoints will not work
-		grafanaregexp.MustCompile(".*"), // corsOrigin *regexp.Regexp,
-		nil,                             // runtimeInfo func() (RuntimeInfo, error)
+		nil,        // func(context.Context) RulesRetriever
+		100,        //TODO: allow config (remoteReadSampleLimit)
+		10,         //TODO: allow config (remoteReadConcurrencyLimit)
+		1024,       //TODO: allow config (remoteReadMaxBytesInFrame) (currently 1KB)
+		false,      // isAgent bool - If this is set to true the query endpoints will not work
+		corsOrigin, // corsOrigin *regexp.Regexp,
+		nil,        // runtimeInfo func() (RuntimeInfo, error)
 		&web.PrometheusVersion{
 			Version:   version.Version,
 			Revision:  version.Revision,
@@ -206,7 +449,7 @@ func createPrometheusAPI(
 
 		metricRegistry,                         // gatherer prometheus.Gatherer
 		metricRegistry,                         // registerer prometheus.Registerer
-		nil,                                    // statsRenderer StatsRenderer
+		querystats.Renderer,                    // statsRenderer StatsRenderer
 		false,                                  //remoteWriteEnabled
 		nil,                                    // acceptRemoteWriteProtoMsgs []config.RemoteWriteProtoMsg,
 		false,                                  //otlpEnabled
@@ -6,7 +6,10 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 
@@ -35,7 +38,7 @@ func TestOperationalRoutes(t *testing.T) {
 
 	conf.StoragesConf.Groups[0].Servers[0].Address = mock1Srv.URL
 
-	app := app.NewApp(conf)
+	app := app.NewApp(conf, "")
 	go func() {
 		app.Start()
 	}()
@@ -80,7 +83,7 @@ func TestIntegrationAnswers422OnRemoteError(t *testing.T) {
 
 	conf.StoragesConf.Groups[0].Servers[0].Address = mock1Srv.URL
 
-	app := app.NewApp(conf)
+	app := app.NewApp(conf, "")
 	go func() {
 		app.Start()
 	}()
@@ -148,7 +151,7 @@ func TestIntegrationQueryEngineUsesTheLookbackDelta(t *testing.T) {
 
 	conf.StoragesConf.Groups[0].Servers[0].Address = mockRemote1Srv.URL
 
-	app := app.NewApp(conf)
+	app := app.NewApp(conf, "")
 	go func() {
 		app.Start()
 	}()
@@ -199,7 +202,7 @@ func TestIntegrationSingleRemoteSuccess(t *testing.T) {
 
 	conf.StoragesConf.Groups[0].Servers[0].Address = mockRemote1Srv.URL
 
-	app := app.NewApp(conf)
+	app := app.NewApp(conf, "")
 	go func() {
 		app.Start()
 	}()
@@ -277,6 +280,86 @@ func TestIntegrationSingleRemoteSuccess(t *testing.T) {
 	}
 }
 
+func TestIntegrationAnswersStatsWithTheRemotesContacted(t *testing.T) {
+	conf := config.GraviolaConfig{}
+	err := yaml.Unmarshal([]byte(configOneGroupWithOneRemote), &conf)
+	panicOnError(err)
+
+	currentTime := time.Now()
+	mockRemote1 := NewMockRemote(map[string]mockRemoteRoute{
+		"/api/v1/query_range": {
+			status:     200,
+			resultType: "matrix",
+			series: &domain.GraviolaSeriesSet{
+				Series: []*domain.GraviolaSeries{
+					{
+						Lbs:        labels.FromStrings("lbl111", "value111", "__name__", "my-metric"),
+						Datapoints: []model.SamplePair{{Timestamp: model.Time(currentTime.Add(-time.Second).UnixMilli()), Value: 312.0}},
+					},
+				},
+			},
+		},
+	})
+
+	mockRemote1Srv := httptest.NewServer(mockRemote1.mux)
+	defer mockRemote1Srv.Close()
+
+	conf.StoragesConf.Groups[0].Servers[0].Address = mockRemote1Srv.URL
+
+	app := app.NewApp(conf, "")
+	go func() {
+		app.Start()
+	}()
+
+	defer app.Stop()
+
+	time.Sleep(200 * time.Millisecond)
+
+	resp := doRequest("http://localhost:8091/api/v1/query_range?stats=all",
+		storage.SelectHints{Start: currentTime.Unix(), End: currentTime.Unix(), Step: 30},
+		labels.MustNewMatcher(labels.MatchEqual, "lbl111", "value111"))
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode, "HTTP status should be 200")
+
+	body, err := io.ReadAll(resp.Body)
+	panicOnError(err)
+
+	var respJSON struct {
+		Data struct {
+			Stats struct {
+				Timings  map[string]interface{} `json:"timings"`
+				Graviola struct {
+					Remotes []struct {
+						Name     string `json:"name"`
+						Requests int    `json:"requests"`
+						Series   int    `json:"series"`
+						Samples  int    `json:"samples"`
+					} `json:"remotes"`
+					Groups []struct {
+						Name string `json:"name"`
+					} `json:"groups"`
+				} `json:"graviola"`
+			} `json:"stats"`
+		} `json:"data"`
+	}
+	err = json.Unmarshal(body, &respJSON)
+	panicOnError(err)
+
+	stats := respJSON.Data.Stats
+	assert.NotEmpty(t, stats.Timings, "should answer with the engine timings")
+	require.Len(t, stats.Graviola.Remotes, 1, "should answer with the remotes contacted")
+	assert.Equal(t, "the server 1", stats.Graviola.Remotes[0].Name, "should answer with the remote name")
+	assert.Equal(t, 1, stats.Graviola.Remotes[0].Requests, "should answer with the requests sent to the remote")
+	assert.Equal(t, 1, stats.Graviola.Remotes[0].Series, "should answer with the series returned by the remote")
+	assert.Equal(t, 1, stats.Graviola.Remotes[0].Samples, "should answer with the samples returned by the remote")
+
+	groups := make([]string, 0)
+	for _, group := range stats.Graviola.Groups {
+		groups = append(groups, group.Name)
+	}
+	assert.Contains(t, groups, "the solo group", "should answer with the groups contacted")
+}
+
 func mustParseInt64(i string) int64 {
 	ret, err := strconv.ParseInt(i, 10, 64)
 	if err != nil {
@@ -284,3 +367,61 @@ func mustParseInt64(i string) int64 {
 	}
 	return ret
 }
+
+func TestIntegrationReloadsTheRemotesFromTheConfigFile(t *testing.T) {
+	emptyMatrix := mockRemoteRoute{status: 200, resultType: "matrix", series: &domain.GraviolaSeriesSet{}}
+	mockRemote1 := NewMockRemote(map[string]mockRemoteRoute{"/api/v1/query_range": emptyMatrix})
+	mockRemote1Srv := httptest.NewServer(mockRemote1.mux)
+	defer mockRemote1Srv.Close()
+	mockRemote2 := NewMockRemote(map[string]mockRemoteRoute{"/api/v1/query_range": emptyMatrix})
+	mockRemote2Srv := httptest.NewServer(mockRemote2.mux)
+	defer mockRemote2Srv.Close()
+
+	configPath := filepath.Join(t.TempDir(), "config.yml")
+	writeConfigWithRemote := func(address string) {
+		content := strings.Replace(configOneGroupWithOneRemote, "http://localhost:9090", address, 1) +
+			"\nconfig_reload:\n  enable_endpoint: true\n"
+		require.NoError(t, os.WriteFile(configPath, []byte(content), 0o600), "should write the config file")
+	}
+	writeConfigWithRemote(mockRemote1Srv.URL)
+
+	confContent, err := os.ReadFile(configPath)
+	require.NoError(t, err, "should read the config file")
+	conf, err := config.Parse(confContent)
+	require.NoError(t, err, "should parse the config file")
+
+	app := app.NewApp(conf.FillDefaults(), configPath)
+	go func() {
+		app.Start()
+	}()
+	defer app.Stop()
+	time.Sleep(200 * time.Millisecond)
+
+	query := func() {
+		now := time.Now().Unix()
+		resp := doRequest("http://localhost:8091/api/v1/query_range", storage.SelectHints{Start: now, End: now, Step: 30},
+			labels.MustNewMatcher(labels.MatchEqual, "lbl", "value"))
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode, "should answer the query")
+	}
+	reload := func() int {
+		resp, err := http.Post("http://localhost:8091/-/reload", "", nil)
+		require.NoError(t, err, "request should return no error")
+		defer resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	query()
+	require.Len(t, mockRemote1.calledWith, 1, "should query the remote of the config")
+
+	writeConfigWithRemote(mockRemote2Srv.URL)
+	require.Equal(t, http.StatusOK, reload(), "should reload the config")
+	query()
+	assert.Len(t, mockRemote1.calledWith, 1, "should not query the remote removed from the config")
+	assert.Len(t, mockRemote2.calledWith, 1, "should query the remote added to the config")
+
+	require.NoError(t, os.WriteFile(configPath, []byte("storages: [broken"), 0o600), "should write the config file")
+	assert.Equal(t, http.StatusInternalServerError, reload(), "should fail to reload a broken config")
+	query()
+	assert.Len(t, mockRemote2.calledWith, 2, "should keep the config when the reload fails")
+}
@@ -0,0 +1,99 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+type apiKeysFile struct {
+	Keys []apiKeyEntry `yaml:"keys"`
+}
+
+// apiKeyEntry is a key on the keys file. Only the SHA-256 of the key is stored, hex encoded.
+type apiKeyEntry struct {
+	Name   string `yaml:"name"`
+	SHA256 string `yaml:"sha256"`
+}
+
+type apiKey struct {
+	name string
+	hash []byte
+}
+
+// APIKeys are the keys allowed to call the API. Each key has a name, used to identify the client
+// on logs and metrics.
+type APIKeys struct {
+	keys []apiKey
+}
+
+// LoadAPIKeys reads the keys from a YAML file, like:
+//
+//	keys:
+//	  - name: grafana
+//	    sha256: <hex encoded SHA-256 of the key>
+func LoadAPIKeys(path string) (*APIKeys, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the api keys file: %w", err)
+	}
+
+	return ParseAPIKeys(data)
+}
+
+func ParseAPIKeys(data []byte) (*APIKeys, error) {
+	file := apiKeysFile{}
+	err := yaml.Unmarshal(data, &file)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse the api keys file: %w", err)
+	}
+
+	names := make(map[string]struct{}, len(file.Keys))
+	keys := make([]apiKey, 0, len(file.Keys))
+	for _, entry := range file.Keys {
+		if entry.Name == "" {
+			return nil, fmt.Errorf("api keys cannot have an empty name")
+		}
+
+		if _, repeated := names[entry.Name]; repeated {
+			return nil, fmt.Errorf("api key name %q is repeated", entry.Name)
+		}
+		names[entry.Name] = struct{}{}
+
+		hash, err := hex.DecodeString(entry.SHA256)
+		if err != nil || len(hash) != sha256.Size {
+			return nil, fmt.Errorf("api key %q should have a hex encoded SHA-256", entry.Name)
+		}
+
+		keys = append(keys, apiKey{name: entry.Name, hash: hash})
+	}
+
+	return &APIKeys{keys: keys}, nil
+}
+
+// HashAPIKey returns what is stored on the keys file for the key
+func HashAPIKey(key string) string {
+	hash := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(hash[:])
+}
+
+// Authenticate returns the name of the key, if it is a known one
+func (apiKeys *APIKeys) Authenticate(key string) (string, bool) {
+	hash := sha256.Sum256([]byte(key))
+
+	name := ""
+	found := false
+	// Every key is compared, so the time taken doesn't tell which key (if any) matched
+	for _, known := range apiKeys.keys {
+		if subtle.ConstantTimeCompare(hash[:], known.hash) == 1 {
+			name = known.name
+			found = true
+		}
+	}
+
+	return name, found
+}
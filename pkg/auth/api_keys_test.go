@@ -0,0 +1,61 @@
+package auth_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jademcosta/graviola/pkg/auth"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthenticatesTheKnownKeys(t *testing.T) {
+	keysFile := "keys:\n" +
+		"  - name: grafana\n" +
+		"    sha256: " + auth.HashAPIKey("grafana-secret") + "\n" +
+		"  - name: alerting\n" +
+		"    sha256: " + auth.HashAPIKey("alerting-secret") + "\n"
+
+	path := filepath.Join(t.TempDir(), "keys.yml")
+	require.NoError(t, os.WriteFile(path, []byte(keysFile), 0600), "should write the keys file")
+
+	keys, err := auth.LoadAPIKeys(path)
+	require.NoError(t, err, "should load the keys file")
+
+	name, ok := keys.Authenticate("grafana-secret")
+	assert.True(t, ok, "should authenticate a known key")
+	assert.Equal(t, "grafana", name, "should answer with the name of the key")
+
+	name, ok = keys.Authenticate("alerting-secret")
+	assert.True(t, ok, "should authenticate a known key")
+	assert.Equal(t, "alerting", name, "should answer with the name of the key")
+
+	_, ok = keys.Authenticate("unknown-secret")
+	assert.False(t, ok, "should not authenticate unknown keys")
+
+	_, ok = keys.Authenticate("")
+	assert.False(t, ok, "should not authenticate empty keys")
+}
+
+func TestFailsToParseInvalidKeysFiles(t *testing.T) {
+	validHash := auth.HashAPIKey("secret")
+
+	testCases := map[string]string{
+		"invalid YAML":  "keys: [",
+		"empty name":    "keys:\n  - name: \"\"\n    sha256: " + validHash,
+		"repeated name": "keys:\n  - name: a\n    sha256: " + validHash + "\n  - name: a\n    sha256: " + validHash,
+		"not hex":       "keys:\n  - name: a\n    sha256: not-hex",
+		"not a SHA-256": "keys:\n  - name: a\n    sha256: abcdef",
+	}
+
+	for name, keysFile := range testCases {
+		_, err := auth.ParseAPIKeys([]byte(keysFile))
+		assert.Error(t, err, "should fail to parse a keys file with %s", name)
+	}
+}
+
+func TestFailsToLoadAMissingKeysFile(t *testing.T) {
+	_, err := auth.LoadAPIKeys(filepath.Join(t.TempDir(), "missing.yml"))
+	assert.Error(t, err, "should fail when the file doesn't exist")
+}
@@ -0,0 +1,262 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// minJWKSRefreshInterval is how long to wait between fetches of the JWKS URL when tokens are
+// signed by unknown keys, so a client sending bad tokens can't make Graviola flood the issuer
+const minJWKSRefreshInterval = 10 * time.Second
+
+type jwksDocument struct {
+	Keys []jwk `json:"keys"`
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// KeySet are the public keys of a JWKS, used to check the signature of tokens. When loaded from
+// an URL the keys are fetched again every refresh interval, and when a token is signed by an
+// unknown key (as issuers rotate their keys).
+type KeySet struct {
+	logg            *slog.Logger
+	url             string
+	httpClient      *http.Client
+	refreshInterval time.Duration
+	refreshGroup    singleflight.Group
+
+	mu          sync.Mutex
+	keys        map[string]crypto.PublicKey
+	lastRefresh time.Time
+}
+
+// NewFileKeySet loads the keys from a JWKS file. The keys are never reloaded.
+func NewFileKeySet(path string) (*KeySet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the jwks file: %w", err)
+	}
+
+	keys, err := parseJWKS(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return &KeySet{keys: keys}, nil
+}
+
+// NewURLKeySet fetches the keys from a JWKS URL. Failing to fetch them is not an error, as the
+// issuer might be back later: the fetch is retried when a token needs to be checked.
+func NewURLKeySet(
+	logg *slog.Logger, url string, httpClient *http.Client, refreshInterval time.Duration,
+) *KeySet {
+	keySet := &KeySet{
+		logg:            logg,
+		url:             url,
+		httpClient:      httpClient,
+		refreshInterval: refreshInterval,
+		keys:            make(map[string]crypto.PublicKey),
+	}
+
+	keySet.refresh(context.Background())
+
+	return keySet
+}
+
+// Key returns the key with the id. An empty id is only accepted when there's a single key.
+func (keySet *KeySet) Key(ctx context.Context, kid string) (crypto.PublicKey, bool) {
+	if keySet.url != "" && keySet.needsRefresh(kid) {
+		keySet.refresh(ctx)
+	}
+
+	keySet.mu.Lock()
+	defer keySet.mu.Unlock()
+
+	if kid == "" && len(keySet.keys) == 1 {
+		for _, key := range keySet.keys {
+			return key, true
+		}
+	}
+
+	key, ok := keySet.keys[kid]
+	return key, ok
+}
+
+func (keySet *KeySet) needsRefresh(kid string) bool {
+	keySet.mu.Lock()
+	defer keySet.mu.Unlock()
+
+	sinceRefresh := time.Since(keySet.lastRefresh)
+	_, known := keySet.keys[kid]
+	unknownKeyInterval := min(keySet.refreshInterval, minJWKSRefreshInterval)
+	return sinceRefresh >= keySet.refreshInterval || (!known && sinceRefresh >= unknownKeyInterval)
+}
+
+// refresh fetches the keys again, keeping the current ones if it fails. Concurrent calls share a
+// single fetch, which is not bound to the context of any request, so a client going away doesn't
+// fail it for the others. The wait ends early if the context is done.
+func (keySet *KeySet) refresh(ctx context.Context) {
+	done := keySet.refreshGroup.DoChan("jwks", func() (interface{}, error) {
+		keys, err := keySet.fetch(context.Background())
+
+		keySet.mu.Lock()
+		defer keySet.mu.Unlock()
+		keySet.lastRefresh = time.Now()
+		if err != nil {
+			keySet.logg.Error("unable to fetch the jwks", "url", keySet.url, "error", err)
+			return nil, nil
+		}
+
+		keySet.keys = keys
+		return nil, nil
+	})
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+}
+
+func (keySet *KeySet) fetch(ctx context.Context) (map[string]crypto.PublicKey, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, keySet.url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := keySet.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("jwks answered with status %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseJWKS(data)
+}
+
+// parseJWKS returns the signing keys of the JWKS, by id. Keys of unsupported types are ignored.
+func parseJWKS(data []byte) (map[string]crypto.PublicKey, error) {
+	document := jwksDocument{}
+	err := json.Unmarshal(data, &document)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse the jwks: %w", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(document.Keys))
+	for _, entry := range document.Keys {
+		if entry.Use != "" && entry.Use != "sig" {
+			continue
+		}
+
+		key, err := entry.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("jwks key %q is invalid: %w", entry.Kid, err)
+		}
+
+		if key != nil {
+			keys[entry.Kid] = key
+		}
+	}
+
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("the jwks has no signing keys")
+	}
+
+	return keys, nil
+}
+
+// publicKey returns the key, or nil if its type is not supported
+func (entry jwk) publicKey() (crypto.PublicKey, error) {
+	switch entry.Kty {
+	case "RSA":
+		n, err := decodeBigInt(entry.N)
+		if err != nil {
+			return nil, err
+		}
+
+		e, err := decodeBigInt(entry.E)
+		if err != nil {
+			return nil, err
+		}
+
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch entry.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", entry.Crv)
+		}
+
+		x, err := decodeBigInt(entry.X)
+		if err != nil {
+			return nil, err
+		}
+
+		y, err := decodeBigInt(entry.Y)
+		if err != nil {
+			return nil, err
+		}
+
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case "OKP":
+		if entry.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %q", entry.Crv)
+		}
+
+		x, err := base64.RawURLEncoding.DecodeString(entry.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid Ed25519 key")
+		}
+
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, nil
+	}
+}
+
+func decodeBigInt(value string) (*big.Int, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil || len(decoded) == 0 {
+		return nil, fmt.Errorf("invalid key parameter")
+	}
+
+	return new(big.Int).SetBytes(decoded), nil
+}
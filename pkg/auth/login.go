@@ -0,0 +1,84 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/jademcosta/graviola/pkg/config"
+	"golang.org/x/oauth2"
+)
+
+// LoginFlow is the OIDC authorization code flow, used to log in browsers. The ID token it gets
+// from the issuer is checked by the token verifier, same as bearer tokens.
+type LoginFlow struct {
+	oauthConf  *oauth2.Config
+	verifier   *TokenVerifier
+	httpClient *http.Client
+}
+
+func NewLoginFlow(
+	conf config.OIDCLoginConfig, verifier *TokenVerifier, httpClient *http.Client,
+) (*LoginFlow, error) {
+	clientSecret := ""
+	if conf.ClientSecretFile != "" {
+		data, err := os.ReadFile(conf.ClientSecretFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read the oidc client secret file: %w", err)
+		}
+		clientSecret = strings.TrimSpace(string(data))
+	}
+
+	return &LoginFlow{
+		oauthConf: &oauth2.Config{
+			ClientID:     conf.ClientID,
+			ClientSecret: clientSecret,
+			Endpoint:     oauth2.Endpoint{AuthURL: conf.AuthorizationURL, TokenURL: conf.TokenURL},
+			RedirectURL:  conf.RedirectURL,
+			Scopes:       conf.Scopes,
+		},
+		verifier:   verifier,
+		httpClient: httpClient,
+	}, nil
+}
+
+// AuthCodeURL returns where to send the browser to log in. The state is sent back on the callback.
+func (flow *LoginFlow) AuthCodeURL(state string) string {
+	return flow.oauthConf.AuthCodeURL(state)
+}
+
+// Exchange trades the code received on the callback for an ID token, and checks it
+func (flow *LoginFlow) Exchange(ctx context.Context, code string) (string, Identity, error) {
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, flow.httpClient)
+	token, err := flow.oauthConf.Exchange(ctx, code)
+	if err != nil {
+		return "", Identity{}, fmt.Errorf("unable to exchange the code: %w", err)
+	}
+
+	idToken, _ := token.Extra("id_token").(string)
+	if idToken == "" {
+		return "", Identity{}, fmt.Errorf("%w: the issuer answered without an id_token", ErrInvalidToken)
+	}
+
+	identity, err := flow.verifier.Verify(ctx, idToken)
+	if err != nil {
+		return "", Identity{}, err
+	}
+
+	return idToken, identity, nil
+}
+
+// NewState returns a random value, to be sent as the state of the login
+func NewState() (string, error) {
+	value := make([]byte, 32)
+	_, err := rand.Read(value)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(value), nil
+}
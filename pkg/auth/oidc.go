@@ -0,0 +1,94 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/jademcosta/graviola/pkg/config"
+)
+
+var ErrInvalidToken = errors.New("invalid token")
+
+var signingMethods = []string{
+	"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA",
+}
+
+// Identity is who sent a token, taken from its claims
+type Identity struct {
+	Principal string
+	Groups    []string
+	Expiry    time.Time
+}
+
+// TokenVerifier checks OIDC (JWT) tokens: their signature, issuer, audience and expiration
+type TokenVerifier struct {
+	keys           *KeySet
+	parser         *jwt.Parser
+	principalClaim string
+	groupsClaim    string
+}
+
+func NewTokenVerifier(keys *KeySet, conf config.OIDCConfig) *TokenVerifier {
+	return &TokenVerifier{
+		keys: keys,
+		parser: jwt.NewParser(
+			jwt.WithValidMethods(signingMethods),
+			jwt.WithIssuer(conf.Issuer),
+			jwt.WithAudience(conf.Audience),
+			jwt.WithExpirationRequired(),
+			jwt.WithLeeway(conf.ClockSkewDuration()),
+		),
+		principalClaim: conf.ClaimsConf.Principal,
+		groupsClaim:    conf.ClaimsConf.Groups,
+	}
+}
+
+// Verify returns the identity of who sent the token. The errors returned wrap ErrInvalidToken.
+func (verifier *TokenVerifier) Verify(ctx context.Context, token string) (Identity, error) {
+	claims := jwt.MapClaims{}
+	_, err := verifier.parser.ParseWithClaims(token, claims, func(parsed *jwt.Token) (interface{}, error) {
+		kid, _ := parsed.Header["kid"].(string)
+		key, ok := verifier.keys.Key(ctx, kid)
+		if !ok {
+			return nil, fmt.Errorf("unknown key %q", kid)
+		}
+		return key, nil
+	})
+	if err != nil {
+		return Identity{}, fmt.Errorf("%w: %w", ErrInvalidToken, err)
+	}
+
+	principal, _ := claims[verifier.principalClaim].(string)
+	if principal == "" {
+		return Identity{}, fmt.Errorf("%w: missing the %q claim", ErrInvalidToken, verifier.principalClaim)
+	}
+
+	identity := Identity{Principal: principal, Groups: stringsClaim(claims[verifier.groupsClaim])}
+	expiry, err := claims.GetExpirationTime()
+	if err == nil && expiry != nil {
+		identity.Expiry = expiry.Time
+	}
+
+	return identity, nil
+}
+
+// stringsClaim accepts both a list of strings and a single string as claim value
+func stringsClaim(value interface{}) []string {
+	switch typed := value.(type) {
+	case string:
+		return []string{typed}
+	case []interface{}:
+		values := make([]string, 0, len(typed))
+		for _, item := range typed {
+			if str, ok := item.(string); ok {
+				values = append(values, str)
+			}
+		}
+		return values
+	default:
+		return []string{}
+	}
+}
@@ -0,0 +1,170 @@
+package auth_test
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/jademcosta/graviola/internal/mocks"
+	"github.com/jademcosta/graviola/pkg/auth"
+	"github.com/jademcosta/graviola/pkg/config"
+	"github.com/jademcosta/graviola/pkg/graviolalog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var logger = graviolalog.NewLogger(config.LogConfig{Level: "error"})
+
+const issuer = "https://issuer.example.com"
+const audience = "graviola"
+
+func validClaims() jwt.MapClaims {
+	return jwt.MapClaims{
+		"iss":    issuer,
+		"aud":    audience,
+		"sub":    "1234",
+		"email":  "someone@example.com",
+		"groups": []string{"sre", "dev"},
+		"exp":    time.Now().Add(time.Hour).Unix(),
+	}
+}
+
+func newVerifier(issuerMock *mocks.OIDCIssuerMock, refreshInterval time.Duration) *auth.TokenVerifier {
+	conf := config.OIDCConfig{Enabled: true, Issuer: issuer, Audience: audience}.FillDefaults()
+	keys := auth.NewURLKeySet(logger, issuerMock.JWKSURL(), http.DefaultClient, refreshInterval)
+	return auth.NewTokenVerifier(keys, conf)
+}
+
+func TestVerifiesTokensOfTheIssuer(t *testing.T) {
+	issuerMock := mocks.NewOIDCIssuerMock()
+	defer issuerMock.Close()
+	sut := newVerifier(issuerMock, time.Hour)
+
+	identity, err := sut.Verify(context.Background(), issuerMock.Token(validClaims()))
+	require.NoError(t, err, "should accept a valid token")
+	assert.Equal(t, "someone@example.com", identity.Principal, "should take the principal from the email claim")
+	assert.Equal(t, []string{"sre", "dev"}, identity.Groups, "should take the groups from the groups claim")
+	assert.WithinDuration(t, time.Now().Add(time.Hour), identity.Expiry, time.Minute,
+		"should answer with the expiration of the token")
+}
+
+func TestRejectsInvalidTokens(t *testing.T) {
+	issuerMock := mocks.NewOIDCIssuerMock()
+	defer issuerMock.Close()
+	sut := newVerifier(issuerMock, time.Hour)
+
+	otherIssuer := mocks.NewOIDCIssuerMock()
+	defer otherIssuer.Close()
+
+	withClaim := func(name string, value interface{}) jwt.MapClaims {
+		claims := validClaims()
+		if value == nil {
+			delete(claims, name)
+		} else {
+			claims[name] = value
+		}
+		return claims
+	}
+
+	testCases := map[string]string{
+		"another issuer":        issuerMock.Token(withClaim("iss", "https://other.example.com")),
+		"another audience":      issuerMock.Token(withClaim("aud", "other")),
+		"expired":               issuerMock.Token(withClaim("exp", time.Now().Add(-time.Hour).Unix())),
+		"no expiration":         issuerMock.Token(withClaim("exp", nil)),
+		"no principal":          issuerMock.Token(withClaim("email", nil)),
+		"signed by another key": otherIssuer.Token(validClaims()),
+		"garbage":               "not.a.token",
+	}
+
+	for name, token := range testCases {
+		_, err := sut.Verify(context.Background(), token)
+		assert.ErrorIs(t, err, auth.ErrInvalidToken, "should reject a token %s", name)
+	}
+}
+
+func TestAcceptsTokensSignedByRotatedKeys(t *testing.T) {
+	issuerMock := mocks.NewOIDCIssuerMock()
+	defer issuerMock.Close()
+	sut := newVerifier(issuerMock, time.Millisecond)
+
+	issuerMock.RotateKey()
+	time.Sleep(2 * time.Millisecond)
+
+	_, err := sut.Verify(context.Background(), issuerMock.Token(validClaims()))
+	assert.NoError(t, err, "should fetch the keys again when the token is signed by an unknown key")
+}
+
+func TestDoesNotFetchTheKeysOnEveryToken(t *testing.T) {
+	issuerMock := mocks.NewOIDCIssuerMock()
+	defer issuerMock.Close()
+	sut := newVerifier(issuerMock, time.Hour)
+
+	for range 5 {
+		_, err := sut.Verify(context.Background(), issuerMock.Token(validClaims()))
+		require.NoError(t, err, "should accept a valid token")
+	}
+
+	assert.Equal(t, 1, issuerMock.JWKSRequests(), "should fetch the keys only once in the refresh interval")
+}
+
+func TestFetchesTheKeysOnceForConcurrentTokens(t *testing.T) {
+	issuerMock := mocks.NewOIDCIssuerMock()
+	defer issuerMock.Close()
+	sut := newVerifier(issuerMock, time.Millisecond)
+
+	issuerMock.RotateKey()
+	issuerMock.SetJWKSDelay(100 * time.Millisecond)
+	time.Sleep(2 * time.Millisecond)
+
+	cancelledCtx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := sut.Verify(cancelledCtx, issuerMock.Token(validClaims()))
+	assert.Error(t, err, "should not wait for the keys when the request is gone")
+
+	var wg sync.WaitGroup
+	for range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := sut.Verify(context.Background(), issuerMock.Token(validClaims()))
+			assert.NoError(t, err, "should accept the token once the keys are fetched")
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 2, issuerMock.JWKSRequests(),
+		"should share a single fetch of the keys, not cancelled by a request going away")
+}
+
+func TestVerifiesTokensWithKeysFromAFile(t *testing.T) {
+	issuerMock := mocks.NewOIDCIssuerMock()
+	defer issuerMock.Close()
+
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	require.NoError(t, os.WriteFile(path, issuerMock.JWKS(), 0600), "should write the jwks file")
+
+	keys, err := auth.NewFileKeySet(path)
+	require.NoError(t, err, "should load the jwks file")
+
+	conf := config.OIDCConfig{
+		Enabled: true, Issuer: issuer, Audience: audience,
+		ClaimsConf: config.OIDCClaimsConfig{Principal: "sub", Groups: "roles"},
+	}.FillDefaults()
+	sut := auth.NewTokenVerifier(keys, conf)
+
+	claims := validClaims()
+	claims["roles"] = "admin"
+	identity, err := sut.Verify(context.Background(), issuerMock.Token(claims))
+	require.NoError(t, err, "should accept a valid token")
+	assert.Equal(t, "1234", identity.Principal, "should take the principal from the configured claim")
+	assert.Equal(t, []string{"admin"}, identity.Groups, "should take the groups from the configured claim")
+	assert.Equal(t, 0, issuerMock.JWKSRequests(), "should not need the issuer")
+
+	_, err = auth.NewFileKeySet(filepath.Join(t.TempDir(), "missing.json"))
+	assert.Error(t, err, "should fail when the file doesn't exist")
+}
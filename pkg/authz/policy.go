@@ -0,0 +1,144 @@
+package authz
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/jademcosta/graviola/pkg/clientinfo"
+	"github.com/jademcosta/graviola/pkg/config"
+	"github.com/prometheus/prometheus/model/labels"
+)
+
+var ErrForbidden = errors.New("forbidden")
+
+type contextKey struct{}
+
+// Policy is what a client can see. A nil policy allows everything.
+type Policy struct {
+	Name          string
+	identities    []string
+	groups        []string
+	storageGroups []string
+	// Matchers are added to every select and label request
+	Matchers []*labels.Matcher
+	// MaxLookback is how far into the past the data can be read, zero when there's no limit
+	MaxLookback time.Duration
+}
+
+// AllowsStorageGroup tells if the storage group can be queried
+func (policy *Policy) AllowsStorageGroup(name string) bool {
+	if policy == nil || len(policy.storageGroups) == 0 {
+		return true
+	}
+
+	return slices.Contains(policy.storageGroups, name)
+}
+
+func (policy *Policy) matches(identity string, groups []string) bool {
+	if identity != "" && slices.Contains(policy.identities, identity) {
+		return true
+	}
+
+	return slices.ContainsFunc(groups, func(group string) bool {
+		return slices.Contains(policy.groups, group)
+	})
+}
+
+// Policies decide the policy of each client
+type Policies struct {
+	identityHeader string
+	policies       []*Policy
+	defaultPolicy  *Policy
+}
+
+func NewPolicies(conf config.AuthorizationConfig) (*Policies, error) {
+	policies := &Policies{
+		identityHeader: conf.IdentityHeader,
+		policies:       make([]*Policy, 0, len(conf.Policies)),
+	}
+
+	for _, policyConf := range conf.Policies {
+		matchers, err := policyConf.ParsedMatchers()
+		if err != nil {
+			return nil, fmt.Errorf("authorization policy %s: %w", policyConf.Name, err)
+		}
+
+		policy := &Policy{
+			Name:          policyConf.Name,
+			identities:    policyConf.Identities,
+			groups:        policyConf.Groups,
+			storageGroups: policyConf.StorageGroups,
+			Matchers:      matchers,
+			MaxLookback:   policyConf.MaxLookbackDuration(),
+		}
+		policies.policies = append(policies.policies, policy)
+
+		if policy.Name == conf.DefaultPolicy {
+			policies.defaultPolicy = policy
+		}
+	}
+
+	return policies, nil
+}
+
+// For returns the first policy matching the client, or the default policy if none does. The
+// errors returned wrap ErrForbidden.
+func (policies *Policies) For(info clientinfo.Info) (*Policy, error) {
+	identity := policies.Identity(info)
+	for _, policy := range policies.policies {
+		if policy.matches(identity, info.Groups) {
+			return policy, nil
+		}
+	}
+
+	if policies.defaultPolicy != nil {
+		return policies.defaultPolicy, nil
+	}
+
+	if identity == "" {
+		return nil, fmt.Errorf("%w: the client is not identified", ErrForbidden)
+	}
+	return nil, fmt.Errorf("%w: no authorization policy for %q", ErrForbidden, identity)
+}
+
+// ForContext returns the policy stored on the context or, if there's none, the policy of the
+// client of the context
+func (policies *Policies) ForContext(ctx context.Context) (*Policy, error) {
+	if policy, ok := ctx.Value(contextKey{}).(*Policy); ok {
+		return policy, nil
+	}
+
+	info, _ := clientinfo.FromContext(ctx)
+	return policies.For(info)
+}
+
+// Identity returns who the client is: the principal it authenticated as, the subject of its
+// certificate or, if none, the value of the trusted identity header
+func (policies *Policies) Identity(info clientinfo.Info) string {
+	if info.Principal != "" {
+		return info.Principal
+	}
+
+	if info.CertificateSubject != "" {
+		return info.CertificateSubject
+	}
+
+	if policies.identityHeader != "" && info.Headers != nil {
+		return info.Headers.Get(policies.identityHeader)
+	}
+
+	return ""
+}
+
+func NewContext(ctx context.Context, policy *Policy) context.Context {
+	return context.WithValue(ctx, contextKey{}, policy)
+}
+
+// FromContext returns the policy of the client, or nil if there's none
+func FromContext(ctx context.Context) *Policy {
+	policy, _ := ctx.Value(contextKey{}).(*Policy)
+	return policy
+}
@@ -0,0 +1,90 @@
+package authz_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/jademcosta/graviola/pkg/authz"
+	"github.com/jademcosta/graviola/pkg/clientinfo"
+	"github.com/jademcosta/graviola/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newPolicies(t *testing.T, defaultPolicy string) *authz.Policies {
+	t.Helper()
+	policies, err := authz.NewPolicies(config.AuthorizationConfig{
+		Enabled:        true,
+		IdentityHeader: "X-Graviola-User",
+		DefaultPolicy:  defaultPolicy,
+		Policies: []config.AuthorizationPolicyConfig{
+			{
+				Name:          "payments",
+				Identities:    []string{"payments-grafana", "payments.example.com"},
+				Groups:        []string{"payments-team"},
+				StorageGroups: []string{"main"},
+				Matchers:      []string{`team="payments"`},
+				MaxLookback:   "7d",
+			},
+			{Name: "admins", Groups: []string{"sre"}},
+			{Name: "public", Matchers: []string{`public="true"`}},
+		},
+	})
+	require.NoError(t, err, "should create the policies")
+
+	return policies
+}
+
+func TestPoliciesMatchTheIdentityOfTheClient(t *testing.T) {
+	sut := newPolicies(t, "")
+
+	headers := http.Header{}
+	headers.Set("X-Graviola-User", "payments-grafana")
+
+	testCases := map[string]clientinfo.Info{
+		"authenticated principal": {Principal: "payments-grafana"},
+		"certificate subject":     {CertificateSubject: "payments.example.com"},
+		"trusted header":          {Headers: headers},
+		"group":                   {Principal: "someone@example.com", Groups: []string{"dev", "payments-team"}},
+	}
+
+	for name, info := range testCases {
+		policy, err := sut.For(info)
+		require.NoError(t, err, "should find a policy by the %s", name)
+		assert.Equal(t, "payments", policy.Name, "should find the policy by the %s", name)
+	}
+
+	policy, err := sut.For(clientinfo.Info{Principal: "someone@example.com", Groups: []string{"sre"}})
+	require.NoError(t, err, "should find a policy by the groups")
+	assert.Equal(t, "admins", policy.Name, "should find the first policy matching the client")
+	assert.True(t, policy.AllowsStorageGroup("any"), "should allow all groups when none is listed")
+
+	policy, err = sut.For(clientinfo.Info{Principal: "payments-grafana"})
+	require.NoError(t, err, "should find a policy")
+	assert.True(t, policy.AllowsStorageGroup("main"), "should allow the listed groups")
+	assert.False(t, policy.AllowsStorageGroup("other"), "should not allow groups that are not listed")
+	require.Len(t, policy.Matchers, 1, "should have the matchers of the policy")
+	assert.Equal(t, `team="payments"`, policy.Matchers[0].String(), "should parse the matchers")
+}
+
+func TestPrincipalsTakePrecedenceOverTheTrustedHeader(t *testing.T) {
+	sut := newPolicies(t, "")
+
+	headers := http.Header{}
+	headers.Set("X-Graviola-User", "payments-grafana")
+	_, err := sut.For(clientinfo.Info{Principal: "someone@example.com", Headers: headers})
+	assert.ErrorIs(t, err, authz.ErrForbidden, "should not trust the header when the client is authenticated")
+}
+
+func TestClientsWithoutAPolicyAreForbiddenUnlessThereIsADefault(t *testing.T) {
+	sut := newPolicies(t, "")
+	_, err := sut.For(clientinfo.Info{})
+	assert.ErrorIs(t, err, authz.ErrForbidden, "should forbid clients not identified")
+	_, err = sut.For(clientinfo.Info{Principal: "unknown"})
+	assert.ErrorIs(t, err, authz.ErrForbidden, "should forbid clients without a policy")
+
+	sut = newPolicies(t, "public")
+	policy, err := sut.For(clientinfo.Info{Principal: "unknown"})
+	require.NoError(t, err, "should use the default policy")
+	assert.Equal(t, "public", policy.Name, "should use the default policy")
+}
@@ -0,0 +1,51 @@
+package clientinfo
+
+import (
+	"context"
+	"net/http"
+)
+
+type contextKey struct{}
+
+// Info holds what is known about the client that sent the request being processed
+type Info struct {
+	// Address is the IP address of the client, without the port
+	Address string
+	// Tenant is the tenant informed by the client, empty if none was
+	Tenant string
+	// Headers are the headers of the request sent by the client
+	Headers http.Header
+	// Principal is the name of the authenticated client (like the name of its API key), empty
+	// when the client is not authenticated
+	Principal string
+	// Groups are the groups of the authenticated client, when its credentials tell them (like the
+	// groups claim of an OIDC token)
+	Groups []string
+	// CertificateSubject is the common name of the TLS certificate of the client, empty when it
+	// didn't send one
+	CertificateSubject string
+}
+
+// AuthenticatedTenant returns the tenant informed by the client only when the client is
+// authenticated, as anyone can send any tenant header
+func (info Info) AuthenticatedTenant() string {
+	if info.Principal == "" {
+		return ""
+	}
+	return info.Tenant
+}
+
+// Key returns the value that better identifies the client
+func (info Info) Key() string {
+	return info.Address
+}
+
+func NewContext(ctx context.Context, info Info) context.Context {
+	return context.WithValue(ctx, contextKey{}, info)
+}
+
+// FromContext returns the client info stored in the context, if any
+func FromContext(ctx context.Context) (Info, bool) {
+	info, ok := ctx.Value(contextKey{}).(Info)
+	return info, ok
+}
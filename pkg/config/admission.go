@@ -0,0 +1,136 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+const DefaultAdmissionMaxQueueLength = 100
+const DefaultAdmissionMaxQueueTime = "30s"
+const DefaultAdmissionPriorityHeader = "X-Graviola-Priority"
+const DefaultAdmissionPriority = "default"
+
+// AdmissionConfig configures the queue where queries wait for a free slot before being executed.
+// It is disabled by default.
+type AdmissionConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// MaxQueueLength is how many queries can wait on the queue of each priority. Zero means
+	// queries are rejected when there's no free slot, so it is only filled when not set.
+	MaxQueueLength *int   `yaml:"max_queue_length"`
+	MaxQueueTime   string `yaml:"max_queue_time"`
+	PriorityHeader string `yaml:"priority_header"`
+	// DefaultPriority is used when the request doesn't inform one, or informs an unknown one
+	DefaultPriority string           `yaml:"default_priority"`
+	Priorities      []PriorityConfig `yaml:"priorities"`
+}
+
+// PriorityConfig is a class of queries, which has its own pool of slots
+type PriorityConfig struct {
+	Name          string `yaml:"name"`
+	MaxConcurrent int    `yaml:"max_concurrent"`
+}
+
+// FillDefaults uses maxConcurrentQueries as the size of the pool of the default priority, when
+// no priority is configured.
+func (ac AdmissionConfig) FillDefaults(maxConcurrentQueries int) AdmissionConfig {
+	if ac.MaxQueueLength == nil {
+		maxQueueLength := DefaultAdmissionMaxQueueLength
+		ac.MaxQueueLength = &maxQueueLength
+	}
+
+	if ac.MaxQueueTime == "" {
+		ac.MaxQueueTime = DefaultAdmissionMaxQueueTime
+	}
+
+	if ac.PriorityHeader == "" {
+		ac.PriorityHeader = DefaultAdmissionPriorityHeader
+	}
+
+	if len(ac.Priorities) == 0 {
+		ac.Priorities = []PriorityConfig{{Name: DefaultAdmissionPriority, MaxConcurrent: maxConcurrentQueries}}
+	}
+
+	if ac.DefaultPriority == "" {
+		ac.DefaultPriority = ac.Priorities[len(ac.Priorities)-1].Name
+	}
+
+	return ac
+}
+
+// IsValid checks the config, including that the priorities don't have more slots than
+// maxConcurrentQueries
+func (ac AdmissionConfig) IsValid(maxConcurrentQueries int) error {
+	if !ac.Enabled {
+		return nil
+	}
+
+	if ac.MaxQueueLengthValue() < 0 {
+		return fmt.Errorf("admission max_queue_length cannot be < 0")
+	}
+
+	parsed, err := ParseDuration(ac.MaxQueueTime)
+	if err != nil {
+		return fmt.Errorf("admission max_queue_time is invalid: %w", err)
+	}
+
+	if parsed <= 0 {
+		return fmt.Errorf("admission max_queue_time cannot be <= 0")
+	}
+
+	if ac.PriorityHeader == "" {
+		return fmt.Errorf("admission priority_header cannot be empty")
+	}
+
+	if len(ac.Priorities) == 0 {
+		return fmt.Errorf("admission priorities cannot be empty")
+	}
+
+	names := make(map[string]struct{}, len(ac.Priorities))
+	totalConcurrent := 0
+	for _, priority := range ac.Priorities {
+		if priority.Name == "" {
+			return fmt.Errorf("admission priority name cannot be empty")
+		}
+
+		if _, exists := names[priority.Name]; exists {
+			return fmt.Errorf("admission priority %s is defined more than once", priority.Name)
+		}
+		names[priority.Name] = struct{}{}
+
+		if priority.MaxConcurrent <= 0 {
+			return fmt.Errorf("admission priority %s max_concurrent cannot be <= 0", priority.Name)
+		}
+		totalConcurrent += priority.MaxConcurrent
+	}
+
+	// The queries of all the priorities are executed by the same engine, which has no more slots
+	// than max_concurrent_queries
+	if totalConcurrent > maxConcurrentQueries {
+		return fmt.Errorf("admission priorities max_concurrent sum %d, more than the %d max_concurrent_queries",
+			totalConcurrent, maxConcurrentQueries)
+	}
+
+	if _, exists := names[ac.DefaultPriority]; !exists {
+		return fmt.Errorf("admission default_priority %s is not one of the priorities", ac.DefaultPriority)
+	}
+
+	return nil
+}
+
+// MaxQueueLengthValue returns the max queue length, or the default one when it is not set
+func (ac AdmissionConfig) MaxQueueLengthValue() int {
+	if ac.MaxQueueLength == nil {
+		return DefaultAdmissionMaxQueueLength
+	}
+
+	return *ac.MaxQueueLength
+}
+
+func (ac AdmissionConfig) MaxQueueTimeDuration() time.Duration {
+	parsed, err := ParseDuration(ac.MaxQueueTime)
+	if err != nil {
+		panic(err)
+	}
+
+	return parsed
+}
@@ -0,0 +1,79 @@
+package config_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jademcosta/graviola/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdmissionValidate(t *testing.T) {
+	sut := config.AdmissionConfig{}
+	require.NoError(t, sut.IsValid(10), "disabled admission should be valid")
+
+	sut = config.AdmissionConfig{Enabled: true}.FillDefaults(10)
+	require.NoError(t, sut.IsValid(10), "filled with defaults should be valid")
+
+	negative := -1
+	sut = config.AdmissionConfig{Enabled: true, MaxQueueLength: &negative}.FillDefaults(10)
+	require.Error(t, sut.IsValid(10), "should return error when max_queue_length is < 0")
+
+	sut = config.AdmissionConfig{Enabled: true, MaxQueueTime: "0s"}.FillDefaults(10)
+	require.Error(t, sut.IsValid(10), "should return error when max_queue_time is zero")
+
+	sut = config.AdmissionConfig{Enabled: true, MaxQueueTime: "abc"}.FillDefaults(10)
+	require.Error(t, sut.IsValid(10), "should return error when max_queue_time is not a duration")
+
+	sut = config.AdmissionConfig{Enabled: true, Priorities: []config.PriorityConfig{
+		{Name: "alerting", MaxConcurrent: 2}, {Name: "alerting", MaxConcurrent: 3},
+	}}.FillDefaults(10)
+	require.Error(t, sut.IsValid(10), "should return error when a priority is repeated")
+
+	sut = config.AdmissionConfig{Enabled: true, Priorities: []config.PriorityConfig{
+		{Name: "alerting", MaxConcurrent: 0},
+	}}.FillDefaults(10)
+	require.Error(t, sut.IsValid(10), "should return error when max_concurrent of a priority is <= 0")
+
+	sut = config.AdmissionConfig{Enabled: true, Priorities: []config.PriorityConfig{
+		{Name: "", MaxConcurrent: 1},
+	}, DefaultPriority: "x"}.FillDefaults(10)
+	require.Error(t, sut.IsValid(10), "should return error when a priority has no name")
+
+	sut = config.AdmissionConfig{Enabled: true, DefaultPriority: "unknown", Priorities: []config.PriorityConfig{
+		{Name: "alerting", MaxConcurrent: 1},
+	}}.FillDefaults(10)
+	require.Error(t, sut.IsValid(10), "should return error when the default priority doesn't exist")
+
+	sut = config.AdmissionConfig{Enabled: true, Priorities: []config.PriorityConfig{
+		{Name: "alerting", MaxConcurrent: 4}, {Name: "adhoc", MaxConcurrent: 6},
+	}}.FillDefaults(10)
+	require.NoError(t, sut.IsValid(10), "priorities using all the concurrent queries should be valid")
+
+	sut = config.AdmissionConfig{Enabled: true, Priorities: []config.PriorityConfig{
+		{Name: "alerting", MaxConcurrent: 5}, {Name: "adhoc", MaxConcurrent: 6},
+	}}.FillDefaults(10)
+	require.Error(t, sut.IsValid(10),
+		"should return error when the priorities have more slots than the max concurrent queries")
+}
+
+func TestAdmissionFillDefaults(t *testing.T) {
+	sut := config.AdmissionConfig{}.FillDefaults(13)
+	assert.False(t, sut.Enabled, "should be disabled by default")
+	assert.Equal(t, config.DefaultAdmissionMaxQueueLength, sut.MaxQueueLengthValue(), "should fill the max queue length")
+	assert.Equal(t, 30*time.Second, sut.MaxQueueTimeDuration(), "should fill the max queue time")
+	assert.Equal(t, config.DefaultAdmissionPriorityHeader, sut.PriorityHeader, "should fill the priority header")
+	assert.Equal(t, []config.PriorityConfig{{Name: config.DefaultAdmissionPriority, MaxConcurrent: 13}},
+		sut.Priorities, "should create a single priority with all the concurrent queries")
+	assert.Equal(t, config.DefaultAdmissionPriority, sut.DefaultPriority, "should fill the default priority")
+
+	sut = config.AdmissionConfig{Priorities: []config.PriorityConfig{
+		{Name: "alerting", MaxConcurrent: 5}, {Name: "adhoc", MaxConcurrent: 2},
+	}}.FillDefaults(13)
+	assert.Equal(t, "adhoc", sut.DefaultPriority, "should use the last priority as the default")
+
+	noQueue := 0
+	sut = config.AdmissionConfig{MaxQueueLength: &noQueue}.FillDefaults(13)
+	assert.Equal(t, 0, sut.MaxQueueLengthValue(), "should keep a max queue length of zero")
+}
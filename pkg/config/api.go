@@ -7,7 +7,42 @@ import (
 const DefaultPort = 9197
 
 type APIConfig struct {
-	Port int `yaml:"port"`
+	// ListenAddress is the address the API binds to. All interfaces are used when it is empty.
+	ListenAddress string `yaml:"listen_address"`
+	Port          int    `yaml:"port"`
+	// ExternalURL is the URL Graviola is reached on, when it is behind a reverse proxy. Its path
+	// is the default route prefix.
+	ExternalURL string `yaml:"external_url"`
+	// RoutePrefix is the path all the routes are served under, like "/prometheus"
+	RoutePrefix string `yaml:"route_prefix"`
+	// CORSOrigin is a regex of the origins allowed on the Prometheus API
+	CORSOrigin    string          `yaml:"cors_origin"`
+	TLSConf       APITLSConfig    `yaml:"tls"`
+	ServerConf    APIServerConfig `yaml:"server"`
+	AdminConf     APIAdminConfig  `yaml:"admin"`
+	AuthConf      APIAuthConfig   `yaml:"auth"`
+	RateLimitConf RateLimitConfig `yaml:"rate_limit"`
+}
+
+// APIAuthConfig configures how clients authenticate on the API. It is disabled by default.
+type APIAuthConfig struct {
+	APIKeysConf APIKeysConfig `yaml:"api_keys"`
+	OIDCConf    OIDCConfig    `yaml:"oidc"`
+}
+
+// APIKeysConfig configures the authentication with API keys. Keys are loaded from a file, where
+// only their SHA-256 hashes are stored.
+type APIKeysConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Header is the request header with the key. When empty, the key is sent as a bearer token on
+	// the Authorization header.
+	Header   string `yaml:"header"`
+	KeysFile string `yaml:"keys_file"`
+	// ExemptOperationalRoutes allows /healthy, /ready and /metrics to be called without a key
+	ExemptOperationalRoutes bool `yaml:"exempt_operational_routes"`
+	// DebugKeyNames are the names of the keys allowed to call /debug, /-/reload and
+	// /api/v1/status/active_queries. No key is allowed if empty.
+	DebugKeyNames []string `yaml:"debug_key_names"`
 }
 
 func (apiConf APIConfig) FillDefaults() APIConfig {
@@ -15,6 +50,23 @@ func (apiConf APIConfig) FillDefaults() APIConfig {
 		apiConf.Port = DefaultPort
 	}
 
+	if apiConf.RoutePrefix == "" {
+		apiConf.RoutePrefix = routePrefixOf(apiConf.ExternalURL)
+	}
+	apiConf.RoutePrefix = normalizeRoutePrefix(apiConf.RoutePrefix)
+
+	if apiConf.CORSOrigin == "" {
+		apiConf.CORSOrigin = DefaultCORSOrigin
+	}
+
+	apiConf.TLSConf = apiConf.TLSConf.FillDefaults()
+	apiConf.ServerConf = apiConf.ServerConf.FillDefaults()
+	if apiConf.AdminConf.Enabled {
+		apiConf.AdminConf = apiConf.AdminConf.FillDefaults()
+	}
+	apiConf.AuthConf.OIDCConf = apiConf.AuthConf.OIDCConf.FillDefaults()
+	apiConf.RateLimitConf = apiConf.RateLimitConf.FillDefaults()
+
 	return apiConf
 }
 
@@ -23,5 +75,54 @@ func (apiConf APIConfig) IsValid() error {
 		return fmt.Errorf("port cannot be zero")
 	}
 
+	if apiConf.ExternalURL != "" {
+		err := isValidExternalURL(apiConf.ExternalURL)
+		if err != nil {
+			return err
+		}
+	}
+
+	err := isValidCORSOrigin(apiConf.CORSOrigin)
+	if err != nil {
+		return err
+	}
+
+	err = apiConf.TLSConf.IsValid()
+	if err != nil {
+		return err
+	}
+
+	err = apiConf.ServerConf.IsValid()
+	if err != nil {
+		return err
+	}
+
+	if apiConf.AdminConf.Enabled && apiConf.AdminConf.Port == apiConf.Port &&
+		(apiConf.AdminConf.ListenAddress == apiConf.ListenAddress || apiConf.ListenAddress == "") {
+		return fmt.Errorf("api admin should listen on another port than the api")
+	}
+
+	err = apiConf.AuthConf.APIKeysConf.IsValid()
+	if err != nil {
+		return err
+	}
+
+	err = apiConf.AuthConf.OIDCConf.IsValid()
+	if err != nil {
+		return err
+	}
+
+	return apiConf.RateLimitConf.IsValid()
+}
+
+func (keysConf APIKeysConfig) IsValid() error {
+	if !keysConf.Enabled {
+		return nil
+	}
+
+	if keysConf.KeysFile == "" {
+		return fmt.Errorf("api_keys keys_file cannot be empty")
+	}
+
 	return nil
 }
@@ -0,0 +1,241 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultCORSOrigin              = ".*"
+	DefaultRoutePrefix             = "/"
+	DefaultServerReadHeaderTimeout = "10s"
+	DefaultServerReadTimeout       = "1m"
+	DefaultServerWriteTimeout      = "5m"
+	DefaultServerIdleTimeout       = "2m"
+	DefaultServerMaxHeaderBytes    = 1 << 20
+	DefaultServerMaxBodyBytes      = 10 << 20
+	DefaultServerPreStopDelay      = "0s"
+	DefaultServerDrainTimeout      = "30s"
+	DefaultTLSMinVersion           = "1.2"
+	DefaultAdminPort               = 9198
+	DefaultAdminListenAddress      = "127.0.0.1"
+)
+
+var tlsMinVersions = []string{"1.2", "1.3"}
+
+// APITLSConfig makes the API be served on HTTPS. The certificate and key files are loaded again
+// when they change, so they can be renewed without restarting Graviola.
+type APITLSConfig struct {
+	CertFile string `yaml:"cert_file"`
+	KeyFile  string `yaml:"key_file"`
+	// ClientCAFile enables client certificates, which are checked against the CAs of this file.
+	// The subject of the certificate identifies the client on authorization policies.
+	ClientCAFile string `yaml:"client_ca_file"`
+	// RequireClientCert rejects the connections without a valid client certificate
+	RequireClientCert bool   `yaml:"require_client_cert"`
+	MinVersion        string `yaml:"min_version"`
+}
+
+// APIServerConfig has the limits of the HTTP server, so slow or misbehaving clients can't hold
+// connections forever
+type APIServerConfig struct {
+	ReadHeaderTimeout string `yaml:"read_header_timeout"`
+	ReadTimeout       string `yaml:"read_timeout"`
+	// WriteTimeout should be longer than the query timeout, or slow queries won't be answered
+	WriteTimeout   string `yaml:"write_timeout"`
+	IdleTimeout    string `yaml:"idle_timeout"`
+	MaxHeaderBytes int    `yaml:"max_header_bytes"`
+	MaxBodyBytes   int64  `yaml:"max_body_bytes"`
+	// PreStopDelay is how long /ready fails before the API stops accepting queries when Graviola
+	// is stopping, so load balancers have time to stop sending requests to it
+	PreStopDelay string `yaml:"pre_stop_delay"`
+	// DrainTimeout is how long the running queries have to finish when Graviola is stopping,
+	// before being cancelled
+	DrainTimeout string `yaml:"drain_timeout"`
+}
+
+// APIAdminConfig moves /metrics, /debug and /-/reload to a listener of their own, which only
+// listens on localhost by default. /metrics doesn't require authentication there, while /debug
+// and /-/reload keep the access control of the API. It is disabled by default.
+type APIAdminConfig struct {
+	Enabled       bool   `yaml:"enabled"`
+	ListenAddress string `yaml:"listen_address"`
+	Port          int    `yaml:"port"`
+}
+
+func (tlsConf APITLSConfig) FillDefaults() APITLSConfig {
+	if tlsConf.MinVersion == "" {
+		tlsConf.MinVersion = DefaultTLSMinVersion
+	}
+
+	return tlsConf
+}
+
+// Enabled is true when there's a certificate to serve HTTPS
+func (tlsConf APITLSConfig) Enabled() bool {
+	return tlsConf.CertFile != ""
+}
+
+func (tlsConf APITLSConfig) IsValid() error {
+	if (tlsConf.CertFile == "") != (tlsConf.KeyFile == "") {
+		return fmt.Errorf("api tls should have both cert_file and key_file")
+	}
+
+	if !tlsConf.Enabled() && tlsConf.ClientCAFile != "" {
+		return fmt.Errorf("api tls client_ca_file needs cert_file and key_file")
+	}
+
+	if tlsConf.RequireClientCert && tlsConf.ClientCAFile == "" {
+		return fmt.Errorf("api tls require_client_cert needs a client_ca_file")
+	}
+
+	if tlsConf.Enabled() && !slices.Contains(tlsMinVersions, tlsConf.MinVersion) {
+		return fmt.Errorf("api tls min_version should be one of %v", tlsMinVersions)
+	}
+
+	return nil
+}
+
+func (serverConf APIServerConfig) FillDefaults() APIServerConfig {
+	if serverConf.ReadHeaderTimeout == "" {
+		serverConf.ReadHeaderTimeout = DefaultServerReadHeaderTimeout
+	}
+
+	if serverConf.ReadTimeout == "" {
+		serverConf.ReadTimeout = DefaultServerReadTimeout
+	}
+
+	if serverConf.WriteTimeout == "" {
+		serverConf.WriteTimeout = DefaultServerWriteTimeout
+	}
+
+	if serverConf.IdleTimeout == "" {
+		serverConf.IdleTimeout = DefaultServerIdleTimeout
+	}
+
+	if serverConf.MaxHeaderBytes == 0 {
+		serverConf.MaxHeaderBytes = DefaultServerMaxHeaderBytes
+	}
+
+	if serverConf.MaxBodyBytes == 0 {
+		serverConf.MaxBodyBytes = DefaultServerMaxBodyBytes
+	}
+
+	if serverConf.PreStopDelay == "" {
+		serverConf.PreStopDelay = DefaultServerPreStopDelay
+	}
+
+	if serverConf.DrainTimeout == "" {
+		serverConf.DrainTimeout = DefaultServerDrainTimeout
+	}
+
+	return serverConf
+}
+
+func (serverConf APIServerConfig) IsValid() error {
+	for name, value := range map[string]string{
+		"read_header_timeout": serverConf.ReadHeaderTimeout,
+		"read_timeout":        serverConf.ReadTimeout,
+		"write_timeout":       serverConf.WriteTimeout,
+		"idle_timeout":        serverConf.IdleTimeout,
+		"pre_stop_delay":      serverConf.PreStopDelay,
+		"drain_timeout":       serverConf.DrainTimeout,
+	} {
+		if value == "" {
+			continue
+		}
+
+		_, err := ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("api server %s is invalid: %w", name, err)
+		}
+	}
+
+	if serverConf.MaxHeaderBytes < 0 {
+		return fmt.Errorf("api server max_header_bytes cannot be < 0")
+	}
+
+	if serverConf.MaxBodyBytes < 0 {
+		return fmt.Errorf("api server max_body_bytes cannot be < 0")
+	}
+
+	return nil
+}
+
+func (serverConf APIServerConfig) ReadHeaderTimeoutDuration() time.Duration {
+	return parseOptionalDuration(serverConf.ReadHeaderTimeout)
+}
+
+func (serverConf APIServerConfig) ReadTimeoutDuration() time.Duration {
+	return parseOptionalDuration(serverConf.ReadTimeout)
+}
+
+func (serverConf APIServerConfig) WriteTimeoutDuration() time.Duration {
+	return parseOptionalDuration(serverConf.WriteTimeout)
+}
+
+func (serverConf APIServerConfig) IdleTimeoutDuration() time.Duration {
+	return parseOptionalDuration(serverConf.IdleTimeout)
+}
+
+func (serverConf APIServerConfig) PreStopDelayDuration() time.Duration {
+	return parseOptionalDuration(serverConf.PreStopDelay)
+}
+
+func (serverConf APIServerConfig) DrainTimeoutDuration() time.Duration {
+	return parseOptionalDuration(serverConf.DrainTimeout)
+}
+
+func (adminConf APIAdminConfig) FillDefaults() APIAdminConfig {
+	if adminConf.ListenAddress == "" {
+		adminConf.ListenAddress = DefaultAdminListenAddress
+	}
+	if adminConf.Port == 0 {
+		adminConf.Port = DefaultAdminPort
+	}
+
+	return adminConf
+}
+
+// routePrefixOf returns the path of the external URL, which is where Graviola is served from when
+// it is behind a reverse proxy
+func routePrefixOf(externalURL string) string {
+	parsed, err := url.Parse(externalURL)
+	if err != nil || parsed.Path == "" {
+		return DefaultRoutePrefix
+	}
+
+	return parsed.Path
+}
+
+// normalizeRoutePrefix makes the prefix start with a slash and not end with one, except for
+// the root
+func normalizeRoutePrefix(prefix string) string {
+	return "/" + strings.Trim(prefix, "/")
+}
+
+func isValidExternalURL(externalURL string) error {
+	parsed, err := url.Parse(externalURL)
+	if err != nil {
+		return fmt.Errorf("api external_url is invalid: %w", err)
+	}
+
+	if parsed.Scheme == "" || parsed.Host == "" {
+		return fmt.Errorf("api external_url should have a scheme and a host")
+	}
+
+	return nil
+}
+
+func isValidCORSOrigin(origin string) error {
+	_, err := regexp.Compile("^(?:" + origin + ")$")
+	if err != nil {
+		return fmt.Errorf("api cors_origin is invalid: %w", err)
+	}
+
+	return nil
+}
@@ -2,6 +2,7 @@ package config_test
 
 import (
 	"testing"
+	"time"
 
 	"github.com/jademcosta/graviola/pkg/config"
 	"github.com/stretchr/testify/assert"
@@ -29,3 +30,81 @@ func TestApiDefaultValues(t *testing.T) {
 	assert.Equalf(t, config.DefaultPort, newSut.Port,
 		"api port should be set to %d if the provided value is empty", config.DefaultPort)
 }
+
+func TestApiKeysValidate(t *testing.T) {
+	sut := config.APIConfig{Port: 100, AuthConf: config.APIAuthConfig{
+		APIKeysConf: config.APIKeysConfig{Enabled: true},
+	}}
+	require.Error(t, sut.IsValid(), "should return error when api keys are enabled without a keys file")
+
+	sut.AuthConf.APIKeysConf.KeysFile = "/etc/graviola/keys.yml"
+	require.NoError(t, sut.IsValid(), "should return NO error when api keys have a keys file")
+
+	sut.AuthConf.APIKeysConf = config.APIKeysConfig{}
+	require.NoError(t, sut.IsValid(), "should return NO error when api keys are disabled")
+}
+
+func TestApiServerDefaultValues(t *testing.T) {
+	sut := config.APIConfig{}.FillDefaults()
+
+	assert.Equal(t, config.DefaultRoutePrefix, sut.RoutePrefix, "should serve the routes on / by default")
+	assert.Equal(t, config.DefaultCORSOrigin, sut.CORSOrigin, "should allow every origin by default")
+	assert.Equal(t, config.DefaultServerWriteTimeout, sut.ServerConf.WriteTimeout, "should have a default write timeout")
+	assert.Equal(t, int64(config.DefaultServerMaxBodyBytes), sut.ServerConf.MaxBodyBytes,
+		"should have a default max body size")
+	assert.Equal(t, config.DefaultServerDrainTimeout, sut.ServerConf.DrainTimeout, "should have a default drain timeout")
+	assert.Equal(t, time.Duration(0), sut.ServerConf.PreStopDelayDuration(), "should not delay the stop by default")
+	assert.Equal(t, 0, sut.AdminConf.Port, "should not have an admin port when the admin listener is disabled")
+
+	sut = config.APIConfig{ExternalURL: "https://example.com/prometheus/"}.FillDefaults()
+	assert.Equal(t, "/prometheus", sut.RoutePrefix, "should use the path of the external URL as the route prefix")
+
+	sut = config.APIConfig{RoutePrefix: "graviola/"}.FillDefaults()
+	assert.Equal(t, "/graviola", sut.RoutePrefix, "should normalize the route prefix")
+
+	sut = config.APIConfig{AdminConf: config.APIAdminConfig{Enabled: true}}.FillDefaults()
+	assert.Equal(t, config.DefaultAdminPort, sut.AdminConf.Port, "should have a default admin port")
+	assert.Equal(t, "127.0.0.1", sut.AdminConf.ListenAddress, "should only listen on localhost by default")
+}
+
+func TestApiServerValidate(t *testing.T) {
+	valid := func() config.APIConfig {
+		return config.APIConfig{}.FillDefaults()
+	}
+
+	sut := valid()
+	sut.ExternalURL = "/prometheus"
+	require.Error(t, sut.IsValid(), "should return error when the external URL has no scheme and host")
+
+	sut = valid()
+	sut.CORSOrigin = "("
+	require.Error(t, sut.IsValid(), "should return error when the cors origin is not a regex")
+
+	sut = valid()
+	sut.ServerConf.ReadTimeout = "forever"
+	require.Error(t, sut.IsValid(), "should return error when a timeout is invalid")
+
+	sut = valid()
+	sut.ServerConf.DrainTimeout = "until done"
+	require.Error(t, sut.IsValid(), "should return error when the drain timeout is invalid")
+
+	sut = valid()
+	sut.TLSConf.CertFile = "/etc/graviola/cert.pem"
+	require.Error(t, sut.IsValid(), "should return error when tls has no key file")
+
+	sut.TLSConf.KeyFile = "/etc/graviola/key.pem"
+	require.NoError(t, sut.IsValid(), "should return NO error when tls has cert and key files")
+
+	sut.TLSConf.RequireClientCert = true
+	require.Error(t, sut.IsValid(), "should return error when client certs are required without a CA")
+
+	sut.TLSConf.ClientCAFile = "/etc/graviola/ca.pem"
+	require.NoError(t, sut.IsValid(), "should return NO error when client certs have a CA")
+
+	sut.TLSConf.MinVersion = "1.0"
+	require.Error(t, sut.IsValid(), "should return error when the tls min version is unknown")
+
+	sut = valid()
+	sut.AdminConf = config.APIAdminConfig{Enabled: true, Port: sut.Port}
+	require.Error(t, sut.IsValid(), "should return error when the admin listener uses the port of the api")
+}
@@ -0,0 +1,109 @@
+package config
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql/parser"
+)
+
+// AuthorizationConfig configures which storage groups and series each client can see. When
+// enabled, clients that don't match any policy can't query anything.
+type AuthorizationConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// IdentityHeader is a header trusted to tell who the client is, used when the client isn't
+	// authenticated (by API keys, OIDC or a client certificate). It should only be set when a
+	// trusted proxy sets the header.
+	IdentityHeader string `yaml:"identity_header"`
+	// DefaultPolicy is the name of the policy of the clients that don't match any other, empty
+	// to deny them.
+	DefaultPolicy string                      `yaml:"default_policy"`
+	Policies      []AuthorizationPolicyConfig `yaml:"policies"`
+}
+
+// AuthorizationPolicyConfig is what a set of clients can see. Clients match a policy by their
+// identity or by one of their groups, the first matching policy being used.
+type AuthorizationPolicyConfig struct {
+	Name       string   `yaml:"name"`
+	Identities []string `yaml:"identities"`
+	Groups     []string `yaml:"groups"`
+	// StorageGroups are the names of the groups that can be queried, all of them when empty
+	StorageGroups []string `yaml:"storage_groups"`
+	// Matchers are added to every select and label request, like `team="payments"`
+	Matchers []string `yaml:"matchers"`
+	// MaxLookback is how far into the past the data can be read, no limit when empty
+	MaxLookback string `yaml:"max_lookback"`
+}
+
+func (authzConf AuthorizationConfig) IsValid() error {
+	if !authzConf.Enabled {
+		return nil
+	}
+
+	names := make(map[string]bool, len(authzConf.Policies))
+	for _, policy := range authzConf.Policies {
+		if policy.Name == "" {
+			return fmt.Errorf("authorization policies cannot have an empty name")
+		}
+
+		if names[policy.Name] {
+			return fmt.Errorf("repeated authorization policy name: %s", policy.Name)
+		}
+		names[policy.Name] = true
+
+		err := policy.IsValid()
+		if err != nil {
+			return fmt.Errorf("authorization policy %s: %w", policy.Name, err)
+		}
+	}
+
+	if authzConf.DefaultPolicy != "" && !names[authzConf.DefaultPolicy] {
+		return fmt.Errorf("authorization default_policy %s is not a policy", authzConf.DefaultPolicy)
+	}
+
+	return nil
+}
+
+func (policyConf AuthorizationPolicyConfig) IsValid() error {
+	_, err := policyConf.ParsedMatchers()
+	if err != nil {
+		return err
+	}
+
+	if policyConf.MaxLookback != "" {
+		parsed, err := ParseDuration(policyConf.MaxLookback)
+		if err != nil {
+			return fmt.Errorf("max_lookback is invalid: %w", err)
+		}
+
+		if parsed < 0 {
+			return fmt.Errorf("max_lookback cannot be < 0")
+		}
+	}
+
+	return nil
+}
+
+// ParsedMatchers returns the matchers of the policy
+func (policyConf AuthorizationPolicyConfig) ParsedMatchers() ([]*labels.Matcher, error) {
+	parsed := make([]*labels.Matcher, 0, len(policyConf.Matchers))
+	for _, matcher := range policyConf.Matchers {
+		matchers, err := parser.ParseMetricSelector("{" + matcher + "}")
+		if err != nil {
+			return nil, fmt.Errorf("matcher %s is invalid: %w", matcher, err)
+		}
+
+		if len(matchers) != 1 {
+			return nil, fmt.Errorf("matcher %s should be a single matcher", matcher)
+		}
+		parsed = append(parsed, matchers[0])
+	}
+
+	return parsed, nil
+}
+
+// MaxLookbackDuration returns the parsed max lookback, or zero when there's no limit
+func (policyConf AuthorizationPolicyConfig) MaxLookbackDuration() time.Duration {
+	return parseOptionalDuration(policyConf.MaxLookback)
+}
@@ -0,0 +1,60 @@
+package config_test
+
+import (
+	"testing"
+
+	"github.com/jademcosta/graviola/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthorizationValidate(t *testing.T) {
+	require.NoError(t, config.AuthorizationConfig{}.IsValid(), "should be valid when disabled")
+
+	valid := func() config.AuthorizationConfig {
+		return config.AuthorizationConfig{
+			Enabled:       true,
+			DefaultPolicy: "public",
+			Policies: []config.AuthorizationPolicyConfig{
+				{Name: "payments", Matchers: []string{`team="payments"`, `env=~"prod|staging"`}, MaxLookback: "30d"},
+				{Name: "public"},
+			},
+		}
+	}
+	require.NoError(t, valid().IsValid(), "should return NO error when every option is correct")
+
+	testCases := map[string]func(conf *config.AuthorizationConfig){
+		"a policy without name":   func(conf *config.AuthorizationConfig) { conf.Policies[1].Name = "" },
+		"repeated policy names":   func(conf *config.AuthorizationConfig) { conf.Policies[1].Name = "payments" },
+		"an unknown default":      func(conf *config.AuthorizationConfig) { conf.DefaultPolicy = "unknown" },
+		"an invalid matcher":      func(conf *config.AuthorizationConfig) { conf.Policies[0].Matchers = []string{`team=`} },
+		"many matchers in one":    func(conf *config.AuthorizationConfig) { conf.Policies[0].Matchers = []string{`a="1",b="2"`} },
+		"an invalid max lookback": func(conf *config.AuthorizationConfig) { conf.Policies[0].MaxLookback = "abc" },
+	}
+
+	for name, change := range testCases {
+		conf := valid()
+		change(&conf)
+		assert.Error(t, conf.IsValid(), "should return error when there's %s", name)
+	}
+}
+
+func TestAuthorizationPoliciesShouldHaveExistingStorageGroups(t *testing.T) {
+	conf := config.MustParse([]byte(`
+storages:
+  groups:
+    - name: main
+      remotes:
+        - name: prom
+          address: http://localhost:9090
+`))
+
+	conf.AuthzConf = config.AuthorizationConfig{
+		Enabled:  true,
+		Policies: []config.AuthorizationPolicyConfig{{Name: "payments", StorageGroups: []string{"main"}}},
+	}
+	require.NoError(t, conf.IsValid(), "should accept policies with existing storage groups")
+
+	conf.AuthzConf.Policies[0].StorageGroups = []string{"unknown"}
+	assert.Error(t, conf.IsValid(), "should return error when a policy has an unknown storage group")
+}
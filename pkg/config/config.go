@@ -7,10 +7,15 @@ import (
 )
 
 type GraviolaConfig struct {
-	APIConf      APIConfig      `yaml:"api"`
-	LogConf      LogConfig      `yaml:"log"`
-	StoragesConf StoragesConfig `yaml:"storages"`
-	QueryConf    QueryConfig    `yaml:"query"`
+	APIConf      APIConfig           `yaml:"api"`
+	LogConf      LogConfig           `yaml:"log"`
+	StoragesConf StoragesConfig      `yaml:"storages"`
+	QueryConf    QueryConfig         `yaml:"query"`
+	CacheConf    ResultsCacheConfig  `yaml:"results_cache"`
+	QueryLogConf QueryLogConfig      `yaml:"query_log"`
+	AuthzConf    AuthorizationConfig `yaml:"authorization"`
+	TracingConf  TracingConfig       `yaml:"tracing"`
+	ReloadConf   ConfigReloadConfig  `yaml:"config_reload"`
 }
 
 // MustParse parses the configuration from the given byte slice and panics if there is an error.
@@ -49,6 +54,10 @@ func (gravConf GraviolaConfig) FillDefaults() GraviolaConfig {
 	gravConf.LogConf = gravConf.LogConf.FillDefaults()
 	gravConf.StoragesConf = gravConf.StoragesConf.FillDefaults()
 	gravConf.QueryConf = gravConf.QueryConf.FillDefaults()
+	gravConf.CacheConf = gravConf.CacheConf.FillDefaults()
+	gravConf.QueryLogConf = gravConf.QueryLogConf.FillDefaults()
+	gravConf.TracingConf = gravConf.TracingConf.FillDefaults()
+	gravConf.ReloadConf = gravConf.ReloadConf.FillDefaults()
 
 	return gravConf
 }
@@ -74,11 +83,41 @@ func (gravConf GraviolaConfig) IsValid() error {
 		return err
 	}
 
+	err = gravConf.CacheConf.IsValid()
+	if err != nil {
+		return err
+	}
+
+	err = gravConf.QueryLogConf.IsValid()
+	if err != nil {
+		return err
+	}
+
+	err = gravConf.AuthzConf.IsValid()
+	if err != nil {
+		return err
+	}
+
+	err = gravConf.TracingConf.IsValid()
+	if err != nil {
+		return err
+	}
+
+	err = gravConf.ReloadConf.IsValid()
+	if err != nil {
+		return err
+	}
+
 	err = gravConf.checkGroupHasRepeatedNames()
 	if err != nil {
 		return err
 	}
 
+	err = gravConf.checkPoliciesHaveExistingGroups()
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -94,3 +133,20 @@ func (gravConf GraviolaConfig) checkGroupHasRepeatedNames() error {
 
 	return nil
 }
+
+func (gravConf GraviolaConfig) checkPoliciesHaveExistingGroups() error {
+	names := make(map[string]bool)
+	for _, group := range gravConf.StoragesConf.Groups {
+		names[group.Name] = true
+	}
+
+	for _, policy := range gravConf.AuthzConf.Policies {
+		for _, groupName := range policy.StorageGroups {
+			if !names[groupName] {
+				return fmt.Errorf("authorization policy %s has an unknown storage group: %s", policy.Name, groupName)
+			}
+		}
+	}
+
+	return nil
+}
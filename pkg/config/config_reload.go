@@ -0,0 +1,49 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+const DefaultConfigReloadWatchInterval = "30s"
+
+// ConfigReloadConfig configures how the config file is reloaded while Graviola runs. It can
+// always be reloaded with a SIGHUP. Only the storages are reloaded, the other sections need a
+// restart.
+type ConfigReloadConfig struct {
+	// EnableEndpoint allows the config to be reloaded with a POST to /-/reload. It is disabled by
+	// default, as anyone reaching the API could reload it otherwise.
+	EnableEndpoint bool `yaml:"enable_endpoint"`
+	// WatchFile makes the config file be checked for changes, and reloaded when it changes
+	WatchFile     bool   `yaml:"watch_file"`
+	WatchInterval string `yaml:"watch_interval"`
+}
+
+func (reloadConf ConfigReloadConfig) FillDefaults() ConfigReloadConfig {
+	if reloadConf.WatchInterval == "" {
+		reloadConf.WatchInterval = DefaultConfigReloadWatchInterval
+	}
+
+	return reloadConf
+}
+
+func (reloadConf ConfigReloadConfig) IsValid() error {
+	if !reloadConf.WatchFile {
+		return nil
+	}
+
+	interval, err := ParseDuration(reloadConf.WatchInterval)
+	if err != nil {
+		return fmt.Errorf("config_reload watch_interval is invalid: %w", err)
+	}
+
+	if interval <= 0 {
+		return fmt.Errorf("config_reload watch_interval should be > 0")
+	}
+
+	return nil
+}
+
+func (reloadConf ConfigReloadConfig) WatchIntervalDuration() time.Duration {
+	return parseOptionalDuration(reloadConf.WatchInterval)
+}
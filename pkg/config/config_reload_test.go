@@ -0,0 +1,31 @@
+package config_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jademcosta/graviola/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigReloadDefaultValues(t *testing.T) {
+	sut := config.ConfigReloadConfig{}.FillDefaults()
+
+	assert.False(t, sut.EnableEndpoint, "should not allow reloads through the API by default")
+	assert.False(t, sut.WatchFile, "should not watch the file by default")
+	assert.Equal(t, 30*time.Second, sut.WatchIntervalDuration(), "should have a default watch interval")
+}
+
+func TestConfigReloadValidate(t *testing.T) {
+	require.NoError(t, config.ConfigReloadConfig{}.IsValid(), "should be valid when not watching the file")
+
+	sut := config.ConfigReloadConfig{WatchFile: true}.FillDefaults()
+	require.NoError(t, sut.IsValid(), "should return NO error with the default interval")
+
+	sut.WatchInterval = "often"
+	require.Error(t, sut.IsValid(), "should return error when the interval is invalid")
+
+	sut.WatchInterval = "0s"
+	require.Error(t, sut.IsValid(), "should return error when the interval is zero")
+}
@@ -0,0 +1,104 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"time"
+)
+
+const GuardrailActionWarn = "warn"
+const GuardrailActionReject = "reject"
+
+var allowedGuardrailActions = []string{"", GuardrailActionWarn, GuardrailActionReject}
+
+// GuardrailsConfig configures the rules that queries are checked against before being
+// executed. Each rule is disabled unless it has an action.
+type GuardrailsConfig struct {
+	// NoMetricName matches selectors without a metric name, like {job="api"}
+	NoMetricName GuardrailRuleConfig `yaml:"no_metric_name"`
+	// OnlyRegexOrNegativeMatchers matches selectors where every matcher is a regex or negative
+	// one, like {__name__=~".+"}
+	OnlyRegexOrNegativeMatchers GuardrailRuleConfig `yaml:"only_regex_or_negative_matchers"`
+	// MaxRangeSelector matches range selectors and subqueries longer than the threshold, like
+	// up[30d] or max_over_time(up[30d:1m])
+	MaxRangeSelector GuardrailRuleConfig `yaml:"max_range_selector"`
+	// MinSubqueryStep matches subqueries with a resolution finer than the threshold, like up[1d:1s]
+	MinSubqueryStep GuardrailRuleConfig `yaml:"min_subquery_step"`
+	// DeniedMetricNames matches selectors whose metric name matches the pattern, or can match it
+	// when the name is selected with a regex
+	DeniedMetricNames GuardrailRuleConfig `yaml:"denied_metric_names"`
+}
+
+// GuardrailRuleConfig is a single rule. The threshold is used only by the rules that compare
+// durations, and the pattern only by the ones that match names.
+type GuardrailRuleConfig struct {
+	// Action is what happens to the queries matching the rule: warn, reject or empty (disabled)
+	Action    string `yaml:"action"`
+	Threshold string `yaml:"threshold"`
+	Pattern   string `yaml:"pattern"`
+}
+
+func (gc GuardrailsConfig) IsValid() error {
+	for name, rule := range map[string]GuardrailRuleConfig{
+		"no_metric_name": gc.NoMetricName, "only_regex_or_negative_matchers": gc.OnlyRegexOrNegativeMatchers,
+	} {
+		err := rule.validateAction(name)
+		if err != nil {
+			return err
+		}
+	}
+
+	for name, rule := range map[string]GuardrailRuleConfig{
+		"max_range_selector": gc.MaxRangeSelector, "min_subquery_step": gc.MinSubqueryStep,
+	} {
+		err := rule.validateAction(name)
+		if err != nil {
+			return err
+		}
+
+		if rule.Action == "" {
+			continue
+		}
+
+		parsed, err := ParseDuration(rule.Threshold)
+		if err != nil {
+			return fmt.Errorf("guardrail %s threshold is invalid: %w", name, err)
+		}
+
+		if parsed <= 0 {
+			return fmt.Errorf("guardrail %s threshold cannot be <= 0", name)
+		}
+	}
+
+	err := gc.DeniedMetricNames.validateAction("denied_metric_names")
+	if err != nil {
+		return err
+	}
+
+	if gc.DeniedMetricNames.Action != "" {
+		if gc.DeniedMetricNames.Pattern == "" {
+			return fmt.Errorf("guardrail denied_metric_names pattern cannot be empty")
+		}
+
+		_, err = regexp.Compile(gc.DeniedMetricNames.Pattern)
+		if err != nil {
+			return fmt.Errorf("guardrail denied_metric_names pattern is invalid: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// ThresholdDuration returns the parsed threshold, or zero when it is empty
+func (grc GuardrailRuleConfig) ThresholdDuration() time.Duration {
+	return parseOptionalDuration(grc.Threshold)
+}
+
+func (grc GuardrailRuleConfig) validateAction(name string) error {
+	if !slices.Contains(allowedGuardrailActions, grc.Action) {
+		return fmt.Errorf("guardrail %s action should be one of %v", name, allowedGuardrailActions)
+	}
+
+	return nil
+}
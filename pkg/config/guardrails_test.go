@@ -0,0 +1,49 @@
+package config_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jademcosta/graviola/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGuardrailsValidate(t *testing.T) {
+	sut := config.GuardrailsConfig{}
+	require.NoError(t, sut.IsValid(), "disabled guardrails should be valid")
+
+	sut = config.GuardrailsConfig{
+		NoMetricName:                config.GuardrailRuleConfig{Action: "reject"},
+		OnlyRegexOrNegativeMatchers: config.GuardrailRuleConfig{Action: "warn"},
+		MaxRangeSelector:            config.GuardrailRuleConfig{Action: "reject", Threshold: "7d"},
+		MinSubqueryStep:             config.GuardrailRuleConfig{Action: "warn", Threshold: "10s"},
+		DeniedMetricNames:           config.GuardrailRuleConfig{Action: "reject", Pattern: "^expensive_.*"},
+	}
+	require.NoError(t, sut.IsValid(), "should be valid when all rules are properly configured")
+	assert.Equal(t, 7*24*time.Hour, sut.MaxRangeSelector.ThresholdDuration(), "should parse the threshold")
+
+	testCases := []struct {
+		conf   config.GuardrailsConfig
+		reason string
+	}{
+		{config.GuardrailsConfig{NoMetricName: config.GuardrailRuleConfig{Action: "block"}},
+			"the action is unknown"},
+		{config.GuardrailsConfig{OnlyRegexOrNegativeMatchers: config.GuardrailRuleConfig{Action: "deny"}},
+			"the action is unknown"},
+		{config.GuardrailsConfig{MaxRangeSelector: config.GuardrailRuleConfig{Action: "reject"}},
+			"a duration rule has no threshold"},
+		{config.GuardrailsConfig{MinSubqueryStep: config.GuardrailRuleConfig{Action: "warn", Threshold: "abc"}},
+			"the threshold is not a duration"},
+		{config.GuardrailsConfig{MinSubqueryStep: config.GuardrailRuleConfig{Action: "warn", Threshold: "0s"}},
+			"the threshold is zero"},
+		{config.GuardrailsConfig{DeniedMetricNames: config.GuardrailRuleConfig{Action: "reject"}},
+			"the denied metric names have no pattern"},
+		{config.GuardrailsConfig{DeniedMetricNames: config.GuardrailRuleConfig{Action: "reject", Pattern: "("}},
+			"the denied metric names pattern is not a regex"},
+	}
+
+	for _, tc := range testCases {
+		require.Error(t, tc.conf.IsValid(), "should return error when %s", tc.reason)
+	}
+}
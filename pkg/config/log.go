@@ -6,10 +6,38 @@ import (
 	"strings"
 )
 
+const (
+	LogFormatJSON   = "json"
+	LogFormatLogfmt = "logfmt"
+
+	LogOutputStdout = "stdout"
+	LogOutputFile   = "file"
+)
+
 const DefaultLogLevel = "info"
+const DefaultLogFormat = LogFormatJSON
+const DefaultLogOutput = LogOutputStdout
+const DefaultLogFileMaxSizeBytes = 100 * 1024 * 1024 // 100MB
+const DefaultLogFileMaxBackups = 3
+
+var logFormats = []string{LogFormatJSON, LogFormatLogfmt}
+var logOutputs = []string{LogOutputStdout, LogOutputFile}
 
 type LogConfig struct {
 	Level string `yaml:"level"`
+	// ComponentLevels overrides the level of the logs of some components, like api, remote,
+	// group or access (the log of the HTTP requests)
+	ComponentLevels map[string]string `yaml:"component_levels"`
+	Format          string            `yaml:"format"`
+	Output          string            `yaml:"output"`
+	FileConf        LogFileConfig     `yaml:"file"`
+}
+
+// LogFileConfig configures the file the logs are written to, when the output is "file"
+type LogFileConfig struct {
+	Path         string `yaml:"path"`
+	MaxSizeBytes int    `yaml:"max_size_bytes"`
+	MaxBackups   int    `yaml:"max_backups"`
 }
 
 func (lc LogConfig) FillDefaults() LogConfig {
@@ -17,16 +45,66 @@ func (lc LogConfig) FillDefaults() LogConfig {
 		lc.Level = DefaultLogLevel
 	}
 
+	if lc.Format == "" {
+		lc.Format = DefaultLogFormat
+	}
+
+	if lc.Output == "" {
+		lc.Output = DefaultLogOutput
+	}
+
+	if lc.FileConf.MaxSizeBytes == 0 {
+		lc.FileConf.MaxSizeBytes = DefaultLogFileMaxSizeBytes
+	}
+
+	if lc.FileConf.MaxBackups == 0 {
+		lc.FileConf.MaxBackups = DefaultLogFileMaxBackups
+	}
+
 	return lc
 }
 
 func (lc LogConfig) IsValid() error {
-	if !slices.Contains(listSupportedLogLevels(), strings.ToLower(lc.Level)) {
+	if !IsValidLogLevel(lc.Level) {
 		return fmt.Errorf("unsupported log level %s", lc.Level)
 	}
+
+	for component, level := range lc.ComponentLevels {
+		if !IsValidLogLevel(level) {
+			return fmt.Errorf("unsupported log level %s for component %s", level, component)
+		}
+	}
+
+	if lc.Format != "" && !slices.Contains(logFormats, lc.Format) {
+		return fmt.Errorf("log format should be one of %v", logFormats)
+	}
+
+	if lc.Output != "" && !slices.Contains(logOutputs, lc.Output) {
+		return fmt.Errorf("log output should be one of %v", logOutputs)
+	}
+
+	if lc.Output == LogOutputFile {
+		if lc.FileConf.Path == "" {
+			return fmt.Errorf("log file path cannot be empty when the output is file")
+		}
+
+		if lc.FileConf.MaxSizeBytes <= 0 {
+			return fmt.Errorf("log file max_size_bytes cannot be <= 0")
+		}
+
+		if lc.FileConf.MaxBackups < 0 {
+			return fmt.Errorf("log file max_backups cannot be < 0")
+		}
+	}
+
 	return nil
 }
 
+// IsValidLogLevel returns true when the level is one of the supported ones, in any case
+func IsValidLogLevel(level string) bool {
+	return slices.Contains(listSupportedLogLevels(), strings.ToLower(level))
+}
+
 func listSupportedLogLevels() []string {
 	return []string{"debug", "info", "warn", "error"}
 }
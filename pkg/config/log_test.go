@@ -48,3 +48,46 @@ func TestLogDefaultValues(t *testing.T) {
 	assert.Equal(t, config.DefaultLogLevel, newSut.Level,
 		"log level should be set to %s if the provided value is empty", config.DefaultLogLevel)
 }
+
+func TestLogFormatOutputAndComponentLevelsAreValidated(t *testing.T) {
+	testCases := []struct {
+		conf        config.LogConfig
+		shouldError bool
+	}{
+		{config.LogConfig{Level: "info", Format: "logfmt", Output: "stdout"}, false},
+		{config.LogConfig{Level: "info", Format: "json"}, false},
+		{config.LogConfig{Level: "info", ComponentLevels: map[string]string{"remote": "DEBUG"}}, false},
+		{config.LogConfig{Level: "info", Output: "file",
+			FileConf: config.LogFileConfig{Path: "/tmp/graviola.log", MaxSizeBytes: 10}}, false},
+
+		{config.LogConfig{Level: "info", Format: "xml"}, true},
+		{config.LogConfig{Level: "info", Output: "stderr"}, true},
+		{config.LogConfig{Level: "info", ComponentLevels: map[string]string{"remote": "verbose"}}, true},
+		{config.LogConfig{Level: "info", Output: "file", FileConf: config.LogFileConfig{MaxSizeBytes: 10}}, true},
+		{config.LogConfig{Level: "info", Output: "file",
+			FileConf: config.LogFileConfig{Path: "/tmp/graviola.log"}}, true},
+		{config.LogConfig{Level: "info", Output: "file",
+			FileConf: config.LogFileConfig{Path: "/tmp/graviola.log", MaxSizeBytes: 10, MaxBackups: -1}}, true},
+	}
+
+	for _, tc := range testCases {
+		err := tc.conf.IsValid()
+
+		if tc.shouldError {
+			assert.Error(t, err, "config %+v should result in error", tc.conf)
+		} else {
+			assert.NoError(t, err, "config %+v should NOT result in error", tc.conf)
+		}
+	}
+}
+
+func TestLogFormatAndOutputDefaultValues(t *testing.T) {
+	sut := config.LogConfig{}.FillDefaults()
+
+	assert.Equal(t, config.DefaultLogFormat, sut.Format, "format should be set to the default if empty")
+	assert.Equal(t, config.DefaultLogOutput, sut.Output, "output should be set to the default if empty")
+	assert.Equal(t, config.DefaultLogFileMaxSizeBytes, sut.FileConf.MaxSizeBytes,
+		"file max size should be set to the default if empty")
+	assert.Equal(t, config.DefaultLogFileMaxBackups, sut.FileConf.MaxBackups,
+		"file max backups should be set to the default if empty")
+}
@@ -8,11 +8,28 @@ import (
 const (
 	MergeStrategyAlwaysMerge = "always_merge"
 	MergeStrategyKeepBiggest = "keep_biggest"
+	MergeStrategyReconcile   = "reconcile"
 )
 const DefaultMergeStrategyType = MergeStrategyAlwaysMerge
 
+const (
+	ReconcileFunctionMin   = "min"
+	ReconcileFunctionMax   = "max"
+	ReconcileFunctionAvg   = "avg"
+	ReconcileFunctionFirst = "first"
+	ReconcileFunctionLast  = "last"
+)
+const DefaultReconcileFunction = ReconcileFunctionFirst
+
 type MergeStrategyConfig struct {
 	Strategy string `yaml:"type"`
+	// Function is only used by the reconcile strategy, to decide which value is kept when
+	// 2 or more datapoints of the same series share the same timestamp
+	Function string `yaml:"function"`
+	// DivergenceThreshold is only used by the reconcile strategy. It is the relative difference
+	// (0.1 means 10%) between conflicting values above which the divergence is reported.
+	// Zero disables the reporting.
+	DivergenceThreshold float64 `yaml:"divergence_threshold"`
 	// Time string `yaml:"time"` //TODO: will be used in the future when dedup by time window is implemented
 }
 
@@ -21,6 +38,10 @@ func (mergeStratConf MergeStrategyConfig) FillDefaults() MergeStrategyConfig {
 		mergeStratConf.Strategy = DefaultMergeStrategyType
 	}
 
+	if mergeStratConf.Strategy == MergeStrategyReconcile && mergeStratConf.Function == "" {
+		mergeStratConf.Function = DefaultReconcileFunction
+	}
+
 	return mergeStratConf
 }
 
@@ -29,9 +50,28 @@ func (mergeStratConf MergeStrategyConfig) IsValid() error {
 		return fmt.Errorf("merge strategy Strategy %s is invalid", mergeStratConf.Strategy)
 	}
 
+	if mergeStratConf.Strategy != MergeStrategyReconcile {
+		return nil
+	}
+
+	if !slices.Contains(listSupportedReconcileFunctions(), mergeStratConf.Function) {
+		return fmt.Errorf("merge strategy function should be one of %v", listSupportedReconcileFunctions())
+	}
+
+	if mergeStratConf.DivergenceThreshold < 0 {
+		return fmt.Errorf("merge strategy divergence_threshold cannot be < 0")
+	}
+
 	return nil
 }
 
 func listSupportedMergeStrategies() []string {
-	return []string{"keep_biggest", "always_merge"}
+	return []string{MergeStrategyKeepBiggest, MergeStrategyAlwaysMerge, MergeStrategyReconcile}
+}
+
+func listSupportedReconcileFunctions() []string {
+	return []string{
+		ReconcileFunctionMin, ReconcileFunctionMax, ReconcileFunctionAvg,
+		ReconcileFunctionFirst, ReconcileFunctionLast,
+	}
 }
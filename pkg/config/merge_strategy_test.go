@@ -21,10 +21,12 @@ func TestAcceptSpecificMergeStrategiesNames(t *testing.T) {
 
 		{"always_merge", false},
 		{"keep_biggest", false},
+		{"reconcile", false},
 	}
 
 	for _, tc := range testCases {
-		sut := config.MergeStrategyConfig{Strategy: tc.value}
+		// The function is only checked by the reconcile strategy, which needs one to be valid
+		sut := config.MergeStrategyConfig{Strategy: tc.value, Function: config.DefaultReconcileFunction}
 		err := sut.IsValid()
 
 		if tc.shouldError {
@@ -42,3 +44,46 @@ func TestMergeStrategyDefaultValues(t *testing.T) {
 	assert.Equal(t, config.DefaultMergeStrategyType, newSut.Strategy,
 		"merge strategy type should be set to %s if the provided value is empty", config.DefaultMergeStrategyType)
 }
+
+func TestReconcileMergeStrategyValidation(t *testing.T) {
+	testCases := []struct {
+		function    string
+		threshold   float64
+		shouldError bool
+	}{
+		{"", 0, true},
+		{"median", 0, true},
+		{"MAX", 0, true},
+		{"max", -0.1, true},
+
+		{"min", 0, false},
+		{"max", 0.5, false},
+		{"avg", 0, false},
+		{"first", 1, false},
+		{"last", 0, false},
+	}
+
+	for _, tc := range testCases {
+		sut := config.MergeStrategyConfig{
+			Strategy: "reconcile", Function: tc.function, DivergenceThreshold: tc.threshold}
+		err := sut.IsValid()
+
+		if tc.shouldError {
+			assert.Error(t, err, "function %s with threshold %f should result in error", tc.function, tc.threshold)
+		} else {
+			assert.NoError(t, err, "function %s with threshold %f should NOT result in error", tc.function, tc.threshold)
+		}
+	}
+}
+
+func TestReconcileMergeStrategyDefaultValues(t *testing.T) {
+	sut := config.MergeStrategyConfig{Strategy: config.MergeStrategyReconcile}
+	newSut := sut.FillDefaults()
+
+	assert.Equal(t, config.DefaultReconcileFunction, newSut.Function,
+		"reconcile function should be set to %s if the provided value is empty", config.DefaultReconcileFunction)
+
+	sut = config.MergeStrategyConfig{Strategy: config.MergeStrategyAlwaysMerge}
+	newSut = sut.FillDefaults()
+	assert.Empty(t, newSut.Function, "function should not be set for strategies other than reconcile")
+}
@@ -0,0 +1,154 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	DefaultOIDCJWKSRefreshInterval = "1h"
+	DefaultOIDCClockSkew           = "30s"
+	DefaultOIDCPrincipalClaim      = "email"
+	DefaultOIDCGroupsClaim         = "groups"
+	DefaultOIDCSessionCookieName   = "graviola_session"
+)
+
+var DefaultOIDCScopes = []string{"openid", "email", "profile"}
+
+// OIDCConfig configures the authentication with OIDC (JWT) bearer tokens. Tokens are checked
+// against the keys of a JWKS, loaded from an URL or from a local file.
+type OIDCConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Issuer must match the "iss" claim of the tokens
+	Issuer string `yaml:"issuer"`
+	// Audience must be one of the "aud" claim of the tokens
+	Audience string `yaml:"audience"`
+	// Only one of JWKSURL and JWKSFile can be set
+	JWKSURL             string           `yaml:"jwks_url"`
+	JWKSFile            string           `yaml:"jwks_file"`
+	JWKSRefreshInterval string           `yaml:"jwks_refresh_interval"`
+	ClockSkew           string           `yaml:"clock_skew"`
+	ClaimsConf          OIDCClaimsConfig `yaml:"claims"`
+	// ExemptOperationalRoutes allows /healthy, /ready and /metrics to be called without a token
+	ExemptOperationalRoutes bool `yaml:"exempt_operational_routes"`
+	// DebugGroups are the groups allowed to call /debug, /-/reload and
+	// /api/v1/status/active_queries. No one is allowed if empty.
+	DebugGroups []string        `yaml:"debug_groups"`
+	LoginConf   OIDCLoginConfig `yaml:"login"`
+}
+
+// OIDCClaimsConfig are the claims of the token that identify the client
+type OIDCClaimsConfig struct {
+	Principal string `yaml:"principal"`
+	Groups    string `yaml:"groups"`
+}
+
+// OIDCLoginConfig configures the authorization code flow, used by browsers. After the login the
+// token is kept on a session cookie.
+type OIDCLoginConfig struct {
+	Enabled          bool   `yaml:"enabled"`
+	ClientID         string `yaml:"client_id"`
+	ClientSecretFile string `yaml:"client_secret_file"`
+	AuthorizationURL string `yaml:"authorization_url"`
+	TokenURL         string `yaml:"token_url"`
+	// RedirectURL is the external URL of the /auth/callback route of Graviola
+	RedirectURL string   `yaml:"redirect_url"`
+	Scopes      []string `yaml:"scopes"`
+	CookieName  string   `yaml:"cookie_name"`
+}
+
+func (oidcConf OIDCConfig) FillDefaults() OIDCConfig {
+	if oidcConf.JWKSRefreshInterval == "" {
+		oidcConf.JWKSRefreshInterval = DefaultOIDCJWKSRefreshInterval
+	}
+
+	if oidcConf.ClockSkew == "" {
+		oidcConf.ClockSkew = DefaultOIDCClockSkew
+	}
+
+	if oidcConf.ClaimsConf.Principal == "" {
+		oidcConf.ClaimsConf.Principal = DefaultOIDCPrincipalClaim
+	}
+
+	if oidcConf.ClaimsConf.Groups == "" {
+		oidcConf.ClaimsConf.Groups = DefaultOIDCGroupsClaim
+	}
+
+	if len(oidcConf.LoginConf.Scopes) == 0 {
+		oidcConf.LoginConf.Scopes = DefaultOIDCScopes
+	}
+
+	if oidcConf.LoginConf.CookieName == "" {
+		oidcConf.LoginConf.CookieName = DefaultOIDCSessionCookieName
+	}
+
+	return oidcConf
+}
+
+func (oidcConf OIDCConfig) IsValid() error {
+	if !oidcConf.Enabled {
+		return nil
+	}
+
+	if oidcConf.Issuer == "" {
+		return fmt.Errorf("oidc issuer cannot be empty")
+	}
+
+	if oidcConf.Audience == "" {
+		return fmt.Errorf("oidc audience cannot be empty")
+	}
+
+	if (oidcConf.JWKSURL == "") == (oidcConf.JWKSFile == "") {
+		return fmt.Errorf("oidc should have one of jwks_url or jwks_file")
+	}
+
+	for name, value := range map[string]string{
+		"jwks_refresh_interval": oidcConf.JWKSRefreshInterval, "clock_skew": oidcConf.ClockSkew,
+	} {
+		parsed, err := ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("oidc %s is invalid: %w", name, err)
+		}
+
+		if parsed < 0 {
+			return fmt.Errorf("oidc %s cannot be < 0", name)
+		}
+	}
+
+	if oidcConf.ClaimsConf.Principal == "" {
+		return fmt.Errorf("oidc principal claim cannot be empty")
+	}
+
+	return oidcConf.LoginConf.IsValid()
+}
+
+func (loginConf OIDCLoginConfig) IsValid() error {
+	if !loginConf.Enabled {
+		return nil
+	}
+
+	for name, value := range map[string]string{
+		"client_id":         loginConf.ClientID,
+		"authorization_url": loginConf.AuthorizationURL,
+		"token_url":         loginConf.TokenURL,
+		"redirect_url":      loginConf.RedirectURL,
+		"cookie_name":       loginConf.CookieName,
+	} {
+		if value == "" {
+			return fmt.Errorf("oidc login %s cannot be empty", name)
+		}
+	}
+
+	return nil
+}
+
+// JWKSRefreshIntervalDuration returns how often the keys are fetched again from the JWKS URL
+func (oidcConf OIDCConfig) JWKSRefreshIntervalDuration() time.Duration {
+	return parseOptionalDuration(oidcConf.JWKSRefreshInterval)
+}
+
+// ClockSkewDuration returns how much the clocks of Graviola and of the issuer can differ when
+// checking the expiration of tokens
+func (oidcConf OIDCConfig) ClockSkewDuration() time.Duration {
+	return parseOptionalDuration(oidcConf.ClockSkew)
+}
@@ -0,0 +1,64 @@
+package config_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jademcosta/graviola/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func validOIDCConfig() config.OIDCConfig {
+	return config.OIDCConfig{
+		Enabled:  true,
+		Issuer:   "https://issuer.example.com",
+		Audience: "graviola",
+		JWKSURL:  "https://issuer.example.com/jwks",
+	}.FillDefaults()
+}
+
+func TestOIDCDefaultValues(t *testing.T) {
+	sut := config.OIDCConfig{}.FillDefaults()
+
+	assert.Equal(t, time.Hour, sut.JWKSRefreshIntervalDuration(), "should refresh the keys every hour by default")
+	assert.Equal(t, 30*time.Second, sut.ClockSkewDuration(), "should allow 30s of clock skew by default")
+	assert.Equal(t, "email", sut.ClaimsConf.Principal, "should take the principal from the email claim by default")
+	assert.Equal(t, "groups", sut.ClaimsConf.Groups, "should take the groups from the groups claim by default")
+	assert.Equal(t, config.DefaultOIDCScopes, sut.LoginConf.Scopes, "should have default scopes")
+	assert.Equal(t, config.DefaultOIDCSessionCookieName, sut.LoginConf.CookieName, "should have a default cookie name")
+}
+
+func TestOIDCValidate(t *testing.T) {
+	require.NoError(t, config.OIDCConfig{}.FillDefaults().IsValid(), "should be valid when disabled")
+	require.NoError(t, validOIDCConfig().IsValid(), "should return NO error when every option is correct")
+
+	sut := validOIDCConfig()
+	sut.JWKSURL = ""
+	sut.JWKSFile = "/etc/graviola/jwks.json"
+	require.NoError(t, sut.IsValid(), "should accept a jwks file")
+
+	testCases := map[string]func(conf *config.OIDCConfig){
+		"no issuer":              func(conf *config.OIDCConfig) { conf.Issuer = "" },
+		"no audience":            func(conf *config.OIDCConfig) { conf.Audience = "" },
+		"no jwks":                func(conf *config.OIDCConfig) { conf.JWKSURL = "" },
+		"both jwks url and file": func(conf *config.OIDCConfig) { conf.JWKSFile = "/etc/graviola/jwks.json" },
+		"invalid refresh":        func(conf *config.OIDCConfig) { conf.JWKSRefreshInterval = "abc" },
+		"invalid clock skew":     func(conf *config.OIDCConfig) { conf.ClockSkew = "abc" },
+		"login without client":   func(conf *config.OIDCConfig) { conf.LoginConf.Enabled = true },
+	}
+
+	for name, change := range testCases {
+		sut := validOIDCConfig()
+		change(&sut)
+		assert.Error(t, sut.IsValid(), "should return error when there's %s", name)
+	}
+
+	sut = validOIDCConfig()
+	sut.LoginConf.Enabled = true
+	sut.LoginConf.ClientID = "graviola"
+	sut.LoginConf.AuthorizationURL = "https://issuer.example.com/authorize"
+	sut.LoginConf.TokenURL = "https://issuer.example.com/token"
+	sut.LoginConf.RedirectURL = "https://graviola.example.com/auth/callback"
+	assert.NoError(t, sut.IsValid(), "should accept a complete login config")
+}
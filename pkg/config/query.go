@@ -9,12 +9,26 @@ const DefaultQueryMaxSamples = 100000
 const DefaultQueryLookbackDelta = "5m"
 const DefaultQueryConcurrentQueries = 20
 const DefaultTimeout = "1m"
+const DefaultQuerySplitMaxParallelism = 4
 
 type QueryConfig struct {
-	MaxSamples        int    `yaml:"max_samples"`
-	LookbackDelta     string `yaml:"lookback_delta"`
-	ConcurrentQueries int    `yaml:"max_concurrent_queries"`
-	Timeout           string `yaml:"timeout"`
+	MaxSamples        int              `yaml:"max_samples"`
+	LookbackDelta     string           `yaml:"lookback_delta"`
+	ConcurrentQueries int              `yaml:"max_concurrent_queries"`
+	Timeout           string           `yaml:"timeout"`
+	SplitConf         QuerySplitConfig `yaml:"split"`
+	// ActiveQueryLogDir is where the file with the active queries is written. Empty disables it.
+	ActiveQueryLogDir string            `yaml:"active_query_log_dir"`
+	AdmissionConf     AdmissionConfig   `yaml:"admission"`
+	LimitsConf        QueryLimitsConfig `yaml:"limits"`
+	GuardrailsConf    GuardrailsConfig  `yaml:"guardrails"`
+}
+
+// QuerySplitConfig configures how range queries are split into smaller ones, sent in parallel.
+// Splitting is disabled when the interval is empty.
+type QuerySplitConfig struct {
+	Interval       string `yaml:"interval"`
+	MaxParallelism int    `yaml:"max_parallelism"`
 }
 
 func (qc QueryConfig) FillDefaults() QueryConfig {
@@ -34,6 +48,12 @@ func (qc QueryConfig) FillDefaults() QueryConfig {
 		qc.Timeout = DefaultTimeout
 	}
 
+	if qc.SplitConf.MaxParallelism == 0 {
+		qc.SplitConf.MaxParallelism = DefaultQuerySplitMaxParallelism
+	}
+
+	qc.AdmissionConf = qc.AdmissionConf.FillDefaults(qc.ConcurrentQueries)
+
 	return qc
 }
 
@@ -64,9 +84,59 @@ func (qc QueryConfig) IsValid() error {
 		return fmt.Errorf("timeout must be a valid number: %w", err)
 	}
 
+	err = qc.SplitConf.IsValid()
+	if err != nil {
+		return err
+	}
+
+	err = qc.AdmissionConf.IsValid(qc.ConcurrentQueries)
+	if err != nil {
+		return err
+	}
+
+	err = qc.LimitsConf.IsValid()
+	if err != nil {
+		return err
+	}
+
+	return qc.GuardrailsConf.IsValid()
+}
+
+func (qsc QuerySplitConfig) IsValid() error {
+	if qsc.Interval == "" {
+		return nil
+	}
+
+	parsed, err := ParseDuration(qsc.Interval)
+	if err != nil {
+		return fmt.Errorf("error validating query split interval: %w", err)
+	}
+
+	if parsed <= 0 {
+		return fmt.Errorf("error validating query split interval: it cannot be <= 0")
+	}
+
+	if qsc.MaxParallelism <= 0 {
+		return fmt.Errorf("error validating query split max_parallelism: it cannot be <= 0")
+	}
+
 	return nil
 }
 
+// IntervalDuration returns the parsed split interval, or zero when splitting is disabled
+func (qsc QuerySplitConfig) IntervalDuration() time.Duration {
+	if qsc.Interval == "" {
+		return 0
+	}
+
+	parsed, err := ParseDuration(qsc.Interval)
+	if err != nil {
+		panic(err)
+	}
+
+	return parsed
+}
+
 func (qc QueryConfig) LookbackDeltaDuration() time.Duration {
 	parsed, err := ParseDuration(qc.LookbackDelta)
 	if err != nil {
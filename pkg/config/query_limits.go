@@ -0,0 +1,78 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// QueryLimitsConfig are limits enforced while a query fetches data from the remotes, so a query
+// is aborted before it holds too much data in memory. A zero (or empty) value disables a limit.
+type QueryLimitsConfig struct {
+	// MaxSeries is how many series a query can fetch, summing the ones of every remote
+	MaxSeries int `yaml:"max_series"`
+	// MaxFetchedBytes is how many bytes a query can receive, summing the responses of every remote
+	MaxFetchedBytes int `yaml:"max_fetched_bytes"`
+	// MaxQueryRange is the longest range (end - start) a range query can have
+	MaxQueryRange string `yaml:"max_query_range"`
+	// MaxQueryLookback is how far into the past a query can read, including the offsets and
+	// ranges of its selectors
+	MaxQueryLookback string `yaml:"max_query_lookback"`
+	// MaxLabelValues is how many values a label values request can answer with
+	MaxLabelValues int `yaml:"max_label_values"`
+}
+
+func (qlc QueryLimitsConfig) IsValid() error {
+	if qlc.MaxSeries < 0 {
+		return fmt.Errorf("query limits max_series cannot be < 0")
+	}
+
+	if qlc.MaxFetchedBytes < 0 {
+		return fmt.Errorf("query limits max_fetched_bytes cannot be < 0")
+	}
+
+	if qlc.MaxLabelValues < 0 {
+		return fmt.Errorf("query limits max_label_values cannot be < 0")
+	}
+
+	for name, value := range map[string]string{
+		"max_query_range": qlc.MaxQueryRange, "max_query_lookback": qlc.MaxQueryLookback,
+	} {
+		if value == "" {
+			continue
+		}
+
+		parsed, err := ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("query limits %s is invalid: %w", name, err)
+		}
+
+		if parsed < 0 {
+			return fmt.Errorf("query limits %s cannot be < 0", name)
+		}
+	}
+
+	return nil
+}
+
+// MaxQueryRangeDuration returns the parsed max query range, or zero when there's no limit
+func (qlc QueryLimitsConfig) MaxQueryRangeDuration() time.Duration {
+	return parseOptionalDuration(qlc.MaxQueryRange)
+}
+
+// MaxQueryLookbackDuration returns the parsed max query lookback, or zero when there's no limit
+func (qlc QueryLimitsConfig) MaxQueryLookbackDuration() time.Duration {
+	return parseOptionalDuration(qlc.MaxQueryLookback)
+}
+
+func parseOptionalDuration(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+
+	parsed, err := ParseDuration(value)
+	if err != nil {
+		panic(err)
+	}
+
+	return parsed
+}
@@ -0,0 +1,81 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+const DefaultQueryLogMaxSizeBytes = 100 * 1024 * 1024 // 100MB
+const DefaultQueryLogMaxBackups = 3
+const DefaultQueryLogSlowQueryThreshold = "0s"
+
+// QueryLogConfig configures the log of executed queries. It is disabled when the path is empty.
+type QueryLogConfig struct {
+	Path         string `yaml:"path"`
+	MaxSizeBytes int    `yaml:"max_size_bytes"`
+	MaxBackups   int    `yaml:"max_backups"`
+	// SlowQueryThreshold makes only queries that took longer than it to be logged
+	SlowQueryThreshold string `yaml:"slow_query_threshold"`
+	// IdentityHeaders are request headers that identify who sent the query, added to each entry
+	IdentityHeaders []string `yaml:"identity_headers"`
+}
+
+func (qlc QueryLogConfig) FillDefaults() QueryLogConfig {
+	if qlc.MaxSizeBytes == 0 {
+		qlc.MaxSizeBytes = DefaultQueryLogMaxSizeBytes
+	}
+
+	if qlc.MaxBackups == 0 {
+		qlc.MaxBackups = DefaultQueryLogMaxBackups
+	}
+
+	if qlc.SlowQueryThreshold == "" {
+		qlc.SlowQueryThreshold = DefaultQueryLogSlowQueryThreshold
+	}
+
+	return qlc
+}
+
+func (qlc QueryLogConfig) IsValid() error {
+	if qlc.Path == "" {
+		return nil
+	}
+
+	if qlc.MaxSizeBytes <= 0 {
+		return fmt.Errorf("query_log max_size_bytes cannot be <= 0")
+	}
+
+	if qlc.MaxBackups < 0 {
+		return fmt.Errorf("query_log max_backups cannot be < 0")
+	}
+
+	parsed, err := ParseDuration(qlc.SlowQueryThreshold)
+	if err != nil {
+		return fmt.Errorf("query_log slow_query_threshold is invalid: %w", err)
+	}
+
+	if parsed < 0 {
+		return fmt.Errorf("query_log slow_query_threshold cannot be < 0")
+	}
+
+	for _, header := range qlc.IdentityHeaders {
+		if header == "" {
+			return fmt.Errorf("query_log identity_headers cannot have empty values")
+		}
+	}
+
+	return nil
+}
+
+func (qlc QueryLogConfig) SlowQueryThresholdDuration() time.Duration {
+	if qlc.SlowQueryThreshold == "" {
+		return 0
+	}
+
+	parsed, err := ParseDuration(qlc.SlowQueryThreshold)
+	if err != nil {
+		panic(err)
+	}
+
+	return parsed
+}
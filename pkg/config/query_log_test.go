@@ -0,0 +1,43 @@
+package config_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jademcosta/graviola/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryLogValidate(t *testing.T) {
+	sut := config.QueryLogConfig{}
+	require.NoError(t, sut.IsValid(), "disabled query log should be valid")
+
+	sut = config.QueryLogConfig{Path: "/tmp/queries.log"}.FillDefaults()
+	require.NoError(t, sut.IsValid(), "filled with defaults should be valid")
+
+	sut = config.QueryLogConfig{Path: "/tmp/queries.log", MaxSizeBytes: -1}.FillDefaults()
+	require.Error(t, sut.IsValid(), "should return error when max_size_bytes is < 0")
+
+	sut = config.QueryLogConfig{Path: "/tmp/queries.log", MaxBackups: -1}.FillDefaults()
+	require.Error(t, sut.IsValid(), "should return error when max_backups is < 0")
+
+	sut = config.QueryLogConfig{Path: "/tmp/queries.log", SlowQueryThreshold: "abc"}.FillDefaults()
+	require.Error(t, sut.IsValid(), "should return error when slow_query_threshold is not a duration")
+
+	sut = config.QueryLogConfig{Path: "/tmp/queries.log", IdentityHeaders: []string{""}}.FillDefaults()
+	require.Error(t, sut.IsValid(), "should return error when an identity header is empty")
+
+	sut = config.QueryLogConfig{
+		Path: "/tmp/queries.log", SlowQueryThreshold: "2s", IdentityHeaders: []string{"X-Grafana-User"},
+	}.FillDefaults()
+	require.NoError(t, sut.IsValid(), "should accept a slow query threshold and identity headers")
+}
+
+func TestQueryLogFillDefaults(t *testing.T) {
+	sut := config.QueryLogConfig{}.FillDefaults()
+	assert.Empty(t, sut.Path, "should be disabled by default")
+	assert.Equal(t, config.DefaultQueryLogMaxSizeBytes, sut.MaxSizeBytes, "should fill the max size")
+	assert.Equal(t, config.DefaultQueryLogMaxBackups, sut.MaxBackups, "should fill the max backups")
+	assert.Equal(t, time.Duration(0), sut.SlowQueryThresholdDuration(), "should log all queries by default")
+}
@@ -2,6 +2,7 @@ package config_test
 
 import (
 	"testing"
+	"time"
 
 	"github.com/jademcosta/graviola/pkg/config"
 	"github.com/stretchr/testify/assert"
@@ -98,3 +99,76 @@ func TestQueryDefaultValues(t *testing.T) {
 	assert.Equalf(t, config.DefaultTimeout, newSut.Timeout,
 		"api port should be set to %s if the provided value is empty", config.DefaultTimeout)
 }
+
+func TestQuerySplitValidation(t *testing.T) {
+	base := config.QueryConfig{MaxSamples: 1, LookbackDelta: "1m", ConcurrentQueries: 1, Timeout: "1m"}
+
+	sut := base
+	err := sut.IsValid()
+	require.NoError(t, err, "should return NO error when split is not configured")
+
+	sut.SplitConf = config.QuerySplitConfig{Interval: "1d", MaxParallelism: 4}
+	err = sut.IsValid()
+	require.NoError(t, err, "should return NO error when split interval and max_parallelism are valid")
+
+	sut.SplitConf = config.QuerySplitConfig{Interval: "abc", MaxParallelism: 4}
+	err = sut.IsValid()
+	require.Error(t, err, "should return error when split interval is not a duration")
+
+	sut.SplitConf = config.QuerySplitConfig{Interval: "0s", MaxParallelism: 4}
+	err = sut.IsValid()
+	require.Error(t, err, "should return error when split interval is zero")
+
+	sut.SplitConf = config.QuerySplitConfig{Interval: "1d", MaxParallelism: 0}
+	err = sut.IsValid()
+	require.Error(t, err, "should return error when split max_parallelism is zero")
+
+	sut.SplitConf = config.QuerySplitConfig{Interval: "1d", MaxParallelism: -2}
+	err = sut.IsValid()
+	require.Error(t, err, "should return error when split max_parallelism is negative")
+}
+
+func TestQuerySplitDefaultValues(t *testing.T) {
+	newSut := config.QueryConfig{}.FillDefaults()
+
+	assert.Equal(t, config.DefaultQuerySplitMaxParallelism, newSut.SplitConf.MaxParallelism,
+		"query split max_parallelism should be set to %d if the provided value is empty",
+		config.DefaultQuerySplitMaxParallelism)
+	assert.Empty(t, newSut.SplitConf.Interval, "query split should be disabled by default")
+	assert.Zero(t, newSut.SplitConf.IntervalDuration(), "query split interval should be zero when disabled")
+}
+
+func TestQueryLimitsValidation(t *testing.T) {
+	base := config.QueryConfig{MaxSamples: 1, LookbackDelta: "1m", ConcurrentQueries: 1, Timeout: "1m"}
+
+	sut := base
+	err := sut.IsValid()
+	require.NoError(t, err, "should return NO error when limits are not configured")
+	assert.Zero(t, sut.LimitsConf.MaxQueryRangeDuration(), "max_query_range should be zero when not configured")
+	assert.Zero(t, sut.LimitsConf.MaxQueryLookbackDuration(), "max_query_lookback should be zero when not configured")
+
+	sut.LimitsConf = config.QueryLimitsConfig{
+		MaxSeries: 10, MaxFetchedBytes: 1000, MaxQueryRange: "1d", MaxQueryLookback: "30d", MaxLabelValues: 10,
+	}
+	err = sut.IsValid()
+	require.NoError(t, err, "should return NO error when limits are valid")
+	assert.Equal(t, 24*time.Hour, sut.LimitsConf.MaxQueryRangeDuration(), "should parse max_query_range")
+	assert.Equal(t, 30*24*time.Hour, sut.LimitsConf.MaxQueryLookbackDuration(), "should parse max_query_lookback")
+
+	testCases := []struct {
+		limits config.QueryLimitsConfig
+		reason string
+	}{
+		{config.QueryLimitsConfig{MaxSeries: -1}, "max_series is negative"},
+		{config.QueryLimitsConfig{MaxFetchedBytes: -1}, "max_fetched_bytes is negative"},
+		{config.QueryLimitsConfig{MaxLabelValues: -1}, "max_label_values is negative"},
+		{config.QueryLimitsConfig{MaxQueryRange: "abc"}, "max_query_range is not a duration"},
+		{config.QueryLimitsConfig{MaxQueryLookback: "abc"}, "max_query_lookback is not a duration"},
+	}
+
+	for _, tc := range testCases {
+		sut.LimitsConf = tc.limits
+		err = sut.IsValid()
+		require.Error(t, err, "should return error when %s", tc.reason)
+	}
+}
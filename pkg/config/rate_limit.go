@@ -0,0 +1,77 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+)
+
+const (
+	RateLimitKeyPrincipal = "principal"
+	RateLimitKeyTenant    = "tenant"
+	RateLimitKeyClientIP  = "client_ip"
+)
+
+const DefaultRateLimitKey = RateLimitKeyClientIP
+
+var rateLimitKeys = []string{RateLimitKeyPrincipal, RateLimitKeyTenant, RateLimitKeyClientIP}
+
+// RateLimitConfig configures a token bucket per client, so a single client can't starve the
+// others. Queries and metadata requests have separate budgets. It is disabled by default.
+type RateLimitConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Key is what identifies a client: its principal (like the API key name), its tenant or its
+	// IP. Clients without a principal or tenant are identified by their IP, and so are the
+	// tenants of clients that are not authenticated.
+	Key          string                `yaml:"key"`
+	QueryConf    RateLimitBudgetConfig `yaml:"query"`
+	MetadataConf RateLimitBudgetConfig `yaml:"metadata"`
+}
+
+// RateLimitBudgetConfig is how many requests per second a client can send, and how many it can
+// send at once (burst). A zero rate means no limit.
+type RateLimitBudgetConfig struct {
+	RequestsPerSecond float64 `yaml:"requests_per_second"`
+	Burst             int     `yaml:"burst"`
+}
+
+func (rlc RateLimitConfig) FillDefaults() RateLimitConfig {
+	if rlc.Key == "" {
+		rlc.Key = DefaultRateLimitKey
+	}
+
+	rlc.QueryConf = rlc.QueryConf.FillDefaults()
+	rlc.MetadataConf = rlc.MetadataConf.FillDefaults()
+
+	return rlc
+}
+
+// FillDefaults uses a burst of one second worth of requests, when none is set
+func (budget RateLimitBudgetConfig) FillDefaults() RateLimitBudgetConfig {
+	if budget.Burst == 0 && budget.RequestsPerSecond > 0 {
+		budget.Burst = max(1, int(budget.RequestsPerSecond))
+	}
+
+	return budget
+}
+
+func (rlc RateLimitConfig) IsValid() error {
+	if !rlc.Enabled {
+		return nil
+	}
+
+	if !slices.Contains(rateLimitKeys, rlc.Key) {
+		return fmt.Errorf("rate limit key should be one of %v", rateLimitKeys)
+	}
+
+	for name, budget := range map[string]RateLimitBudgetConfig{"query": rlc.QueryConf, "metadata": rlc.MetadataConf} {
+		if budget.RequestsPerSecond < 0 {
+			return fmt.Errorf("rate limit %s requests_per_second cannot be < 0", name)
+		}
+
+		if budget.Burst < 0 {
+			return fmt.Errorf("rate limit %s burst cannot be < 0", name)
+		}
+	}
+
+	return nil
+}
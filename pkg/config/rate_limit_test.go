@@ -0,0 +1,40 @@
+package config_test
+
+import (
+	"testing"
+
+	"github.com/jademcosta/graviola/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimitDefaultValues(t *testing.T) {
+	sut := config.RateLimitConfig{
+		QueryConf:    config.RateLimitBudgetConfig{RequestsPerSecond: 5},
+		MetadataConf: config.RateLimitBudgetConfig{RequestsPerSecond: 0.2},
+	}.FillDefaults()
+
+	assert.Equal(t, config.RateLimitKeyClientIP, sut.Key, "should identify clients by IP by default")
+	assert.Equal(t, 5, sut.QueryConf.Burst, "should default the burst to one second worth of requests")
+	assert.Equal(t, 1, sut.MetadataConf.Burst, "should default the burst to at least 1")
+}
+
+func TestRateLimitValidate(t *testing.T) {
+	require.NoError(t, config.RateLimitConfig{}.FillDefaults().IsValid(), "should be valid when disabled")
+
+	sut := config.RateLimitConfig{
+		Enabled:   true,
+		QueryConf: config.RateLimitBudgetConfig{RequestsPerSecond: 5},
+	}.FillDefaults()
+	require.NoError(t, sut.IsValid(), "should return NO error when every option is correct")
+
+	sut.Key = "user-agent"
+	require.Error(t, sut.IsValid(), "should return error when the key is unknown")
+
+	sut.Key = config.RateLimitKeyPrincipal
+	sut.MetadataConf.RequestsPerSecond = -1
+	require.Error(t, sut.IsValid(), "should return error when the rate is negative")
+
+	sut.MetadataConf = config.RateLimitBudgetConfig{Burst: -1}
+	require.Error(t, sut.IsValid(), "should return error when the burst is negative")
+}
@@ -3,16 +3,28 @@ package config
 import (
 	"fmt"
 	"regexp"
+	"time"
 )
 
+const DefaultRemoteChunkParallelism = 1
+
 type RemoteConfig struct {
 	Name           string           `yaml:"name"`
 	Address        string           `yaml:"address"`
 	PathPrefix     string           `yaml:"path_prefix"`
 	TimeWindowConf TimeWindowConfig `yaml:"time_window"`
+	// MaxQueryRange and MaxPointsPerSeries make range queries bigger than them be fetched in
+	// chunks. Empty (or zero) means no limit.
+	MaxQueryRange      string `yaml:"max_query_range"`
+	MaxPointsPerSeries int    `yaml:"max_points_per_series"`
+	ChunkParallelism   int    `yaml:"chunk_parallelism"`
 }
 
 func (sc RemoteConfig) FillDefaults() RemoteConfig {
+	if sc.ChunkParallelism == 0 {
+		sc.ChunkParallelism = DefaultRemoteChunkParallelism
+	}
+
 	return sc
 }
 
@@ -31,5 +43,42 @@ func (sc RemoteConfig) IsValid() error {
 		return fmt.Errorf("address should start with http:// or https://")
 	}
 
+	if sc.MaxQueryRange != "" {
+		parsed, err := ParseDuration(sc.MaxQueryRange)
+		if err != nil {
+			return fmt.Errorf("error validating max_query_range of remote %s: %w", sc.Name, err)
+		}
+
+		if parsed <= 0 {
+			return fmt.Errorf("max_query_range of remote %s cannot be <= 0", sc.Name)
+		}
+	}
+
+	if sc.MaxPointsPerSeries < 0 {
+		return fmt.Errorf("max_points_per_series of remote %s cannot be < 0", sc.Name)
+	}
+
+	if sc.ChunkParallelism < 0 {
+		return fmt.Errorf("chunk_parallelism of remote %s cannot be < 0", sc.Name)
+	}
+
+	if err := sc.TimeWindowConf.IsValid(); err != nil {
+		return fmt.Errorf("remote %s: %w", sc.Name, err)
+	}
+
 	return nil
 }
+
+// MaxQueryRangeDuration returns the parsed max_query_range, or zero when there is no limit
+func (sc RemoteConfig) MaxQueryRangeDuration() time.Duration {
+	if sc.MaxQueryRange == "" {
+		return 0
+	}
+
+	parsed, err := ParseDuration(sc.MaxQueryRange)
+	if err != nil {
+		panic(err)
+	}
+
+	return parsed
+}
@@ -2,26 +2,75 @@ package config
 
 import (
 	"fmt"
+	"math"
 	"slices"
 	"strings"
+	"time"
 )
 
 // TODO append a prefix on these consts
 const StrategyFailAll = "fail_all"
 const StrategyPartialResponse = "partial_response"
+const StrategyQuorum = "quorum"
 const DefaultOnFailStrategy = StrategyFailAll
 
+const ReadModeAll = "all"
+const ReadModeFailover = "failover"
+const ReadModeRoundRobin = "round_robin"
+const ReadModeFastest = "fastest"
+const DefaultReadMode = ReadModeAll
+
 type RemoteGroupsConfig struct {
-	Name                string           `yaml:"name"`
-	Servers             []RemoteConfig   `yaml:"remotes"`
-	TimeWindow          TimeWindowConfig `yaml:"time_window"`
-	OnQueryFailStrategy string           `yaml:"on_query_fail"`
+	Name                string              `yaml:"name"`
+	Servers             []RemoteConfig      `yaml:"remotes"`
+	TimeWindow          TimeWindowConfig    `yaml:"time_window"`
+	OnQueryFailStrategy string              `yaml:"on_query_fail"`
+	MergeConf           MergeStrategyConfig `yaml:"merge_strategy"`
+	QuorumConf          QuorumConfig        `yaml:"quorum"`
+	ReadMode            string              `yaml:"read_mode"`
+	FailoverConf        FailoverConfig      `yaml:"failover"`
+	RoundRobinConf      RoundRobinConfig    `yaml:"round_robin"`
+}
+
+// RoundRobinConfig is only used when the read_mode is round_robin.
+type RoundRobinConfig struct {
+	// Sticky makes queries from the same client always go to the same remote.
+	Sticky bool `yaml:"sticky"`
+}
+
+// FailoverConfig is only used when the read_mode is failover.
+type FailoverConfig struct {
+	// AttemptTimeout is how long each remote is waited for before trying the next one. When
+	// empty, each remote is waited for as long as the query timeout allows.
+	AttemptTimeout string `yaml:"attempt_timeout"`
+}
+
+// QuorumConfig is only used when the on_query_fail strategy is quorum. Only one of the fields
+// can be set. If none is set, the majority of the remotes is needed.
+type QuorumConfig struct {
+	MinSuccessful           int     `yaml:"min_successful"`
+	MinSuccessfulPercentage float64 `yaml:"min_successful_percentage"`
 }
 
 func (rgc RemoteGroupsConfig) FillDefaults() RemoteGroupsConfig {
 	if rgc.OnQueryFailStrategy == "" {
 		rgc.OnQueryFailStrategy = DefaultOnFailStrategy
 	}
+	if rgc.ReadMode == "" {
+		rgc.ReadMode = DefaultReadMode
+	}
+	rgc.MergeConf = rgc.MergeConf.FillDefaults()
+
+	servers := make([]RemoteConfig, 0, len(rgc.Servers))
+	for _, remote := range rgc.Servers {
+		// The time window of the group is used by the remotes that don't have one of their own
+		if !remote.TimeWindowConf.IsSet() {
+			remote.TimeWindowConf = rgc.TimeWindow
+		}
+		servers = append(servers, remote.FillDefaults())
+	}
+	rgc.Servers = servers
+
 	return rgc
 }
 
@@ -34,6 +83,42 @@ func (rgc RemoteGroupsConfig) IsValid() error {
 		return fmt.Errorf("on_query_fail should be one of %v", listSupportedFailureStrategies())
 	}
 
+	if strings.ToLower(rgc.OnQueryFailStrategy) == StrategyQuorum {
+		err := rgc.QuorumConf.IsValid(len(rgc.Servers))
+		if err != nil {
+			return fmt.Errorf("group %s: %w", rgc.Name, err)
+		}
+	}
+
+	if rgc.ReadMode != "" && !slices.Contains(listSupportedReadModes(), strings.ToLower(rgc.ReadMode)) {
+		return fmt.Errorf("read_mode should be one of %v", listSupportedReadModes())
+	}
+
+	// Only the "all" read mode calls many remotes for a query, the others can never reach a quorum
+	readMode := strings.ToLower(rgc.ReadMode)
+	if strings.ToLower(rgc.OnQueryFailStrategy) == StrategyQuorum && readMode != "" && readMode != ReadModeAll {
+		return fmt.Errorf("group %s: on_query_fail %s can only be used with the %s read_mode",
+			rgc.Name, StrategyQuorum, ReadModeAll)
+	}
+
+	if rgc.FailoverConf.AttemptTimeout != "" {
+		_, err := ParseDuration(rgc.FailoverConf.AttemptTimeout)
+		if err != nil {
+			return fmt.Errorf("group %s: failover attempt_timeout is invalid: %w", rgc.Name, err)
+		}
+	}
+
+	if rgc.MergeConf.Strategy != "" {
+		err := rgc.MergeConf.IsValid()
+		if err != nil {
+			return fmt.Errorf("group %s: %w", rgc.Name, err)
+		}
+	}
+
+	if err := rgc.TimeWindow.IsValid(); err != nil {
+		return fmt.Errorf("group %s: %w", rgc.Name, err)
+	}
+
 	if len(rgc.Servers) == 0 {
 		return fmt.Errorf("remotes cannot be empty")
 	}
@@ -61,5 +146,57 @@ func (rgc RemoteGroupsConfig) ensureNonDuplicatedRemoteNames() error {
 }
 
 func listSupportedFailureStrategies() []string {
-	return []string{StrategyFailAll, StrategyPartialResponse}
+	return []string{StrategyFailAll, StrategyPartialResponse, StrategyQuorum}
+}
+
+func listSupportedReadModes() []string {
+	return []string{ReadModeAll, ReadModeFailover, ReadModeRoundRobin, ReadModeFastest}
+}
+
+// AttemptTimeoutDuration returns the parsed attempt timeout, or zero when it is not set
+func (fc FailoverConfig) AttemptTimeoutDuration() time.Duration {
+	if fc.AttemptTimeout == "" {
+		return 0
+	}
+
+	parsed, err := ParseDuration(fc.AttemptTimeout)
+	if err != nil {
+		panic(err)
+	}
+
+	return parsed
+}
+
+func (qc QuorumConfig) IsValid(remotesCount int) error {
+	if qc.MinSuccessful != 0 && qc.MinSuccessfulPercentage != 0 {
+		return fmt.Errorf("quorum min_successful and min_successful_percentage cannot be both set")
+	}
+
+	if qc.MinSuccessful < 0 {
+		return fmt.Errorf("quorum min_successful cannot be < 0")
+	}
+
+	if qc.MinSuccessful > remotesCount {
+		return fmt.Errorf("quorum min_successful cannot be bigger than the number of remotes (%d)", remotesCount)
+	}
+
+	if qc.MinSuccessfulPercentage < 0 || qc.MinSuccessfulPercentage > 100 {
+		return fmt.Errorf("quorum min_successful_percentage should be between 0 and 100")
+	}
+
+	return nil
+}
+
+// MinSuccessfulFor returns how many remotes (out of remotesCount) need to answer successfully
+// for the quorum to be reached.
+func (qc QuorumConfig) MinSuccessfulFor(remotesCount int) int {
+	if qc.MinSuccessful > 0 {
+		return qc.MinSuccessful
+	}
+
+	if qc.MinSuccessfulPercentage > 0 {
+		return max(1, int(math.Ceil(float64(remotesCount)*qc.MinSuccessfulPercentage/100)))
+	}
+
+	return remotesCount/2 + 1
 }
@@ -18,6 +18,7 @@ func TestOnQueryFailAcceptSpecificValues(t *testing.T) {
 		{"partial_response", false},
 		{"Partial_Response", false},
 		{"FAIL_ALL", false},
+		{"quorum", false},
 
 		{"FAILALL", true},
 		{"partialresponse", true},
@@ -66,6 +67,12 @@ func TestGroupsValidate(t *testing.T) {
 			{Name: "some name", Address: "http://non-existent.something"},
 			{Name: "some name", Address: "http://non-existent.something"}}}
 	require.Error(t, sut.IsValid(), "should error when remotes have the same name")
+
+	sut = config.RemoteGroupsConfig{Name: "group 1", OnQueryFailStrategy: "fail_all",
+		MergeConf: config.MergeStrategyConfig{Strategy: "reconcile", Function: "anything"},
+		Servers: []config.RemoteConfig{
+			{Name: "some name", Address: "http://non-existent.something"}}}
+	require.Error(t, sut.IsValid(), "should error when the merge strategy is invalid")
 }
 
 func TestOnQueryFailDefaultValues(t *testing.T) {
@@ -76,4 +83,171 @@ func TestOnQueryFailDefaultValues(t *testing.T) {
 		"query failure strategy should be set to %s if the provided value is empty",
 		config.StrategyFailAll,
 	)
+
+	assert.Equalf(t, config.DefaultMergeStrategyType, newSut.MergeConf.Strategy,
+		"merge strategy should be set to %s if the provided value is empty",
+		config.DefaultMergeStrategyType,
+	)
+}
+
+func TestQuorumValidate(t *testing.T) {
+	servers := []config.RemoteConfig{
+		{Name: "some name", Address: "http://non-existent.something"},
+		{Name: "some name 2", Address: "http://non-existent.something"},
+		{Name: "some name 3", Address: "http://non-existent.something"},
+	}
+
+	testCases := []struct {
+		quorumConf  config.QuorumConfig
+		shouldError bool
+	}{
+		{config.QuorumConfig{}, false},
+		{config.QuorumConfig{MinSuccessful: 2}, false},
+		{config.QuorumConfig{MinSuccessful: 3}, false},
+		{config.QuorumConfig{MinSuccessfulPercentage: 66.6}, false},
+		{config.QuorumConfig{MinSuccessfulPercentage: 100}, false},
+
+		{config.QuorumConfig{MinSuccessful: 4}, true},
+		{config.QuorumConfig{MinSuccessful: -1}, true},
+		{config.QuorumConfig{MinSuccessfulPercentage: 101}, true},
+		{config.QuorumConfig{MinSuccessfulPercentage: -1}, true},
+		{config.QuorumConfig{MinSuccessful: 2, MinSuccessfulPercentage: 50}, true},
+	}
+
+	for _, tc := range testCases {
+		sut := config.RemoteGroupsConfig{Name: "group 1", OnQueryFailStrategy: "quorum",
+			QuorumConf: tc.quorumConf, Servers: servers}
+		err := sut.IsValid()
+
+		if tc.shouldError {
+			assert.Error(t, err, "quorum %v should result in error when calling Validate", tc.quorumConf)
+		} else {
+			assert.NoError(t, err, "quorum %v should NOT result in error when calling Validate", tc.quorumConf)
+		}
+	}
+}
+
+func TestQuorumMinSuccessfulFor(t *testing.T) {
+	testCases := []struct {
+		quorumConf   config.QuorumConfig
+		remotesCount int
+		expected     int
+	}{
+		{config.QuorumConfig{}, 3, 2},
+		{config.QuorumConfig{}, 4, 3},
+		{config.QuorumConfig{}, 1, 1},
+		{config.QuorumConfig{MinSuccessful: 1}, 3, 1},
+		{config.QuorumConfig{MinSuccessfulPercentage: 50}, 3, 2},
+		{config.QuorumConfig{MinSuccessfulPercentage: 100}, 3, 3},
+		{config.QuorumConfig{MinSuccessfulPercentage: 1}, 3, 1},
+	}
+
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expected, tc.quorumConf.MinSuccessfulFor(tc.remotesCount),
+			"quorum %v with %d remotes should need %d successful answers", tc.quorumConf, tc.remotesCount, tc.expected)
+	}
+}
+
+func TestReadModeValidate(t *testing.T) {
+	servers := []config.RemoteConfig{{Name: "some name", Address: "http://non-existent.something"}}
+
+	testCases := []struct {
+		readMode       string
+		attemptTimeout string
+		shouldError    bool
+	}{
+		{"", "", false},
+		{"all", "", false},
+		{"failover", "", false},
+		{"Failover", "10s", false},
+		{"round_robin", "", false},
+		{"fastest", "", false},
+
+		{"anything", "", true},
+		{"failover", "10", true},
+		{"failover", "abc", true},
+	}
+
+	for _, tc := range testCases {
+		sut := config.RemoteGroupsConfig{Name: "group 1", OnQueryFailStrategy: "fail_all", Servers: servers,
+			ReadMode: tc.readMode, FailoverConf: config.FailoverConfig{AttemptTimeout: tc.attemptTimeout}}
+		err := sut.IsValid()
+
+		if tc.shouldError {
+			assert.Error(t, err, "read mode %s (timeout %s) should result in error", tc.readMode, tc.attemptTimeout)
+		} else {
+			assert.NoError(t, err, "read mode %s (timeout %s) should NOT result in error", tc.readMode, tc.attemptTimeout)
+		}
+	}
+
+	assert.Equal(t, config.DefaultReadMode, config.RemoteGroupsConfig{}.FillDefaults().ReadMode,
+		"read mode should be set to %s if the provided value is empty", config.DefaultReadMode)
+}
+
+func TestQuorumIsOnlyValidWhenReadingFromAllRemotes(t *testing.T) {
+	servers := []config.RemoteConfig{
+		{Name: "remote 1", Address: "http://non-existent.something"},
+		{Name: "remote 2", Address: "http://non-existent.something"},
+	}
+
+	testCases := []struct {
+		readMode    string
+		shouldError bool
+	}{
+		{"", false},
+		{"all", false},
+		{"failover", true},
+		{"round_robin", true},
+		{"Fastest", true},
+	}
+
+	for _, tc := range testCases {
+		sut := config.RemoteGroupsConfig{Name: "group 1", OnQueryFailStrategy: "quorum", Servers: servers,
+			ReadMode: tc.readMode}
+		err := sut.IsValid()
+
+		if tc.shouldError {
+			assert.Error(t, err, "quorum with read mode %s should result in error", tc.readMode)
+		} else {
+			assert.NoError(t, err, "quorum with read mode %s should NOT result in error", tc.readMode)
+		}
+	}
+}
+
+func TestTimeWindowValidate(t *testing.T) {
+	testCases := []struct {
+		window      config.TimeWindowConfig
+		shouldError bool
+	}{
+		{config.TimeWindowConfig{}, false},
+		{config.TimeWindowConfig{Start: "now-6h", End: "now"}, false},
+		{config.TimeWindowConfig{Start: "1136239445"}, false},
+		{config.TimeWindowConfig{End: "1996-12-19T16:39:57-08:00"}, false},
+		{config.TimeWindowConfig{Start: "yesterday"}, true},
+		{config.TimeWindowConfig{End: "now-"}, true},
+		{config.TimeWindowConfig{Start: "now", End: "now-1d"}, true},
+	}
+
+	for _, tc := range testCases {
+		sut := config.RemoteGroupsConfig{Name: "group 1", OnQueryFailStrategy: "fail_all", TimeWindow: tc.window,
+			Servers: []config.RemoteConfig{{Name: "some name", Address: "http://non-existent.something"}}}
+		if tc.shouldError {
+			assert.Error(t, sut.IsValid(), "time window %v should result in error", tc.window)
+		} else {
+			assert.NoError(t, sut.IsValid(), "time window %v should NOT result in error", tc.window)
+		}
+	}
+}
+
+func TestRemotesWithoutATimeWindowUseTheOneOfTheGroup(t *testing.T) {
+	groupWindow := config.TimeWindowConfig{Start: "now-6h"}
+	remoteWindow := config.TimeWindowConfig{Start: "now-30d", End: "now-6h"}
+	sut := config.RemoteGroupsConfig{Name: "group 1", TimeWindow: groupWindow,
+		Servers: []config.RemoteConfig{
+			{Name: "recent", Address: "http://non-existent.something"},
+			{Name: "old", Address: "http://non-existent.something", TimeWindowConf: remoteWindow},
+		}}.FillDefaults()
+
+	assert.Equal(t, groupWindow, sut.Servers[0].TimeWindowConf, "should use the time window of the group")
+	assert.Equal(t, remoteWindow, sut.Servers[1].TimeWindowConf, "should keep the time window of the remote")
 }
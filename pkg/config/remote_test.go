@@ -28,3 +28,34 @@ func TestRemoteValidate(t *testing.T) {
 	err = sut.IsValid()
 	require.NoError(t, err, "should NOT return error when address starts with http:// or https://")
 }
+
+func TestRemoteChunkingValidate(t *testing.T) {
+	sut := config.RemoteConfig{Name: "a name", Address: "http://something", MaxQueryRange: "1d",
+		MaxPointsPerSeries: 11000, ChunkParallelism: 2}
+	err := sut.IsValid()
+	require.NoError(t, err, "should NOT return error when max_query_range, max_points_per_series and chunk_parallelism are valid")
+
+	sut = config.RemoteConfig{Name: "a name", Address: "http://something", MaxQueryRange: "abc"}
+	err = sut.IsValid()
+	require.Error(t, err, "should return error when max_query_range is not a duration")
+
+	sut = config.RemoteConfig{Name: "a name", Address: "http://something", MaxQueryRange: "0s"}
+	err = sut.IsValid()
+	require.Error(t, err, "should return error when max_query_range is zero")
+
+	sut = config.RemoteConfig{Name: "a name", Address: "http://something", MaxPointsPerSeries: -1}
+	err = sut.IsValid()
+	require.Error(t, err, "should return error when max_points_per_series is negative")
+
+	sut = config.RemoteConfig{Name: "a name", Address: "http://something", ChunkParallelism: -1}
+	err = sut.IsValid()
+	require.Error(t, err, "should return error when chunk_parallelism is negative")
+}
+
+func TestRemoteDefaultValues(t *testing.T) {
+	sut := config.RemoteConfig{}.FillDefaults()
+
+	require.Equal(t, config.DefaultRemoteChunkParallelism, sut.ChunkParallelism,
+		"chunk_parallelism should be set to %d if the provided value is empty", config.DefaultRemoteChunkParallelism)
+	require.Zero(t, sut.MaxQueryRangeDuration(), "max_query_range should be unlimited by default")
+}
@@ -0,0 +1,54 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+const DefaultResultsCacheMaxSizeBytes = 100 * 1024 * 1024 // 100MB
+const DefaultResultsCacheMaxFreshness = "1m"
+
+// ResultsCacheConfig configures the cache of range query results. It is disabled by default.
+type ResultsCacheConfig struct {
+	Enabled      bool   `yaml:"enabled"`
+	MaxSizeBytes int    `yaml:"max_size_bytes"`
+	MaxFreshness string `yaml:"max_freshness"`
+}
+
+func (rcc ResultsCacheConfig) FillDefaults() ResultsCacheConfig {
+	if rcc.MaxSizeBytes == 0 {
+		rcc.MaxSizeBytes = DefaultResultsCacheMaxSizeBytes
+	}
+
+	if rcc.MaxFreshness == "" {
+		rcc.MaxFreshness = DefaultResultsCacheMaxFreshness
+	}
+
+	return rcc
+}
+
+func (rcc ResultsCacheConfig) IsValid() error {
+	if !rcc.Enabled {
+		return nil
+	}
+
+	if rcc.MaxSizeBytes <= 0 {
+		return fmt.Errorf("results_cache max_size_bytes cannot be <= 0")
+	}
+
+	_, err := ParseDuration(rcc.MaxFreshness)
+	if err != nil {
+		return fmt.Errorf("results_cache max_freshness is invalid: %w", err)
+	}
+
+	return nil
+}
+
+func (rcc ResultsCacheConfig) MaxFreshnessDuration() time.Duration {
+	parsed, err := ParseDuration(rcc.MaxFreshness)
+	if err != nil {
+		panic(err)
+	}
+
+	return parsed
+}
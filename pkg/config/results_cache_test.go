@@ -0,0 +1,34 @@
+package config_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jademcosta/graviola/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResultsCacheValidate(t *testing.T) {
+	sut := config.ResultsCacheConfig{}
+	require.NoError(t, sut.IsValid(), "disabled cache should be valid")
+
+	sut = config.ResultsCacheConfig{Enabled: true}.FillDefaults()
+	require.NoError(t, sut.IsValid(), "filled with defaults should be valid")
+
+	sut = config.ResultsCacheConfig{Enabled: true, MaxSizeBytes: -1}.FillDefaults()
+	require.Error(t, sut.IsValid(), "should return error when max_size_bytes is < 0")
+
+	sut = config.ResultsCacheConfig{Enabled: true, MaxFreshness: "10"}.FillDefaults()
+	require.Error(t, sut.IsValid(), "should return error when max_freshness has no unit")
+
+	sut = config.ResultsCacheConfig{Enabled: true, MaxFreshness: "0s"}.FillDefaults()
+	require.NoError(t, sut.IsValid(), "should accept a zero max_freshness")
+}
+
+func TestResultsCacheFillDefaults(t *testing.T) {
+	sut := config.ResultsCacheConfig{}.FillDefaults()
+	assert.False(t, sut.Enabled, "should be disabled by default")
+	assert.Equal(t, config.DefaultResultsCacheMaxSizeBytes, sut.MaxSizeBytes, "should fill the max size")
+	assert.Equal(t, time.Minute, sut.MaxFreshnessDuration(), "should fill the max freshness")
+}
@@ -1,13 +1,72 @@
 package config
 
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// TimeWindowConfig is the time range a remote has data for, so it is not queried outside of it.
+// Start and End accept relative (now-4d), Unix and RFC3339 times. An empty Start or End leaves
+// the window open on that side.
 type TimeWindowConfig struct {
 	Start string `yaml:"start"`
 	End   string `yaml:"end"`
 }
 
-//FIXME: implement
+// IsSet tells if the window limits the time range at all
+func (tWindowConf TimeWindowConfig) IsSet() bool {
+	return tWindowConf.Start != "" || tWindowConf.End != ""
+}
 
 func (tWindowConf TimeWindowConfig) IsValid() error {
+	now := time.Now()
+	var start, end time.Time
+
+	if tWindowConf.Start != "" {
+		parsed, err := ParseDate(tWindowConf.Start, now)
+		if err != nil {
+			return fmt.Errorf("time_window start is invalid: %w", err)
+		}
+		start = parsed
+	}
+
+	if tWindowConf.End != "" {
+		parsed, err := ParseDate(tWindowConf.End, now)
+		if err != nil {
+			return fmt.Errorf("time_window end is invalid: %w", err)
+		}
+		end = parsed
+	}
+
+	if tWindowConf.Start != "" && tWindowConf.End != "" && start.After(end) {
+		return fmt.Errorf("time_window start cannot be after its end")
+	}
 
 	return nil
 }
+
+// Bounds returns the start and end of the window at the given time, in millis. The sides that
+// are open are math.MinInt64 and math.MaxInt64.
+func (tWindowConf TimeWindowConfig) Bounds(now time.Time) (int64, int64) {
+	start := int64(math.MinInt64)
+	end := int64(math.MaxInt64)
+
+	if tWindowConf.Start != "" {
+		parsed, err := ParseDate(tWindowConf.Start, now)
+		if err != nil {
+			panic(err)
+		}
+		start = parsed.UnixMilli()
+	}
+
+	if tWindowConf.End != "" {
+		parsed, err := ParseDate(tWindowConf.End, now)
+		if err != nil {
+			panic(err)
+		}
+		end = parsed.UnixMilli()
+	}
+
+	return start, end
+}
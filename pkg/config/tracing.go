@@ -0,0 +1,116 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+	"time"
+)
+
+const (
+	TracingExporterOTLP   = "otlp"
+	TracingExporterStdout = "stdout"
+	TracingExporterFile   = "file"
+
+	TracingOTLPProtocolGRPC = "grpc"
+	TracingOTLPProtocolHTTP = "http"
+)
+
+const (
+	DefaultTracingServiceName   = "graviola"
+	DefaultTracingExporter      = TracingExporterOTLP
+	DefaultTracingSamplingRatio = 1.0
+	DefaultTracingOTLPProtocol  = TracingOTLPProtocolGRPC
+	DefaultTracingOTLPTimeout   = "10s"
+)
+
+var tracingExporters = []string{TracingExporterOTLP, TracingExporterStdout, TracingExporterFile}
+var tracingOTLPProtocols = []string{TracingOTLPProtocolGRPC, TracingOTLPProtocolHTTP}
+
+// TracingConfig configures the OpenTelemetry traces of the requests, from the HTTP API down to
+// the remotes. It is disabled by default.
+type TracingConfig struct {
+	Enabled     bool   `yaml:"enabled"`
+	ServiceName string `yaml:"service_name"`
+	// Exporter is where the spans are sent to: an OTLP collector, the stdout or a file. The last
+	// two are meant for local debugging.
+	Exporter string `yaml:"exporter"`
+	// SamplingRatio is the fraction of the traces that are recorded. When the caller informs
+	// whether the trace is sampled (on the traceparent header), its decision is used instead.
+	SamplingRatio float64           `yaml:"sampling_ratio"`
+	OTLPConf      TracingOTLPConfig `yaml:"otlp"`
+	// FilePath is the file the spans are appended to, when the exporter is "file"
+	FilePath string `yaml:"file_path"`
+}
+
+type TracingOTLPConfig struct {
+	// Endpoint is the host:port of the collector
+	Endpoint string            `yaml:"endpoint"`
+	Protocol string            `yaml:"protocol"`
+	Insecure bool              `yaml:"insecure"`
+	Headers  map[string]string `yaml:"headers"`
+	Timeout  string            `yaml:"timeout"`
+}
+
+func (tracingConf TracingConfig) FillDefaults() TracingConfig {
+	if tracingConf.ServiceName == "" {
+		tracingConf.ServiceName = DefaultTracingServiceName
+	}
+
+	if tracingConf.Exporter == "" {
+		tracingConf.Exporter = DefaultTracingExporter
+	}
+
+	if tracingConf.SamplingRatio == 0 {
+		tracingConf.SamplingRatio = DefaultTracingSamplingRatio
+	}
+
+	if tracingConf.OTLPConf.Protocol == "" {
+		tracingConf.OTLPConf.Protocol = DefaultTracingOTLPProtocol
+	}
+
+	if tracingConf.OTLPConf.Timeout == "" {
+		tracingConf.OTLPConf.Timeout = DefaultTracingOTLPTimeout
+	}
+
+	return tracingConf
+}
+
+func (tracingConf TracingConfig) IsValid() error {
+	if !tracingConf.Enabled {
+		return nil
+	}
+
+	if !slices.Contains(tracingExporters, tracingConf.Exporter) {
+		return fmt.Errorf("tracing exporter should be one of %v", tracingExporters)
+	}
+
+	if tracingConf.SamplingRatio <= 0 || tracingConf.SamplingRatio > 1 {
+		return fmt.Errorf("tracing sampling_ratio should be > 0 and <= 1")
+	}
+
+	switch tracingConf.Exporter {
+	case TracingExporterOTLP:
+		if tracingConf.OTLPConf.Endpoint == "" {
+			return fmt.Errorf("tracing otlp endpoint cannot be empty")
+		}
+
+		if !slices.Contains(tracingOTLPProtocols, tracingConf.OTLPConf.Protocol) {
+			return fmt.Errorf("tracing otlp protocol should be one of %v", tracingOTLPProtocols)
+		}
+
+		_, err := ParseDuration(tracingConf.OTLPConf.Timeout)
+		if err != nil {
+			return fmt.Errorf("tracing otlp timeout is invalid: %w", err)
+		}
+	case TracingExporterFile:
+		if tracingConf.FilePath == "" {
+			return fmt.Errorf("tracing file_path cannot be empty when the exporter is file")
+		}
+	}
+
+	return nil
+}
+
+func (otlpConf TracingOTLPConfig) TimeoutDuration() time.Duration {
+	return parseOptionalDuration(otlpConf.Timeout)
+}
@@ -0,0 +1,47 @@
+package config_test
+
+import (
+	"testing"
+
+	"github.com/jademcosta/graviola/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTracingDefaultValues(t *testing.T) {
+	sut := config.TracingConfig{}.FillDefaults()
+
+	assert.Equal(t, config.DefaultTracingServiceName, sut.ServiceName, "should have a default service name")
+	assert.Equal(t, config.TracingExporterOTLP, sut.Exporter, "should export to OTLP by default")
+	assert.InDelta(t, 1.0, sut.SamplingRatio, 0.0001, "should sample every trace by default")
+	assert.Equal(t, config.TracingOTLPProtocolGRPC, sut.OTLPConf.Protocol, "should use gRPC by default")
+	assert.Equal(t, config.DefaultTracingOTLPTimeout, sut.OTLPConf.Timeout, "should have a default timeout")
+}
+
+func TestTracingValidate(t *testing.T) {
+	require.NoError(t, config.TracingConfig{}.FillDefaults().IsValid(), "should be valid when disabled")
+
+	sut := config.TracingConfig{Enabled: true, OTLPConf: config.TracingOTLPConfig{Endpoint: "otel:4317"}}.FillDefaults()
+	require.NoError(t, sut.IsValid(), "should return NO error when every option is correct")
+
+	sut.OTLPConf.Endpoint = ""
+	require.Error(t, sut.IsValid(), "should return error when the otlp endpoint is empty")
+
+	sut.OTLPConf.Endpoint = "otel:4317"
+	sut.OTLPConf.Protocol = "thrift"
+	require.Error(t, sut.IsValid(), "should return error when the otlp protocol is unknown")
+
+	sut.OTLPConf.Protocol = config.TracingOTLPProtocolHTTP
+	sut.SamplingRatio = 1.5
+	require.Error(t, sut.IsValid(), "should return error when the sampling ratio is above 1")
+
+	sut.SamplingRatio = 0.1
+	sut.Exporter = config.TracingExporterFile
+	require.Error(t, sut.IsValid(), "should return error when the file exporter has no path")
+
+	sut.FilePath = "/tmp/spans.json"
+	require.NoError(t, sut.IsValid(), "should return NO error when the file exporter has a path")
+
+	sut.Exporter = "jaeger"
+	require.Error(t, sut.IsValid(), "should return error when the exporter is unknown")
+}
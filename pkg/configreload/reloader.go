@@ -0,0 +1,167 @@
+package configreload
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/jademcosta/graviola/pkg/config"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	resultSuccess = "success"
+	resultFailure = "failure"
+)
+
+var runOnceReloaderO11y sync.Once
+var lastReloadSuccessful prometheus.Gauge
+var lastReloadSuccessTimestamp prometheus.Gauge
+var reloadsTotal *prometheus.CounterVec
+
+// Reloader loads the config file again and applies it, while Graviola runs. Reloads never run
+// at the same time, and when the new config can't be loaded or applied the current one is kept.
+type Reloader struct {
+	logger *slog.Logger
+	path   string
+	apply  func(config.GraviolaConfig) error
+	now    func() time.Time
+
+	mu sync.Mutex
+	// checksum is of the content of the file on the last reload, so the watcher only reloads
+	// it when it changes
+	checksum [sha256.Size]byte
+}
+
+// NewReloader creates the reloader of the config file on path. The apply function receives the
+// new config, already validated, and returns an error when it can't be applied.
+func NewReloader(
+	logger *slog.Logger, metricz *prometheus.Registry, path string, apply func(config.GraviolaConfig) error,
+	now func() time.Time,
+) *Reloader {
+	runOnceReloaderO11y.Do(func() {
+		lastReloadSuccessful = prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "graviola",
+			Subsystem: "config",
+			Name:      "last_reload_successful",
+			Help:      "Whether the last config reload succeeded (1) or failed and the previous config was kept (0).",
+		})
+		lastReloadSuccessTimestamp = prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "graviola",
+			Subsystem: "config",
+			Name:      "last_reload_success_timestamp_seconds",
+			Help:      "Timestamp of the last config load or reload that succeeded.",
+		})
+		reloadsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "graviola",
+			Subsystem: "config",
+			Name:      "reloads_total",
+			Help:      "Counter of config reloads, by result (success or failure).",
+		},
+			[]string{"result"})
+
+		if metricz != nil {
+			metricz.MustRegister(lastReloadSuccessful, lastReloadSuccessTimestamp, reloadsTotal)
+		}
+	})
+
+	reloader := &Reloader{
+		logger: logger.With("component", "config_reload"),
+		path:   path,
+		apply:  apply,
+		now:    now,
+	}
+
+	// The config was loaded when Graviola started
+	content, err := os.ReadFile(path)
+	if err == nil {
+		reloader.checksum = sha256.Sum256(content)
+	}
+	lastReloadSuccessful.Set(1)
+	lastReloadSuccessTimestamp.Set(float64(now().Unix()))
+
+	return reloader
+}
+
+// Reload loads the config file and applies it
+func (reloader *Reloader) Reload() error {
+	reloader.mu.Lock()
+	defer reloader.mu.Unlock()
+
+	err := reloader.reload()
+	if err != nil {
+		lastReloadSuccessful.Set(0)
+		reloadsTotal.WithLabelValues(resultFailure).Inc()
+		reloader.logger.Error("error reloading the config, keeping the current one", "error", err)
+		return err
+	}
+
+	lastReloadSuccessful.Set(1)
+	lastReloadSuccessTimestamp.Set(float64(reloader.now().Unix()))
+	reloadsTotal.WithLabelValues(resultSuccess).Inc()
+	reloader.logger.Info("config reloaded", "path", reloader.path)
+	return nil
+}
+
+func (reloader *Reloader) reload() error {
+	content, err := os.ReadFile(reloader.path)
+	if err != nil {
+		return fmt.Errorf("error reading config file: %w", err)
+	}
+	// A broken file is not reloaded again by the watcher until it changes
+	reloader.checksum = sha256.Sum256(content)
+
+	conf, err := config.Parse(content)
+	if err != nil {
+		return fmt.Errorf("error parsing config: %w", err)
+	}
+
+	conf = conf.FillDefaults()
+
+	err = conf.IsValid()
+	if err != nil {
+		return fmt.Errorf("error validating config: %w", err)
+	}
+
+	err = reloader.apply(conf)
+	if err != nil {
+		return fmt.Errorf("error applying config: %w", err)
+	}
+
+	return nil
+}
+
+// Watch checks the config file for changes on every interval, and reloads it when it changes.
+// It returns when the context is done.
+func (reloader *Reloader) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if reloader.changed() {
+				_ = reloader.Reload()
+			}
+		}
+	}
+}
+
+func (reloader *Reloader) changed() bool {
+	content, err := os.ReadFile(reloader.path)
+	if err != nil {
+		reloader.logger.Warn("error checking the config file for changes", "error", err)
+		return false
+	}
+
+	reloader.mu.Lock()
+	defer reloader.mu.Unlock()
+
+	return sha256.Sum256(content) != reloader.checksum
+}
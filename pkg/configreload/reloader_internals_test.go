@@ -0,0 +1,120 @@
+package configreload
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jademcosta/graviola/pkg/config"
+	"github.com/jademcosta/graviola/pkg/graviolalog"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type appliedConfigs struct {
+	mu      sync.Mutex
+	configs []config.GraviolaConfig
+	err     error
+}
+
+func (applied *appliedConfigs) apply(conf config.GraviolaConfig) error {
+	applied.mu.Lock()
+	defer applied.mu.Unlock()
+
+	if applied.err != nil {
+		return applied.err
+	}
+	applied.configs = append(applied.configs, conf)
+	return nil
+}
+
+func (applied *appliedConfigs) remoteNames() []string {
+	applied.mu.Lock()
+	defer applied.mu.Unlock()
+
+	names := make([]string, 0, len(applied.configs))
+	for _, conf := range applied.configs {
+		names = append(names, conf.StoragesConf.Groups[0].Servers[0].Name)
+	}
+	return names
+}
+
+func writeConfig(t *testing.T, path string, content string) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600), "should write the config file")
+}
+
+func newSut(t *testing.T, applied *appliedConfigs) (*Reloader, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yml")
+	writeConfig(t, path, fmtConfig("prom-1"))
+
+	return NewReloader(graviolalog.NewNoopLogger(), prometheus.NewRegistry(), path, applied.apply, time.Now), path
+}
+
+func fmtConfig(remoteName string) string {
+	return "storages:\n  groups:\n    - name: main\n      remotes:\n        - name: " + remoteName +
+		"\n          address: http://localhost:9090\n"
+}
+
+func TestReloadAppliesTheNewConfigWithDefaults(t *testing.T) {
+	applied := &appliedConfigs{}
+	sut, path := newSut(t, applied)
+
+	writeConfig(t, path, fmtConfig("prom-2"))
+	require.NoError(t, sut.Reload(), "should reload the config")
+
+	require.Equal(t, []string{"prom-2"}, applied.remoteNames(), "should apply the new config")
+	assert.Equal(t, config.DefaultPort, applied.configs[0].APIConf.Port, "should fill the defaults of the new config")
+	assert.Equal(t, 1.0, testutil.ToFloat64(lastReloadSuccessful), "should tell the last reload succeeded")
+}
+
+func TestAFailedReloadKeepsTheCurrentConfig(t *testing.T) {
+	applied := &appliedConfigs{}
+	sut, path := newSut(t, applied)
+	failuresBefore := testutil.ToFloat64(reloadsTotal.WithLabelValues(resultFailure))
+
+	writeConfig(t, path, "storages: [broken")
+	assert.Error(t, sut.Reload(), "should fail to reload a config that can't be parsed")
+	assert.Equal(t, 0.0, testutil.ToFloat64(lastReloadSuccessful), "should tell the last reload failed")
+
+	writeConfig(t, path, "storages:\n  groups: []\n")
+	assert.Error(t, sut.Reload(), "should fail to reload an invalid config")
+
+	applied.err = errors.New("remote can't be created")
+	writeConfig(t, path, fmtConfig("prom-2"))
+	assert.Error(t, sut.Reload(), "should fail when the config can't be applied")
+
+	assert.Empty(t, applied.remoteNames(), "should not apply the configs that failed")
+	assert.Equal(t, failuresBefore+3, testutil.ToFloat64(reloadsTotal.WithLabelValues(resultFailure)),
+		"should count the failed reloads")
+
+	applied.err = nil
+	require.NoError(t, sut.Reload(), "should reload once the config is fixed")
+	assert.Equal(t, 1.0, testutil.ToFloat64(lastReloadSuccessful), "should tell the last reload succeeded")
+}
+
+func TestWatchReloadsTheConfigWhenTheFileChanges(t *testing.T) {
+	applied := &appliedConfigs{}
+	sut, path := newSut(t, applied)
+
+	ctx, cancelFn := context.WithCancel(context.Background())
+	defer cancelFn()
+	go sut.Watch(ctx, 10*time.Millisecond)
+
+	time.Sleep(50 * time.Millisecond)
+	assert.Empty(t, applied.remoteNames(), "should not reload the file while it doesn't change")
+
+	writeConfig(t, path, fmtConfig("prom-2"))
+	assert.Eventually(t, func() bool { return len(applied.remoteNames()) == 1 }, time.Second, 10*time.Millisecond,
+		"should reload the file when it changes")
+
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, []string{"prom-2"}, applied.remoteNames(), "should reload each change only once")
+}
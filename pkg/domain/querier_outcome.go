@@ -0,0 +1,63 @@
+package domain
+
+import (
+	"errors"
+
+	"github.com/prometheus/prometheus/util/annotations"
+)
+
+// QuerierOutcome is the result of a single querier (a remote or a group) after a query was sent
+// to it. It allows the callers to know which querier failed, instead of having only a joined
+// error for all of them.
+type QuerierOutcome struct {
+	Name string
+	Err  error
+}
+
+func (outcome QuerierOutcome) Failed() bool {
+	return outcome.Err != nil
+}
+
+// FailedQuerierNames returns the names of the queriers that failed, in the same order as
+// the outcomes were given.
+func FailedQuerierNames(outcomes []QuerierOutcome) []string {
+	names := make([]string, 0)
+	for _, outcome := range outcomes {
+		if outcome.Failed() {
+			names = append(names, outcome.Name)
+		}
+	}
+
+	return names
+}
+
+// PartialResponseWarning is the annotation added when some queriers failed but the answer of
+// the others was returned anyway. It allows the callers to tell partial responses apart from
+// other annotations.
+type PartialResponseWarning struct {
+	Err error
+}
+
+func NewPartialResponseWarning(err error) *PartialResponseWarning {
+	return &PartialResponseWarning{Err: err}
+}
+
+func (warning *PartialResponseWarning) Error() string {
+	return warning.Err.Error()
+}
+
+func (warning *PartialResponseWarning) Unwrap() error {
+	return warning.Err
+}
+
+// HasPartialResponseWarning returns true when any of the annotations tells the response is partial
+func HasPartialResponseWarning(annots annotations.Annotations) bool {
+	var warning *PartialResponseWarning
+	for _, annotation := range annots {
+		if errors.As(annotation, &warning) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,182 @@
+package domain
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/prometheus/prometheus/util/annotations"
+)
+
+const remoteAnnotationSourceSeparator = "/"
+
+// RemoteAnnotation is a warning or an info that a remote sent on its answer. It keeps the
+// queriers it came from (e.g. "group1/remote1"), so the user knows where it was generated.
+// The same message coming from different sources is kept as a single annotation, listing
+// all of them.
+type RemoteAnnotation struct {
+	Message string
+	Info    bool
+	Sources []string
+}
+
+func NewRemoteWarning(message string) *RemoteAnnotation {
+	return &RemoteAnnotation{Message: message}
+}
+
+func NewRemoteInfo(message string) *RemoteAnnotation {
+	return &RemoteAnnotation{Message: message, Info: true}
+}
+
+func (ra *RemoteAnnotation) Error() string {
+	if len(ra.Sources) == 0 {
+		return ra.Message
+	}
+	return fmt.Sprintf("%s: %s", strings.Join(ra.Sources, ", "), ra.Message)
+}
+
+// Unwrap allows the Prometheus code to tell apart warnings and infos
+func (ra *RemoteAnnotation) Unwrap() error {
+	if ra.Info {
+		return annotations.PromQLInfo
+	}
+	return annotations.PromQLWarning
+}
+
+// key is used on the annotations map, so the same message from different sources has the
+// same key.
+func (ra *RemoteAnnotation) key() string {
+	if ra.Info {
+		return "remote info: " + ra.Message
+	}
+	return "remote warning: " + ra.Message
+}
+
+// attributedTo returns a copy of the annotation, with the source prefixed to its sources
+func (ra *RemoteAnnotation) attributedTo(source string) *RemoteAnnotation {
+	sources := make([]string, 0, max(1, len(ra.Sources)))
+	for _, existing := range ra.Sources {
+		sources = append(sources, source+remoteAnnotationSourceSeparator+existing)
+	}
+	if len(sources) == 0 {
+		sources = append(sources, source)
+	}
+
+	return &RemoteAnnotation{Message: ra.Message, Info: ra.Info, Sources: sources}
+}
+
+// mergedWith returns a copy of the annotation with the sources of both, sorted and deduplicated
+func (ra *RemoteAnnotation) mergedWith(other *RemoteAnnotation) *RemoteAnnotation {
+	sources := make([]string, 0, len(ra.Sources)+len(other.Sources))
+	sources = append(sources, ra.Sources...)
+	sources = append(sources, other.Sources...)
+	sort.Strings(sources)
+
+	return &RemoteAnnotation{Message: ra.Message, Info: ra.Info, Sources: slices.Compact(sources)}
+}
+
+// AddRemoteAnnotation adds the annotation in-place. If the same message was already added by
+// another source, both are kept as a single annotation.
+func AddRemoteAnnotation(annots *annotations.Annotations, annotation *RemoteAnnotation) annotations.Annotations {
+	if *annots == nil {
+		*annots = annotations.Annotations{}
+	}
+
+	key := annotation.key()
+	existing, ok := (*annots)[key].(*RemoteAnnotation)
+	if ok {
+		annotation = existing.mergedWith(annotation)
+	}
+
+	(*annots)[key] = annotation
+	return *annots
+}
+
+// MergeAnnotations works like annotations.Merge, but remote annotations with the same message
+// are deduplicated instead of overwritten.
+func MergeAnnotations(annots *annotations.Annotations, other annotations.Annotations) annotations.Annotations {
+	if *annots == nil {
+		if other == nil {
+			return nil
+		}
+		*annots = annotations.Annotations{}
+	}
+
+	for key, annotation := range other {
+		remoteAnnotation, ok := annotation.(*RemoteAnnotation)
+		if ok {
+			AddRemoteAnnotation(annots, remoteAnnotation)
+			continue
+		}
+		(*annots)[key] = annotation
+	}
+
+	return *annots
+}
+
+// AttributeAnnotations returns a copy of the annotations, with the source prefixed on the
+// sources of the remote annotations. Other annotations are kept as they are.
+func AttributeAnnotations(annots annotations.Annotations, source string) annotations.Annotations {
+	if annots == nil {
+		return nil
+	}
+
+	attributed := make(annotations.Annotations, len(annots))
+	for key, annotation := range annots {
+		remoteAnnotation, ok := annotation.(*RemoteAnnotation)
+		if ok {
+			attributed[key] = remoteAnnotation.attributedTo(source)
+			continue
+		}
+		attributed[key] = annotation
+	}
+
+	return attributed
+}
+
+// LimitRemoteAnnotations returns a copy of the annotations keeping at most maxPerKind remote
+// warnings and maxPerKind remote infos. The ones left out are summarized in a single
+// annotation of each kind. Zero means no limit.
+func LimitRemoteAnnotations(annots annotations.Annotations, maxPerKind int) annotations.Annotations {
+	if annots == nil || maxPerKind <= 0 {
+		return annots
+	}
+
+	limited := make(annotations.Annotations, len(annots))
+	warningKeys := make([]string, 0)
+	infoKeys := make([]string, 0)
+
+	for key, annotation := range annots {
+		remoteAnnotation, ok := annotation.(*RemoteAnnotation)
+		switch {
+		case !ok:
+			limited[key] = annotation
+		case remoteAnnotation.Info:
+			infoKeys = append(infoKeys, key)
+		default:
+			warningKeys = append(warningKeys, key)
+		}
+	}
+
+	keepFirst(limited, annots, warningKeys, maxPerKind, NewRemoteWarning("%d more warnings from remotes were omitted"))
+	keepFirst(limited, annots, infoKeys, maxPerKind, NewRemoteInfo("%d more infos from remotes were omitted"))
+	return limited
+}
+
+// keepFirst copies the first maxAmount keys (in sorted order) to dst, and adds a summary of
+// the omitted ones, using summary.Message as format.
+func keepFirst(
+	dst annotations.Annotations, src annotations.Annotations, keys []string, maxAmount int,
+	summary *RemoteAnnotation,
+) {
+	sort.Strings(keys)
+	for idx, key := range keys {
+		if idx >= maxAmount {
+			summary.Message = fmt.Sprintf(summary.Message, len(keys)-maxAmount)
+			dst[summary.key()] = summary
+			return
+		}
+		dst[key] = src[key]
+	}
+}
@@ -0,0 +1,66 @@
+package domain_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/jademcosta/graviola/pkg/domain"
+	"github.com/prometheus/prometheus/util/annotations"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRemoteAnnotationsAreAttributedToTheirSources(t *testing.T) {
+	annots := *annotations.New()
+	domain.AddRemoteAnnotation(&annots, domain.NewRemoteWarning("some warning"))
+	domain.AddRemoteAnnotation(&annots, domain.NewRemoteInfo("some info"))
+
+	attributed := domain.AttributeAnnotations(domain.AttributeAnnotations(annots, "remote1"), "group1")
+
+	warnings, infos := attributed.AsStrings("", 0, 0)
+	assert.Equal(t, []string{"group1/remote1: some warning"}, warnings, "should prefix the group and remote")
+	assert.Equal(t, []string{"group1/remote1: some info"}, infos, "should prefix the group and remote")
+
+	warnings, _ = annots.AsStrings("", 0, 0)
+	assert.Equal(t, []string{"some warning"}, warnings, "should not change the original annotations")
+}
+
+func TestRemoteAnnotationsAreDeduplicatedAcrossSources(t *testing.T) {
+	fromRemote1 := *annotations.New()
+	domain.AddRemoteAnnotation(&fromRemote1, domain.NewRemoteWarning("same warning"))
+	fromRemote2 := *annotations.New()
+	domain.AddRemoteAnnotation(&fromRemote2, domain.NewRemoteWarning("same warning"))
+	domain.AddRemoteAnnotation(&fromRemote2, domain.NewRemoteInfo("same warning"))
+
+	merged := *annotations.New()
+	domain.MergeAnnotations(&merged, domain.AttributeAnnotations(fromRemote2, "remote2"))
+	domain.MergeAnnotations(&merged, domain.AttributeAnnotations(fromRemote1, "remote1"))
+	domain.MergeAnnotations(&merged, domain.AttributeAnnotations(fromRemote1, "remote1"))
+	domain.MergeAnnotations(&merged, annotations.Annotations{"other": errors.New("other")})
+
+	warnings, infos := merged.AsStrings("", 0, 0)
+	assert.ElementsMatch(t, []string{"remote1, remote2: same warning", "other"}, warnings,
+		"should keep a single warning naming all the sources")
+	assert.Equal(t, []string{"remote2: same warning"}, infos, "should not mix warnings and infos")
+}
+
+func TestLimitRemoteAnnotations(t *testing.T) {
+	annots := *annotations.New()
+	for idx := range 4 {
+		domain.AddRemoteAnnotation(&annots, domain.NewRemoteWarning(fmt.Sprintf("warning %d", idx)))
+	}
+	domain.AddRemoteAnnotation(&annots, domain.NewRemoteInfo("info"))
+	annots.Add(errors.New("not from a remote"))
+
+	limited := domain.LimitRemoteAnnotations(annots, 2)
+
+	warnings, infos := limited.AsStrings("", 0, 0)
+	require.Len(t, warnings, 4, "should keep 2 remote warnings, the summary and the non-remote one")
+	assert.Contains(t, warnings, "2 more warnings from remotes were omitted", "should summarize the omitted ones")
+	assert.Contains(t, warnings, "not from a remote", "should keep non-remote annotations")
+	assert.Equal(t, []string{"info"}, infos, "should keep the infos under the limit")
+	assert.Len(t, annots, 6, "should not change the original annotations")
+
+	assert.Equal(t, annots, domain.LimitRemoteAnnotations(annots, 0), "should not limit when zero")
+}
@@ -0,0 +1,22 @@
+package domain
+
+import "context"
+
+type timeRangeContextKey struct{}
+
+// TimeRange is the time range, in millis, that a querier was created for. The label requests
+// of the Prometheus interface don't carry it, so it travels on the context to the remotes.
+type TimeRange struct {
+	Start int64
+	End   int64
+}
+
+func NewTimeRangeContext(ctx context.Context, rng TimeRange) context.Context {
+	return context.WithValue(ctx, timeRangeContextKey{}, rng)
+}
+
+// TimeRangeFromContext returns the time range stored in the context, if any
+func TimeRangeFromContext(ctx context.Context) (TimeRange, bool) {
+	rng, ok := ctx.Value(timeRangeContextKey{}).(TimeRange)
+	return rng, ok
+}
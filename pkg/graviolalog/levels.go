@@ -0,0 +1,127 @@
+package graviolalog
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+
+	"github.com/jademcosta/graviola/pkg/config"
+)
+
+// componentKey is the attribute that tells which component wrote the log
+const componentKey = "component"
+
+// Levels are the log levels of Graviola, the default one and the ones of the components that
+// have a level of their own. They can be changed while Graviola is running.
+type Levels struct {
+	mu         sync.RWMutex
+	base       slog.Level
+	components map[string]slog.Level
+}
+
+func NewLevels(conf config.LogConfig) *Levels {
+	levels := &Levels{
+		base:       parseLevel(conf.Level),
+		components: make(map[string]slog.Level, len(conf.ComponentLevels)),
+	}
+
+	for component, level := range conf.ComponentLevels {
+		levels.components[component] = parseLevel(level)
+	}
+
+	return levels
+}
+
+// Level returns the level of the component, which is the default level when the component
+// has no level of its own
+func (levels *Levels) Level(component string) slog.Level {
+	levels.mu.RLock()
+	defer levels.mu.RUnlock()
+
+	if level, ok := levels.components[component]; ok {
+		return level
+	}
+	return levels.base
+}
+
+// Set changes the level of the component, or the default level when the component is empty
+func (levels *Levels) Set(component string, level string) error {
+	if !config.IsValidLogLevel(level) {
+		return fmt.Errorf("unsupported log level %s", level)
+	}
+
+	levels.mu.Lock()
+	defer levels.mu.Unlock()
+
+	if component == "" {
+		levels.base = parseLevel(level)
+	} else {
+		levels.components[component] = parseLevel(level)
+	}
+	return nil
+}
+
+// Unset makes the component use the default level again
+func (levels *Levels) Unset(component string) {
+	levels.mu.Lock()
+	defer levels.mu.Unlock()
+
+	delete(levels.components, component)
+}
+
+// Default returns the default level, in lower case
+func (levels *Levels) Default() string {
+	levels.mu.RLock()
+	defer levels.mu.RUnlock()
+
+	return strings.ToLower(levels.base.String())
+}
+
+// Components returns the levels of the components that have a level of their own, in lower case
+func (levels *Levels) Components() map[string]string {
+	levels.mu.RLock()
+	defer levels.mu.RUnlock()
+
+	components := make(map[string]string, len(levels.components))
+	for component, level := range levels.components {
+		components[component] = strings.ToLower(level.String())
+	}
+	return components
+}
+
+// levelHandler drops the records below the level of the component that wrote them. The
+// component is known from the "component" attribute added with Logger.With.
+type levelHandler struct {
+	next      slog.Handler
+	levels    *Levels
+	component string
+}
+
+// slog.Handler
+func (handler *levelHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= handler.levels.Level(handler.component)
+}
+
+// slog.Handler
+func (handler *levelHandler) Handle(ctx context.Context, record slog.Record) error {
+	return handler.next.Handle(ctx, record)
+}
+
+// slog.Handler
+func (handler *levelHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	component := handler.component
+	for _, attr := range attrs {
+		if attr.Key == componentKey {
+			component = attr.Value.String()
+		}
+	}
+
+	return &levelHandler{next: handler.next.WithAttrs(attrs), levels: handler.levels, component: component}
+}
+
+// slog.Handler
+func (handler *levelHandler) WithGroup(name string) slog.Handler {
+	return &levelHandler{next: handler.next.WithGroup(name), levels: handler.levels, component: handler.component}
+}
@@ -1,6 +1,7 @@
 package graviolalog
 
 import (
+	"io"
 	"log/slog"
 	"os"
 	"strings"
@@ -8,19 +9,66 @@ import (
 	"github.com/jademcosta/graviola/pkg/config"
 )
 
-func NewLogger(conf config.LogConfig) *slog.Logger {
+// Logging is the logger of Graviola along with its levels, which can be changed while it runs,
+// and the output it writes to.
+type Logging struct {
+	Logger *slog.Logger
+	Levels *Levels
+	output io.Closer
+}
+
+// NewLogging creates the logger that writes to the configured output (the stdout or a rotating
+// file), in the configured format
+func NewLogging(conf config.LogConfig) (*Logging, error) {
+	var output io.Writer = os.Stdout
+	var closer io.Closer = nopCloser{}
+
+	if conf.Output == config.LogOutputFile {
+		file, err := NewRotatingFile(conf.FileConf.Path, int64(conf.FileConf.MaxSizeBytes), conf.FileConf.MaxBackups)
+		if err != nil {
+			return nil, err
+		}
+		output = file
+		closer = file
+	}
 
-	logHandler := slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
-		Level: parseLevel(conf.Level),
-	})
+	levels := NewLevels(conf)
+	return &Logging{
+		Logger: slog.New(&levelHandler{next: newHandler(output, conf.Format), levels: levels}),
+		Levels: levels,
+		output: closer,
+	}, nil
+}
 
-	return slog.New(logHandler)
+// io.Closer
+func (logging *Logging) Close() error {
+	return logging.output.Close()
+}
+
+// NewLogger creates a logger that writes to the stdout, whatever the output configured
+func NewLogger(conf config.LogConfig) *slog.Logger {
+	return slog.New(&levelHandler{next: newHandler(os.Stdout, conf.Format), levels: NewLevels(conf)})
 }
 
 func NewNoopLogger() *slog.Logger {
 	return slog.New(&noopHandler{})
 }
 
+// newHandler creates the handler that formats the records. It accepts all of them, as the
+// levels are checked by the levelHandler.
+func newHandler(output io.Writer, format string) slog.Handler {
+	opts := &slog.HandlerOptions{Level: slog.LevelDebug}
+
+	if format == config.LogFormatLogfmt {
+		return slog.NewTextHandler(output, opts)
+	}
+	return slog.NewJSONHandler(output, opts)
+}
+
+type nopCloser struct{}
+
+func (nopCloser) Close() error { return nil }
+
 func parseLevel(lvl string) slog.Level {
 
 	switch strings.ToUpper(lvl) {
@@ -0,0 +1,87 @@
+package graviolalog_test
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jademcosta/graviola/pkg/config"
+	"github.com/jademcosta/graviola/pkg/graviolalog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newFileLogging(t *testing.T, conf config.LogConfig) (*graviolalog.Logging, string) {
+	t.Helper()
+	conf.Output = config.LogOutputFile
+	conf.FileConf.Path = filepath.Join(t.TempDir(), "graviola.log")
+	conf = conf.FillDefaults()
+
+	logging, err := graviolalog.NewLogging(conf)
+	require.NoError(t, err, "should create the logging")
+	t.Cleanup(func() { _ = logging.Close() })
+	return logging, conf.FileConf.Path
+}
+
+func readLines(t *testing.T, path string) []string {
+	t.Helper()
+	content, err := os.ReadFile(path)
+	require.NoError(t, err, "should read the log file")
+	return strings.Split(strings.TrimSpace(string(content)), "\n")
+}
+
+func TestComponentsCanHaveALevelOfTheirOwn(t *testing.T) {
+	conf := config.LogConfig{Level: "warn", ComponentLevels: map[string]string{"remote": "debug", "access": "error"}}
+	logger := graviolalog.NewLogger(conf)
+	ctx := context.Background()
+
+	assert.False(t, logger.Enabled(ctx, slog.LevelInfo), "should use the default level without a component")
+	assert.True(t, logger.Enabled(ctx, slog.LevelWarn), "should use the default level without a component")
+	assert.False(t, logger.With("component", "api").Enabled(ctx, slog.LevelInfo),
+		"should use the default level on components without a level of their own")
+	assert.True(t, logger.With("component", "remote").Enabled(ctx, slog.LevelDebug),
+		"should use the level of the component")
+	assert.False(t, logger.With("component", "access").Enabled(ctx, slog.LevelWarn),
+		"should use the level of the component")
+	assert.True(t, logger.With("component", "remote").WithGroup("request").Enabled(ctx, slog.LevelDebug),
+		"should keep the level of the component on groups")
+}
+
+func TestLevelsCanBeChangedWhileLogging(t *testing.T) {
+	logging, path := newFileLogging(t, config.LogConfig{Level: "info"})
+	remoteLogger := logging.Logger.With("component", "remote")
+
+	remoteLogger.Debug("dropped")
+	require.NoError(t, logging.Levels.Set("remote", "debug"), "should set the level of the component")
+	remoteLogger.Debug("written")
+	logging.Logger.Debug("dropped too")
+	logging.Levels.Unset("remote")
+	remoteLogger.Debug("dropped after the reset")
+
+	lines := readLines(t, path)
+	require.Len(t, lines, 1, "should only write the log allowed by the level changed")
+	assert.Contains(t, lines[0], "written", "should write the log allowed by the level changed")
+
+	assert.Error(t, logging.Levels.Set("", "verbose"), "should reject unsupported levels")
+	assert.Equal(t, "info", logging.Levels.Default(), "should keep the level when the new one is rejected")
+}
+
+func TestLogsAreWrittenInTheConfiguredFormat(t *testing.T) {
+	logging, path := newFileLogging(t, config.LogConfig{Level: "info", Format: config.LogFormatJSON})
+	logging.Logger.Info("hello", "remote", "remote-1")
+
+	var entry map[string]any
+	require.NoError(t, json.Unmarshal([]byte(readLines(t, path)[0]), &entry), "should write JSON")
+	assert.Equal(t, "remote-1", entry["remote"], "should write the attributes")
+
+	logging, path = newFileLogging(t, config.LogConfig{Level: "info", Format: config.LogFormatLogfmt})
+	logging.Logger.Info("hello", "remote", "remote-1")
+
+	line := readLines(t, path)[0]
+	assert.Contains(t, line, "level=INFO", "should write logfmt")
+	assert.Contains(t, line, "msg=hello remote=remote-1", "should write logfmt")
+}
@@ -0,0 +1,100 @@
+package graviolalog
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+// RotatingFile writes to a file that is rotated when it reaches maxSizeBytes. Rotated files get
+// a numeric suffix (.1 being the newest), and only maxBackups of them are kept.
+type RotatingFile struct {
+	path         string
+	maxSizeBytes int64
+	maxBackups   int
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+func NewRotatingFile(path string, maxSizeBytes int64, maxBackups int) (*RotatingFile, error) {
+	rFile := &RotatingFile{
+		path:         path,
+		maxSizeBytes: maxSizeBytes,
+		maxBackups:   maxBackups,
+	}
+
+	err := rFile.open()
+	if err != nil {
+		return nil, err
+	}
+
+	return rFile, nil
+}
+
+func (rFile *RotatingFile) Write(data []byte) (int, error) {
+	rFile.mu.Lock()
+	defer rFile.mu.Unlock()
+
+	if rFile.size > 0 && rFile.size+int64(len(data)) > rFile.maxSizeBytes {
+		err := rFile.rotate()
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	written, err := rFile.file.Write(data)
+	rFile.size += int64(written)
+	return written, err
+}
+
+func (rFile *RotatingFile) Close() error {
+	rFile.mu.Lock()
+	defer rFile.mu.Unlock()
+
+	return rFile.file.Close()
+}
+
+func (rFile *RotatingFile) open() error {
+	file, err := os.OpenFile(rFile.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("unable to open log file: %w", err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("unable to read log file info: %w", err)
+	}
+
+	rFile.file = file
+	rFile.size = info.Size()
+	return nil
+}
+
+func (rFile *RotatingFile) rotate() error {
+	err := rFile.file.Close()
+	if err != nil {
+		return fmt.Errorf("unable to close log file: %w", err)
+	}
+
+	if rFile.maxBackups == 0 {
+		err = os.Remove(rFile.path)
+	} else {
+		for idx := rFile.maxBackups - 1; idx > 0; idx-- {
+			// The older backups might not exist yet
+			_ = os.Rename(rFile.backupPath(idx), rFile.backupPath(idx+1))
+		}
+		err = os.Rename(rFile.path, rFile.backupPath(1))
+	}
+	if err != nil {
+		return fmt.Errorf("unable to rotate log file: %w", err)
+	}
+
+	return rFile.open()
+}
+
+func (rFile *RotatingFile) backupPath(idx int) string {
+	return fmt.Sprintf("%s.%d", rFile.path, idx)
+}
@@ -0,0 +1,132 @@
+package guardrails
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/jademcosta/graviola/pkg/config"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/promql/parser"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/prometheus/prometheus/util/annotations"
+)
+
+var ErrQueryRejected = errors.New("query rejected by guardrail")
+
+var runOnceEngineO11y sync.Once
+var violationsTotal *prometheus.CounterVec
+
+// Engine checks the queries against the guardrails policy before sending them to the wrapped
+// engine. Queries violating a rule with the reject action are answered with an error that names
+// the rule, and the ones violating a rule with the warn action are executed, but answered with
+// a warning.
+type Engine struct {
+	logg   *slog.Logger
+	next   promql.QueryEngine
+	policy *Policy
+}
+
+func NewEngine(
+	logg *slog.Logger, metricz *prometheus.Registry, next promql.QueryEngine, conf config.GuardrailsConfig,
+) *Engine {
+	runOnceEngineO11y.Do(func() {
+		violationsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "graviola",
+			Subsystem: "guardrails",
+			Name:      "violations_total",
+			Help:      "Counter of queries that violated a guardrail rule, by rule and action taken (warn or reject).",
+		},
+			[]string{"rule", "action"})
+
+		if metricz != nil {
+			metricz.MustRegister(violationsTotal)
+		}
+	})
+
+	return &Engine{
+		logg:   logg.With("component", "guardrails"),
+		next:   next,
+		policy: NewPolicy(conf),
+	}
+}
+
+// QueryEngine
+func (engine *Engine) NewInstantQuery(
+	ctx context.Context, queryable storage.Queryable, opts promql.QueryOpts, qs string, ts time.Time,
+) (promql.Query, error) {
+	warnings, err := engine.check(qs)
+	if err != nil {
+		return nil, err
+	}
+
+	query, err := engine.next.NewInstantQuery(ctx, queryable, opts, qs, ts)
+	return withWarnings(query, warnings), err
+}
+
+// QueryEngine
+func (engine *Engine) NewRangeQuery(
+	ctx context.Context, queryable storage.Queryable, opts promql.QueryOpts, qs string, start, end time.Time,
+	interval time.Duration,
+) (promql.Query, error) {
+	warnings, err := engine.check(qs)
+	if err != nil {
+		return nil, err
+	}
+
+	query, err := engine.next.NewRangeQuery(ctx, queryable, opts, qs, start, end, interval)
+	return withWarnings(query, warnings), err
+}
+
+// check returns an error when the query violates a rule with the reject action, or the
+// warnings of the rules with the warn action it violates
+func (engine *Engine) check(qs string) (annotations.Annotations, error) {
+	if len(engine.policy.rules) == 0 {
+		return nil, nil
+	}
+
+	expr, err := parser.ParseExpr(qs)
+	if err != nil {
+		// The wrapped engine answers with the parsing error
+		return nil, nil
+	}
+
+	var warnings annotations.Annotations
+	for _, violated := range engine.policy.check(expr) {
+		violationsTotal.WithLabelValues(violated.rule, violated.action).Inc()
+		engine.logg.Debug("query violated guardrail", "rule", violated.rule, "action", violated.action,
+			"query", qs)
+
+		if violated.action == config.GuardrailActionReject {
+			return nil, fmt.Errorf("%w %q: %s", ErrQueryRejected, violated.rule, violated.detail)
+		}
+
+		warnings.Add(fmt.Errorf("query violates guardrail %q: %s", violated.rule, violated.detail))
+	}
+
+	return warnings, nil
+}
+
+func withWarnings(query promql.Query, warnings annotations.Annotations) promql.Query {
+	if query == nil || len(warnings) == 0 {
+		return query
+	}
+
+	return &warnedQuery{Query: query, warnings: warnings}
+}
+
+// warnedQuery adds the guardrails warnings to the result of the query
+type warnedQuery struct {
+	promql.Query
+	warnings annotations.Annotations
+}
+
+func (query *warnedQuery) Exec(ctx context.Context) *promql.Result {
+	result := query.Query.Exec(ctx)
+	result.Warnings.Merge(query.warnings)
+	return result
+}
@@ -0,0 +1,145 @@
+package guardrails_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jademcosta/graviola/pkg/config"
+	"github.com/jademcosta/graviola/pkg/graviolalog"
+	"github.com/jademcosta/graviola/pkg/guardrails"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/promql/parser"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/prometheus/prometheus/util/stats"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var logg = graviolalog.NewLogger(config.LogConfig{Level: "error"})
+
+type mockQuery struct{}
+
+func (query *mockQuery) Exec(_ context.Context) *promql.Result { return &promql.Result{} }
+func (query *mockQuery) Close()                                {}
+func (query *mockQuery) Statement() parser.Statement           { return nil }
+func (query *mockQuery) Stats() *stats.Statistics              { return nil }
+func (query *mockQuery) Cancel()                               {}
+func (query *mockQuery) String() string                        { return "" }
+
+// mockEngine records the queries it receives, and fails the ones that can't be parsed
+type mockEngine struct {
+	received []string
+}
+
+func (engine *mockEngine) NewInstantQuery(
+	_ context.Context, _ storage.Queryable, _ promql.QueryOpts, qs string, _ time.Time,
+) (promql.Query, error) {
+	return engine.newQuery(qs)
+}
+
+func (engine *mockEngine) NewRangeQuery(
+	_ context.Context, _ storage.Queryable, _ promql.QueryOpts, qs string, _, _ time.Time, _ time.Duration,
+) (promql.Query, error) {
+	return engine.newQuery(qs)
+}
+
+func (engine *mockEngine) newQuery(qs string) (promql.Query, error) {
+	engine.received = append(engine.received, qs)
+	_, err := parser.ParseExpr(qs)
+	if err != nil {
+		return nil, err
+	}
+	return &mockQuery{}, nil
+}
+
+func allRules(action string) config.GuardrailsConfig {
+	return config.GuardrailsConfig{
+		NoMetricName:                config.GuardrailRuleConfig{Action: action},
+		OnlyRegexOrNegativeMatchers: config.GuardrailRuleConfig{Action: action},
+		MaxRangeSelector:            config.GuardrailRuleConfig{Action: action, Threshold: "7d"},
+		MinSubqueryStep:             config.GuardrailRuleConfig{Action: action, Threshold: "10s"},
+		DeniedMetricNames:           config.GuardrailRuleConfig{Action: action, Pattern: "expensive_.*"},
+	}
+}
+
+func TestRejectsQueriesViolatingARule(t *testing.T) {
+	testCases := []struct {
+		query string
+		rule  string
+	}{
+		{`{job=~".+"}`, "no_metric_name"},
+		{`sum(rate({job="api"}[5m]))`, "no_metric_name"},
+		{`count({__name__=~".+"})`, "only_regex_or_negative_matchers"},
+		{`{__name__=~"http_.+", job!="api"}`, "only_regex_or_negative_matchers"},
+		{`rate(http_requests_total{job="api"}[30d])`, "max_range_selector"},
+		{`max_over_time(rate(http_requests_total{job="api"}[5m])[30d:1m])`, "max_range_selector"},
+		{`max_over_time(up{job="api"}[1h:1s])`, "min_subquery_step"},
+		{`sum(expensive_metric{job="api"})`, "denied_metric_names"},
+		{`sum({__name__=~"up|expensive_metric", job="api"})`, "denied_metric_names"},
+		{`sum({__name__=~"expensive_metri.", job="api"})`, "denied_metric_names"},
+		{`sum({__name__=~".*_metric", job="api"})`, "denied_metric_names"},
+	}
+
+	for _, tc := range testCases {
+		next := &mockEngine{}
+		sut := guardrails.NewEngine(logg, prometheus.NewRegistry(), next, allRules("reject"))
+
+		_, err := sut.NewInstantQuery(context.Background(), nil, nil, tc.query, time.Now())
+		require.ErrorIs(t, err, guardrails.ErrQueryRejected, "should reject %s", tc.query)
+		assert.ErrorContains(t, err, tc.rule, "should name the rule %s violated by %s", tc.rule, tc.query)
+
+		_, err = sut.NewRangeQuery(context.Background(), nil, nil, tc.query, time.Now(), time.Now(), time.Minute)
+		require.ErrorIs(t, err, guardrails.ErrQueryRejected, "should reject range query %s", tc.query)
+		assert.Empty(t, next.received, "should not send rejected queries to the wrapped engine")
+	}
+}
+
+func TestAcceptsQueriesThatDoNotViolateRules(t *testing.T) {
+	queries := []string{
+		`up`,
+		`sum(rate(http_requests_total{job=~"api|web", code!="200"}[5m]))`,
+		`max_over_time(up{job="api"}[7d])`,
+		`max_over_time(up{job="api"}[1h:10s])`,
+		`max_over_time(up{job="api"}[1h:])`,
+		`not_expensive_metric`,
+		`{__name__=~"up|not_expensive_metric", job="api"}`,
+		`{__name__=~"http_.+", job="api"}`,
+		`1 + 1`,
+	}
+
+	for _, query := range queries {
+		next := &mockEngine{}
+		sut := guardrails.NewEngine(logg, prometheus.NewRegistry(), next, allRules("reject"))
+
+		created, err := sut.NewInstantQuery(context.Background(), nil, nil, query, time.Now())
+		require.NoError(t, err, "should accept %s", query)
+		assert.Empty(t, created.Exec(context.Background()).Warnings, "should not warn on %s", query)
+		assert.Equal(t, []string{query}, next.received, "should send the query to the wrapped engine")
+	}
+}
+
+func TestWarnsOnQueriesViolatingARuleWithTheWarnAction(t *testing.T) {
+	next := &mockEngine{}
+	sut := guardrails.NewEngine(logg, prometheus.NewRegistry(), next, allRules("warn"))
+
+	query, err := sut.NewRangeQuery(
+		context.Background(), nil, nil, `count({__name__=~"http_.+"})`, time.Now(), time.Now(), time.Minute)
+	require.NoError(t, err, "should not reject queries violating rules that only warn")
+
+	warnings := query.Exec(context.Background()).Warnings
+	require.Len(t, warnings, 1, "should warn about the violated rule")
+	assert.ErrorContains(t, warnings.AsErrors()[0], "only_regex_or_negative_matchers", "should name the rule violated")
+}
+
+func TestLetsTheWrappedEngineAnswerQueriesThatCannotBeParsed(t *testing.T) {
+	next := &mockEngine{}
+	sut := guardrails.NewEngine(logg, prometheus.NewRegistry(), next, allRules("reject"))
+
+	_, err := sut.NewInstantQuery(context.Background(), nil, nil, `up[`, time.Now())
+	require.Error(t, err, "should fail")
+	assert.False(t, errors.Is(err, guardrails.ErrQueryRejected), "should return the error of the wrapped engine")
+	assert.Equal(t, []string{`up[`}, next.received, "should send the query to the wrapped engine")
+}
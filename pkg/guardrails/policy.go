@@ -0,0 +1,196 @@
+package guardrails
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/jademcosta/graviola/pkg/config"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql/parser"
+)
+
+const (
+	ruleNoMetricName                = "no_metric_name"
+	ruleOnlyRegexOrNegativeMatchers = "only_regex_or_negative_matchers"
+	ruleMaxRangeSelector            = "max_range_selector"
+	ruleMinSubqueryStep             = "min_subquery_step"
+	ruleDeniedMetricNames           = "denied_metric_names"
+)
+
+// violation is a rule matched by a query
+type violation struct {
+	rule   string
+	action string
+	detail string
+}
+
+type rule struct {
+	name   string
+	action string
+	// check returns what in the node violates the rule, or an empty string if nothing does
+	check func(node parser.Node) string
+}
+
+// Policy is the set of rules that queries are checked against
+type Policy struct {
+	rules []rule
+}
+
+func NewPolicy(conf config.GuardrailsConfig) *Policy {
+	rules := make([]rule, 0)
+
+	if conf.NoMetricName.Action != "" {
+		rules = append(rules, rule{
+			name: ruleNoMetricName, action: conf.NoMetricName.Action, check: checkNoMetricName,
+		})
+	}
+
+	if conf.OnlyRegexOrNegativeMatchers.Action != "" {
+		rules = append(rules, rule{
+			name:   ruleOnlyRegexOrNegativeMatchers,
+			action: conf.OnlyRegexOrNegativeMatchers.Action,
+			check:  checkOnlyRegexOrNegativeMatchers,
+		})
+	}
+
+	if conf.MaxRangeSelector.Action != "" {
+		maxRange := conf.MaxRangeSelector.ThresholdDuration()
+		rules = append(rules, rule{
+			name: ruleMaxRangeSelector, action: conf.MaxRangeSelector.Action,
+			check: func(node parser.Node) string {
+				// The range of a subquery reads as much data as the one of a range selector
+				switch rangeNode := node.(type) {
+				case *parser.MatrixSelector:
+					if rangeNode.Range > maxRange {
+						return fmt.Sprintf("the range selector %s is longer than %s", rangeNode, maxRange)
+					}
+				case *parser.SubqueryExpr:
+					if rangeNode.Range > maxRange {
+						return fmt.Sprintf("the range of the subquery %s is longer than %s", rangeNode, maxRange)
+					}
+				}
+				return ""
+			},
+		})
+	}
+
+	if conf.MinSubqueryStep.Action != "" {
+		minStep := conf.MinSubqueryStep.ThresholdDuration()
+		rules = append(rules, rule{
+			name: ruleMinSubqueryStep, action: conf.MinSubqueryStep.Action,
+			check: func(node parser.Node) string {
+				subquery, ok := node.(*parser.SubqueryExpr)
+				// A zero step means the default evaluation interval
+				if !ok || subquery.Step == 0 || subquery.Step >= minStep {
+					return ""
+				}
+				return fmt.Sprintf("the subquery %s has a resolution finer than %s", subquery, minStep)
+			},
+		})
+	}
+
+	if conf.DeniedMetricNames.Action != "" {
+		denied := regexp.MustCompile("^(?:" + conf.DeniedMetricNames.Pattern + ")$")
+		deniedPrefix, _ := regexp.MustCompile(conf.DeniedMetricNames.Pattern).LiteralPrefix()
+		rules = append(rules, rule{
+			name: ruleDeniedMetricNames, action: conf.DeniedMetricNames.Action,
+			check: func(node parser.Node) string {
+				selector, ok := node.(*parser.VectorSelector)
+				if !ok {
+					return ""
+				}
+
+				for _, matcher := range selector.LabelMatchers {
+					if matcher.Name != labels.MetricName {
+						continue
+					}
+					if detail := deniedNameMatch(matcher, denied, deniedPrefix); detail != "" {
+						return detail
+					}
+				}
+				return ""
+			},
+		})
+	}
+
+	return &Policy{rules: rules}
+}
+
+// deniedNameMatch returns why the metric name matcher can select a denied metric, or an empty
+// string if it can't. Regexes that are not a list of names can't be compared with the denied
+// pattern, so they are only accepted when their literal prefix rules out every denied name.
+func deniedNameMatch(matcher *labels.Matcher, denied *regexp.Regexp, deniedPrefix string) string {
+	switch matcher.Type {
+	case labels.MatchEqual:
+		if denied.MatchString(matcher.Value) {
+			return fmt.Sprintf("the metric %s is denied", matcher.Value)
+		}
+	case labels.MatchRegexp:
+		if names := matcher.SetMatches(); len(names) > 0 {
+			for _, name := range names {
+				if denied.MatchString(name) {
+					return fmt.Sprintf("the metric %s is denied", name)
+				}
+			}
+			return ""
+		}
+
+		prefix := matcher.Prefix()
+		if strings.HasPrefix(prefix, deniedPrefix) || strings.HasPrefix(deniedPrefix, prefix) {
+			return fmt.Sprintf("the metric name regex %q can match denied metrics", matcher.Value)
+		}
+	}
+	return ""
+}
+
+// check returns the rules violated by the query, each one only once, in the order the rules
+// are listed on the config
+func (policy *Policy) check(expr parser.Expr) []violation {
+	details := make([]string, len(policy.rules))
+	parser.Inspect(expr, func(node parser.Node, _ []parser.Node) error {
+		for idx, rule := range policy.rules {
+			if details[idx] == "" {
+				details[idx] = rule.check(node)
+			}
+		}
+		return nil
+	})
+
+	violations := make([]violation, 0)
+	for idx, rule := range policy.rules {
+		if details[idx] != "" {
+			violations = append(violations, violation{rule: rule.name, action: rule.action, detail: details[idx]})
+		}
+	}
+
+	return violations
+}
+
+func checkNoMetricName(node parser.Node) string {
+	selector, ok := node.(*parser.VectorSelector)
+	if !ok {
+		return ""
+	}
+
+	for _, matcher := range selector.LabelMatchers {
+		if matcher.Name == labels.MetricName {
+			return ""
+		}
+	}
+	return fmt.Sprintf("the selector %s has no metric name", selector)
+}
+
+func checkOnlyRegexOrNegativeMatchers(node parser.Node) string {
+	selector, ok := node.(*parser.VectorSelector)
+	if !ok {
+		return ""
+	}
+
+	for _, matcher := range selector.LabelMatchers {
+		if matcher.Type == labels.MatchEqual {
+			return ""
+		}
+	}
+	return fmt.Sprintf("the selector %s has only regex or negative matchers", selector)
+}
@@ -0,0 +1,84 @@
+package httpmiddleware
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/jademcosta/graviola/pkg/admission"
+	"github.com/jademcosta/graviola/pkg/clientinfo"
+)
+
+var admittedPaths = []string{"/api/v1/query", "/api/v1/query_range"}
+
+type admissionMiddleware struct {
+	controller     *admission.Controller
+	priorityHeader string
+	next           http.Handler
+}
+
+// NewAdmissionMiddleware makes queries wait for a free slot before being executed. Queries are
+// answered with 429 when the queue is full, and with 503 when they wait too long.
+func NewAdmissionMiddleware(
+	controller *admission.Controller, priorityHeader string,
+) func(next http.Handler) http.Handler {
+	midd := &admissionMiddleware{
+		controller:     controller,
+		priorityHeader: priorityHeader,
+	}
+
+	return func(next http.Handler) http.Handler {
+		midd.next = next
+		return midd
+	}
+}
+
+func (midd *admissionMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !isAdmittedPath(r.URL.Path) {
+		midd.next.ServeHTTP(w, r)
+		return
+	}
+
+	release, err := midd.controller.Acquire(r.Context(), r.Header.Get(midd.priorityHeader), tenantOf(r))
+	if err != nil {
+		writeAdmissionError(w, err)
+		return
+	}
+	defer release()
+
+	midd.next.ServeHTTP(w, r)
+}
+
+// tenantOf returns the tenant of the request, or the client address when there's no tenant.
+// The tenant of clients that are not authenticated is ignored, otherwise they could take more
+// turns by sending a different tenant header on each query.
+func tenantOf(r *http.Request) string {
+	info, ok := clientinfo.FromContext(r.Context())
+	if !ok {
+		return ""
+	}
+
+	if tenant := info.AuthenticatedTenant(); tenant != "" {
+		return tenant
+	}
+	return info.Address
+}
+
+func isAdmittedPath(path string) bool {
+	for _, admitted := range admittedPaths {
+		if strings.HasSuffix(path, admitted) {
+			return true
+		}
+	}
+	return false
+}
+
+func writeAdmissionError(w http.ResponseWriter, err error) {
+	statusCode := http.StatusServiceUnavailable
+	if errors.Is(err, admission.ErrQueueFull) {
+		statusCode = http.StatusTooManyRequests
+		w.Header().Set("Retry-After", "1")
+	}
+
+	writeError(w, statusCode, "unavailable", err)
+}
@@ -0,0 +1,152 @@
+package httpmiddleware
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/jademcosta/graviola/pkg/auth"
+	"github.com/jademcosta/graviola/pkg/clientinfo"
+	"github.com/jademcosta/graviola/pkg/config"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	authResultAuthenticated = "authenticated"
+	authResultMissing       = "missing"
+	authResultInvalid       = "invalid"
+	authResultForbidden     = "forbidden"
+	authResultExempt        = "exempt"
+)
+
+// debugPathPrefixes are the routes that expose or change the internals of Graviola, like pprof,
+// the config reload and the queries of every client, which only some clients are allowed to call
+var debugPathPrefixes = []string{"/debug", "/-/", "/api/v1/status/active_queries"}
+
+var operationalPaths = []string{"/healthy", "/ready", "/metrics"}
+
+var errMissingAPIKey = errors.New("missing api key")
+var errInvalidAPIKey = errors.New("invalid api key")
+var errDebugForbidden = errors.New("the api key is not allowed to call /debug and /-/")
+
+var runOnceAuthO11y sync.Once
+var authRequestsTotal *prometheus.CounterVec
+
+type apiKeyMiddleware struct {
+	logg                    *slog.Logger
+	keys                    *auth.APIKeys
+	header                  string
+	exemptOperationalRoutes bool
+	debugKeyNames           []string
+	next                    http.Handler
+}
+
+// NewAPIKeyMiddleware only lets through requests with a known API key. The name of the key is
+// stored on the client info, so it identifies the client on the layers below. The /debug routes
+// are only allowed to the keys listed on the config. Requests already authenticated by a
+// middleware that runs before this one are let through.
+func NewAPIKeyMiddleware(
+	logg *slog.Logger, metricz *prometheus.Registry, keys *auth.APIKeys, conf config.APIKeysConfig,
+) func(next http.Handler) http.Handler {
+	registerAuthMetrics(metricz)
+
+	midd := &apiKeyMiddleware{
+		logg:                    logg,
+		keys:                    keys,
+		header:                  conf.Header,
+		exemptOperationalRoutes: conf.ExemptOperationalRoutes,
+		debugKeyNames:           conf.DebugKeyNames,
+	}
+
+	return func(next http.Handler) http.Handler {
+		midd.next = next
+		return midd
+	}
+}
+
+func (midd *apiKeyMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// The request was already authenticated by another method, like OIDC
+	if info, _ := clientinfo.FromContext(r.Context()); info.Principal != "" {
+		midd.next.ServeHTTP(w, r)
+		return
+	}
+
+	if midd.exemptOperationalRoutes && slices.Contains(operationalPaths, r.URL.Path) {
+		authRequestsTotal.WithLabelValues("", authResultExempt).Inc()
+		midd.next.ServeHTTP(w, r)
+		return
+	}
+
+	key := midd.keyFrom(r)
+	if key == "" {
+		midd.reject(w, r, "", authResultMissing, http.StatusUnauthorized, errMissingAPIKey)
+		return
+	}
+
+	name, ok := midd.keys.Authenticate(key)
+	if !ok {
+		midd.reject(w, r, "", authResultInvalid, http.StatusUnauthorized, errInvalidAPIKey)
+		return
+	}
+
+	if isDebugPath(r.URL.Path) && !slices.Contains(midd.debugKeyNames, name) {
+		midd.reject(w, r, name, authResultForbidden, http.StatusForbidden, errDebugForbidden)
+		return
+	}
+
+	authRequestsTotal.WithLabelValues(name, authResultAuthenticated).Inc()
+
+	info, _ := clientinfo.FromContext(r.Context())
+	info.Principal = name
+	midd.next.ServeHTTP(w, r.WithContext(clientinfo.NewContext(r.Context(), info)))
+}
+
+func (midd *apiKeyMiddleware) keyFrom(r *http.Request) string {
+	if midd.header != "" {
+		return r.Header.Get(midd.header)
+	}
+
+	token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !found {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+func (midd *apiKeyMiddleware) reject(
+	w http.ResponseWriter, r *http.Request, name string, result string, statusCode int, err error,
+) {
+	authRequestsTotal.WithLabelValues(name, result).Inc()
+	midd.logg.Warn("request rejected by api key authentication", "reason", result, "key_name", name,
+		"path", r.URL.Path, "from", r.RemoteAddr)
+
+	if statusCode == http.StatusUnauthorized && midd.header == "" {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+	}
+	writeError(w, statusCode, "unauthorized", err)
+}
+
+func registerAuthMetrics(metricz *prometheus.Registry) {
+	runOnceAuthO11y.Do(func() {
+		authRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "graviola",
+			Subsystem: "http",
+			Name:      "auth_requests_total",
+			Help:      "Counter of requests that went through authentication, by key name and result (authenticated, missing, invalid, forbidden or exempt).",
+		},
+			[]string{"key_name", "result"})
+
+		if metricz != nil {
+			metricz.MustRegister(authRequestsTotal)
+		}
+	})
+}
+
+func isDebugPath(path string) bool {
+	return slices.ContainsFunc(debugPathPrefixes, func(prefix string) bool {
+		return strings.HasPrefix(path, prefix)
+	})
+}
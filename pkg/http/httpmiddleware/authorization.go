@@ -0,0 +1,36 @@
+package httpmiddleware
+
+import (
+	"net/http"
+
+	"github.com/jademcosta/graviola/pkg/authz"
+	"github.com/jademcosta/graviola/pkg/clientinfo"
+)
+
+type authorizationMiddleware struct {
+	policies *authz.Policies
+	next     http.Handler
+}
+
+// NewAuthorizationMiddleware stores the authorization policy of the client on the request
+// context. It doesn't reject requests: the policy is enforced by the storage, which denies the
+// clients without one.
+func NewAuthorizationMiddleware(policies *authz.Policies) func(next http.Handler) http.Handler {
+	midd := &authorizationMiddleware{policies: policies}
+
+	return func(next http.Handler) http.Handler {
+		midd.next = next
+		return midd
+	}
+}
+
+func (midd *authorizationMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	info, _ := clientinfo.FromContext(r.Context())
+	policy, err := midd.policies.For(info)
+	if err != nil {
+		midd.next.ServeHTTP(w, r)
+		return
+	}
+
+	midd.next.ServeHTTP(w, r.WithContext(authz.NewContext(r.Context(), policy)))
+}
@@ -0,0 +1,29 @@
+package httpmiddleware
+
+import (
+	"net/http"
+)
+
+type bodyLimitMiddleware struct {
+	maxBytes int64
+	next     http.Handler
+}
+
+// NewBodyLimitMiddleware makes reading more than maxBytes from the body of the requests fail, so
+// clients can't make Graviola hold huge bodies in memory. Zero means no limit.
+func NewBodyLimitMiddleware(maxBytes int64) func(next http.Handler) http.Handler {
+	midd := &bodyLimitMiddleware{maxBytes: maxBytes}
+
+	return func(next http.Handler) http.Handler {
+		midd.next = next
+		return midd
+	}
+}
+
+func (midd *bodyLimitMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if midd.maxBytes > 0 && r.Body != nil {
+		r.Body = http.MaxBytesReader(w, r.Body, midd.maxBytes)
+	}
+
+	midd.next.ServeHTTP(w, r)
+}
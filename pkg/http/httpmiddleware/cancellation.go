@@ -0,0 +1,29 @@
+package httpmiddleware
+
+import (
+	"net/http"
+
+	"github.com/jademcosta/graviola/pkg/querytracker"
+)
+
+type cancellationMiddleware struct {
+	next http.Handler
+}
+
+// NewCancellationMiddleware makes the context of the requests cancellable through the query
+// tracker, so the queries they execute can be cancelled by the active queries API.
+func NewCancellationMiddleware() func(next http.Handler) http.Handler {
+	midd := &cancellationMiddleware{}
+
+	return func(next http.Handler) http.Handler {
+		midd.next = next
+		return midd
+	}
+}
+
+func (midd *cancellationMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx, cancelFn := querytracker.WithCancel(r.Context())
+	defer cancelFn()
+
+	midd.next.ServeHTTP(w, r.WithContext(ctx))
+}
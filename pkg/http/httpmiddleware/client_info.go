@@ -0,0 +1,45 @@
+package httpmiddleware
+
+import (
+	"net"
+	"net/http"
+
+	"github.com/jademcosta/graviola/pkg/clientinfo"
+)
+
+// TenantHeader is the header clients use to inform their tenant
+const TenantHeader = "X-Scope-OrgID"
+
+type clientInfoMiddleware struct {
+	next http.Handler
+}
+
+// NewClientInfoMiddleware stores the information about the client on the request context, so
+// it can be used on the layers below the HTTP API.
+func NewClientInfoMiddleware() func(next http.Handler) http.Handler {
+	midd := &clientInfoMiddleware{}
+
+	return func(next http.Handler) http.Handler {
+		midd.next = next
+		return midd
+	}
+}
+
+func (midd *clientInfoMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	address, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		address = r.RemoteAddr
+	}
+
+	info := clientinfo.Info{
+		Address: address,
+		Tenant:  r.Header.Get(TenantHeader),
+		Headers: r.Header.Clone(),
+	}
+	if r.TLS != nil && len(r.TLS.PeerCertificates) > 0 {
+		info.CertificateSubject = r.TLS.PeerCertificates[0].Subject.CommonName
+	}
+
+	ctx := clientinfo.NewContext(r.Context(), info)
+	midd.next.ServeHTTP(w, r.WithContext(ctx))
+}
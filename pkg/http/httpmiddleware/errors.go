@@ -0,0 +1,19 @@
+package httpmiddleware
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// writeError answers with an error in the same format the Prometheus API uses
+func writeError(w http.ResponseWriter, statusCode int, errorType string, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+
+	// The client might be gone, nothing to be done about it
+	_ = json.NewEncoder(w).Encode(map[string]string{
+		"status":    "error",
+		"errorType": errorType,
+		"error":     err.Error(),
+	})
+}
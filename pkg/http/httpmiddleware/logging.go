@@ -1,9 +1,22 @@
 package httpmiddleware
 
 import (
+	"context"
+	"errors"
 	"log/slog"
 	"net/http"
 	"time"
+
+	"github.com/jademcosta/graviola/pkg/clientinfo"
+	"github.com/jademcosta/graviola/pkg/querystats"
+	"github.com/jademcosta/graviola/pkg/tracing"
+)
+
+const (
+	accessOutcomeSuccess   = "success"
+	accessOutcomeRejected  = "rejected"
+	accessOutcomeError     = "error"
+	accessOutcomeCancelled = "cancelled"
 )
 
 type loggingMiddleware struct {
@@ -11,6 +24,9 @@ type loggingMiddleware struct {
 	next http.Handler
 }
 
+// NewLoggingMiddleware writes the access log, a line for each request with who sent it, what it
+// queried, the remotes contacted and its outcome. The query and remotes are read from the query
+// stats collector, so it needs to be after NewQueryStatsMiddleware.
 func NewLoggingMiddleware(l *slog.Logger) func(next http.Handler) http.Handler {
 	logging := &loggingMiddleware{
 		l: l,
@@ -23,18 +39,59 @@ func NewLoggingMiddleware(l *slog.Logger) func(next http.Handler) http.Handler {
 }
 
 func (midd *loggingMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !midd.l.Enabled(r.Context(), slog.LevelInfo) {
+		midd.next.ServeHTTP(w, r)
+		return
+	}
+
 	timeStart := time.Now()
 	wrapper := &responseWriterWrapper{wrapped: w}
 
 	midd.next.ServeHTTP(wrapper, r)
 
-	defer func() {
-		midd.l.Info("HTTP response",
-			"method", r.Method,
-			"path", r.URL.Path,
-			"status", wrapper.statusCode,
-			"size", wrapper.responseSize, //TODO: append the unit on the size
-			"from", r.RemoteAddr,
-			"latency_time", time.Since(timeStart).String())
-	}()
+	info, _ := clientinfo.FromContext(r.Context())
+	route := RouteOf(r)
+	attrs := []slog.Attr{
+		slog.String("method", r.Method),
+		slog.String("path", r.URL.Path),
+		slog.String("route", route),
+		slog.String("query_type", QueryTypeOf(route)),
+		slog.Int("status", wrapper.status()),
+		slog.String("outcome", accessOutcome(r.Context(), wrapper.status())),
+		slog.Int("size_bytes", wrapper.responseSize),
+		slog.String("from", r.RemoteAddr),
+		slog.String("principal", info.Principal),
+		slog.String("tenant", info.Tenant),
+		slog.String("trace_id", tracing.TraceID(r.Context())),
+		slog.String("latency_time", time.Since(timeStart).String()),
+	}
+
+	collector := querystats.FromContext(r.Context())
+	if query, ok := collector.Query(); ok {
+		attrs = append(attrs, slog.String("query", query.Query), slog.Time("start", query.Start),
+			slog.Time("end", query.End))
+		if query.Step > 0 {
+			attrs = append(attrs, slog.String("step", query.Step.String()))
+		}
+	}
+	if remotes := collector.Remotes(); len(remotes) > 0 {
+		attrs = append(attrs, slog.Any("remotes", remotes))
+	}
+
+	midd.l.LogAttrs(r.Context(), slog.LevelInfo, "HTTP response", attrs...)
+}
+
+// accessOutcome tells whether the request succeeded, was rejected (4xx), failed (5xx) or was
+// cancelled by the client
+func accessOutcome(ctx context.Context, status int) string {
+	switch {
+	case errors.Is(ctx.Err(), context.Canceled):
+		return accessOutcomeCancelled
+	case status >= http.StatusInternalServerError:
+		return accessOutcomeError
+	case status >= http.StatusBadRequest:
+		return accessOutcomeRejected
+	default:
+		return accessOutcomeSuccess
+	}
 }
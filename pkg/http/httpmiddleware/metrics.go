@@ -2,6 +2,7 @@ package httpmiddleware
 
 import (
 	"net/http"
+	"slices"
 	"strconv"
 	"sync"
 	"time"
@@ -9,14 +10,26 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 )
 
+// otherMethod is the method label of the requests with a method not listed on knownMethods, so
+// clients can't create a series for each method they make up
+const otherMethod = "other"
+
+var knownMethods = []string{
+	http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete, http.MethodHead, http.MethodOptions,
+}
+
 var ensureMetricRegisteringOnce sync.Once
 var reqsCount *prometheus.CounterVec
 var latencyHist *prometheus.HistogramVec
+var timeToFirstByteHist *prometheus.HistogramVec
+var responseSizeHist *prometheus.HistogramVec
 
 type metricsMiddleware struct {
 	next http.Handler
 }
 
+// NewMetricsMiddleware records the requests by the template of the route that served them (see
+// NewRouteMiddleware), so paths with parameters don't create a series for each value sent.
 func NewMetricsMiddleware(metricRegistry *prometheus.Registry) func(next http.Handler) http.Handler {
 	midd := &metricsMiddleware{}
 
@@ -26,9 +39,9 @@ func NewMetricsMiddleware(metricRegistry *prometheus.Registry) func(next http.Ha
 				Name:      "requests_total",
 				Subsystem: "http",
 				Namespace: "graviola",
-				Help:      "How many HTTP requests processed.",
+				Help:      "How many HTTP requests processed, by route and type of query (instant, range, labels, series, metadata or none).",
 			},
-			[]string{"code", "method", "path"},
+			[]string{"code", "method", "route", "query_type"},
 		)
 
 		latencyHist = prometheus.NewHistogramVec(
@@ -39,10 +52,32 @@ func NewMetricsMiddleware(metricRegistry *prometheus.Registry) func(next http.Ha
 				Help:      "Latency of HTTP requests, in seconds.",
 				Buckets:   []float64{0.1, 0.2, 0.5, 1.0, 2.5, 5.0, 10.0, 15.0, 30.0, 60.0, 120.0},
 			},
-			[]string{"path"},
+			[]string{"route", "query_type"},
+		)
+
+		timeToFirstByteHist = prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:      "time_to_first_byte_seconds",
+				Subsystem: "http",
+				Namespace: "graviola",
+				Help:      "Time until the status and headers of HTTP responses were written, in seconds.",
+				Buckets:   []float64{0.1, 0.2, 0.5, 1.0, 2.5, 5.0, 10.0, 15.0, 30.0, 60.0, 120.0},
+			},
+			[]string{"route", "query_type"},
+		)
+
+		responseSizeHist = prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:      "response_size_bytes",
+				Subsystem: "http",
+				Namespace: "graviola",
+				Help:      "Size of the body of HTTP responses, in bytes.",
+				Buckets:   prometheus.ExponentialBuckets(256, 4, 10),
+			},
+			[]string{"route", "query_type"},
 		)
 
-		metricRegistry.MustRegister(reqsCount, latencyHist)
+		metricRegistry.MustRegister(reqsCount, latencyHist, timeToFirstByteHist, responseSizeHist)
 	})
 
 	return func(next http.Handler) http.Handler {
@@ -57,7 +92,25 @@ func (midd *metricsMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request)
 
 	midd.next.ServeHTTP(wrapper, r)
 
-	latency := time.Since(timeStart).Seconds()
-	latencyHist.WithLabelValues(r.URL.Path).Observe(latency)
-	reqsCount.WithLabelValues(strconv.Itoa(wrapper.statusCode), r.Method, r.URL.Path).Inc()
+	timeEnd := time.Now()
+	firstByteAt := wrapper.firstByteAt
+	if firstByteAt.IsZero() {
+		// Nothing was written, so the header is sent only now that the handler returned
+		firstByteAt = timeEnd
+	}
+
+	route := RouteOf(r)
+	queryType := QueryTypeOf(route)
+
+	latencyHist.WithLabelValues(route, queryType).Observe(timeEnd.Sub(timeStart).Seconds())
+	timeToFirstByteHist.WithLabelValues(route, queryType).Observe(firstByteAt.Sub(timeStart).Seconds())
+	responseSizeHist.WithLabelValues(route, queryType).Observe(float64(wrapper.responseSize))
+	reqsCount.WithLabelValues(strconv.Itoa(wrapper.status()), methodLabel(r.Method), route, queryType).Inc()
+}
+
+func methodLabel(method string) string {
+	if slices.Contains(knownMethods, method) {
+		return method
+	}
+	return otherMethod
 }
@@ -0,0 +1,124 @@
+package httpmiddleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/route"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newRoutedHandler() http.Handler {
+	router := chi.NewRouter()
+	router.Use(NewRouteMiddleware())
+	router.Use(NewMetricsMiddleware(prometheus.NewRegistry()))
+
+	router.Get("/healthy", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	promRouter := route.New().WithInstrumentation(RouteInstrumentation("/api/v1")).WithPrefix("/api/v1")
+	promRouter.Get("/label/:name/values", func(w http.ResponseWriter, _ *http.Request) {
+		time.Sleep(10 * time.Millisecond)
+		_, _ = w.Write([]byte(`{"status":"success",`))
+		_, _ = w.Write([]byte(`"data":[]}`))
+	})
+	promRouter.Post("/query_range", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`{"status":"success"}`))
+	})
+	router.Handle("/*", promRouter)
+
+	return router
+}
+
+func serve(handler http.Handler, method string, path string) {
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(method, path, nil))
+}
+
+func TestRequestsAreMeasuredByRoute(t *testing.T) {
+	handler := newRoutedHandler()
+	reqsCount.Reset()
+	latencyHist.Reset()
+
+	serve(handler, http.MethodGet, "/api/v1/label/job/values")
+	serve(handler, http.MethodGet, "/api/v1/label/instance/values")
+	serve(handler, http.MethodPost, "/api/v1/query_range")
+	serve(handler, http.MethodGet, "/healthy")
+	serve(handler, http.MethodGet, "/api/v1/label/job")
+	serve(handler, http.MethodGet, "/some/scanner/path")
+
+	assert.Equal(t, 2.0,
+		testutil.ToFloat64(reqsCount.WithLabelValues("200", "GET", "/api/v1/label/:name/values", QueryTypeLabels)),
+		"should count the label values requests on the template of their route")
+	assert.Equal(t, 1.0,
+		testutil.ToFloat64(reqsCount.WithLabelValues("200", "POST", "/api/v1/query_range", QueryTypeRange)),
+		"should count the range queries with their type")
+	assert.Equal(t, 1.0, testutil.ToFloat64(reqsCount.WithLabelValues("200", "GET", "/healthy", QueryTypeNone)),
+		"should count the routes of chi")
+	assert.Equal(t, 2.0, testutil.ToFloat64(reqsCount.WithLabelValues("404", "GET", UnmatchedRoute, QueryTypeNone)),
+		"should count the paths without a route together")
+	assert.Equal(t, 4, testutil.CollectAndCount(reqsCount), "should have a series for each route, not for each path")
+	assert.Equal(t, 4, testutil.CollectAndCount(latencyHist), "should have a latency for each route, not for each path")
+}
+
+func TestRequestsWithUnknownMethodsAreMeasuredTogether(t *testing.T) {
+	handler := newRoutedHandler()
+	reqsCount.Reset()
+
+	serve(handler, "PROPFIND", "/healthy")
+	serve(handler, "SCAN", "/healthy")
+	serve(handler, http.MethodHead, "/healthy")
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(reqsCount)
+	families, err := registry.Gather()
+	require.NoError(t, err, "should gather the metrics")
+	require.Len(t, families, 1, "should have the requests metric")
+
+	methods := make(map[string]float64)
+	for _, metric := range families[0].GetMetric() {
+		for _, label := range metric.GetLabel() {
+			if label.GetName() == "method" {
+				methods[label.GetValue()] += metric.GetCounter().GetValue()
+			}
+		}
+	}
+	assert.Equal(t, map[string]float64{"other": 2, http.MethodHead: 1}, methods,
+		"should count the unknown methods together, and keep the known ones")
+}
+
+func TestTheSizeAndTimeToFirstByteOfResponsesAreMeasured(t *testing.T) {
+	handler := newRoutedHandler()
+	timeToFirstByteHist.Reset()
+	responseSizeHist.Reset()
+
+	serve(handler, http.MethodGet, "/api/v1/label/job/values")
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(timeToFirstByteHist, responseSizeHist, latencyHist)
+	families, err := registry.Gather()
+	require.NoError(t, err, "should gather the metrics")
+
+	sums := make(map[string]float64)
+	for _, family := range families {
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if label.GetName() == "route" && label.GetValue() == "/api/v1/label/:name/values" {
+					sums[family.GetName()] = metric.GetHistogram().GetSampleSum()
+				}
+			}
+		}
+	}
+
+	assert.Equal(t, 30.0, sums["graviola_http_response_size_bytes"], "should observe the size of all the writes")
+	assert.GreaterOrEqual(t, sums["graviola_http_time_to_first_byte_seconds"], 0.01,
+		"should observe when the first byte was written")
+	assert.LessOrEqual(t, sums["graviola_http_time_to_first_byte_seconds"], sums["graviola_http_request_duration_seconds"],
+		"should have the first byte before the end of the request")
+}
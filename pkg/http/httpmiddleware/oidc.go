@@ -0,0 +1,247 @@
+package httpmiddleware
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jademcosta/graviola/pkg/auth"
+	"github.com/jademcosta/graviola/pkg/clientinfo"
+	"github.com/jademcosta/graviola/pkg/config"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	LoginPath    = "/auth/login"
+	CallbackPath = "/auth/callback"
+	LogoutPath   = "/auth/logout"
+)
+
+const authResultSkipped = "skipped"
+
+const loginStateMaxAge = 10 * time.Minute
+
+var errMissingToken = errors.New("missing token")
+var errInvalidLoginState = errors.New("invalid login state")
+var errDebugForbiddenToGroups = errors.New("the groups of the client are not allowed to call /debug and /-/")
+
+var runOnceOIDCO11y sync.Once
+var oidcRequestsTotal *prometheus.CounterVec
+
+type oidcMiddleware struct {
+	logg                    *slog.Logger
+	verifier                *auth.TokenVerifier
+	login                   *auth.LoginFlow
+	cookieName              string
+	secureCookies           bool
+	exemptOperationalRoutes bool
+	debugGroups             []string
+	optional                bool
+	next                    http.Handler
+}
+
+// NewOIDCMiddleware only lets through requests with a valid OIDC token, sent as a bearer token
+// or on the session cookie set after a login. The principal and groups of the token are stored
+// on the client info. When login is not nil it also answers the login, callback and logout
+// routes. When optional is true, requests without a token go to the next middleware (which can
+// authenticate them some other way) instead of being rejected.
+func NewOIDCMiddleware(
+	logg *slog.Logger, metricz *prometheus.Registry, verifier *auth.TokenVerifier, login *auth.LoginFlow,
+	conf config.OIDCConfig, optional bool,
+) func(next http.Handler) http.Handler {
+	registerOIDCMetrics(metricz)
+
+	midd := &oidcMiddleware{
+		logg:                    logg,
+		verifier:                verifier,
+		login:                   login,
+		cookieName:              conf.LoginConf.CookieName,
+		secureCookies:           strings.HasPrefix(conf.LoginConf.RedirectURL, "https://"),
+		exemptOperationalRoutes: conf.ExemptOperationalRoutes,
+		debugGroups:             conf.DebugGroups,
+		optional:                optional,
+	}
+
+	return func(next http.Handler) http.Handler {
+		midd.next = next
+		return midd
+	}
+}
+
+func (midd *oidcMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if midd.login != nil {
+		switch r.URL.Path {
+		case LoginPath:
+			midd.startLogin(w, r)
+			return
+		case CallbackPath:
+			midd.finishLogin(w, r)
+			return
+		case LogoutPath:
+			midd.setCookie(w, midd.cookieName, "", time.Unix(0, 0))
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+	}
+
+	if midd.exemptOperationalRoutes && slices.Contains(operationalPaths, r.URL.Path) {
+		oidcRequestsTotal.WithLabelValues(authResultExempt).Inc()
+		midd.next.ServeHTTP(w, r)
+		return
+	}
+
+	token, fromCookie := midd.tokenFrom(r)
+	if token == "" {
+		if midd.optional {
+			oidcRequestsTotal.WithLabelValues(authResultSkipped).Inc()
+			midd.next.ServeHTTP(w, r)
+			return
+		}
+
+		midd.reject(w, r, authResultMissing, http.StatusUnauthorized, errMissingToken)
+		return
+	}
+
+	identity, err := midd.verifier.Verify(r.Context(), token)
+	if err != nil {
+		if fromCookie {
+			midd.setCookie(w, midd.cookieName, "", time.Unix(0, 0))
+		}
+		midd.reject(w, r, authResultInvalid, http.StatusUnauthorized, err)
+		return
+	}
+
+	if isDebugPath(r.URL.Path) &&
+		!slices.ContainsFunc(identity.Groups, func(group string) bool {
+			return slices.Contains(midd.debugGroups, group)
+		}) {
+		midd.reject(w, r, authResultForbidden, http.StatusForbidden, errDebugForbiddenToGroups)
+		return
+	}
+
+	oidcRequestsTotal.WithLabelValues(authResultAuthenticated).Inc()
+
+	info, _ := clientinfo.FromContext(r.Context())
+	info.Principal = identity.Principal
+	info.Groups = identity.Groups
+	midd.next.ServeHTTP(w, r.WithContext(clientinfo.NewContext(r.Context(), info)))
+}
+
+// tokenFrom returns the token of the request and if it came from the session cookie. Bearer
+// tokens that are not JWTs are ignored, as they might be API keys.
+func (midd *oidcMiddleware) tokenFrom(r *http.Request) (string, bool) {
+	token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	token = strings.TrimSpace(token)
+	if found && strings.Count(token, ".") == 2 {
+		return token, false
+	}
+
+	if midd.login != nil {
+		cookie, err := r.Cookie(midd.cookieName)
+		if err == nil && cookie.Value != "" {
+			return cookie.Value, true
+		}
+	}
+
+	return "", false
+}
+
+// startLogin sends the browser to the issuer. The state and the path to go back to after the
+// login are kept on a short lived cookie.
+func (midd *oidcMiddleware) startLogin(w http.ResponseWriter, r *http.Request) {
+	state, err := auth.NewState()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal", err)
+		return
+	}
+
+	returnTo := r.URL.Query().Get("return_to")
+	if !strings.HasPrefix(returnTo, "/") || strings.HasPrefix(returnTo, "//") {
+		returnTo = "/"
+	}
+
+	midd.setCookie(w, midd.stateCookieName(), state+":"+url.QueryEscape(returnTo),
+		time.Now().Add(loginStateMaxAge))
+	http.Redirect(w, r, midd.login.AuthCodeURL(state), http.StatusFound)
+}
+
+// finishLogin checks the state, trades the code for a token and keeps it on the session cookie
+func (midd *oidcMiddleware) finishLogin(w http.ResponseWriter, r *http.Request) {
+	cookie, err := r.Cookie(midd.stateCookieName())
+	if err != nil {
+		midd.reject(w, r, authResultInvalid, http.StatusBadRequest, errInvalidLoginState)
+		return
+	}
+	midd.setCookie(w, midd.stateCookieName(), "", time.Unix(0, 0))
+
+	state, escapedReturnTo, _ := strings.Cut(cookie.Value, ":")
+	if state == "" || r.URL.Query().Get("state") != state {
+		midd.reject(w, r, authResultInvalid, http.StatusBadRequest, errInvalidLoginState)
+		return
+	}
+
+	token, identity, err := midd.login.Exchange(r.Context(), r.URL.Query().Get("code"))
+	if err != nil {
+		midd.reject(w, r, authResultInvalid, http.StatusUnauthorized, err)
+		return
+	}
+
+	midd.setCookie(w, midd.cookieName, token, identity.Expiry)
+	midd.logg.Info("oidc login", "principal", identity.Principal, "from", r.RemoteAddr)
+
+	returnTo, err := url.QueryUnescape(escapedReturnTo)
+	if err != nil || returnTo == "" {
+		returnTo = "/"
+	}
+	http.Redirect(w, r, returnTo, http.StatusFound)
+}
+
+func (midd *oidcMiddleware) setCookie(w http.ResponseWriter, name string, value string, expires time.Time) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   midd.secureCookies,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+func (midd *oidcMiddleware) stateCookieName() string {
+	return midd.cookieName + "_state"
+}
+
+func (midd *oidcMiddleware) reject(
+	w http.ResponseWriter, r *http.Request, result string, statusCode int, err error,
+) {
+	oidcRequestsTotal.WithLabelValues(result).Inc()
+	midd.logg.Warn("request rejected by oidc authentication", "reason", result, "error", err,
+		"path", r.URL.Path, "from", r.RemoteAddr)
+
+	if statusCode == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+	}
+	writeError(w, statusCode, "unauthorized", err)
+}
+
+func registerOIDCMetrics(metricz *prometheus.Registry) {
+	runOnceOIDCO11y.Do(func() {
+		oidcRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "graviola",
+			Subsystem: "http",
+			Name:      "oidc_requests_total",
+			Help:      "Counter of requests that went through OIDC authentication, by result (authenticated, missing, invalid, forbidden, exempt or skipped).",
+		},
+			[]string{"result"})
+
+		if metricz != nil {
+			metricz.MustRegister(oidcRequestsTotal)
+		}
+	})
+}
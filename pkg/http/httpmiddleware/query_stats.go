@@ -0,0 +1,27 @@
+package httpmiddleware
+
+import (
+	"net/http"
+
+	"github.com/jademcosta/graviola/pkg/querystats"
+)
+
+type queryStatsMiddleware struct {
+	next http.Handler
+}
+
+// NewQueryStatsMiddleware adds a stats collector to the context of the requests, so what
+// happens on the remotes while their queries are executed can be returned with the response.
+func NewQueryStatsMiddleware() func(next http.Handler) http.Handler {
+	midd := &queryStatsMiddleware{}
+
+	return func(next http.Handler) http.Handler {
+		midd.next = next
+		return midd
+	}
+}
+
+func (midd *queryStatsMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := querystats.NewContext(r.Context(), querystats.NewCollector())
+	midd.next.ServeHTTP(w, r.WithContext(ctx))
+}
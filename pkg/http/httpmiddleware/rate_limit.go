@@ -0,0 +1,95 @@
+package httpmiddleware
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/jademcosta/graviola/pkg/clientinfo"
+	"github.com/jademcosta/graviola/pkg/config"
+	"github.com/jademcosta/graviola/pkg/ratelimit"
+)
+
+var metadataPathSuffixes = []string{"/api/v1/labels", "/api/v1/series", "/api/v1/metadata"}
+
+type rateLimitMiddleware struct {
+	limiter *ratelimit.Limiter
+	key     string
+	next    http.Handler
+}
+
+// NewRateLimitMiddleware rejects with 429 the queries and metadata requests of clients that
+// exceeded their rate limit. The Retry-After header tells when the client can try again.
+func NewRateLimitMiddleware(limiter *ratelimit.Limiter, key string) func(next http.Handler) http.Handler {
+	midd := &rateLimitMiddleware{
+		limiter: limiter,
+		key:     key,
+	}
+
+	return func(next http.Handler) http.Handler {
+		midd.next = next
+		return midd
+	}
+}
+
+func (midd *rateLimitMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	class, limited := rateLimitClassOf(r.URL.Path)
+	if !limited {
+		midd.next.ServeHTTP(w, r)
+		return
+	}
+
+	client, identity := midd.clientOf(r)
+	allowed, retryAfter := midd.limiter.Allow(class, client, identity)
+	if !allowed {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+		writeError(w, http.StatusTooManyRequests, "unavailable",
+			fmt.Errorf("rate limit of %s requests exceeded by %s", class, client))
+		return
+	}
+
+	midd.next.ServeHTTP(w, r)
+}
+
+// clientOf returns what identifies the client, falling back to its address when it has no
+// principal or tenant. The tenant is only used for authenticated clients, otherwise changing the
+// tenant header would be enough to have a new budget. The identity is the principal when clients
+// are identified by it, as principals are only the known API keys and users. It is empty
+// otherwise, so addresses and tenant headers sent by clients don't become metric labels.
+func (midd *rateLimitMiddleware) clientOf(r *http.Request) (string, string) {
+	info, _ := clientinfo.FromContext(r.Context())
+
+	switch midd.key {
+	case config.RateLimitKeyPrincipal:
+		if info.Principal != "" {
+			return info.Principal, info.Principal
+		}
+	case config.RateLimitKeyTenant:
+		if tenant := info.AuthenticatedTenant(); tenant != "" {
+			return tenant, ""
+		}
+	}
+
+	return info.Address, ""
+}
+
+func rateLimitClassOf(path string) (string, bool) {
+	if isAdmittedPath(path) {
+		return ratelimit.ClassQuery, true
+	}
+
+	for _, suffix := range metadataPathSuffixes {
+		if strings.HasSuffix(path, suffix) {
+			return ratelimit.ClassMetadata, true
+		}
+	}
+
+	// Label values are on /api/v1/label/<name>/values
+	if strings.Contains(path, "/api/v1/label/") && strings.HasSuffix(path, "/values") {
+		return ratelimit.ClassMetadata, true
+	}
+
+	return "", false
+}
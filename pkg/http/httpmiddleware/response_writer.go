@@ -1,11 +1,16 @@
 package httpmiddleware
 
-import "net/http"
+import (
+	"net/http"
+	"time"
+)
 
 type responseWriterWrapper struct {
 	wrapped      http.ResponseWriter
 	statusCode   int
 	responseSize int
+	// firstByteAt is when the header was written, be it explicitly or by the first write
+	firstByteAt time.Time
 }
 
 func (w *responseWriterWrapper) Header() http.Header {
@@ -13,11 +18,27 @@ func (w *responseWriterWrapper) Header() http.Header {
 }
 
 func (w *responseWriterWrapper) Write(data []byte) (int, error) {
-	w.responseSize = len(data)
-	return w.wrapped.Write(data)
+	if w.statusCode == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	written, err := w.wrapped.Write(data)
+	w.responseSize += written
+	return written, err
 }
 
 func (w *responseWriterWrapper) WriteHeader(statusCode int) {
-	w.statusCode = statusCode
+	if w.statusCode == 0 {
+		w.firstByteAt = time.Now()
+		w.statusCode = statusCode
+	}
 	w.wrapped.WriteHeader(statusCode)
 }
+
+// status returns the status code sent to the client, which is 200 when the handler wrote nothing
+func (w *responseWriterWrapper) status() int {
+	if w.statusCode == 0 {
+		return http.StatusOK
+	}
+	return w.statusCode
+}
@@ -0,0 +1,110 @@
+package httpmiddleware
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// UnmatchedRoute is the route of the requests that didn't match any of the registered routes
+const UnmatchedRoute = "unmatched"
+
+const (
+	QueryTypeInstant  = "instant"
+	QueryTypeRange    = "range"
+	QueryTypeLabels   = "labels"
+	QueryTypeSeries   = "series"
+	QueryTypeMetadata = "metadata"
+	QueryTypeNone     = "none"
+)
+
+// queryTypes are the types of the API routes that query the remotes
+var queryTypes = map[string]string{
+	"/api/v1/query":              QueryTypeInstant,
+	"/api/v1/query_range":        QueryTypeRange,
+	"/api/v1/labels":             QueryTypeLabels,
+	"/api/v1/label/:name/values": QueryTypeLabels,
+	"/api/v1/series":             QueryTypeSeries,
+	"/api/v1/metadata":           QueryTypeMetadata,
+}
+
+type routeKey struct{}
+
+// matchedRoute is filled in by the routers that aren't chi, which doesn't know their routes
+type matchedRoute struct {
+	pattern string
+}
+
+type routeMiddleware struct {
+	next http.Handler
+}
+
+// NewRouteMiddleware allows the middlewares after it to know the template of the route that
+// served the request (like /api/v1/label/:name/values), instead of only its path. The routes
+// of the Prometheus API are informed by RouteInstrumentation.
+func NewRouteMiddleware() func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return &routeMiddleware{next: next}
+	}
+}
+
+func (midd *routeMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	midd.next.ServeHTTP(w, withMatchedRoute(r))
+}
+
+// withMatchedRoute makes the request ready to be informed of its route, keeping the route it
+// already has so the middlewares before it can also know it
+func withMatchedRoute(r *http.Request) *http.Request {
+	if _, ok := r.Context().Value(routeKey{}).(*matchedRoute); ok {
+		return r
+	}
+
+	return r.WithContext(context.WithValue(r.Context(), routeKey{}, &matchedRoute{}))
+}
+
+// RouteInstrumentation informs the route of the requests served by a Prometheus route.Router,
+// whose routes are registered without the given prefix.
+func RouteInstrumentation(prefix string) func(handlerName string, handler http.HandlerFunc) http.HandlerFunc {
+	return func(handlerName string, handler http.HandlerFunc) http.HandlerFunc {
+		pattern := prefix + handlerName
+		return func(w http.ResponseWriter, r *http.Request) {
+			if matched, ok := r.Context().Value(routeKey{}).(*matchedRoute); ok {
+				matched.pattern = pattern
+			}
+			handler(w, r)
+		}
+	}
+}
+
+// RouteOf returns the template of the route that served the request, once it was served. It
+// has a bounded number of values, so it is safe to be used as a label of metrics.
+func RouteOf(r *http.Request) string {
+	if matched, ok := r.Context().Value(routeKey{}).(*matchedRoute); ok && matched.pattern != "" {
+		return matched.pattern
+	}
+
+	routeCtx := chi.RouteContext(r.Context())
+	if routeCtx == nil {
+		return UnmatchedRoute
+	}
+
+	// The catch-all route is where the Prometheus API is, so reaching it means no route of the
+	// Prometheus API matched
+	pattern := routeCtx.RoutePattern()
+	if pattern == "" || pattern == "/*" {
+		return UnmatchedRoute
+	}
+
+	return pattern
+}
+
+// QueryTypeOf returns the type of query (instant, range, labels, series or metadata) the
+// route answers, or none when it doesn't query the remotes
+func QueryTypeOf(route string) string {
+	if queryType, ok := queryTypes[route]; ok {
+		return queryType
+	}
+
+	return QueryTypeNone
+}
@@ -0,0 +1,38 @@
+package httpmiddleware
+
+import (
+	"net/http"
+
+	"github.com/jademcosta/graviola/pkg/tracing"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type traceIDMiddleware struct {
+	next http.Handler
+}
+
+// NewTracingMiddleware starts the span of each request, continuing the trace of the caller when
+// it sends a traceparent header. The ID of the trace is sent back on the X-Trace-Id header.
+// The span is named after the template of the route that served the request (like
+// "GET /api/v1/label/:name/values"), as paths have unbounded values.
+func NewTracingMiddleware() func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return otelhttp.NewHandler(&traceIDMiddleware{next: next}, "http",
+			otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+				return r.Method
+			}),
+		)
+	}
+}
+
+func (midd *traceIDMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if traceID := tracing.TraceID(r.Context()); traceID != "" {
+		w.Header().Set(tracing.TraceIDHeader, traceID)
+	}
+
+	// The route is only known after the request is served
+	r = withMatchedRoute(r)
+	midd.next.ServeHTTP(w, r)
+	trace.SpanFromContext(r.Context()).SetName(r.Method + " " + RouteOf(r))
+}
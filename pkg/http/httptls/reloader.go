@@ -0,0 +1,151 @@
+package httptls
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/jademcosta/graviola/pkg/config"
+)
+
+// checkInterval is how often the certificate files are checked for changes. They are only
+// checked when a connection is opened.
+const checkInterval = 10 * time.Second
+
+var tlsVersions = map[string]uint16{
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// CertificateReloader has the certificate of the server, and loads it again when its files
+// change. This way certificates can be renewed without restarting Graviola. It is safe to be
+// used by many goroutines.
+type CertificateReloader struct {
+	logger    *slog.Logger
+	certFile  string
+	keyFile   string
+	now       func() time.Time
+	mu        sync.Mutex
+	cert      *tls.Certificate
+	modTimes  [2]time.Time
+	lastCheck time.Time
+}
+
+func NewCertificateReloader(
+	logger *slog.Logger, certFile string, keyFile string, now func() time.Time,
+) (*CertificateReloader, error) {
+	reloader := &CertificateReloader{
+		logger:   logger.With("component", "tls"),
+		certFile: certFile,
+		keyFile:  keyFile,
+		now:      now,
+	}
+
+	modTimes, err := reloader.modTimesOfFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	err = reloader.load(modTimes)
+	if err != nil {
+		return nil, err
+	}
+
+	return reloader, nil
+}
+
+// GetCertificate is meant to be used on tls.Config.GetCertificate
+func (reloader *CertificateReloader) GetCertificate(_ *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	reloader.mu.Lock()
+	defer reloader.mu.Unlock()
+
+	now := reloader.now()
+	if now.Sub(reloader.lastCheck) < checkInterval {
+		return reloader.cert, nil
+	}
+	reloader.lastCheck = now
+
+	modTimes, err := reloader.modTimesOfFiles()
+	if err != nil {
+		reloader.logger.Error("error checking the certificate files, keeping the current certificate",
+			"error", err)
+		return reloader.cert, nil
+	}
+
+	if modTimes == reloader.modTimes {
+		return reloader.cert, nil
+	}
+
+	// The files might be in the middle of being written, in which case the next check loads them
+	err = reloader.load(modTimes)
+	if err != nil {
+		reloader.logger.Error("error reloading the certificate, keeping the current one", "error", err)
+		return reloader.cert, nil
+	}
+
+	reloader.logger.Info("certificate reloaded", "cert_file", reloader.certFile)
+	return reloader.cert, nil
+}
+
+func (reloader *CertificateReloader) load(modTimes [2]time.Time) error {
+	cert, err := tls.LoadX509KeyPair(reloader.certFile, reloader.keyFile)
+	if err != nil {
+		return fmt.Errorf("error loading the certificate: %w", err)
+	}
+
+	reloader.cert = &cert
+	reloader.modTimes = modTimes
+	return nil
+}
+
+func (reloader *CertificateReloader) modTimesOfFiles() ([2]time.Time, error) {
+	var modTimes [2]time.Time
+
+	for i, path := range []string{reloader.certFile, reloader.keyFile} {
+		stat, err := os.Stat(path)
+		if err != nil {
+			return modTimes, err
+		}
+		modTimes[i] = stat.ModTime()
+	}
+
+	return modTimes, nil
+}
+
+// NewServerConfig returns the TLS config of the API server, which reloads its certificate when
+// the files change and, when there's a client CA, checks the certificates of the clients.
+func NewServerConfig(logger *slog.Logger, conf config.APITLSConfig) (*tls.Config, error) {
+	reloader, err := NewCertificateReloader(logger, conf.CertFile, conf.KeyFile, time.Now)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConf := &tls.Config{
+		MinVersion:     tlsVersions[conf.MinVersion],
+		GetCertificate: reloader.GetCertificate,
+	}
+
+	if conf.ClientCAFile != "" {
+		caPEM, err := os.ReadFile(conf.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading the client CA file: %w", err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("no certificate found on the client CA file %s", conf.ClientCAFile)
+		}
+
+		tlsConf.ClientCAs = pool
+		tlsConf.ClientAuth = tls.VerifyClientCertIfGiven
+		if conf.RequireClientCert {
+			tlsConf.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+	}
+
+	return tlsConf, nil
+}
@@ -0,0 +1,119 @@
+package httptls_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jademcosta/graviola/pkg/config"
+	"github.com/jademcosta/graviola/pkg/graviolalog"
+	"github.com/jademcosta/graviola/pkg/http/httptls"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var logger = graviolalog.NewLogger(config.LogConfig{Level: "error"})
+
+// writeCertificate writes a self-signed certificate and its key, returning the certificate
+func writeCertificate(t *testing.T, certFile string, keyFile string, commonName string) []byte {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err, "should generate a key")
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err, "should create a certificate")
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err, "should marshal the key")
+
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600),
+		"should write the certificate")
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600),
+		"should write the key")
+
+	return der
+}
+
+func TestReloadsTheCertificateWhenTheFilesChange(t *testing.T) {
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	firstCert := writeCertificate(t, certFile, keyFile, "first")
+
+	now := time.Now()
+	sut, err := httptls.NewCertificateReloader(logger, certFile, keyFile, func() time.Time { return now })
+	require.NoError(t, err, "should load the certificate")
+
+	cert, err := sut.GetCertificate(&tls.ClientHelloInfo{})
+	require.NoError(t, err, "should return the certificate")
+	assert.Equal(t, firstCert, cert.Certificate[0], "should serve the certificate of the files")
+
+	secondCert := writeCertificate(t, certFile, keyFile, "second")
+	future := now.Add(time.Minute)
+	require.NoError(t, os.Chtimes(certFile, future, future), "should change the mod time")
+	require.NoError(t, os.Chtimes(keyFile, future, future), "should change the mod time")
+
+	now = now.Add(time.Minute)
+	cert, err = sut.GetCertificate(&tls.ClientHelloInfo{})
+	require.NoError(t, err, "should return the certificate")
+	assert.Equal(t, secondCert, cert.Certificate[0], "should serve the new certificate after the files change")
+
+	require.NoError(t, os.WriteFile(keyFile, []byte("not a key"), 0600), "should write the key")
+	future = future.Add(time.Minute)
+	require.NoError(t, os.Chtimes(keyFile, future, future), "should change the mod time")
+
+	now = now.Add(time.Minute)
+	cert, err = sut.GetCertificate(&tls.ClientHelloInfo{})
+	require.NoError(t, err, "should return the certificate")
+	assert.Equal(t, secondCert, cert.Certificate[0], "should keep the current certificate when the new one is broken")
+}
+
+func TestFailsWithoutAValidCertificate(t *testing.T) {
+	dir := t.TempDir()
+
+	_, err := httptls.NewCertificateReloader(
+		logger, filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem"), time.Now)
+	require.Error(t, err, "should fail when the files don't exist")
+}
+
+func TestServerConfigChecksClientCertificates(t *testing.T) {
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	writeCertificate(t, certFile, keyFile, "graviola")
+
+	sut, err := httptls.NewServerConfig(logger, config.APITLSConfig{
+		CertFile: certFile, KeyFile: keyFile, MinVersion: "1.3",
+	})
+	require.NoError(t, err, "should create the config")
+	assert.Equal(t, uint16(tls.VersionTLS13), sut.MinVersion, "should use the min version")
+	assert.Equal(t, tls.NoClientCert, sut.ClientAuth, "should not ask for client certificates without a CA")
+
+	sut, err = httptls.NewServerConfig(logger, config.APITLSConfig{
+		CertFile: certFile, KeyFile: keyFile, MinVersion: "1.2", ClientCAFile: certFile, RequireClientCert: true,
+	})
+	require.NoError(t, err, "should create the config")
+	assert.Equal(t, tls.RequireAndVerifyClientCert, sut.ClientAuth, "should require client certificates")
+	assert.NotNil(t, sut.ClientCAs, "should check the client certificates against the CA")
+
+	_, err = httptls.NewServerConfig(logger, config.APITLSConfig{
+		CertFile: certFile, KeyFile: keyFile, MinVersion: "1.2", ClientCAFile: keyFile,
+	})
+	require.Error(t, err, "should fail when the CA file has no certificate")
+}
@@ -2,20 +2,45 @@ package o11y
 
 import (
 	"context"
+	"errors"
+	"net"
 	"sync"
 	"time"
 
+	"github.com/jademcosta/graviola/pkg/domain"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/prometheus/model/labels"
 	"github.com/prometheus/prometheus/storage"
 	"github.com/prometheus/prometheus/util/annotations"
 )
 
+const (
+	OperationSelect      = "select"
+	OperationLabelNames  = "label_names"
+	OperationLabelValues = "label_values"
+)
+
+const (
+	outcomeSuccess   = "success"
+	outcomeError     = "error"
+	outcomeTimeout   = "timeout"
+	outcomeCancelled = "cancelled"
+	outcomeSkipped   = "skipped"
+)
+
 var runOnceQuerierO11y sync.Once
 
 var querierLatency *prometheus.HistogramVec
 var querierTotal *prometheus.CounterVec
+var querierRequestsTotal *prometheus.CounterVec
+var querierRequestDuration *prometheus.HistogramVec
+var querierPartialResponsesTotal *prometheus.CounterVec
+var querierResponseBytes *prometheus.HistogramVec
+var querierResponseSeries *prometheus.HistogramVec
+var querierResponseSamples *prometheus.HistogramVec
 
+// QuerierO11y wraps a remote or a group, recording the rate, errors and duration of all of its
+// operations, along with the size of what it answered.
 type QuerierO11y struct {
 	name          string
 	typeOfQuerier string
@@ -36,15 +61,42 @@ func NewQuerierO11y(metricz *prometheus.Registry, name string, typeOfQuerier str
 	}
 }
 
+// Name returns the name of the wrapped remote/group
+func (qO11y *QuerierO11y) Name() string {
+	return qO11y.name
+}
+
 // Querier
+//
+// Select isn't sent to the wrapped querier when the query is already over (timed out or
+// cancelled), which is accounted with the outcome of the query. Selects the wrapped remote
+// answered without sending (see MarkSkipped) are accounted as skipped, without a latency.
 func (qO11y *QuerierO11y) Select(ctx context.Context, sortSeries bool, hints *storage.SelectHints,
 	matchers ...*labels.Matcher) storage.SeriesSet {
 
+	if ctx.Err() != nil {
+		qO11y.countUpRequest(OperationSelect, outcomeOf(ctx.Err()))
+		return &domain.GraviolaSeriesSet{Erro: ctx.Err()}
+	}
+
+	ctx, size := withResponseSize(ctx)
+	ctx, skipped := withSkipped(ctx)
 	start := time.Now()
 
-	qO11y.countUpQueryTotal()
 	result := qO11y.wrapped.Select(ctx, sortSeries, hints, matchers...)
-	qO11y.observeQueryLatency(float64(time.Since(start).Seconds()))
+	elapsed := time.Since(start)
+	if skipped.Load() {
+		qO11y.countUpRequest(OperationSelect, outcomeSkipped)
+		return result
+	}
+
+	qO11y.countUpQueryTotal()
+	qO11y.observeQueryLatency(float64(elapsed.Seconds()))
+
+	qO11y.observe(OperationSelect, elapsed, result.Err(), result.Warnings(), size)
+	if gravSeriesSet, ok := result.(*domain.GraviolaSeriesSet); ok && result.Err() == nil {
+		qO11y.observeSeries(gravSeriesSet.Series)
+	}
 
 	return result
 }
@@ -60,7 +112,23 @@ func (qO11y *QuerierO11y) LabelValues(
 	hints *storage.LabelHints,
 	matchers ...*labels.Matcher,
 ) ([]string, annotations.Annotations, error) {
-	return qO11y.wrapped.LabelValues(ctx, name, hints, matchers...)
+	if ctx.Err() != nil {
+		qO11y.countUpRequest(OperationLabelValues, outcomeOf(ctx.Err()))
+		return []string{}, nil, ctx.Err()
+	}
+
+	ctx, size := withResponseSize(ctx)
+	ctx, skipped := withSkipped(ctx)
+	start := time.Now()
+
+	values, annots, err := qO11y.wrapped.LabelValues(ctx, name, hints, matchers...)
+	if skipped.Load() {
+		qO11y.countUpRequest(OperationLabelValues, outcomeSkipped)
+		return values, annots, err
+	}
+	qO11y.observe(OperationLabelValues, time.Since(start), err, annots, size)
+
+	return values, annots, err
 }
 
 // LabelQuerier
@@ -69,7 +137,46 @@ func (qO11y *QuerierO11y) LabelNames(
 	hints *storage.LabelHints,
 	matchers ...*labels.Matcher,
 ) ([]string, annotations.Annotations, error) {
-	return qO11y.wrapped.LabelNames(ctx, hints, matchers...)
+	if ctx.Err() != nil {
+		qO11y.countUpRequest(OperationLabelNames, outcomeOf(ctx.Err()))
+		return []string{}, nil, ctx.Err()
+	}
+
+	ctx, size := withResponseSize(ctx)
+	ctx, skipped := withSkipped(ctx)
+	start := time.Now()
+
+	names, annots, err := qO11y.wrapped.LabelNames(ctx, hints, matchers...)
+	if skipped.Load() {
+		qO11y.countUpRequest(OperationLabelNames, outcomeSkipped)
+		return names, annots, err
+	}
+	qO11y.observe(OperationLabelNames, time.Since(start), err, annots, size)
+
+	return names, annots, err
+}
+
+// outcomeOf classifies the error answered by a querier. Timeouts of the query and of the HTTP
+// requests are told apart from the other errors, and so are the cancellations.
+func outcomeOf(err error) string {
+	if err == nil {
+		return outcomeSuccess
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		return outcomeTimeout
+	}
+
+	if errors.Is(err, context.Canceled) {
+		return outcomeCancelled
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return outcomeTimeout
+	}
+
+	return outcomeError
 }
 
 func registerMetrics(metricz *prometheus.Registry) {
@@ -79,7 +186,7 @@ func registerMetrics(metricz *prometheus.Registry) {
 			Namespace: "graviola",
 			Subsystem: "querier",
 			Name:      "query_latency_seconds",
-			Help:      "Latency of outgoing requests to a remote/group, in seconds. Only PromQL queries. Label queries are not accounted here.",
+			Help:      "Latency of outgoing requests to a remote/group, in seconds. Only PromQL queries. Label queries are not accounted here, see graviola_querier_request_duration_seconds.",
 			Buckets:   []float64{0.1, 0.25, 0.5, 0.75, 1.0, 1.5, 2.5, 5.0, 10.0, 20.0, 30.0, 45.0, 60.0},
 		},
 			[]string{"querier_type", "querier_name"})
@@ -88,11 +195,66 @@ func registerMetrics(metricz *prometheus.Registry) {
 			Namespace: "graviola",
 			Subsystem: "querier",
 			Name:      "query_total",
-			Help:      "Counter for outgoing requests. Label queries are not accounted here.",
+			Help:      "Counter for outgoing requests. Label queries are not accounted here, see graviola_querier_requests_total.",
 		},
 			[]string{"querier_type", "querier_name"})
 
-		metricz.MustRegister(querierLatency, querierTotal)
+		querierRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "graviola",
+			Subsystem: "querier",
+			Name:      "requests_total",
+			Help:      "Counter of operations (select, label_names or label_values) sent to a remote/group, by outcome (success, error, timeout, cancelled or skipped). Skipped ones weren't sent because the query is outside of the time window of the remote.",
+		},
+			[]string{"querier_type", "querier_name", "operation", "outcome"})
+
+		querierRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "graviola",
+			Subsystem: "querier",
+			Name:      "request_duration_seconds",
+			Help:      "Duration of the operations (select, label_names or label_values) sent to a remote/group, in seconds.",
+			Buckets:   []float64{0.1, 0.25, 0.5, 0.75, 1.0, 1.5, 2.5, 5.0, 10.0, 20.0, 30.0, 45.0, 60.0},
+		},
+			[]string{"querier_type", "querier_name", "operation"})
+
+		querierPartialResponsesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "graviola",
+			Subsystem: "querier",
+			Name:      "partial_responses_total",
+			Help:      "Counter of operations a remote/group answered with a partial response, because some of the queriers inside it failed.",
+		},
+			[]string{"querier_type", "querier_name", "operation"})
+
+		querierResponseBytes = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "graviola",
+			Subsystem: "querier",
+			Name:      "response_bytes",
+			Help:      "Size of the responses read from the remotes while a remote/group answered an operation, in bytes.",
+			Buckets:   prometheus.ExponentialBuckets(1024, 4, 11),
+		},
+			[]string{"querier_type", "querier_name", "operation"})
+
+		querierResponseSeries = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "graviola",
+			Subsystem: "querier",
+			Name:      "response_series",
+			Help:      "Number of series answered by a remote/group on each select.",
+			Buckets:   prometheus.ExponentialBuckets(1, 4, 10),
+		},
+			[]string{"querier_type", "querier_name"})
+
+		querierResponseSamples = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "graviola",
+			Subsystem: "querier",
+			Name:      "response_samples",
+			Help:      "Number of samples answered by a remote/group on each select.",
+			Buckets:   prometheus.ExponentialBuckets(1, 4, 13),
+		},
+			[]string{"querier_type", "querier_name"})
+
+		if metricz != nil {
+			metricz.MustRegister(querierLatency, querierTotal, querierRequestsTotal, querierRequestDuration,
+				querierPartialResponsesTotal, querierResponseBytes, querierResponseSeries, querierResponseSamples)
+		}
 	})
 }
 
@@ -103,3 +265,35 @@ func (qO11y *QuerierO11y) observeQueryLatency(value float64) {
 func (qO11y *QuerierO11y) countUpQueryTotal() {
 	querierTotal.WithLabelValues(qO11y.typeOfQuerier, qO11y.name).Inc()
 }
+
+func (qO11y *QuerierO11y) countUpRequest(operation string, outcome string) {
+	querierRequestsTotal.WithLabelValues(qO11y.typeOfQuerier, qO11y.name, operation, outcome).Inc()
+}
+
+// observe records the outcome and duration of the operation, whether it was a partial response
+// and the bytes read from the remotes to answer it (if any response was read)
+func (qO11y *QuerierO11y) observe(
+	operation string, elapsed time.Duration, err error, annots annotations.Annotations, size *responseSize,
+) {
+	qO11y.countUpRequest(operation, outcomeOf(err))
+	querierRequestDuration.WithLabelValues(qO11y.typeOfQuerier, qO11y.name, operation).Observe(elapsed.Seconds())
+
+	if err == nil && domain.HasPartialResponseWarning(annots) {
+		querierPartialResponsesTotal.WithLabelValues(qO11y.typeOfQuerier, qO11y.name, operation).Inc()
+	}
+
+	if size.responses.Load() > 0 {
+		querierResponseBytes.WithLabelValues(qO11y.typeOfQuerier, qO11y.name, operation).
+			Observe(float64(size.bytes.Load()))
+	}
+}
+
+func (qO11y *QuerierO11y) observeSeries(series []*domain.GraviolaSeries) {
+	samples := 0
+	for _, serie := range series {
+		samples += len(serie.Datapoints)
+	}
+
+	querierResponseSeries.WithLabelValues(qO11y.typeOfQuerier, qO11y.name).Observe(float64(len(series)))
+	querierResponseSamples.WithLabelValues(qO11y.typeOfQuerier, qO11y.name).Observe(float64(samples))
+}
@@ -0,0 +1,181 @@
+package o11y
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/jademcosta/graviola/internal/mocks"
+	"github.com/jademcosta/graviola/pkg/domain"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/prometheus/prometheus/util/annotations"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type netTimeoutError struct{}
+
+func (netTimeoutError) Error() string   { return "i/o timeout" }
+func (netTimeoutError) Timeout() bool   { return true }
+func (netTimeoutError) Temporary() bool { return true }
+
+func selectAnswering(seriesSet storage.SeriesSet) *mocks.RemoteStorageMock {
+	return &mocks.RemoteStorageMock{
+		SeriesSet: &domain.GraviolaSeriesSet{},
+		SelectFn: func(context.Context, bool, *storage.SelectHints, ...*labels.Matcher) storage.SeriesSet {
+			return seriesSet
+		},
+	}
+}
+
+func requests(name string, operation string, outcome string) float64 {
+	return testutil.ToFloat64(querierRequestsTotal.WithLabelValues("remote", name, operation, outcome))
+}
+
+func TestSelectsAreCountedByOutcome(t *testing.T) {
+	testCases := []struct {
+		err     error
+		outcome string
+	}{
+		{nil, outcomeSuccess},
+		{errors.New("server answered with non-succesful status code 500"), outcomeError},
+		{fmt.Errorf("error making request: %w", context.DeadlineExceeded), outcomeTimeout},
+		{fmt.Errorf("error making request: %w", netTimeoutError{}), outcomeTimeout},
+		{fmt.Errorf("error making request: %w", context.Canceled), outcomeCancelled},
+	}
+
+	for _, tc := range testCases {
+		name := "outcome-" + tc.outcome + fmt.Sprint(tc.err)
+		sut := NewQuerierO11y(prometheus.NewRegistry(), name, "remote",
+			selectAnswering(&domain.GraviolaSeriesSet{Erro: tc.err}))
+
+		sut.Select(context.Background(), false, &storage.SelectHints{})
+
+		assert.Equal(t, 1.0, requests(name, OperationSelect, tc.outcome),
+			"should count the select of error %v as %s", tc.err, tc.outcome)
+		assert.Equal(t, 1.0, testutil.ToFloat64(querierTotal.WithLabelValues("remote", name)),
+			"should keep counting the selects on the old metric")
+	}
+}
+
+func TestLabelQueriesAreCounted(t *testing.T) {
+	remote := &mocks.RemoteStorageMock{SeriesSet: &domain.GraviolaSeriesSet{}}
+	sut := NewQuerierO11y(prometheus.NewRegistry(), "labels", "remote", remote)
+
+	_, _, err := sut.LabelNames(context.Background(), nil)
+	require.NoError(t, err, "should not fail")
+	_, _, err = sut.LabelValues(context.Background(), "job", nil)
+	require.NoError(t, err, "should not fail")
+
+	assert.Equal(t, 1.0, requests("labels", OperationLabelNames, outcomeSuccess), "should count the label names")
+	assert.Equal(t, 1.0, requests("labels", OperationLabelValues, outcomeSuccess), "should count the label values")
+
+	remote.Error = errors.New("some error")
+	_, _, err = sut.LabelValues(context.Background(), "job", nil)
+	require.Error(t, err, "should answer the error of the wrapped querier")
+	assert.Equal(t, 1.0, requests("labels", OperationLabelValues, outcomeError), "should count the failed label values")
+}
+
+func TestQueriersAreNotCalledWhenTheQueryIsOver(t *testing.T) {
+	remote := &mocks.RemoteStorageMock{SeriesSet: &domain.GraviolaSeriesSet{}}
+	sut := NewQuerierO11y(prometheus.NewRegistry(), "over", "remote", remote)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	seriesSet := sut.Select(ctx, false, &storage.SelectHints{})
+	require.ErrorIs(t, seriesSet.Err(), context.Canceled, "should answer the error of the context")
+	_, _, err := sut.LabelNames(ctx, nil)
+	require.ErrorIs(t, err, context.Canceled, "should answer the error of the context")
+
+	assert.Empty(t, remote.CalledWithContexts, "should not call the wrapped querier")
+	assert.Equal(t, 1.0, requests("over", OperationSelect, outcomeCancelled), "should count the select as cancelled")
+	assert.Equal(t, 1.0, requests("over", OperationLabelNames, outcomeCancelled),
+		"should count the label names as cancelled")
+	assert.Equal(t, 0.0, requests("over", OperationSelect, outcomeSkipped), "should not count the select as skipped")
+}
+
+func TestQueriersThatDidNotSendTheOperationAreSkipped(t *testing.T) {
+	remote := &mocks.RemoteStorageMock{
+		SeriesSet: &domain.GraviolaSeriesSet{},
+		SelectFn: func(ctx context.Context, _ bool, _ *storage.SelectHints, _ ...*labels.Matcher) storage.SeriesSet {
+			MarkSkipped(ctx)
+			return &domain.GraviolaSeriesSet{}
+		},
+	}
+	sut := NewQuerierO11y(prometheus.NewRegistry(), "skipping", "remote", remote)
+	group := NewQuerierO11y(prometheus.NewRegistry(), "skipping-group", "group", sut)
+
+	group.Select(context.Background(), false, &storage.SelectHints{})
+
+	assert.Equal(t, 1.0, requests("skipping", OperationSelect, outcomeSkipped), "should count the select as skipped")
+	assert.Equal(t, 0.0, requests("skipping", OperationSelect, outcomeSuccess),
+		"should not count the select as a success")
+	assert.Equal(t, 0.0, testutil.ToFloat64(querierTotal.WithLabelValues("remote", "skipping")),
+		"should not count the select as a query sent")
+	assert.Equal(t, 1.0,
+		testutil.ToFloat64(querierRequestsTotal.WithLabelValues("group", "skipping-group", OperationSelect, outcomeSuccess)),
+		"should count the select of the group the remote belongs to as a success")
+}
+
+func TestPartialResponsesAreCounted(t *testing.T) {
+	annots := annotations.New().Add(domain.NewPartialResponseWarning(errors.New("partial response")))
+	sut := NewQuerierO11y(prometheus.NewRegistry(), "partial", "group",
+		selectAnswering(&domain.GraviolaSeriesSet{Annots: annots}))
+
+	sut.Select(context.Background(), false, &storage.SelectHints{})
+
+	assert.Equal(t, 1.0,
+		testutil.ToFloat64(querierPartialResponsesTotal.WithLabelValues("group", "partial", OperationSelect)),
+		"should count the partial response")
+	assert.Equal(t, 1.0,
+		testutil.ToFloat64(querierRequestsTotal.WithLabelValues("group", "partial", OperationSelect, outcomeSuccess)),
+		"should count the partial response as a success")
+}
+
+func TestTheSizeOfTheResponsesIsObserved(t *testing.T) {
+	series := []*domain.GraviolaSeries{
+		{Lbs: labels.FromStrings("job", "a"), Datapoints: []model.SamplePair{{Timestamp: 1, Value: 1}, {Timestamp: 2, Value: 2}}},
+		{Lbs: labels.FromStrings("job", "b"), Datapoints: []model.SamplePair{{Timestamp: 1, Value: 1}}},
+	}
+
+	remote := &mocks.RemoteStorageMock{
+		SeriesSet: &domain.GraviolaSeriesSet{},
+		SelectFn: func(ctx context.Context, _ bool, _ *storage.SelectHints, _ ...*labels.Matcher) storage.SeriesSet {
+			AddResponseBytes(ctx, 1000)
+			AddResponseBytes(ctx, 500)
+			return &domain.GraviolaSeriesSet{Series: series}
+		},
+	}
+	remoteO11y := NewQuerierO11y(prometheus.NewRegistry(), "sized-remote", "remote", remote)
+	sut := NewQuerierO11y(prometheus.NewRegistry(), "sized-group", "group", remoteO11y)
+
+	sut.Select(context.Background(), false, &storage.SelectHints{})
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(querierResponseBytes, querierResponseSeries, querierResponseSamples)
+	families, err := registry.Gather()
+	require.NoError(t, err, "should gather the metrics")
+
+	sums := make(map[string]float64)
+	for _, family := range families {
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if label.GetName() == "querier_name" {
+					sums[family.GetName()+"/"+label.GetValue()] = metric.GetHistogram().GetSampleSum()
+				}
+			}
+		}
+	}
+
+	assert.Equal(t, 1500.0, sums["graviola_querier_response_bytes/sized-remote"], "should observe the bytes of the remote")
+	assert.Equal(t, 1500.0, sums["graviola_querier_response_bytes/sized-group"],
+		"should observe the bytes of the remotes on the group too")
+	assert.Equal(t, 2.0, sums["graviola_querier_response_series/sized-group"], "should observe the series")
+	assert.Equal(t, 3.0, sums["graviola_querier_response_samples/sized-group"], "should observe the samples")
+}
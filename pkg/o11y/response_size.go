@@ -0,0 +1,32 @@
+package o11y
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+type responseSizeKey struct{}
+
+// responseSize sums the bytes of the responses read while a querier answers. The ones of the
+// queriers inside a group are added to the group too, through the parent.
+type responseSize struct {
+	bytes     atomic.Int64
+	responses atomic.Int64
+	parent    *responseSize
+}
+
+func withResponseSize(ctx context.Context) (context.Context, *responseSize) {
+	parent, _ := ctx.Value(responseSizeKey{}).(*responseSize)
+	size := &responseSize{parent: parent}
+	return context.WithValue(ctx, responseSizeKey{}, size), size
+}
+
+// AddResponseBytes registers that a response with this many bytes was read from a remote, so
+// the remote (and the groups it belongs to) can observe the size of what they answered
+func AddResponseBytes(ctx context.Context, bytes int) {
+	size, _ := ctx.Value(responseSizeKey{}).(*responseSize)
+	for ; size != nil; size = size.parent {
+		size.bytes.Add(int64(bytes))
+		size.responses.Add(1)
+	}
+}
@@ -0,0 +1,22 @@
+package o11y
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+type skippedKey struct{}
+
+func withSkipped(ctx context.Context) (context.Context, *atomic.Bool) {
+	skipped := &atomic.Bool{}
+	return context.WithValue(ctx, skippedKey{}, skipped), skipped
+}
+
+// MarkSkipped registers that a remote answered without sending the operation, like when the
+// query is outside of its time window, so it is accounted as skipped instead of as a success.
+// Only the innermost remote/group is marked, as the groups it belongs to still answered.
+func MarkSkipped(ctx context.Context) {
+	if skipped, ok := ctx.Value(skippedKey{}).(*atomic.Bool); ok {
+		skipped.Store(true)
+	}
+}
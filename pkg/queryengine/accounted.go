@@ -0,0 +1,91 @@
+package queryengine
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/promql/parser"
+	"github.com/prometheus/prometheus/util/stats"
+)
+
+// Account makes a query that the wrapped engine doesn't evaluate (like split queries, or the
+// ones answered by the results cache) be executed the same way as the ones it does: with a
+// concurrency slot, the timeout, the limits and an entry on the query log. The stats of the query
+// must have timers, as the execution time is recorded on them.
+func (gravQueryEng *GraviolaQueryEngine) Account(query promql.Query) promql.Query {
+	return gravQueryEng.wrap(&accountedQuery{Query: query, engine: gravQueryEng})
+}
+
+type accountedQuery struct {
+	promql.Query
+	engine *GraviolaQueryEngine
+}
+
+// Query
+func (query *accountedQuery) Exec(ctx context.Context) (result *promql.Result) {
+	ctx, cancelFn := context.WithTimeout(ctx, query.engine.timeout)
+	defer cancelFn()
+
+	defer func() {
+		query.engine.logQuery(ctx, query.Query, result.Err)
+	}()
+
+	execTimer, ctx := query.Stats().Timers.GetSpanTimer(ctx, stats.ExecTotalTime)
+	defer execTimer.Finish()
+
+	queueTimer, _ := query.Stats().Timers.GetSpanTimer(ctx, stats.ExecQueueTime)
+	slot, err := query.engine.queryTracker.Insert(ctx, query.String())
+	queueTimer.Finish()
+	if err != nil {
+		return &promql.Result{Err: err}
+	}
+	defer query.engine.queryTracker.Delete(slot)
+
+	result = query.Query.Exec(ctx)
+	if result.Err == nil && query.exceedsMaxSamples() {
+		return &promql.Result{Err: promql.ErrTooManySamples(samplesLimitEnv), Warnings: result.Warnings}
+	}
+
+	return result
+}
+
+func (query *accountedQuery) exceedsMaxSamples() bool {
+	samples := query.Stats().Samples
+	return query.engine.maxSamples > 0 && samples != nil && samples.PeakSamples > query.engine.maxSamples
+}
+
+// logQuery writes to the query log the queries that the wrapped engine doesn't execute itself,
+// with the same attributes it uses
+func (gravQueryEng *GraviolaQueryEngine) logQuery(ctx context.Context, query promql.Query, err error) {
+	gravQueryEng.queryLoggerMu.RLock()
+	defer gravQueryEng.queryLoggerMu.RUnlock()
+	if gravQueryEng.queryLogger == nil {
+		return
+	}
+
+	params := map[string]interface{}{"query": query.String()}
+	if evalStmt, ok := query.Statement().(*parser.EvalStmt); ok {
+		params["start"] = formatDate(evalStmt.Start)
+		params["end"] = formatDate(evalStmt.End)
+		params["step"] = int64(evalStmt.Interval / time.Second)
+	}
+
+	attrs := []slog.Attr{slog.Any("params", params)}
+	if err != nil {
+		attrs = append(attrs, slog.Any("error", err))
+	}
+	attrs = append(attrs, slog.Any("stats", stats.NewQueryStats(query.Stats())))
+	if origin, ok := ctx.Value(promql.QueryOrigin{}).(map[string]interface{}); ok {
+		for key, value := range origin {
+			attrs = append(attrs, slog.Any(key, value))
+		}
+	}
+
+	slog.New(gravQueryEng.queryLogger).LogAttrs(context.Background(), slog.LevelInfo, "promql query logged", attrs...)
+}
+
+func formatDate(t time.Time) string {
+	return t.UTC().Format("2006-01-02T15:04:05.000Z07:00")
+}
@@ -2,54 +2,128 @@ package queryengine
 
 import (
 	"context"
+	"fmt"
 	"log/slog"
+	"sync"
 	"time"
 
 	"github.com/jademcosta/graviola/pkg/config"
+	"github.com/jademcosta/graviola/pkg/querylimits"
+	"github.com/jademcosta/graviola/pkg/querylog"
+	"github.com/jademcosta/graviola/pkg/querystats"
 	"github.com/jademcosta/graviola/pkg/querytracker"
+	"github.com/jademcosta/graviola/pkg/tracing"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/model/timestamp"
 	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/promql/parser"
 	"github.com/prometheus/prometheus/storage"
+	"github.com/prometheus/prometheus/util/stats"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // This is a thin wrapper of Prometheus query engine, used to make it easier to debug and add
 // telemetry. A query engine breaks the query into smaller pieces and send those pieces to the
 // storage.
+// When a split interval is configured, long range queries are split into smaller ones that are
+// sent in parallel.
 type GraviolaQueryEngine struct {
 	logger             *slog.Logger
 	wrappedQueryEngine *promql.Engine
+	// splitEngine executes the sub-queries of split queries. It has no query tracker nor query
+	// logger, as the split query does that for all of its sub-queries.
+	splitEngine      *promql.Engine
+	queryTracker     *querytracker.GraviolaQueryTracker
+	timeout          time.Duration
+	maxSamples       int
+	splitInterval    time.Duration
+	maxParallelism   int
+	identityHeaders  []string
+	limits           config.QueryLimitsConfig
+	maxQueryRange    time.Duration
+	maxQueryLookback time.Duration
+	now              func() time.Time
+
+	queryLoggerMu sync.RWMutex
+	queryLogger   promql.QueryLogger
 }
 
 func NewGraviolaQueryEngine(
-	logger *slog.Logger, metricRegistry *prometheus.Registry, conf config.GraviolaConfig,
+	logger *slog.Logger, metricRegistry *prometheus.Registry, conf config.GraviolaConfig, now func() time.Time,
 ) *GraviolaQueryEngine {
+	queryTracker := querytracker.NewGraviolaQueryTracker(
+		logger, conf.QueryConf.ConcurrentQueries, conf.QueryConf.ActiveQueryLogDir)
+
 	wrappedPromQLEngine := promql.NewEngine(promql.EngineOpts{
 		Timeout:              conf.QueryConf.TimeoutDuration(),
 		MaxSamples:           conf.QueryConf.MaxSamples,
 		LookbackDelta:        conf.QueryConf.LookbackDeltaDuration(),
 		EnableAtModifier:     true,
 		EnableNegativeOffset: true,
-		ActiveQueryTracker:   querytracker.NewGraviolaQueryTracker(conf.QueryConf.ConcurrentQueries),
+		ActiveQueryTracker:   queryTracker,
 		Reg:                  metricRegistry,
 		Logger:               logger,
 	})
 
+	splitEngine := promql.NewEngine(promql.EngineOpts{
+		Timeout:              conf.QueryConf.TimeoutDuration(),
+		MaxSamples:           conf.QueryConf.MaxSamples,
+		LookbackDelta:        conf.QueryConf.LookbackDeltaDuration(),
+		EnableAtModifier:     true,
+		EnableNegativeOffset: true,
+		Logger:               logger,
+	})
+
 	return &GraviolaQueryEngine{
 		logger:             logger,
 		wrappedQueryEngine: wrappedPromQLEngine,
+		splitEngine:        splitEngine,
+		queryTracker:       queryTracker,
+		timeout:            conf.QueryConf.TimeoutDuration(),
+		maxSamples:         conf.QueryConf.MaxSamples,
+		splitInterval:      conf.QueryConf.SplitConf.IntervalDuration(),
+		maxParallelism:     max(conf.QueryConf.SplitConf.MaxParallelism, 1),
+		identityHeaders:    conf.QueryLogConf.IdentityHeaders,
+		limits:             conf.QueryConf.LimitsConf,
+		maxQueryRange:      conf.QueryConf.LimitsConf.MaxQueryRangeDuration(),
+		maxQueryLookback:   conf.QueryConf.LimitsConf.MaxQueryLookbackDuration(),
+		now:                now,
 	}
 }
 
+// QueryTracker returns the tracker of the queries being executed by this engine
+func (gravQueryEng *GraviolaQueryEngine) QueryTracker() *querytracker.GraviolaQueryTracker {
+	return gravQueryEng.queryTracker
+}
+
 // QueryEngine
-func (gravQueryEng *GraviolaQueryEngine) SetQueryLogger(_ promql.QueryLogger) {
-	panic("should not be called")
+// The previous logger, if any, is closed by the wrapped engine.
+func (gravQueryEng *GraviolaQueryEngine) SetQueryLogger(queryLogger promql.QueryLogger) {
+	gravQueryEng.queryLoggerMu.Lock()
+	defer gravQueryEng.queryLoggerMu.Unlock()
+
+	gravQueryEng.wrappedQueryEngine.SetQueryLogger(queryLogger)
+	gravQueryEng.queryLogger = queryLogger
 }
 
 // QueryEngine
 func (gravQueryEng *GraviolaQueryEngine) NewInstantQuery(
 	ctx context.Context, queriable storage.Queryable, opts promql.QueryOpts, qs string, ts time.Time,
 ) (promql.Query, error) {
-	return gravQueryEng.wrappedQueryEngine.NewInstantQuery(ctx, queriable, opts, qs, ts)
+	querystats.FromContext(ctx).RecordQuery(querystats.QueryInfo{Query: qs, Start: ts, End: ts})
+
+	query, err := gravQueryEng.wrappedQueryEngine.NewInstantQuery(ctx, queriable, opts, qs, ts)
+	if err != nil {
+		return query, err
+	}
+
+	err = gravQueryEng.checkTimeLimits(query.Statement())
+	if err != nil {
+		query.Close()
+		return nil, err
+	}
+
+	return gravQueryEng.wrap(query), nil
 }
 
 // QueryEngine
@@ -57,5 +131,117 @@ func (gravQueryEng *GraviolaQueryEngine) NewRangeQuery(
 	ctx context.Context, queriable storage.Queryable, opts promql.QueryOpts, qs string, start, end time.Time,
 	interval time.Duration,
 ) (promql.Query, error) {
-	return gravQueryEng.wrappedQueryEngine.NewRangeQuery(ctx, queriable, opts, qs, start, end, interval)
+	querystats.FromContext(ctx).RecordQuery(querystats.QueryInfo{Query: qs, Start: start, End: end, Step: interval})
+
+	fullQuery, err := gravQueryEng.wrappedQueryEngine.NewRangeQuery(ctx, queriable, opts, qs, start, end, interval)
+	if err != nil {
+		return fullQuery, err
+	}
+
+	err = gravQueryEng.checkTimeLimits(fullQuery.Statement())
+	if err != nil {
+		fullQuery.Close()
+		return nil, err
+	}
+	if interval <= 0 || interval >= gravQueryEng.splitInterval {
+		return gravQueryEng.wrap(fullQuery), nil
+	}
+
+	ranges := splitRange(start, end, interval, gravQueryEng.splitInterval)
+	if len(ranges) < 2 {
+		return gravQueryEng.wrap(fullQuery), nil
+	}
+
+	if !isSplittable(fullQuery.Statement(), gravQueryEng.splitInterval) {
+		gravQueryEng.logger.Debug("query cannot be split, running it as a single query", "query", qs)
+		return gravQueryEng.wrap(fullQuery), nil
+	}
+
+	return gravQueryEng.Account(&splitRangeQuery{
+		engine:         gravQueryEng,
+		fullQuery:      fullQuery,
+		queryable:      queriable,
+		opts:           opts,
+		qs:             qs,
+		ranges:         ranges,
+		interval:       interval,
+		maxParallelism: gravQueryEng.maxParallelism,
+		stats:          &stats.Statistics{Timers: stats.NewQueryTimers(), Samples: stats.NewQuerySamples(false)},
+	}), nil
+}
+
+// checkTimeLimits rejects queries that are too long or go too far into the past, before any
+// data is fetched. How far into the past a query goes is the earliest time any of its selectors
+// reads, which includes their offsets, ranges and subqueries.
+func (gravQueryEng *GraviolaQueryEngine) checkTimeLimits(statement parser.Statement) error {
+	evalStmt, ok := statement.(*parser.EvalStmt)
+	if !ok {
+		return nil
+	}
+
+	if gravQueryEng.maxQueryRange > 0 && evalStmt.End.Sub(evalStmt.Start) > gravQueryEng.maxQueryRange {
+		return fmt.Errorf("%w: the query range of %s is longer than %s (max_query_range)",
+			querylimits.ErrLimitExceeded, evalStmt.End.Sub(evalStmt.Start), gravQueryEng.maxQueryRange)
+	}
+
+	if gravQueryEng.maxQueryLookback > 0 &&
+		earliestRead(evalStmt).Before(gravQueryEng.now().Add(-gravQueryEng.maxQueryLookback)) {
+		return fmt.Errorf("%w: the query reads data from more than %s in the past (max_query_lookback)",
+			querylimits.ErrLimitExceeded, gravQueryEng.maxQueryLookback)
+	}
+
+	return nil
+}
+
+// earliestRead returns the earliest time read by the query, or its start when it reads nothing
+// before it. The lookback delta is not taken into account, so that a query starting right at the
+// max query lookback is accepted.
+func earliestRead(evalStmt *parser.EvalStmt) time.Time {
+	withoutLookback := *evalStmt
+	withoutLookback.LookbackDelta = 0
+
+	mint, maxt := promql.FindMinMaxTime(&withoutLookback)
+	if mint == 0 && maxt == 0 {
+		// The query has no selectors
+		return evalStmt.Start
+	}
+
+	return timestamp.Time(min(mint, timestamp.FromTime(evalStmt.Start)))
+}
+
+func (gravQueryEng *GraviolaQueryEngine) wrap(query promql.Query) promql.Query {
+	return &graviolaQuery{
+		Query:           query,
+		identityHeaders: gravQueryEng.identityHeaders,
+		limits:          gravQueryEng.limits,
+	}
+}
+
+// graviolaQuery adds to the context of the query what is needed while it is executed: the
+// information about the client, used by the query logger, and the limiter of what it fetches.
+type graviolaQuery struct {
+	promql.Query
+	identityHeaders []string
+	limits          config.QueryLimitsConfig
+}
+
+func (query *graviolaQuery) Exec(ctx context.Context) *promql.Result {
+	ctx, span := tracing.Start(ctx, "engine.exec", attribute.String("graviola.query", query.String()))
+	defer span.End()
+
+	ctx, cancelFn := context.WithCancelCause(ctx)
+	defer cancelFn(nil)
+
+	limiter := querylimits.NewLimiter(query.limits, cancelFn)
+	ctx = querylimits.NewContext(ctx, limiter)
+
+	result := query.Query.Exec(querylog.NewOriginContext(ctx, query.identityHeaders))
+	if limitErr := limiter.Err(); limitErr != nil {
+		// The limit error is returned instead of the errors caused by the cancellation
+		tracing.RecordError(span, limitErr)
+		return &promql.Result{Err: limitErr, Warnings: result.Warnings}
+	}
+
+	tracing.RecordError(span, result.Err)
+	return result
 }
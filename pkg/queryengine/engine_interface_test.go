@@ -3,10 +3,12 @@ package queryengine_test
 import (
 	"os"
 	"testing"
+	"time"
 
 	"github.com/jademcosta/graviola/pkg/config"
 	"github.com/jademcosta/graviola/pkg/graviolalog"
 	"github.com/jademcosta/graviola/pkg/queryengine"
+	"github.com/jademcosta/graviola/pkg/resultscache"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/prometheus/promql"
 )
@@ -22,8 +24,10 @@ func TestGraviolaQueryEngineComplyWithPromqlQueryEngine(_ *testing.T) {
 	conf := config.MustParse(confContent)
 
 	dummyFunc := func(_ promql.QueryEngine) {}
+	accountantFunc := func(_ resultscache.Accountant) {}
 
-	sut := queryengine.NewGraviolaQueryEngine(logger, registry, conf)
+	sut := queryengine.NewGraviolaQueryEngine(logger, registry, conf, time.Now)
 
 	dummyFunc(sut)
+	accountantFunc(sut)
 }
@@ -255,8 +255,8 @@ func TestIntegrationSendsExpectedHintsAndLabelMatchers(t *testing.T) {
 			mockQuerier,
 		}
 
-		gravStorage := storageproxy.NewGraviolaStorage(logger, groups, defaultMergeStrategy)
-		sut := queryengine.NewGraviolaQueryEngine(logger, reg, conf)
+		gravStorage := storageproxy.NewGraviolaStorage(logger, groups, defaultMergeStrategy, 0, nil)
+		sut := queryengine.NewGraviolaQueryEngine(logger, reg, conf, time.Now)
 
 		querier, err := sut.NewInstantQuery(
 			ctx, gravStorage, promql.NewPrometheusQueryOpts(false, 0), tc.query, currentTime)
@@ -309,8 +309,8 @@ func TestIntegrationSendsExpectedHintsAndLabelMatchers(t *testing.T) {
 			mockQuerier,
 		}
 
-		gravStorage := storageproxy.NewGraviolaStorage(logger, groups, defaultMergeStrategy)
-		sut := queryengine.NewGraviolaQueryEngine(logger, reg, conf)
+		gravStorage := storageproxy.NewGraviolaStorage(logger, groups, defaultMergeStrategy, 0, nil)
+		sut := queryengine.NewGraviolaQueryEngine(logger, reg, conf, time.Now)
 
 		startTime := currentTime.Add(-rangeQueryLookback)
 		endTime := currentTime
@@ -443,8 +443,8 @@ func TestIntegrationHandlesCorrectlyTheReturnedSeriesSetOnInstantQuery(t *testin
 			mockQuerier,
 		}
 
-		gravStorage := storageproxy.NewGraviolaStorage(logger, groups, defaultMergeStrategy)
-		eng := queryengine.NewGraviolaQueryEngine(logger, reg, conf)
+		gravStorage := storageproxy.NewGraviolaStorage(logger, groups, defaultMergeStrategy, 0, nil)
+		eng := queryengine.NewGraviolaQueryEngine(logger, reg, conf, time.Now)
 
 		querier, err := eng.NewInstantQuery(ctx, gravStorage, promql.NewPrometheusQueryOpts(false, 0), tc.query, currentTime)
 		require.NoError(t, err, "should return no error")
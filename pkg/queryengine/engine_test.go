@@ -6,10 +6,12 @@ import (
 	"testing"
 	"time"
 
+	"github.com/jademcosta/graviola/internal/mocks"
 	"github.com/jademcosta/graviola/pkg/config"
 	"github.com/jademcosta/graviola/pkg/domain"
 	"github.com/jademcosta/graviola/pkg/graviolalog"
 	"github.com/jademcosta/graviola/pkg/queryengine"
+	"github.com/jademcosta/graviola/pkg/querylimits"
 	"github.com/jademcosta/graviola/pkg/remotestoragegroup"
 	"github.com/jademcosta/graviola/pkg/storageproxy"
 	"github.com/prometheus/client_golang/prometheus"
@@ -19,9 +21,10 @@ import (
 	"github.com/prometheus/prometheus/storage"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
 )
 
-var defaultMergeStrategy remotestoragegroup.MergeStrategy = remotestoragegroup.MergeStrategyFactory(config.DefaultMergeStrategyType)
+var defaultMergeStrategy remotestoragegroup.MergeStrategy = remotestoragegroup.MergeStrategyFactory(config.MergeStrategyConfig{Strategy: config.DefaultMergeStrategyType}, nil)
 
 func TestSampleLimit(t *testing.T) {
 	logger := graviolalog.NewLogger(conf.LogConf)
@@ -152,8 +155,8 @@ func TestSampleLimit(t *testing.T) {
 			selectReturn: tc.returnSet,
 		}
 
-		gravStorage := storageproxy.NewGraviolaStorage(logger, []storage.Querier{mock1}, defaultMergeStrategy)
-		eng := queryengine.NewGraviolaQueryEngine(logger, reg, conf)
+		gravStorage := storageproxy.NewGraviolaStorage(logger, []storage.Querier{mock1}, defaultMergeStrategy, 0, nil)
+		eng := queryengine.NewGraviolaQueryEngine(logger, reg, conf, time.Now)
 
 		querier, err := eng.NewInstantQuery(ctx, gravStorage, promql.NewPrometheusQueryOpts(false, 0), "up", currentTime)
 		require.NoError(t, err, "should return no error")
@@ -187,8 +190,8 @@ func TestEngineUsesTheProvidedLookbackDelta(t *testing.T) {
 		selectReturn: storage.NoopSeriesSet(),
 	}
 
-	gravStorage := storageproxy.NewGraviolaStorage(logger, []storage.Querier{mock1}, defaultMergeStrategy)
-	eng := queryengine.NewGraviolaQueryEngine(logger, reg, conf)
+	gravStorage := storageproxy.NewGraviolaStorage(logger, []storage.Querier{mock1}, defaultMergeStrategy, 0, nil)
+	eng := queryengine.NewGraviolaQueryEngine(logger, reg, conf, time.Now)
 
 	querier, err := eng.NewInstantQuery(ctx, gravStorage, promql.NewPrometheusQueryOpts(false, 0), "up", currentTime)
 	require.NoError(t, err, "should return no error")
@@ -230,8 +233,8 @@ func TestEngineConcurrentQueriesLimit(t *testing.T) {
 		delay:        200 * time.Millisecond,
 	}
 
-	gravStorage := storageproxy.NewGraviolaStorage(logger, []storage.Querier{mock1}, defaultMergeStrategy)
-	sut := queryengine.NewGraviolaQueryEngine(logger, reg, conf)
+	gravStorage := storageproxy.NewGraviolaStorage(logger, []storage.Querier{mock1}, defaultMergeStrategy, 0, nil)
+	sut := queryengine.NewGraviolaQueryEngine(logger, reg, conf, time.Now)
 
 	querier, err := sut.NewInstantQuery(ctx, gravStorage, promql.NewPrometheusQueryOpts(false, 0), metricName, currentTime)
 	require.NoError(t, err, "should return no error")
@@ -254,3 +257,119 @@ func TestEngineConcurrentQueriesLimit(t *testing.T) {
 
 	assert.GreaterOrEqual(t, elapsed, 400*time.Millisecond, "should have respected the concurrent queries limit")
 }
+
+func newLimitedSut(limits config.QueryLimitsConfig, now time.Time) *queryengine.GraviolaQueryEngine {
+	return queryengine.NewGraviolaQueryEngine(graviolalog.NewLogger(conf.LogConf), prometheus.NewRegistry(),
+		config.GraviolaConfig{
+			QueryConf: config.QueryConfig{
+				MaxSamples:        1000,
+				LookbackDelta:     config.DefaultQueryLookbackDelta,
+				ConcurrentQueries: 10,
+				Timeout:           "1m",
+				LimitsConf:        limits,
+			},
+		}, func() time.Time { return now })
+}
+
+func TestEngineRejectsQueriesBeyondTheTimeLimits(t *testing.T) {
+	logger := graviolalog.NewLogger(conf.LogConf)
+	gravStorage := storageproxy.NewGraviolaStorage(
+		logger, []storage.Querier{&mocks.RemoteStorageMock{}}, defaultMergeStrategy, 0, nil)
+	now := time.Date(2024, 5, 20, 12, 0, 0, 0, time.UTC)
+	sut := newLimitedSut(config.QueryLimitsConfig{MaxQueryRange: "1d", MaxQueryLookback: "7d"}, now)
+	ctx := context.Background()
+
+	query, err := sut.NewRangeQuery(ctx, gravStorage, nil, "up", now.Add(-24*time.Hour), now, time.Minute)
+	require.NoError(t, err, "should accept range queries up to the max query range")
+	query.Close()
+
+	_, err = sut.NewRangeQuery(ctx, gravStorage, nil, "up", now.Add(-25*time.Hour), now, time.Minute)
+	assert.ErrorIs(t, err, querylimits.ErrLimitExceeded, "should reject range queries longer than the max query range")
+
+	_, err = sut.NewRangeQuery(ctx, gravStorage, nil, "up", now.Add(-8*24*time.Hour), now.Add(-7*24*time.Hour), time.Minute)
+	assert.ErrorIs(t, err, querylimits.ErrLimitExceeded, "should reject range queries starting before the max query lookback")
+
+	query, err = sut.NewInstantQuery(ctx, gravStorage, nil, "up", now.Add(-6*24*time.Hour))
+	require.NoError(t, err, "should accept instant queries inside the max query lookback")
+	query.Close()
+
+	_, err = sut.NewInstantQuery(ctx, gravStorage, nil, "up", now.Add(-8*24*time.Hour))
+	assert.ErrorIs(t, err, querylimits.ErrLimitExceeded, "should reject instant queries before the max query lookback")
+
+	for _, qs := range []string{"up offset 2d", "rate(up[2d])", "max_over_time(up[2d:5m])", "up @ 0"} {
+		_, err = sut.NewInstantQuery(ctx, gravStorage, nil, qs, now.Add(-6*24*time.Hour))
+		assert.ErrorIs(t, err, querylimits.ErrLimitExceeded,
+			"should reject %s, as it reads data from before the max query lookback", qs)
+	}
+}
+
+func TestEngineAbortsQueriesThatExceedAFetchLimit(t *testing.T) {
+	logger := graviolalog.NewLogger(conf.LogConf)
+
+	slowRemoteCancelled := make(chan struct{})
+	slowRemote := &mocks.RemoteStorageMock{
+		SelectFn: func(ctx context.Context, _ bool, _ *storage.SelectHints, _ ...*labels.Matcher) storage.SeriesSet {
+			select {
+			case <-ctx.Done():
+				close(slowRemoteCancelled)
+				return &domain.GraviolaSeriesSet{Erro: ctx.Err()}
+			case <-time.After(5 * time.Second):
+				return &domain.GraviolaSeriesSet{}
+			}
+		},
+	}
+	greedyRemote := &mocks.RemoteStorageMock{
+		SelectFn: func(ctx context.Context, _ bool, _ *storage.SelectHints, _ ...*labels.Matcher) storage.SeriesSet {
+			// Remotes account what they fetch, like this
+			err := querylimits.FromContext(ctx).AddSeries(3)
+			return &domain.GraviolaSeriesSet{Erro: err}
+		},
+	}
+
+	gravStorage := storageproxy.NewGraviolaStorage(
+		logger, []storage.Querier{slowRemote, greedyRemote}, defaultMergeStrategy, 0, nil)
+	sut := newLimitedSut(config.QueryLimitsConfig{MaxSeries: 2}, time.Now())
+
+	query, err := sut.NewInstantQuery(context.Background(), gravStorage, nil, "up", time.Now())
+	require.NoError(t, err, "should create the query")
+	defer query.Close()
+
+	result := query.Exec(context.Background())
+	require.ErrorIs(t, result.Err, querylimits.ErrLimitExceeded, "should fail the query with the limit error")
+	assert.ErrorContains(t, result.Err, "max_series", "should tell which limit was exceeded")
+
+	select {
+	case <-slowRemoteCancelled:
+	default:
+		assert.Fail(t, "should have cancelled the requests in-flight")
+	}
+}
+
+func TestQueriesAreTraced(t *testing.T) {
+	recorder, restore := mocks.RecordSpans()
+	defer restore()
+
+	logger := graviolalog.NewLogger(conf.LogConf)
+	ctx := context.Background()
+
+	conf := config.GraviolaConfig{
+		QueryConf: config.QueryConfig{
+			MaxSamples:        10,
+			LookbackDelta:     config.DefaultQueryLookbackDelta,
+			ConcurrentQueries: 2,
+			Timeout:           "3m",
+		},
+	}
+
+	gravStorage := storageproxy.NewGraviolaStorage(
+		logger, []storage.Querier{&MockQuerier{selectReturn: storage.NoopSeriesSet()}}, defaultMergeStrategy, 0, nil)
+	eng := queryengine.NewGraviolaQueryEngine(logger, prometheus.NewRegistry(), conf, time.Now)
+
+	query, err := eng.NewInstantQuery(ctx, gravStorage, promql.NewPrometheusQueryOpts(false, 0), "up", time.Now())
+	require.NoError(t, err, "should return no error")
+	require.NoError(t, query.Exec(ctx).Err, "should execute the query")
+
+	span := mocks.SpanNamed(recorder, "engine.exec")
+	require.NotNil(t, span, "should record a span of the evaluation")
+	assert.Contains(t, span.Attributes(), attribute.String("graviola.query", "up"), "should have the query")
+}
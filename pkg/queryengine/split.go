@@ -0,0 +1,290 @@
+package queryengine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jademcosta/graviola/pkg/tracing"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/promql/parser"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/prometheus/prometheus/util/annotations"
+	"github.com/prometheus/prometheus/util/stats"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// samplesLimitEnv is where the wrapped engine says max_samples was exceeded
+const samplesLimitEnv = "query execution"
+
+type timeRange struct {
+	start time.Time
+	end   time.Time
+}
+
+// splitRangeQuery runs a range query as many smaller range queries, one for each interval the
+// original range touches. The sub-queries are sent in parallel (up to maxParallelism at a time)
+// and their results are stitched together into a single matrix.
+// The split query is what the clients see: it is accounted like a single query (see
+// GraviolaQueryEngine.Account) and max_samples applies to all the sub-queries together. The
+// sub-queries run on an engine that does none of this.
+type splitRangeQuery struct {
+	engine         *GraviolaQueryEngine
+	fullQuery      promql.Query
+	queryable      storage.Queryable
+	opts           promql.QueryOpts
+	qs             string
+	ranges         []timeRange
+	interval       time.Duration
+	maxParallelism int
+	stats          *stats.Statistics
+
+	mu       sync.Mutex
+	cancelFn context.CancelFunc
+}
+
+// Query
+func (query *splitRangeQuery) Exec(ctx context.Context) *promql.Result {
+	ctx, cancelFn := context.WithCancel(ctx)
+	defer cancelFn()
+	query.mu.Lock()
+	query.cancelFn = cancelFn
+	query.mu.Unlock()
+
+	results := make([]*promql.Result, len(query.ranges))
+	semaphore := make(chan struct{}, query.maxParallelism)
+	var wg sync.WaitGroup
+
+	for idx, rng := range query.ranges {
+		wg.Add(1)
+		go func(idx int, rng timeRange) {
+			defer wg.Done()
+
+			select {
+			case semaphore <- struct{}{}:
+			case <-ctx.Done():
+				results[idx] = &promql.Result{Err: ctx.Err()}
+				return
+			}
+			defer func() { <-semaphore }()
+
+			results[idx] = query.execRange(ctx, rng)
+			if results[idx].Err != nil {
+				cancelFn()
+			}
+		}(idx, rng)
+	}
+	wg.Wait()
+
+	return stitchResults(results)
+}
+
+// Query
+func (query *splitRangeQuery) Close() {
+	query.fullQuery.Close()
+}
+
+// Query
+func (query *splitRangeQuery) Statement() parser.Statement {
+	return query.fullQuery.Statement()
+}
+
+// Query
+// The stats are the sum of the ones of the sub-queries.
+func (query *splitRangeQuery) Stats() *stats.Statistics {
+	return query.stats
+}
+
+// Query
+func (query *splitRangeQuery) Cancel() {
+	query.fullQuery.Cancel()
+
+	query.mu.Lock()
+	defer query.mu.Unlock()
+	if query.cancelFn != nil {
+		query.cancelFn()
+	}
+}
+
+// Query
+func (query *splitRangeQuery) String() string {
+	return query.qs
+}
+
+func (query *splitRangeQuery) execRange(ctx context.Context, rng timeRange) *promql.Result {
+	ctx, span := tracing.Start(ctx, "engine.exec_split",
+		attribute.String("graviola.split.start", rng.start.Format(time.RFC3339)),
+		attribute.String("graviola.split.end", rng.end.Format(time.RFC3339)))
+	defer span.End()
+
+	subQuery, err := query.engine.splitEngine.NewRangeQuery(ctx, query.queryable, query.opts, query.qs,
+		rng.start, rng.end, query.interval)
+	if err != nil {
+		return &promql.Result{Err: err}
+	}
+	defer subQuery.Close()
+
+	result := subQuery.Exec(ctx)
+	if result.Err == nil {
+		result.Err = query.addSamples(subQuery.Stats().Samples)
+	}
+	if result.Err != nil {
+		tracing.RecordError(span, result.Err)
+		return result
+	}
+
+	matrix, ok := result.Value.(promql.Matrix)
+	if !ok {
+		return &promql.Result{
+			Err:      fmt.Errorf("split query returned %s instead of a matrix", result.Value.Type()),
+			Warnings: result.Warnings,
+		}
+	}
+
+	// The points are copied, as the engine reuses them after the query is closed
+	return &promql.Result{Value: copyMatrix(matrix), Warnings: result.Warnings}
+}
+
+// addSamples adds the samples of a sub-query to the ones of the split query. The results of all
+// the sub-queries are kept until they are stitched, so max_samples applies to the sum of their
+// peaks.
+func (query *splitRangeQuery) addSamples(samples *stats.QuerySamples) error {
+	if samples == nil {
+		return nil
+	}
+
+	query.mu.Lock()
+	defer query.mu.Unlock()
+
+	query.stats.Samples.TotalSamples += samples.TotalSamples
+	query.stats.Samples.PeakSamples += samples.PeakSamples
+	if query.engine.maxSamples > 0 && query.stats.Samples.PeakSamples > query.engine.maxSamples {
+		return promql.ErrTooManySamples(samplesLimitEnv)
+	}
+
+	return nil
+}
+
+// stitchResults joins the results of the sub-queries, which must be in time order. The first
+// error found is returned, preferring the ones that are not a consequence of the cancellation of
+// the other sub-queries.
+func stitchResults(results []*promql.Result) *promql.Result {
+	warnings := annotations.New()
+	var firstErr error
+	for _, result := range results {
+		warnings.Merge(result.Warnings)
+		if result.Err == nil {
+			continue
+		}
+		if firstErr == nil || errors.Is(firstErr, context.Canceled) {
+			firstErr = result.Err
+		}
+	}
+
+	if firstErr != nil {
+		return &promql.Result{Err: firstErr, Warnings: *warnings}
+	}
+
+	stitched := make(map[string]*promql.Series)
+	for _, result := range results {
+		for _, series := range result.Value.(promql.Matrix) {
+			key := series.Metric.String()
+			existing, ok := stitched[key]
+			if !ok {
+				stitched[key] = &promql.Series{
+					Metric: series.Metric, Floats: series.Floats, Histograms: series.Histograms,
+					DropName: series.DropName,
+				}
+				continue
+			}
+			existing.Floats = append(existing.Floats, series.Floats...)
+			existing.Histograms = append(existing.Histograms, series.Histograms...)
+		}
+	}
+
+	matrix := make(promql.Matrix, 0, len(stitched))
+	for _, series := range stitched {
+		matrix = append(matrix, *series)
+	}
+	sort.Sort(matrix)
+
+	return &promql.Result{Value: matrix, Warnings: *warnings}
+}
+
+func copyMatrix(matrix promql.Matrix) promql.Matrix {
+	copied := make(promql.Matrix, 0, len(matrix))
+	for _, series := range matrix {
+		newSeries := promql.Series{Metric: series.Metric, DropName: series.DropName}
+		if len(series.Floats) > 0 {
+			newSeries.Floats = append([]promql.FPoint(nil), series.Floats...)
+		}
+		if len(series.Histograms) > 0 {
+			newSeries.Histograms = make([]promql.HPoint, 0, len(series.Histograms))
+			for _, point := range series.Histograms {
+				newSeries.Histograms = append(newSeries.Histograms, promql.HPoint{T: point.T, H: point.H.Copy()})
+			}
+		}
+		copied = append(copied, newSeries)
+	}
+
+	return copied
+}
+
+// splitRange breaks the range into sub-ranges aligned to the split interval (counted since the
+// epoch). Each sub-range only has the steps of the original range that fall inside its interval,
+// so the evaluation timestamps are the same as if the query was not split.
+func splitRange(start, end time.Time, step, splitInterval time.Duration) []timeRange {
+	startMs := start.UnixMilli()
+	endMs := end.UnixMilli()
+	stepMs := step.Milliseconds()
+	intervalMs := splitInterval.Milliseconds()
+
+	ranges := make([]timeRange, 0)
+	for current := startMs; current <= endMs; {
+		boundary := (floorDiv(current, intervalMs) + 1) * intervalMs
+		// The last step of the original range that is before the boundary
+		last := current + ((min(boundary-1, endMs)-current)/stepMs)*stepMs
+		ranges = append(ranges, timeRange{start: time.UnixMilli(current), end: time.UnixMilli(last)})
+		current = last + stepMs
+	}
+
+	return ranges
+}
+
+func floorDiv(a, b int64) int64 {
+	result := a / b
+	if a%b != 0 && (a < 0) != (b < 0) {
+		result--
+	}
+	return result
+}
+
+// isSplittable checks that the result of the query doesn't change when it is split. This isn't
+// the case when the @ modifier is used, as start() and end() would change, or when a subquery
+// looks further back than the split interval.
+func isSplittable(stmt parser.Statement, splitInterval time.Duration) bool {
+	evalStmt, ok := stmt.(*parser.EvalStmt)
+	if !ok {
+		return false
+	}
+
+	splittable := true
+	parser.Inspect(evalStmt.Expr, func(node parser.Node, _ []parser.Node) error {
+		switch n := node.(type) {
+		case *parser.VectorSelector:
+			if n.Timestamp != nil || n.StartOrEnd != 0 {
+				splittable = false
+			}
+		case *parser.SubqueryExpr:
+			if n.Timestamp != nil || n.StartOrEnd != 0 || n.Range+n.OriginalOffset > splitInterval {
+				splittable = false
+			}
+		}
+		return nil
+	})
+
+	return splittable
+}
@@ -0,0 +1,316 @@
+package queryengine_test
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jademcosta/graviola/pkg/config"
+	"github.com/jademcosta/graviola/pkg/domain"
+	"github.com/jademcosta/graviola/pkg/graviolalog"
+	"github.com/jademcosta/graviola/pkg/queryengine"
+	"github.com/jademcosta/graviola/pkg/storageproxy"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/prometheus/prometheus/util/annotations"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// concurrentMockQuerier can be called by many goroutines at the same time. It returns a new
+// series set on each call, with the same series.
+type concurrentMockQuerier struct {
+	series []*domain.GraviolaSeries
+	delay  time.Duration
+
+	mu             sync.Mutex
+	hints          []*storage.SelectHints
+	running        int
+	maxConcurrency int
+}
+
+func (mock *concurrentMockQuerier) Select(
+	_ context.Context, _ bool, hints *storage.SelectHints, _ ...*labels.Matcher,
+) storage.SeriesSet {
+	mock.mu.Lock()
+	mock.hints = append(mock.hints, hints)
+	mock.running++
+	mock.maxConcurrency = max(mock.maxConcurrency, mock.running)
+	mock.mu.Unlock()
+
+	time.Sleep(mock.delay)
+
+	mock.mu.Lock()
+	mock.running--
+	mock.mu.Unlock()
+
+	return &domain.GraviolaSeriesSet{Series: mock.series}
+}
+
+func (mock *concurrentMockQuerier) Close() error {
+	return nil
+}
+
+func (mock *concurrentMockQuerier) LabelValues(
+	_ context.Context, _ string, _ *storage.LabelHints, _ ...*labels.Matcher,
+) ([]string, annotations.Annotations, error) {
+	return nil, nil, nil
+}
+
+func (mock *concurrentMockQuerier) LabelNames(
+	_ context.Context, _ *storage.LabelHints, _ ...*labels.Matcher,
+) ([]string, annotations.Annotations, error) {
+	return nil, nil, nil
+}
+
+func (mock *concurrentMockQuerier) selectCount() int {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	return len(mock.hints)
+}
+
+var splitTestStart = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+var splitTestEnd = splitTestStart.Add(48 * time.Hour)
+
+func newSplitMockQuerier() *concurrentMockQuerier {
+	series := make([]*domain.GraviolaSeries, 0, 2)
+	for _, instance := range []string{"a", "b"} {
+		datapoints := make([]model.SamplePair, 0)
+		for ts := splitTestStart.Add(-time.Hour); !ts.After(splitTestEnd); ts = ts.Add(30 * time.Second) {
+			datapoints = append(datapoints, model.SamplePair{
+				Timestamp: model.Time(ts.UnixMilli()), Value: model.SampleValue(ts.Unix()),
+			})
+		}
+		series = append(series, &domain.GraviolaSeries{
+			Lbs:        labels.FromStrings("__name__", "up", "instance", instance),
+			Datapoints: datapoints,
+		})
+	}
+
+	return &concurrentMockQuerier{series: series}
+}
+
+func newSplitSut(t *testing.T, splitConf config.QuerySplitConfig) *queryengine.GraviolaQueryEngine {
+	t.Helper()
+	return queryengine.NewGraviolaQueryEngine(graviolalog.NewLogger(conf.LogConf), prometheus.NewRegistry(),
+		config.GraviolaConfig{
+			QueryConf: config.QueryConfig{
+				MaxSamples:        1000000,
+				LookbackDelta:     config.DefaultQueryLookbackDelta,
+				ConcurrentQueries: 10,
+				Timeout:           "3m",
+				SplitConf:         splitConf,
+			},
+		}, time.Now)
+}
+
+func execSplitQuery(
+	t *testing.T, eng *queryengine.GraviolaQueryEngine, mock *concurrentMockQuerier, qs string,
+) promql.Matrix {
+	t.Helper()
+	logger := graviolalog.NewLogger(conf.LogConf)
+	gravStorage := storageproxy.NewGraviolaStorage(logger, []storage.Querier{mock}, defaultMergeStrategy, 0, nil)
+
+	query, err := eng.NewRangeQuery(context.Background(), gravStorage, promql.NewPrometheusQueryOpts(false, 0),
+		qs, splitTestStart, splitTestEnd, 5*time.Minute)
+	require.NoError(t, err, "should create the query")
+	defer query.Close()
+
+	result := query.Exec(context.Background())
+	require.NoError(t, result.Err, "should execute the query")
+	matrix, err := result.Matrix()
+	require.NoError(t, err, "should return a matrix")
+
+	// The points are copied, as the engine reuses them after the query is closed
+	copied := make(promql.Matrix, 0, len(matrix))
+	for _, series := range matrix {
+		series.Floats = slices.Clone(series.Floats)
+		copied = append(copied, series)
+	}
+	return copied
+}
+
+func TestSplitQueryReturnsTheSameResultAsTheUnsplitQuery(t *testing.T) {
+	queries := []string{
+		"up",
+		"rate(up[10m])",
+		"sum(up)",
+		"max_over_time(up[30m:1m])",
+	}
+
+	for _, qs := range queries {
+		unsplitMock := newSplitMockQuerier()
+		expected := execSplitQuery(t, newSplitSut(t, config.QuerySplitConfig{}), unsplitMock, qs)
+
+		splitMock := newSplitMockQuerier()
+		result := execSplitQuery(t, newSplitSut(t, config.QuerySplitConfig{Interval: "1d", MaxParallelism: 2}),
+			splitMock, qs)
+
+		assert.Equal(t, expected, result, "should return the same result as the unsplit query for %s", qs)
+		assert.Equal(t, 1, unsplitMock.selectCount(), "should fetch data once when not split for %s", qs)
+		assert.Equal(t, 3, splitMock.selectCount(),
+			"should fetch data once for each day touched by the range for %s", qs)
+	}
+}
+
+func TestSplitQuerySendsEachSliceToTheStorage(t *testing.T) {
+	mock := newSplitMockQuerier()
+	execSplitQuery(t, newSplitSut(t, config.QuerySplitConfig{Interval: "1d", MaxParallelism: 1}), mock, "up")
+
+	require.Len(t, mock.hints, 3, "should send one select for each day")
+	slices.SortFunc(mock.hints, func(a, b *storage.SelectHints) int { return int(a.End - b.End) })
+
+	dayBoundary := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	expectedEnds := []int64{
+		dayBoundary.Add(-5 * time.Minute).UnixMilli(),
+		dayBoundary.Add(24*time.Hour - 5*time.Minute).UnixMilli(),
+		splitTestEnd.UnixMilli(),
+	}
+	for idx, hints := range mock.hints {
+		assert.Equal(t, expectedEnds[idx], hints.End, "slice %d should end on the last step before the boundary", idx)
+	}
+}
+
+func TestSplitQueryRespectsTheMaxParallelism(t *testing.T) {
+	mock := newSplitMockQuerier()
+	mock.delay = 50 * time.Millisecond
+	execSplitQuery(t, newSplitSut(t, config.QuerySplitConfig{Interval: "6h", MaxParallelism: 2}), mock, "up")
+
+	assert.Equal(t, 9, mock.selectCount(), "should send one select for each slice")
+	assert.LessOrEqual(t, mock.maxConcurrency, 2, "should not run more slices in parallel than the limit")
+	assert.Equal(t, 2, mock.maxConcurrency, "should run slices in parallel")
+}
+
+func TestQueriesThatCannotBeSplitAreRunUnsplit(t *testing.T) {
+	queries := []string{
+		"up @ end()",
+		"up @ 1704110400",
+		"max_over_time(up[2d:1h])",
+		"max_over_time(up[1h:1m] offset 1d)",
+	}
+
+	for _, qs := range queries {
+		mock := newSplitMockQuerier()
+		execSplitQuery(t, newSplitSut(t, config.QuerySplitConfig{Interval: "1d", MaxParallelism: 2}), mock, qs)
+
+		assert.Equal(t, 1, mock.selectCount(), "should not split the query %s", qs)
+	}
+}
+
+// recordingQueryLogger keeps the entries of the query log
+type recordingQueryLogger struct {
+	mu      sync.Mutex
+	records []slog.Record
+}
+
+func (logger *recordingQueryLogger) Enabled(_ context.Context, _ slog.Level) bool { return true }
+func (logger *recordingQueryLogger) WithAttrs(_ []slog.Attr) slog.Handler         { return logger }
+func (logger *recordingQueryLogger) WithGroup(_ string) slog.Handler              { return logger }
+func (logger *recordingQueryLogger) Close() error                                 { return nil }
+
+func (logger *recordingQueryLogger) Handle(_ context.Context, record slog.Record) error {
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+	logger.records = append(logger.records, record)
+	return nil
+}
+
+func TestSplitQueryIsASingleQueryForTheTrackerAndTheQueryLog(t *testing.T) {
+	mock := newSplitMockQuerier()
+	mock.delay = 50 * time.Millisecond
+	logger := graviolalog.NewLogger(conf.LogConf)
+	sut := queryengine.NewGraviolaQueryEngine(logger, prometheus.NewRegistry(), config.GraviolaConfig{
+		QueryConf: config.QueryConfig{
+			MaxSamples:        1000000,
+			LookbackDelta:     config.DefaultQueryLookbackDelta,
+			ConcurrentQueries: 3,
+			Timeout:           "3m",
+			SplitConf:         config.QuerySplitConfig{Interval: "1d", MaxParallelism: 3},
+		},
+	}, time.Now)
+	queryLogger := &recordingQueryLogger{}
+	sut.SetQueryLogger(queryLogger)
+
+	gravStorage := storageproxy.NewGraviolaStorage(logger, []storage.Querier{mock}, defaultMergeStrategy, 0, nil)
+	query, err := sut.NewRangeQuery(context.Background(), gravStorage, promql.NewPrometheusQueryOpts(false, 0),
+		"up", splitTestStart, splitTestEnd, 5*time.Minute)
+	require.NoError(t, err, "should create the query")
+	defer query.Close()
+
+	maxActive := 0
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for mock.selectCount() < 3 {
+			maxActive = max(maxActive, len(sut.QueryTracker().List()))
+			time.Sleep(time.Millisecond)
+		}
+	}()
+
+	result := query.Exec(context.Background())
+	<-done
+	require.NoError(t, result.Err, "should execute the query")
+	assert.Equal(t, 1, maxActive, "should take a single concurrency slot")
+	assert.Equal(t, 3, mock.selectCount(), "should have split the query")
+	assert.Len(t, queryLogger.records, 1, "should write a single entry on the query log")
+
+	unsplit, err := newSplitSut(t, config.QuerySplitConfig{}).NewRangeQuery(context.Background(), gravStorage,
+		promql.NewPrometheusQueryOpts(false, 0), "up", splitTestStart, splitTestEnd, 5*time.Minute)
+	require.NoError(t, err, "should create the query")
+	defer unsplit.Close()
+	require.NoError(t, unsplit.Exec(context.Background()).Err, "should execute the query")
+	assert.Equal(t, unsplit.Stats().Samples.TotalSamples, query.Stats().Samples.TotalSamples,
+		"should have the samples of all the sub-queries on its stats")
+}
+
+func TestSplitQueryAppliesTheMaxSamplesToAllTheSubQueriesTogether(t *testing.T) {
+	logger := graviolalog.NewLogger(conf.LogConf)
+	sut := queryengine.NewGraviolaQueryEngine(logger, prometheus.NewRegistry(), config.GraviolaConfig{
+		QueryConf: config.QueryConfig{
+			MaxSamples:        600,
+			LookbackDelta:     config.DefaultQueryLookbackDelta,
+			ConcurrentQueries: 10,
+			Timeout:           "3m",
+			SplitConf:         config.QuerySplitConfig{Interval: "1d", MaxParallelism: 1},
+		},
+	}, time.Now)
+
+	gravStorage := storageproxy.NewGraviolaStorage(
+		logger, []storage.Querier{newSplitMockQuerier()}, defaultMergeStrategy, 0, nil)
+	query, err := sut.NewRangeQuery(context.Background(), gravStorage, promql.NewPrometheusQueryOpts(false, 0),
+		"up", splitTestStart, splitTestEnd, 5*time.Minute)
+	require.NoError(t, err, "should create the query")
+	defer query.Close()
+
+	result := query.Exec(context.Background())
+	assert.ErrorIs(t, result.Err, promql.ErrTooManySamples("query execution"),
+		"should fail when the sub-queries together load more samples than the limit")
+}
+
+func TestCancellingASplitQueryCancelsItsSubQueries(t *testing.T) {
+	mock := newSplitMockQuerier()
+	mock.delay = 100 * time.Millisecond
+	sut := newSplitSut(t, config.QuerySplitConfig{Interval: "1d", MaxParallelism: 1})
+	logger := graviolalog.NewLogger(conf.LogConf)
+	gravStorage := storageproxy.NewGraviolaStorage(logger, []storage.Querier{mock}, defaultMergeStrategy, 0, nil)
+
+	query, err := sut.NewRangeQuery(context.Background(), gravStorage, promql.NewPrometheusQueryOpts(false, 0),
+		"up", splitTestStart, splitTestEnd, 5*time.Minute)
+	require.NoError(t, err, "should create the query")
+	defer query.Close()
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		query.Cancel()
+	}()
+
+	result := query.Exec(context.Background())
+	assert.Error(t, result.Err, "should fail the cancelled query")
+	assert.Less(t, mock.selectCount(), 3, "should not run the sub-queries after it was cancelled")
+}
@@ -0,0 +1,104 @@
+package querylimits
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/jademcosta/graviola/pkg/config"
+)
+
+var ErrLimitExceeded = errors.New("query limit exceeded")
+
+type contextKey struct{}
+
+// Limiter accounts what a query fetched from the remotes. When a limit is exceeded, it cancels
+// the context of the query, so the requests still running are aborted. It is safe to be used by
+// many goroutines. All of its methods do nothing on a nil limiter, so callers don't need to
+// check if there's one.
+type Limiter struct {
+	maxSeries int64
+	maxBytes  int64
+	cancelFn  context.CancelCauseFunc
+
+	mu     sync.Mutex
+	series int64
+	bytes  int64
+	err    error
+}
+
+// NewLimiter creates a limiter for a single query. The cancelFn must cancel the context in which
+// the query is executed.
+func NewLimiter(conf config.QueryLimitsConfig, cancelFn context.CancelCauseFunc) *Limiter {
+	return &Limiter{
+		maxSeries: int64(conf.MaxSeries),
+		maxBytes:  int64(conf.MaxFetchedBytes),
+		cancelFn:  cancelFn,
+	}
+}
+
+func NewContext(ctx context.Context, limiter *Limiter) context.Context {
+	return context.WithValue(ctx, contextKey{}, limiter)
+}
+
+// FromContext returns the limiter of the query being executed, or nil if there's none
+func FromContext(ctx context.Context) *Limiter {
+	limiter, _ := ctx.Value(contextKey{}).(*Limiter)
+	return limiter
+}
+
+// AddSeries accounts series fetched, returning an error if the limit was exceeded
+func (limiter *Limiter) AddSeries(count int) error {
+	if limiter == nil {
+		return nil
+	}
+
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+	limiter.series += int64(count)
+	if limiter.maxSeries > 0 && limiter.series > limiter.maxSeries {
+		limiter.exceeded(fmt.Errorf("%w: the query fetched more than %d series (max_series)",
+			ErrLimitExceeded, limiter.maxSeries))
+	}
+
+	return limiter.err
+}
+
+// AddBytes accounts bytes received from a remote, returning an error if the limit was exceeded
+func (limiter *Limiter) AddBytes(count int) error {
+	if limiter == nil {
+		return nil
+	}
+
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+	limiter.bytes += int64(count)
+	if limiter.maxBytes > 0 && limiter.bytes > limiter.maxBytes {
+		limiter.exceeded(fmt.Errorf("%w: the query fetched more than %d bytes (max_fetched_bytes)",
+			ErrLimitExceeded, limiter.maxBytes))
+	}
+
+	return limiter.err
+}
+
+// Err returns the error of the first limit exceeded, or nil if none was
+func (limiter *Limiter) Err() error {
+	if limiter == nil {
+		return nil
+	}
+
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+	return limiter.err
+}
+
+// exceeded keeps the first error and cancels the query. It must be called with the lock held.
+func (limiter *Limiter) exceeded(err error) {
+	if limiter.err != nil {
+		return
+	}
+
+	limiter.err = err
+	limiter.cancelFn(err)
+}
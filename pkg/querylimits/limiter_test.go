@@ -0,0 +1,66 @@
+package querylimits_test
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/jademcosta/graviola/pkg/config"
+	"github.com/jademcosta/graviola/pkg/querylimits"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newSut(conf config.QueryLimitsConfig) (context.Context, *querylimits.Limiter) {
+	ctx, cancelFn := context.WithCancelCause(context.Background())
+	limiter := querylimits.NewLimiter(conf, cancelFn)
+	return querylimits.NewContext(ctx, limiter), limiter
+}
+
+func TestCancelsTheQueryWhenTooManySeriesAreFetched(t *testing.T) {
+	ctx, sut := newSut(config.QueryLimitsConfig{MaxSeries: 3})
+
+	require.NoError(t, sut.AddSeries(2), "should allow series below the limit")
+	require.NoError(t, sut.AddSeries(1), "should allow series up to the limit")
+	assert.NoError(t, ctx.Err(), "should not cancel the query below the limit")
+
+	err := sut.AddSeries(1)
+	require.ErrorIs(t, err, querylimits.ErrLimitExceeded, "should fail when the limit is exceeded")
+	assert.ErrorContains(t, err, "max_series", "should tell which limit was exceeded")
+	assert.ErrorIs(t, context.Cause(ctx), querylimits.ErrLimitExceeded, "should cancel the query with the limit error")
+	assert.Equal(t, err, sut.Err(), "should keep the error")
+	assert.Equal(t, querylimits.FromContext(ctx), sut, "should be available on the context")
+}
+
+func TestCancelsTheQueryWhenTooManyBytesAreFetched(t *testing.T) {
+	ctx, sut := newSut(config.QueryLimitsConfig{MaxFetchedBytes: 10})
+
+	_, err := io.ReadAll(querylimits.NewReader(strings.NewReader("12345"), sut))
+	require.NoError(t, err, "should allow reading below the limit")
+
+	_, err = io.ReadAll(querylimits.NewReader(strings.NewReader("123456"), sut))
+	require.ErrorIs(t, err, querylimits.ErrLimitExceeded, "should fail the read when the limit is exceeded")
+	assert.ErrorContains(t, err, "max_fetched_bytes", "should tell which limit was exceeded")
+	assert.ErrorIs(t, context.Cause(ctx), querylimits.ErrLimitExceeded, "should cancel the query with the limit error")
+}
+
+func TestDoesNotLimitWhenLimitsAreZero(t *testing.T) {
+	ctx, sut := newSut(config.QueryLimitsConfig{})
+
+	assert.NoError(t, sut.AddSeries(1000000), "should not limit series")
+	assert.NoError(t, sut.AddBytes(1000000), "should not limit bytes")
+	assert.NoError(t, ctx.Err(), "should not cancel the query")
+}
+
+func TestIgnoresAccountingWhenThereIsNoLimiter(t *testing.T) {
+	sut := querylimits.FromContext(context.Background())
+	assert.Nil(t, sut, "should return nil when there's no limiter on the context")
+
+	assert.NoError(t, sut.AddSeries(1), "should be safe to account on a nil limiter")
+	assert.NoError(t, sut.AddBytes(1), "should be safe to account on a nil limiter")
+	assert.NoError(t, sut.Err(), "should have no error")
+
+	reader := strings.NewReader("data")
+	assert.Equal(t, reader, querylimits.NewReader(reader, sut), "should not wrap the reader")
+}
@@ -0,0 +1,30 @@
+package querylimits
+
+import "io"
+
+type limitedReader struct {
+	reader  io.Reader
+	limiter *Limiter
+}
+
+// NewReader accounts the bytes read from the reader on the limiter, failing the read as soon as
+// the limit is exceeded, instead of after the whole response was received.
+func NewReader(reader io.Reader, limiter *Limiter) io.Reader {
+	if limiter == nil {
+		return reader
+	}
+
+	return &limitedReader{reader: reader, limiter: limiter}
+}
+
+func (limited *limitedReader) Read(data []byte) (int, error) {
+	read, err := limited.reader.Read(data)
+	if read > 0 {
+		limitErr := limited.limiter.AddBytes(read)
+		if limitErr != nil {
+			return read, limitErr
+		}
+	}
+
+	return read, err
+}
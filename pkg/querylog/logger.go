@@ -0,0 +1,136 @@
+package querylog
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/jademcosta/graviola/pkg/config"
+	"github.com/jademcosta/graviola/pkg/graviolalog"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/util/stats"
+)
+
+const (
+	statusSuccess = "success"
+	statusError   = "error"
+)
+
+var _ promql.QueryLogger = (*Logger)(nil)
+
+// entry is a line of the query log
+type entry struct {
+	Time            time.Time              `json:"time"`
+	Query           string                 `json:"query"`
+	Params          map[string]interface{} `json:"params,omitempty"`
+	DurationSeconds float64                `json:"duration_seconds"`
+	Samples         int64                  `json:"samples"`
+	Status          string                 `json:"status"`
+	Error           string                 `json:"error,omitempty"`
+	ClientAddress   string                 `json:"client_address,omitempty"`
+	Tenant          string                 `json:"tenant,omitempty"`
+	Principal       string                 `json:"principal,omitempty"`
+	TraceID         string                 `json:"trace_id,omitempty"`
+	Identity        map[string]string      `json:"identity,omitempty"`
+	Remotes         []string               `json:"remotes"`
+}
+
+// Logger writes the queries executed by the engine to a file, one JSON per line. It is set on
+// the engine as its query logger, and only logs the queries that took at least the slow query
+// threshold.
+type Logger struct {
+	file               *graviolalog.RotatingFile
+	slowQueryThreshold time.Duration
+}
+
+func NewLogger(conf config.QueryLogConfig) (*Logger, error) {
+	file, err := graviolalog.NewRotatingFile(conf.Path, int64(conf.MaxSizeBytes), conf.MaxBackups)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Logger{
+		file:               file,
+		slowQueryThreshold: conf.SlowQueryThresholdDuration(),
+	}, nil
+}
+
+// slog.Handler
+func (logger *Logger) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= slog.LevelInfo
+}
+
+// slog.Handler
+func (logger *Logger) Handle(_ context.Context, record slog.Record) error {
+	logEntry := entry{Time: record.Time, Status: statusSuccess, Remotes: []string{}}
+
+	record.Attrs(func(attr slog.Attr) bool {
+		value := attr.Value.Resolve().Any()
+
+		switch attr.Key {
+		case "params":
+			if params, ok := value.(map[string]interface{}); ok {
+				logEntry.Params = make(map[string]interface{}, len(params))
+				for key, paramValue := range params {
+					if key == "query" {
+						logEntry.Query = fmt.Sprint(paramValue)
+						continue
+					}
+					logEntry.Params[key] = paramValue
+				}
+			}
+		case "error":
+			logEntry.Status = statusError
+			logEntry.Error = fmt.Sprint(value)
+		case "stats":
+			if queryStats, ok := value.(stats.QueryStats); ok {
+				builtin := queryStats.Builtin()
+				logEntry.DurationSeconds = builtin.Timings.ExecTotalTime
+				if builtin.Samples != nil {
+					logEntry.Samples = builtin.Samples.TotalQueryableSamples
+				}
+			}
+		case originKey:
+			if queryOrigin, ok := value.(origin); ok {
+				logEntry.ClientAddress = queryOrigin.clientAddress
+				logEntry.Tenant = queryOrigin.tenant
+				logEntry.Principal = queryOrigin.principal
+				logEntry.TraceID = queryOrigin.traceID
+				logEntry.Identity = queryOrigin.identity
+				logEntry.Remotes = queryOrigin.collector.Remotes()
+			}
+		}
+		return true
+	})
+
+	if logEntry.DurationSeconds < logger.slowQueryThreshold.Seconds() {
+		return nil
+	}
+
+	data, err := json.Marshal(logEntry)
+	if err != nil {
+		return fmt.Errorf("unable to encode query log entry: %w", err)
+	}
+
+	_, err = logger.file.Write(append(data, '\n'))
+	return err
+}
+
+// slog.Handler
+// The engine doesn't add attributes to the logger, only to each record.
+func (logger *Logger) WithAttrs(_ []slog.Attr) slog.Handler {
+	return logger
+}
+
+// slog.Handler
+// The engine doesn't use groups.
+func (logger *Logger) WithGroup(_ string) slog.Handler {
+	return logger
+}
+
+// io.Closer
+func (logger *Logger) Close() error {
+	return logger.file.Close()
+}
@@ -0,0 +1,215 @@
+package querylog_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jademcosta/graviola/pkg/clientinfo"
+	"github.com/jademcosta/graviola/pkg/config"
+	"github.com/jademcosta/graviola/pkg/domain"
+	"github.com/jademcosta/graviola/pkg/graviolalog"
+	"github.com/jademcosta/graviola/pkg/queryengine"
+	"github.com/jademcosta/graviola/pkg/querylog"
+	"github.com/jademcosta/graviola/pkg/querystats"
+	"github.com/jademcosta/graviola/pkg/remotestoragegroup"
+	"github.com/jademcosta/graviola/pkg/storageproxy"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/prometheus/prometheus/util/annotations"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var logConf = config.LogConfig{Level: "error"}
+var queryTime = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+// remoteMockQuerier behaves like a remote, registering itself as contacted on each call
+type remoteMockQuerier struct {
+	name  string
+	delay time.Duration
+}
+
+func (mock *remoteMockQuerier) Select(
+	ctx context.Context, _ bool, _ *storage.SelectHints, _ ...*labels.Matcher,
+) storage.SeriesSet {
+	querystats.FromContext(ctx).RecordRemoteRequest(mock.name, mock.delay, 0)
+	time.Sleep(mock.delay)
+
+	return &domain.GraviolaSeriesSet{Series: []*domain.GraviolaSeries{
+		{
+			Lbs: labels.FromStrings("__name__", "up", "instance", "a"),
+			Datapoints: []model.SamplePair{
+				{Timestamp: model.Time(queryTime.Add(-time.Minute).UnixMilli()), Value: 1},
+			},
+		},
+	}}
+}
+
+func (mock *remoteMockQuerier) Close() error {
+	return nil
+}
+
+func (mock *remoteMockQuerier) LabelValues(
+	_ context.Context, _ string, _ *storage.LabelHints, _ ...*labels.Matcher,
+) ([]string, annotations.Annotations, error) {
+	return nil, nil, nil
+}
+
+func (mock *remoteMockQuerier) LabelNames(
+	_ context.Context, _ *storage.LabelHints, _ ...*labels.Matcher,
+) ([]string, annotations.Annotations, error) {
+	return nil, nil, nil
+}
+
+type logEntry struct {
+	Query           string            `json:"query"`
+	DurationSeconds float64           `json:"duration_seconds"`
+	Samples         int64             `json:"samples"`
+	Status          string            `json:"status"`
+	Error           string            `json:"error"`
+	ClientAddress   string            `json:"client_address"`
+	Tenant          string            `json:"tenant"`
+	Identity        map[string]string `json:"identity"`
+	Remotes         []string          `json:"remotes"`
+}
+
+func newEngineWithLogger(t *testing.T, logConfig config.QueryLogConfig) *queryengine.GraviolaQueryEngine {
+	t.Helper()
+	eng := queryengine.NewGraviolaQueryEngine(graviolalog.NewLogger(logConf), prometheus.NewRegistry(),
+		config.GraviolaConfig{
+			QueryConf: config.QueryConfig{
+				MaxSamples:        1000000,
+				LookbackDelta:     config.DefaultQueryLookbackDelta,
+				ConcurrentQueries: 10,
+				Timeout:           "1m",
+			},
+			QueryLogConf: logConfig,
+		}, time.Now)
+
+	logger, err := querylog.NewLogger(logConfig)
+	require.NoError(t, err, "should create the query logger")
+	eng.SetQueryLogger(logger)
+	t.Cleanup(func() { eng.SetQueryLogger(nil) })
+
+	return eng
+}
+
+func execInstantQuery(
+	t *testing.T, ctx context.Context, eng *queryengine.GraviolaQueryEngine, mock *remoteMockQuerier, qs string,
+) {
+	t.Helper()
+	mergeStrategy := remotestoragegroup.MergeStrategyFactory(
+		config.MergeStrategyConfig{Strategy: config.DefaultMergeStrategyType}, nil)
+	gravStorage := storageproxy.NewGraviolaStorage(
+		graviolalog.NewLogger(logConf), []storage.Querier{mock}, mergeStrategy, 0, nil)
+
+	query, err := eng.NewInstantQuery(context.Background(), gravStorage, nil, qs, queryTime)
+	require.NoError(t, err, "should create the query")
+	defer query.Close()
+	query.Exec(ctx)
+}
+
+func readEntries(t *testing.T, path string) []logEntry {
+	t.Helper()
+	file, err := os.Open(path)
+	require.NoError(t, err, "should open the query log")
+	defer file.Close()
+
+	entries := make([]logEntry, 0)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry logEntry
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &entry), "each line should be a JSON")
+		entries = append(entries, entry)
+	}
+
+	return entries
+}
+
+func TestLogsTheQueriesExecuted(t *testing.T) {
+	logConfig := config.QueryLogConfig{
+		Path:            filepath.Join(t.TempDir(), "query.log"),
+		IdentityHeaders: []string{"X-User"},
+	}.FillDefaults()
+	eng := newEngineWithLogger(t, logConfig)
+
+	headers := http.Header{}
+	headers.Set("X-User", "someone")
+	headers.Set("X-Not-Logged", "secret")
+	ctx := clientinfo.NewContext(context.Background(),
+		clientinfo.Info{Address: "10.0.0.1", Tenant: "team-a", Headers: headers})
+
+	execInstantQuery(t, ctx, eng, &remoteMockQuerier{name: "remote-1"}, "up")
+
+	entries := readEntries(t, logConfig.Path)
+	require.Len(t, entries, 1, "should log the query executed")
+
+	entry := entries[0]
+	assert.Equal(t, "up", entry.Query, "should log the query")
+	assert.Equal(t, "success", entry.Status, "should log the status")
+	assert.Equal(t, int64(1), entry.Samples, "should log the samples read")
+	assert.Equal(t, "10.0.0.1", entry.ClientAddress, "should log the client address")
+	assert.Equal(t, "team-a", entry.Tenant, "should log the tenant")
+	assert.Equal(t, map[string]string{"X-User": "someone"}, entry.Identity,
+		"should log only the configured identity headers")
+	assert.Equal(t, []string{"remote-1"}, entry.Remotes, "should log the remotes contacted")
+	assert.Greater(t, entry.DurationSeconds, 0.0, "should log the duration")
+}
+
+func TestLogsTheErrorOfFailedQueries(t *testing.T) {
+	logConfig := config.QueryLogConfig{Path: filepath.Join(t.TempDir(), "query.log")}.FillDefaults()
+	eng := newEngineWithLogger(t, logConfig)
+
+	ctx, cancelFn := context.WithCancel(context.Background())
+	cancelFn()
+	execInstantQuery(t, ctx, eng, &remoteMockQuerier{name: "remote-1"}, "up")
+
+	entries := readEntries(t, logConfig.Path)
+	require.Len(t, entries, 1, "should log failed queries")
+	assert.Equal(t, "error", entries[0].Status, "should log the status")
+	assert.NotEmpty(t, entries[0].Error, "should log the error")
+	assert.Empty(t, entries[0].ClientAddress, "should not need client info to log")
+}
+
+func TestLogsOnlyTheSlowQueriesWhenThereIsAThreshold(t *testing.T) {
+	logConfig := config.QueryLogConfig{
+		Path:               filepath.Join(t.TempDir(), "query.log"),
+		SlowQueryThreshold: "100ms",
+	}.FillDefaults()
+	eng := newEngineWithLogger(t, logConfig)
+
+	execInstantQuery(t, context.Background(), eng, &remoteMockQuerier{name: "fast"}, "up")
+	execInstantQuery(t, context.Background(), eng,
+		&remoteMockQuerier{name: "slow", delay: 150 * time.Millisecond}, "up")
+
+	entries := readEntries(t, logConfig.Path)
+	require.Len(t, entries, 1, "should log only the queries slower than the threshold")
+	assert.Equal(t, []string{"slow"}, entries[0].Remotes, "should log the slow query")
+	assert.GreaterOrEqual(t, entries[0].DurationSeconds, 0.1, "should log the duration")
+}
+
+func TestRotatesTheFileWhenItReachesTheMaxSize(t *testing.T) {
+	logConfig := config.QueryLogConfig{
+		Path:         filepath.Join(t.TempDir(), "query.log"),
+		MaxSizeBytes: 100,
+		MaxBackups:   2,
+	}.FillDefaults()
+	eng := newEngineWithLogger(t, logConfig)
+
+	for range 5 {
+		execInstantQuery(t, context.Background(), eng, &remoteMockQuerier{name: "remote-1"}, "up")
+	}
+
+	assert.Len(t, readEntries(t, logConfig.Path), 1, "should have rotated the file, as each entry is bigger than the max")
+	assert.Len(t, readEntries(t, logConfig.Path+".1"), 1, "should keep the backups")
+	assert.Len(t, readEntries(t, logConfig.Path+".2"), 1, "should keep the backups")
+	assert.NoFileExists(t, logConfig.Path+".3", "should keep at most max_backups files")
+}
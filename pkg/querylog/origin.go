@@ -0,0 +1,63 @@
+package querylog
+
+import (
+	"context"
+	"maps"
+
+	"github.com/jademcosta/graviola/pkg/clientinfo"
+	"github.com/jademcosta/graviola/pkg/querystats"
+	"github.com/jademcosta/graviola/pkg/tracing"
+	"github.com/prometheus/prometheus/promql"
+)
+
+// originKey is the key of the Graviola information on the origin of the query. The engine
+// passes each key of the origin to the query logger.
+const originKey = "graviola"
+
+type origin struct {
+	clientAddress string
+	tenant        string
+	principal     string
+	traceID       string
+	identity      map[string]string
+	collector     *querystats.Collector
+}
+
+// NewOriginContext adds to the origin of the query (which the engine sends to the query logger)
+// the information about the client, the ID of its trace and a collector of the remotes contacted.
+// The values of the identityHeaders of the request are added too.
+func NewOriginContext(ctx context.Context, identityHeaders []string) context.Context {
+	collector := querystats.FromContext(ctx)
+	if collector == nil {
+		collector = querystats.NewCollector()
+		ctx = querystats.NewContext(ctx, collector)
+	}
+
+	queryOrigin := origin{collector: collector, traceID: tracing.TraceID(ctx)}
+	if info, ok := clientinfo.FromContext(ctx); ok {
+		queryOrigin.clientAddress = info.Address
+		queryOrigin.tenant = info.Tenant
+		queryOrigin.principal = info.Principal
+		queryOrigin.identity = identityFromHeaders(info, identityHeaders)
+	}
+
+	values := make(map[string]interface{})
+	if existing, ok := ctx.Value(promql.QueryOrigin{}).(map[string]interface{}); ok {
+		maps.Copy(values, existing)
+	}
+	values[originKey] = queryOrigin
+
+	return promql.NewOriginContext(ctx, values)
+}
+
+func identityFromHeaders(info clientinfo.Info, identityHeaders []string) map[string]string {
+	identity := make(map[string]string, len(identityHeaders))
+	for _, header := range identityHeaders {
+		value := info.Headers.Get(header)
+		if value != "" {
+			identity[header] = value
+		}
+	}
+
+	return identity
+}
@@ -0,0 +1,247 @@
+package querystats
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type contextKey struct{}
+
+// The reasons for a remote not being queried
+const (
+	// SkipReasonTimeWindow is when the query is outside of the time window of the remote
+	SkipReasonTimeWindow = "time_window"
+	// SkipReasonFailover is when another remote of the failover group answered first
+	SkipReasonFailover = "failover"
+	// SkipReasonRoundRobin is when another remote of the round robin group was picked
+	SkipReasonRoundRobin = "round_robin"
+)
+
+// RemoteStats is what happened with a remote while a query was executed
+type RemoteStats struct {
+	Name           string  `json:"name"`
+	Requests       int     `json:"requests"`
+	LatencySeconds float64 `json:"latencySeconds"`
+	BytesReceived  int64   `json:"bytesReceived"`
+	Series         int     `json:"series"`
+	Samples        int     `json:"samples"`
+	// Skipped is true when the remote wasn't queried, SkipReason telling why (one of the
+	// SkipReason constants)
+	Skipped    bool   `json:"skipped"`
+	SkipReason string `json:"skipReason,omitempty"`
+}
+
+// GroupStats is what happened with a group while a query was executed
+type GroupStats struct {
+	Name           string  `json:"name"`
+	Selects        int     `json:"selects"`
+	LatencySeconds float64 `json:"latencySeconds"`
+}
+
+// Summary is a copy of everything gathered by a collector
+type Summary struct {
+	Remotes      []RemoteStats `json:"remotes"`
+	Groups       []GroupStats  `json:"groups"`
+	MergeSeconds float64       `json:"mergeSeconds"`
+}
+
+// QueryInfo is the PromQL query executed, along with its time range and step. The step is zero
+// on instant queries, whose start and end are the same.
+type QueryInfo struct {
+	Query string
+	Start time.Time
+	End   time.Time
+	Step  time.Duration
+}
+
+// Collector gathers what happened while a query was executed, like which remotes were
+// contacted and how long they took. It is safe to be used by many goroutines. All of its
+// methods do nothing on a nil collector, so callers don't need to check if there's one.
+type Collector struct {
+	mu      sync.Mutex
+	remotes []*RemoteStats
+	groups  []*GroupStats
+	merge   time.Duration
+	query   *QueryInfo
+}
+
+func NewCollector() *Collector {
+	return &Collector{
+		remotes: make([]*RemoteStats, 0),
+		groups:  make([]*GroupStats, 0),
+	}
+}
+
+func NewContext(ctx context.Context, collector *Collector) context.Context {
+	return context.WithValue(ctx, contextKey{}, collector)
+}
+
+// FromContext returns the collector of the query being executed, or nil if there's none
+func FromContext(ctx context.Context) *Collector {
+	collector, _ := ctx.Value(contextKey{}).(*Collector)
+	return collector
+}
+
+// RecordRemoteRequest registers that a request was sent to the remote, how long it took and how
+// many bytes were received in the response.
+func (collector *Collector) RecordRemoteRequest(name string, latency time.Duration, bytesReceived int) {
+	if collector == nil {
+		return
+	}
+
+	collector.mu.Lock()
+	defer collector.mu.Unlock()
+	remote := collector.remote(name)
+	remote.Skipped = false
+	remote.SkipReason = ""
+	remote.Requests++
+	remote.LatencySeconds += latency.Seconds()
+	remote.BytesReceived += int64(bytesReceived)
+}
+
+// RecordRemoteSeries registers the series and samples returned by the remote
+func (collector *Collector) RecordRemoteSeries(name string, series int, samples int) {
+	if collector == nil {
+		return
+	}
+
+	collector.mu.Lock()
+	defer collector.mu.Unlock()
+	remote := collector.remote(name)
+	remote.Series += series
+	remote.Samples += samples
+}
+
+// RecordRemoteSkipped registers that the remote wasn't queried, and why. A remote that was
+// queried by other parts of the query is not considered skipped.
+func (collector *Collector) RecordRemoteSkipped(name string, reason string) {
+	if collector == nil {
+		return
+	}
+
+	collector.mu.Lock()
+	defer collector.mu.Unlock()
+	remote := collector.remote(name)
+	if remote.Requests > 0 {
+		return
+	}
+	remote.Skipped = true
+	remote.SkipReason = reason
+}
+
+// RecordGroupSelect registers that a select was answered by the group, and how long it took
+func (collector *Collector) RecordGroupSelect(name string, latency time.Duration) {
+	if collector == nil {
+		return
+	}
+
+	collector.mu.Lock()
+	defer collector.mu.Unlock()
+
+	var group *GroupStats
+	for _, existing := range collector.groups {
+		if existing.Name == name {
+			group = existing
+			break
+		}
+	}
+	if group == nil {
+		group = &GroupStats{Name: name}
+		collector.groups = append(collector.groups, group)
+	}
+
+	group.Selects++
+	group.LatencySeconds += latency.Seconds()
+}
+
+// RecordMerge registers the time spent merging the answers of many remotes or groups
+func (collector *Collector) RecordMerge(duration time.Duration) {
+	if collector == nil {
+		return
+	}
+
+	collector.mu.Lock()
+	defer collector.mu.Unlock()
+	collector.merge += duration
+}
+
+// RecordQuery registers the query being executed. Only the first one is kept, as the engines
+// might create other queries from it (like for the parts of a range that aren't cached).
+func (collector *Collector) RecordQuery(info QueryInfo) {
+	if collector == nil {
+		return
+	}
+
+	collector.mu.Lock()
+	defer collector.mu.Unlock()
+	if collector.query == nil {
+		collector.query = &info
+	}
+}
+
+// Query returns the query executed, if any was
+func (collector *Collector) Query() (QueryInfo, bool) {
+	if collector == nil {
+		return QueryInfo{}, false
+	}
+
+	collector.mu.Lock()
+	defer collector.mu.Unlock()
+	if collector.query == nil {
+		return QueryInfo{}, false
+	}
+	return *collector.query, true
+}
+
+// Remotes returns the names of the remotes contacted, in the order they were first contacted
+func (collector *Collector) Remotes() []string {
+	if collector == nil {
+		return []string{}
+	}
+
+	collector.mu.Lock()
+	defer collector.mu.Unlock()
+	names := make([]string, 0, len(collector.remotes))
+	for _, remote := range collector.remotes {
+		if remote.Requests > 0 {
+			names = append(names, remote.Name)
+		}
+	}
+	return names
+}
+
+// Summary returns a copy of what was gathered, with remotes and groups in the order they were
+// first seen
+func (collector *Collector) Summary() Summary {
+	summary := Summary{Remotes: []RemoteStats{}, Groups: []GroupStats{}}
+	if collector == nil {
+		return summary
+	}
+
+	collector.mu.Lock()
+	defer collector.mu.Unlock()
+	for _, remote := range collector.remotes {
+		summary.Remotes = append(summary.Remotes, *remote)
+	}
+	for _, group := range collector.groups {
+		summary.Groups = append(summary.Groups, *group)
+	}
+	summary.MergeSeconds = collector.merge.Seconds()
+
+	return summary
+}
+
+// remote returns the stats of the remote, creating them if needed. It must be called with the
+// lock held.
+func (collector *Collector) remote(name string) *RemoteStats {
+	for _, existing := range collector.remotes {
+		if existing.Name == name {
+			return existing
+		}
+	}
+
+	remote := &RemoteStats{Name: name}
+	collector.remotes = append(collector.remotes, remote)
+	return remote
+}
@@ -0,0 +1,104 @@
+package querystats_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/jademcosta/graviola/pkg/querystats"
+	"github.com/prometheus/prometheus/util/stats"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordsEachRemoteOnceInTheOrderTheyWereContacted(t *testing.T) {
+	collector := querystats.NewCollector()
+	ctx := querystats.NewContext(context.Background(), collector)
+
+	querystats.FromContext(ctx).RecordRemoteRequest("b", time.Second, 10)
+	querystats.FromContext(ctx).RecordRemoteRequest("a", time.Second, 10)
+	querystats.FromContext(ctx).RecordRemoteRequest("b", time.Second, 10)
+	querystats.FromContext(ctx).RecordRemoteSkipped("c", "time window")
+
+	assert.Equal(t, []string{"b", "a"}, collector.Remotes(),
+		"should return each remote contacted once, in order, without the skipped ones")
+}
+
+func TestSumsWhatHappenedOnEachRemoteAndGroup(t *testing.T) {
+	collector := querystats.NewCollector()
+
+	collector.RecordRemoteRequest("remote-1", time.Second, 100)
+	collector.RecordRemoteRequest("remote-1", 2*time.Second, 50)
+	collector.RecordRemoteSeries("remote-1", 2, 10)
+	collector.RecordRemoteSeries("remote-1", 1, 5)
+	collector.RecordRemoteSkipped("remote-2", "time window")
+	collector.RecordGroupSelect("group-1", time.Second)
+	collector.RecordGroupSelect("group-1", time.Second)
+	collector.RecordMerge(500 * time.Millisecond)
+	collector.RecordMerge(500 * time.Millisecond)
+
+	assert.Equal(t, querystats.Summary{
+		Remotes: []querystats.RemoteStats{
+			{Name: "remote-1", Requests: 2, LatencySeconds: 3, BytesReceived: 150, Series: 3, Samples: 15},
+			{Name: "remote-2", Skipped: true, SkipReason: "time window"},
+		},
+		Groups:       []querystats.GroupStats{{Name: "group-1", Selects: 2, LatencySeconds: 2}},
+		MergeSeconds: 1,
+	}, collector.Summary(), "should sum the stats of each remote and group")
+}
+
+func TestIgnoresRecordsWhenThereIsNoCollector(t *testing.T) {
+	collector := querystats.FromContext(context.Background())
+	assert.Nil(t, collector, "should return nil when there's no collector on the context")
+
+	assert.NotPanics(t, func() {
+		collector.RecordRemoteRequest("a", time.Second, 1)
+		collector.RecordRemoteSeries("a", 1, 1)
+		collector.RecordRemoteSkipped("a", "time window")
+		collector.RecordGroupSelect("a", time.Second)
+		collector.RecordMerge(time.Second)
+	}, "should be safe to record on a nil collector")
+	assert.Empty(t, collector.Remotes(), "should have no remotes")
+	assert.Empty(t, collector.Summary().Remotes, "should have no remotes")
+}
+
+func TestRendersStatsOnlyWhenRequested(t *testing.T) {
+	collector := querystats.NewCollector()
+	collector.RecordRemoteRequest("remote-1", time.Second, 100)
+	ctx := querystats.NewContext(context.Background(), collector)
+	engineStats := &stats.Statistics{Timers: stats.NewQueryTimers(), Samples: stats.NewQuerySamples(false)}
+
+	assert.Nil(t, querystats.Renderer(ctx, engineStats, ""), "should not render stats when not requested")
+
+	rendered := querystats.Renderer(ctx, engineStats, "all")
+	require.NotNil(t, rendered, "should render stats when requested")
+
+	encoded, err := json.Marshal(rendered)
+	require.NoError(t, err, "should encode the stats")
+
+	var decoded map[string]interface{}
+	require.NoError(t, json.Unmarshal(encoded, &decoded), "should decode the stats")
+	assert.Contains(t, decoded, "timings", "should have the engine timings")
+	assert.Contains(t, decoded, "samples", "should have the engine samples")
+	require.Contains(t, decoded, "graviola", "should have the graviola section")
+
+	remotes := decoded["graviola"].(map[string]interface{})["remotes"].([]interface{})
+	require.Len(t, remotes, 1, "should have the remotes contacted")
+	assert.Equal(t, "remote-1", remotes[0].(map[string]interface{})["name"], "should have the remote stats")
+}
+
+func TestKeepsTheFirstQueryRecorded(t *testing.T) {
+	collector := querystats.NewCollector()
+
+	_, ok := collector.Query()
+	assert.False(t, ok, "should have no query before one is recorded")
+
+	collector.RecordQuery(querystats.QueryInfo{Query: "up", Step: time.Minute})
+	collector.RecordQuery(querystats.QueryInfo{Query: "down"})
+
+	query, ok := collector.Query()
+	require.True(t, ok, "should have the query recorded")
+	assert.Equal(t, "up", query.Query, "should keep the first query recorded")
+	assert.Equal(t, time.Minute, query.Step, "should keep the step of the query")
+}
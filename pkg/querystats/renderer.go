@@ -0,0 +1,32 @@
+package querystats
+
+import (
+	"context"
+
+	"github.com/prometheus/prometheus/util/stats"
+)
+
+// queryStats are the stats of the engine with what happened on the remotes and groups
+type queryStats struct {
+	stats.BuiltinStats
+	Graviola Summary `json:"graviola"`
+}
+
+// stats.QueryStats
+func (qStats queryStats) Builtin() stats.BuiltinStats {
+	return qStats.BuiltinStats
+}
+
+// Renderer is the stats renderer of the Prometheus API. When stats are requested (with the
+// `stats` param), it returns the timings and samples of the engine along with a Graviola section
+// that tells what happened with each remote and group contacted.
+func Renderer(ctx context.Context, engineStats *stats.Statistics, param string) stats.QueryStats {
+	if param == "" {
+		return nil
+	}
+
+	return queryStats{
+		BuiltinStats: stats.NewQueryStats(engineStats).Builtin(),
+		Graviola:     FromContext(ctx).Summary(),
+	}
+}
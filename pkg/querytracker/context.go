@@ -0,0 +1,17 @@
+package querytracker
+
+import "context"
+
+type contextKey struct{}
+
+// WithCancel returns a cancellable context that allows the queries executed with it to be
+// cancelled through the tracker.
+func WithCancel(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancelFn := context.WithCancel(ctx)
+	return context.WithValue(ctx, contextKey{}, cancelFn), cancelFn
+}
+
+func cancelFromContext(ctx context.Context) context.CancelFunc {
+	cancelFn, _ := ctx.Value(contextKey{}).(context.CancelFunc)
+	return cancelFn
+}
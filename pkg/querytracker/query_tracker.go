@@ -2,24 +2,75 @@ package querytracker
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"math"
-	"math/rand"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/jademcosta/graviola/pkg/clientinfo"
+	"github.com/prometheus/prometheus/promql"
 )
 
+var ErrQueryNotFound = errors.New("query not found")
+var ErrQueryNotCancellable = errors.New("query cannot be cancelled")
+var ErrShuttingDown = errors.New("graviola is shutting down, no new queries are accepted")
+
+// ActiveQuery is a query that is being executed
+type ActiveQuery struct {
+	ID            int       `json:"id"`
+	Query         string    `json:"query"`
+	Start         time.Time `json:"start"`
+	ClientAddress string    `json:"client_address,omitempty"`
+	Tenant        string    `json:"tenant,omitempty"`
+
+	cancelFn  context.CancelFunc
+	fileIndex int
+}
+
+// GraviolaQueryTracker limits how many queries are executed at the same time, and keeps track
+// of the ones being executed, so they can be listed and cancelled.
+// When a directory is provided, the active queries are also written into an mmapped file on it,
+// the same way Prometheus does. The queries that were running when the process crashed are
+// logged on the next start.
 type GraviolaQueryTracker struct {
 	concurrencyLimmiter  chan struct{}
 	maxConcurrentQueries int
+	fileTracker          promql.QueryTracker
+	now                  func() time.Time
+
+	mu     sync.Mutex
+	nextID int
+	active map[int]*ActiveQuery
+	// draining is closed when new queries stop being accepted, and drained when the last of
+	// the active queries finishes after that
+	draining chan struct{}
+	drained  chan struct{}
 }
 
-func NewGraviolaQueryTracker(maxConcurrentQueries int) *GraviolaQueryTracker {
+func NewGraviolaQueryTracker(
+	logg *slog.Logger, maxConcurrentQueries int, activeQueryLogDir string,
+) *GraviolaQueryTracker {
 	if maxConcurrentQueries < 1 {
 		panic("maxConcurrentQueries < 1 is not allowed")
 	}
 
-	return &GraviolaQueryTracker{
-		concurrencyLimmiter: make(chan struct{}, maxConcurrentQueries),
+	tracker := &GraviolaQueryTracker{
+		concurrencyLimmiter:  make(chan struct{}, maxConcurrentQueries),
+		maxConcurrentQueries: maxConcurrentQueries,
+		now:                  time.Now,
+		active:               make(map[int]*ActiveQuery),
+		draining:             make(chan struct{}),
+		drained:              make(chan struct{}),
+	}
+
+	if activeQueryLogDir != "" {
+		tracker.fileTracker = promql.NewActiveQueryTracker(
+			activeQueryLogDir, maxConcurrentQueries, logg.With("component", "query_tracker"))
 	}
+
+	return tracker
 }
 
 // QueryTracker
@@ -32,22 +83,165 @@ func (tracker *GraviolaQueryTracker) GetMaxConcurrent() int {
 // Insert inserts query into query tracker. This call must block if maximum number of queries is already running.
 // If Insert doesn't return error then returned integer value should be used in subsequent Delete call.
 // Insert should return error if context is finished before query can proceed, and integer value returned in this case should be ignored by caller.
-func (tracker *GraviolaQueryTracker) Insert(ctx context.Context, _ string) (int, error) {
+func (tracker *GraviolaQueryTracker) Insert(ctx context.Context, query string) (int, error) {
 	select {
 	case tracker.concurrencyLimmiter <- struct{}{}:
-		return rand.Intn(math.MaxInt), nil
 	case <-ctx.Done():
 		return 0, fmt.Errorf("when waiting for query concurrency slot: %w", ctx.Err())
+	case <-tracker.draining:
+		return 0, ErrShuttingDown
+	}
+
+	activeQuery := &ActiveQuery{
+		Query:    query,
+		Start:    tracker.now(),
+		cancelFn: cancelFromContext(ctx),
+	}
+
+	if info, ok := clientinfo.FromContext(ctx); ok {
+		activeQuery.ClientAddress = info.Address
+		activeQuery.Tenant = info.Tenant
+	}
+
+	if tracker.fileTracker != nil {
+		// It never blocks, as it has the same amount of slots as the concurrency limiter
+		fileIndex, err := tracker.fileTracker.Insert(ctx, query)
+		if err != nil {
+			<-tracker.concurrencyLimmiter
+			return 0, fmt.Errorf("when writing query to the active query log: %w", err)
+		}
+		activeQuery.fileIndex = fileIndex
 	}
+
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+	if tracker.isDraining() {
+		tracker.release(activeQuery)
+		return 0, ErrShuttingDown
+	}
+	activeQuery.ID = tracker.nextID
+	tracker.nextID++
+	tracker.active[activeQuery.ID] = activeQuery
+
+	return activeQuery.ID, nil
 }
 
 // QueryTracker
 // Delete removes query from activity tracker. InsertIndex is value returned by Insert call.
-func (tracker *GraviolaQueryTracker) Delete(_ int) {
+func (tracker *GraviolaQueryTracker) Delete(insertIndex int) {
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+
+	activeQuery, ok := tracker.active[insertIndex]
+	if !ok {
+		return
+	}
+
+	delete(tracker.active, insertIndex)
+	tracker.release(activeQuery)
+	if tracker.isDraining() && len(tracker.active) == 0 {
+		close(tracker.drained)
+	}
+}
+
+// release frees the concurrency slot and the active query log entry of the query
+func (tracker *GraviolaQueryTracker) release(activeQuery *ActiveQuery) {
+	if tracker.fileTracker != nil {
+		tracker.fileTracker.Delete(activeQuery.fileIndex)
+	}
 	<-tracker.concurrencyLimmiter
 }
 
+// StopAccepting makes new queries fail with ErrShuttingDown, including the ones waiting for a
+// concurrency slot. The queries already running are not affected.
+func (tracker *GraviolaQueryTracker) StopAccepting() {
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+
+	if tracker.isDraining() {
+		return
+	}
+
+	close(tracker.draining)
+	if len(tracker.active) == 0 {
+		close(tracker.drained)
+	}
+}
+
+// Drain waits for the running queries to finish, after StopAccepting was called. It returns the
+// error of the context when it is done before that.
+func (tracker *GraviolaQueryTracker) Drain(ctx context.Context) error {
+	select {
+	case <-tracker.drained:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// CancelAll stops the execution of all the running queries that can be cancelled, and returns
+// how many were cancelled
+func (tracker *GraviolaQueryTracker) CancelAll() int {
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+
+	cancelled := 0
+	for _, activeQuery := range tracker.active {
+		if activeQuery.cancelFn != nil {
+			activeQuery.cancelFn()
+			cancelled++
+		}
+	}
+	return cancelled
+}
+
+func (tracker *GraviolaQueryTracker) isDraining() bool {
+	select {
+	case <-tracker.draining:
+		return true
+	default:
+		return false
+	}
+}
+
 // QueryTracker
 func (tracker *GraviolaQueryTracker) Close() error {
+	if tracker.fileTracker != nil {
+		return tracker.fileTracker.Close()
+	}
+	return nil
+}
+
+// List returns the queries being executed, the oldest first
+func (tracker *GraviolaQueryTracker) List() []ActiveQuery {
+	tracker.mu.Lock()
+	result := make([]ActiveQuery, 0, len(tracker.active))
+	for _, activeQuery := range tracker.active {
+		result = append(result, *activeQuery)
+	}
+	tracker.mu.Unlock()
+
+	slices.SortFunc(result, func(a, b ActiveQuery) int {
+		return a.ID - b.ID
+	})
+
+	return result
+}
+
+// Cancel stops the execution of the query with the given ID
+func (tracker *GraviolaQueryTracker) Cancel(id int) error {
+	tracker.mu.Lock()
+	activeQuery, ok := tracker.active[id]
+	tracker.mu.Unlock()
+
+	if !ok {
+		return ErrQueryNotFound
+	}
+
+	if activeQuery.cancelFn == nil {
+		return ErrQueryNotCancellable
+	}
+
+	activeQuery.cancelFn()
 	return nil
 }
@@ -2,21 +2,29 @@ package querytracker_test
 
 import (
 	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
+	"github.com/jademcosta/graviola/pkg/clientinfo"
+	"github.com/jademcosta/graviola/pkg/config"
+	"github.com/jademcosta/graviola/pkg/graviolalog"
 	"github.com/jademcosta/graviola/pkg/querytracker"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
+var logg *slog.Logger = graviolalog.NewLogger(config.LogConfig{Level: "error"})
+
 func TestInsertBlocksIfMaxConcurrencyIsReached(t *testing.T) {
 	ctx, cancelFn := context.WithCancel(context.Background())
 	defer cancelFn()
 
 	signalChan := make(chan struct{}, 5)
 
-	sut := querytracker.NewGraviolaQueryTracker(2)
+	sut := querytracker.NewGraviolaQueryTracker(logg, 2, "")
 
 	_, err := sut.Insert(ctx, "")
 	require.NoError(t, err, "should not error")
@@ -52,7 +60,7 @@ func TestABlockedInsertIsReleasedIfContextIsDone(t *testing.T) {
 
 	signalChan := make(chan error, 5)
 
-	sut := querytracker.NewGraviolaQueryTracker(2)
+	sut := querytracker.NewGraviolaQueryTracker(logg, 2, "")
 
 	_, err := sut.Insert(ctx, "")
 	require.NoError(t, err, "should not error")
@@ -84,6 +92,132 @@ func TestABlockedInsertIsReleasedIfContextIsDone(t *testing.T) {
 }
 
 func TestNewPanicsIfConcurrentQueriesIsLessThanOne(t *testing.T) {
-	assert.Panics(t, func() { querytracker.NewGraviolaQueryTracker(0) },
+	assert.Panics(t, func() { querytracker.NewGraviolaQueryTracker(logg, 0, "") },
 		"should panic if concurrent queries is < 1")
 }
+
+func TestGetMaxConcurrentReturnsTheLimit(t *testing.T) {
+	sut := querytracker.NewGraviolaQueryTracker(logg, 7, "")
+	assert.Equal(t, 7, sut.GetMaxConcurrent(), "should return the max concurrent queries")
+}
+
+func TestListsTheActiveQueries(t *testing.T) {
+	sut := querytracker.NewGraviolaQueryTracker(logg, 5, "")
+
+	ctx := clientinfo.NewContext(context.Background(), clientinfo.Info{Address: "10.0.0.1", Tenant: "team-a"})
+	firstID, err := sut.Insert(ctx, "up")
+	require.NoError(t, err, "should not error")
+	secondID, err := sut.Insert(context.Background(), "sum(rate(errors_total[5m]))")
+	require.NoError(t, err, "should not error")
+
+	active := sut.List()
+	require.Len(t, active, 2, "should list all the active queries")
+	assert.Equal(t, firstID, active[0].ID, "should list the oldest query first")
+	assert.Equal(t, "up", active[0].Query, "should record the query")
+	assert.Equal(t, "10.0.0.1", active[0].ClientAddress, "should record the client address")
+	assert.Equal(t, "team-a", active[0].Tenant, "should record the tenant")
+	assert.False(t, active[0].Start.IsZero(), "should record the start time")
+	assert.Equal(t, secondID, active[1].ID, "should list the newest query last")
+
+	sut.Delete(firstID)
+	active = sut.List()
+	require.Len(t, active, 1, "should not list deleted queries")
+	assert.Equal(t, secondID, active[0].ID, "should keep the other queries")
+}
+
+func TestCancelsActiveQueries(t *testing.T) {
+	sut := querytracker.NewGraviolaQueryTracker(logg, 5, "")
+
+	ctx, cancelFn := querytracker.WithCancel(context.Background())
+	defer cancelFn()
+
+	id, err := sut.Insert(ctx, "up")
+	require.NoError(t, err, "should not error")
+
+	require.NoError(t, sut.Cancel(id), "should cancel the query")
+	assert.Error(t, ctx.Err(), "should have cancelled the context of the query")
+
+	assert.ErrorIs(t, sut.Cancel(id+1), querytracker.ErrQueryNotFound, "should not find unknown queries")
+
+	id, err = sut.Insert(context.Background(), "up")
+	require.NoError(t, err, "should not error")
+	assert.ErrorIs(t, sut.Cancel(id), querytracker.ErrQueryNotCancellable,
+		"should not cancel queries without a cancellable context")
+}
+
+func TestStopAcceptingRejectsNewQueriesAndDrainWaitsForTheRunningOnes(t *testing.T) {
+	sut := querytracker.NewGraviolaQueryTracker(logg, 1, "")
+
+	id, err := sut.Insert(context.Background(), "up")
+	require.NoError(t, err, "should not error")
+
+	blockedErr := make(chan error, 1)
+	go func() {
+		_, err := sut.Insert(context.Background(), "waiting_for_a_slot")
+		blockedErr <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	sut.StopAccepting()
+	select {
+	case err := <-blockedErr:
+		assert.ErrorIs(t, err, querytracker.ErrShuttingDown, "should reject the queries waiting for a slot")
+	case <-time.After(time.Second):
+		assert.Fail(t, "the query waiting for a slot should have been rejected")
+	}
+
+	_, err = sut.Insert(context.Background(), "up")
+	assert.ErrorIs(t, err, querytracker.ErrShuttingDown, "should reject new queries")
+
+	ctx, cancelFn := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancelFn()
+	assert.ErrorIs(t, sut.Drain(ctx), context.DeadlineExceeded, "should wait for the running query")
+
+	sut.Delete(id)
+	assert.NoError(t, sut.Drain(context.Background()), "should finish draining when the running query finishes")
+	assert.Empty(t, sut.List(), "should have no active queries")
+}
+
+func TestDrainReturnsRightAwayWithoutRunningQueries(t *testing.T) {
+	sut := querytracker.NewGraviolaQueryTracker(logg, 2, "")
+	sut.StopAccepting()
+	sut.StopAccepting()
+
+	assert.NoError(t, sut.Drain(context.Background()), "should not wait when there are no running queries")
+}
+
+func TestCancelAllCancelsTheRunningQueries(t *testing.T) {
+	sut := querytracker.NewGraviolaQueryTracker(logg, 5, "")
+
+	firstCtx, firstCancelFn := querytracker.WithCancel(context.Background())
+	defer firstCancelFn()
+	secondCtx, secondCancelFn := querytracker.WithCancel(context.Background())
+	defer secondCancelFn()
+
+	for _, ctx := range []context.Context{firstCtx, secondCtx, context.Background()} {
+		_, err := sut.Insert(ctx, "up")
+		require.NoError(t, err, "should not error")
+	}
+
+	assert.Equal(t, 2, sut.CancelAll(), "should cancel the queries that can be cancelled")
+	assert.Error(t, firstCtx.Err(), "should have cancelled the context of the query")
+	assert.Error(t, secondCtx.Err(), "should have cancelled the context of the query")
+}
+
+func TestWritesTheActiveQueriesToAFile(t *testing.T) {
+	dir := t.TempDir()
+	sut := querytracker.NewGraviolaQueryTracker(logg, 2, dir)
+	defer sut.Close()
+
+	id, err := sut.Insert(context.Background(), "some_unique_metric_name")
+	require.NoError(t, err, "should not error")
+
+	content, err := os.ReadFile(filepath.Join(dir, "queries.active"))
+	require.NoError(t, err, "should create the active query file")
+	assert.Contains(t, string(content), "some_unique_metric_name", "should write the query to the file")
+
+	sut.Delete(id)
+	content, err = os.ReadFile(filepath.Join(dir, "queries.active"))
+	require.NoError(t, err, "should keep the active query file")
+	assert.NotContains(t, string(content), "some_unique_metric_name", "should remove finished queries from the file")
+}
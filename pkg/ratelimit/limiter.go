@@ -0,0 +1,154 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+
+	"github.com/jademcosta/graviola/pkg/config"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/time/rate"
+)
+
+const (
+	ClassQuery    = "query"
+	ClassMetadata = "metadata"
+)
+
+// cleanupInterval is how often the buckets of clients that stopped sending requests are removed
+const cleanupInterval = time.Minute
+
+var runOnceO11y sync.Once
+var rejectedTotal *prometheus.CounterVec
+var trackedClients *prometheus.GaugeVec
+
+type bucket struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// budget is the token buckets of the clients for a class of requests
+type budget struct {
+	class   string
+	limit   rate.Limit
+	burst   int
+	buckets map[string]*bucket
+}
+
+// Limiter has a token bucket per client and class of requests (queries or metadata), so a single
+// client can't starve the others. It is safe to be used by many goroutines.
+type Limiter struct {
+	mu          sync.Mutex
+	budgets     map[string]*budget
+	now         func() time.Time
+	lastCleanup time.Time
+}
+
+func NewLimiter(metricz *prometheus.Registry, conf config.RateLimitConfig, now func() time.Time) *Limiter {
+	registerMetrics(metricz)
+
+	limiter := &Limiter{
+		budgets:     make(map[string]*budget),
+		now:         now,
+		lastCleanup: now(),
+	}
+
+	for class, budgetConf := range map[string]config.RateLimitBudgetConfig{
+		ClassQuery: conf.QueryConf, ClassMetadata: conf.MetadataConf,
+	} {
+		if budgetConf.RequestsPerSecond <= 0 {
+			continue
+		}
+
+		limiter.budgets[class] = &budget{
+			class:   class,
+			limit:   rate.Limit(budgetConf.RequestsPerSecond),
+			burst:   budgetConf.Burst,
+			buckets: make(map[string]*bucket),
+		}
+	}
+
+	return limiter
+}
+
+// Allow takes a token from the bucket of the client. When there's none, it returns false and
+// how long the client should wait before trying again. The identity is the label of the client on
+// the rejected requests metric, so it must come from a bounded set (like the API key names), or
+// be empty.
+func (limiter *Limiter) Allow(class string, key string, identity string) (bool, time.Duration) {
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+
+	classBudget, ok := limiter.budgets[class]
+	if !ok {
+		return true, 0
+	}
+
+	now := limiter.now()
+	if now.Sub(limiter.lastCleanup) >= cleanupInterval {
+		limiter.cleanup(now)
+	}
+
+	clientBucket, ok := classBudget.buckets[key]
+	if !ok {
+		clientBucket = &bucket{limiter: rate.NewLimiter(classBudget.limit, classBudget.burst)}
+		classBudget.buckets[key] = clientBucket
+		trackedClients.WithLabelValues(class).Inc()
+	}
+	clientBucket.lastSeen = now
+
+	reservation := clientBucket.limiter.ReserveN(now, 1)
+	if !reservation.OK() {
+		rejectedTotal.WithLabelValues(class, identity).Inc()
+		return false, time.Second
+	}
+
+	delay := reservation.DelayFrom(now)
+	if delay == 0 {
+		return true, 0
+	}
+
+	reservation.CancelAt(now)
+	rejectedTotal.WithLabelValues(class, identity).Inc()
+	return false, delay
+}
+
+// cleanup removes the buckets that were idle long enough to be full again, as they are the same
+// as new ones. It must be called with the lock held.
+func (limiter *Limiter) cleanup(now time.Time) {
+	limiter.lastCleanup = now
+
+	for _, classBudget := range limiter.budgets {
+		timeToFill := time.Duration(float64(classBudget.burst) / float64(classBudget.limit) * float64(time.Second))
+		for key, clientBucket := range classBudget.buckets {
+			if now.Sub(clientBucket.lastSeen) >= timeToFill {
+				delete(classBudget.buckets, key)
+				trackedClients.WithLabelValues(classBudget.class).Dec()
+			}
+		}
+	}
+}
+
+func registerMetrics(metricz *prometheus.Registry) {
+	runOnceO11y.Do(func() {
+		rejectedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "graviola",
+			Subsystem: "ratelimit",
+			Name:      "rejected_total",
+			Help: "Counter of requests rejected for exceeding the rate limit, by class (query or metadata) " +
+				"and identity (the principal, when clients are identified by it).",
+		},
+			[]string{"class", "identity"})
+
+		trackedClients = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "graviola",
+			Subsystem: "ratelimit",
+			Name:      "tracked_clients",
+			Help:      "Number of clients with a token bucket, by class (query or metadata).",
+		},
+			[]string{"class"})
+
+		if metricz != nil {
+			metricz.MustRegister(rejectedTotal, trackedClients)
+		}
+	})
+}
@@ -0,0 +1,30 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jademcosta/graviola/pkg/config"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRejectedRequestsAreCountedByIdentity(t *testing.T) {
+	now := time.Unix(1000, 0)
+	sut := NewLimiter(prometheus.NewRegistry(), config.RateLimitConfig{
+		Enabled:   true,
+		QueryConf: config.RateLimitBudgetConfig{RequestsPerSecond: 1, Burst: 1},
+	}.FillDefaults(), func() time.Time { return now })
+	rejectedTotal.Reset()
+
+	for range 3 {
+		sut.Allow(ClassQuery, "grafana", "grafana")
+		sut.Allow(ClassQuery, "10.0.0.1", "")
+	}
+
+	assert.Equal(t, 2.0, testutil.ToFloat64(rejectedTotal.WithLabelValues(ClassQuery, "grafana")),
+		"should count the rejected requests of the identity")
+	assert.Equal(t, 2.0, testutil.ToFloat64(rejectedTotal.WithLabelValues(ClassQuery, "")),
+		"should count the clients without an identity together")
+}
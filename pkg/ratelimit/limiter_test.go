@@ -0,0 +1,77 @@
+package ratelimit_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jademcosta/graviola/pkg/config"
+	"github.com/jademcosta/graviola/pkg/ratelimit"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (clock *fakeClock) Now() time.Time {
+	return clock.now
+}
+
+func newLimiter(clock *fakeClock) *ratelimit.Limiter {
+	return ratelimit.NewLimiter(prometheus.NewRegistry(), config.RateLimitConfig{
+		Enabled:      true,
+		QueryConf:    config.RateLimitBudgetConfig{RequestsPerSecond: 1, Burst: 2},
+		MetadataConf: config.RateLimitBudgetConfig{RequestsPerSecond: 10, Burst: 1},
+	}.FillDefaults(), clock.Now)
+}
+
+func TestRejectsClientsAfterTheBurst(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	sut := newLimiter(clock)
+
+	allowed, _ := sut.Allow(ratelimit.ClassQuery, "client-a", "")
+	assert.True(t, allowed, "should allow the first request")
+	allowed, _ = sut.Allow(ratelimit.ClassQuery, "client-a", "")
+	assert.True(t, allowed, "should allow requests up to the burst")
+
+	allowed, retryAfter := sut.Allow(ratelimit.ClassQuery, "client-a", "")
+	assert.False(t, allowed, "should reject requests after the burst")
+	assert.Equal(t, time.Second, retryAfter, "should tell how long until there's a token again")
+
+	allowed, retryAfter = sut.Allow(ratelimit.ClassQuery, "client-a", "")
+	assert.False(t, allowed, "rejected requests should not take tokens")
+	assert.Equal(t, time.Second, retryAfter, "rejected requests should not make the client wait more")
+
+	clock.now = clock.now.Add(time.Second)
+	allowed, _ = sut.Allow(ratelimit.ClassQuery, "client-a", "")
+	assert.True(t, allowed, "should allow requests again after the bucket refills")
+}
+
+func TestClientsAndClassesHaveSeparateBudgets(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	sut := newLimiter(clock)
+
+	allowed, _ := sut.Allow(ratelimit.ClassMetadata, "client-a", "")
+	assert.True(t, allowed, "should allow the first metadata request")
+	allowed, _ = sut.Allow(ratelimit.ClassMetadata, "client-a", "")
+	assert.False(t, allowed, "should reject metadata requests after the burst")
+
+	allowed, _ = sut.Allow(ratelimit.ClassQuery, "client-a", "")
+	assert.True(t, allowed, "queries should not use the metadata budget")
+	allowed, _ = sut.Allow(ratelimit.ClassMetadata, "client-b", "")
+	assert.True(t, allowed, "other clients should not use the budget of client-a")
+}
+
+func TestClassesWithoutARateAreNotLimited(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	sut := ratelimit.NewLimiter(prometheus.NewRegistry(), config.RateLimitConfig{
+		Enabled:   true,
+		QueryConf: config.RateLimitBudgetConfig{RequestsPerSecond: 1},
+	}.FillDefaults(), clock.Now)
+
+	for range 100 {
+		allowed, _ := sut.Allow(ratelimit.ClassMetadata, "client-a", "")
+		assert.True(t, allowed, "should not limit metadata requests when they have no rate")
+	}
+}
@@ -0,0 +1,142 @@
+package remotestorage
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+
+	"github.com/jademcosta/graviola/pkg/domain"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/util/annotations"
+)
+
+// rangeChunk is a range of a range query, with start and end in seconds (both inclusive)
+type rangeChunk struct {
+	start int64
+	end   int64
+}
+
+// rangeChunks breaks the range into the smallest number of chunks that respect the max query
+// range and max points per series of the remote. Every chunk starts on a step of the original
+// range, so the timestamps of the returned points are the same as if it was a single request.
+func (rStorage *RemoteStorage) rangeChunks(start, end, step int64) []rangeChunk {
+	if step <= 0 {
+		return []rangeChunk{{start: start, end: end}}
+	}
+
+	// How many steps fit in a chunk, after its first point
+	stepsPerChunk := int64(-1)
+	if rStorage.maxQueryRange > 0 {
+		stepsPerChunk = int64(rStorage.maxQueryRange.Seconds()) / step
+	}
+	if rStorage.maxPointsPerSeries > 0 {
+		stepsByPoints := int64(rStorage.maxPointsPerSeries - 1)
+		if stepsPerChunk < 0 || stepsByPoints < stepsPerChunk {
+			stepsPerChunk = stepsByPoints
+		}
+	}
+
+	if stepsPerChunk < 0 || end-start <= stepsPerChunk*step {
+		return []rangeChunk{{start: start, end: end}}
+	}
+
+	chunks := make([]rangeChunk, 0, (end-start)/((stepsPerChunk+1)*step)+1)
+	for chunkStart := start; chunkStart <= end; {
+		chunkEnd := min(chunkStart+stepsPerChunk*step, end)
+		chunks = append(chunks, rangeChunk{start: chunkStart, end: chunkEnd})
+		chunkStart = chunkEnd + step
+	}
+
+	return chunks
+}
+
+// fetchRangeInChunks requests each chunk (up to chunkParallelism at a time) and concatenates the
+// datapoints of the series with the same labels, keeping them in time order. If any chunk fails,
+// the whole select fails, as the result would have gaps.
+func (rStorage *RemoteStorage) fetchRangeInChunks(
+	ctx context.Context, query string, chunks []rangeChunk, step int64, sortSeries bool,
+) *domain.GraviolaSeriesSet {
+	ctx, cancelFn := context.WithCancel(ctx)
+	defer cancelFn()
+
+	results := make([]*domain.GraviolaSeriesSet, len(chunks))
+	semaphore := make(chan struct{}, rStorage.chunkParallelism)
+	var wg sync.WaitGroup
+
+	for idx, chunk := range chunks {
+		semaphore <- struct{}{}
+		// A chunk failed or the query was cancelled, so the chunks not started yet are not needed
+		if err := ctx.Err(); err != nil {
+			<-semaphore
+			for skipped := idx; skipped < len(chunks); skipped++ {
+				results[skipped] = &domain.GraviolaSeriesSet{Erro: err}
+			}
+			break
+		}
+
+		wg.Add(1)
+		go func(idx int, chunk rangeChunk) {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+
+			results[idx] = rStorage.fetchRange(ctx, query, chunk, step, false)
+			if results[idx].Erro != nil {
+				cancelFn()
+			}
+		}(idx, chunk)
+	}
+	wg.Wait()
+
+	return concatSeriesSets(results, sortSeries)
+}
+
+func concatSeriesSets(seriesSets []*domain.GraviolaSeriesSet, sortSeries bool) *domain.GraviolaSeriesSet {
+	annots := *annotations.New()
+	for _, seriesSet := range seriesSets {
+		domain.MergeAnnotations(&annots, seriesSet.Annots)
+	}
+
+	// the chunks that were running when another one failed are cancelled, so their error only
+	// hides the one that caused it
+	var firstErr error
+	for _, seriesSet := range seriesSets {
+		if seriesSet.Erro == nil {
+			continue
+		}
+		if firstErr == nil || errors.Is(firstErr, context.Canceled) {
+			firstErr = seriesSet.Erro
+		}
+	}
+	if firstErr != nil {
+		return &domain.GraviolaSeriesSet{Erro: firstErr, Annots: annots}
+	}
+
+	series := make([]*domain.GraviolaSeries, 0)
+	seriesByLabels := make(map[string]*domain.GraviolaSeries)
+	for _, seriesSet := range seriesSets {
+		for _, serie := range seriesSet.Series {
+			key := serie.Lbs.String()
+			existing, ok := seriesByLabels[key]
+			if !ok {
+				seriesByLabels[key] = serie
+				series = append(series, serie)
+				continue
+			}
+			existing.Datapoints = append(existing.Datapoints, serie.Datapoints...)
+		}
+	}
+
+	if sortSeries && len(series) > 1 {
+		slices.SortFunc(series, func(a, b *domain.GraviolaSeries) int {
+			return labels.Compare(a.Labels(), b.Labels())
+		})
+	}
+
+	result := &domain.GraviolaSeriesSet{Series: series}
+	if len(annots) > 0 {
+		result.Annots = annots
+	}
+
+	return result
+}
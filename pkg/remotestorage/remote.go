@@ -3,24 +3,31 @@ package remotestorage
 import (
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
 	"net/url"
 	"slices"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/jademcosta/graviola/pkg/config"
 	"github.com/jademcosta/graviola/pkg/domain"
+	"github.com/jademcosta/graviola/pkg/o11y"
+	"github.com/jademcosta/graviola/pkg/querylimits"
+	"github.com/jademcosta/graviola/pkg/querystats"
 	"github.com/prometheus/common/model"
 	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/timestamp"
 	"github.com/prometheus/prometheus/promql/parser"
 	"github.com/prometheus/prometheus/storage"
 	"github.com/prometheus/prometheus/util/annotations"
 	api_v1 "github.com/prometheus/prometheus/web/api/v1"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const DefaultLabelValuesPath = "/api/v1/label/%s/values"
@@ -30,25 +37,52 @@ const DefaultRangeQueryPath = "/api/v1/query_range"
 const DefaultStep = 30 //30 seconds
 
 type RemoteStorage struct {
-	logg   *slog.Logger
-	URLs   map[string]string //TODO: I probably don't need this anymore
-	client *http.Client
-	now    func() time.Time
+	name               string
+	logg               *slog.Logger
+	URLs               map[string]string //TODO: I probably don't need this anymore
+	client             *http.Client
+	transport          *http.Transport
+	now                func() time.Time
+	maxQueryRange      time.Duration
+	maxPointsPerSeries int
+	chunkParallelism   int
+	timeWindow         config.TimeWindowConfig
 }
 
 func NewRemoteStorage(
 	logg *slog.Logger, conf config.RemoteConfig, now func() time.Time, timeout time.Duration,
 ) *RemoteStorage {
+	// Each remote has connections of its own, so they can be closed without affecting the others
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
 	return &RemoteStorage{
+		name: conf.Name,
 		logg: logg.With("name", conf.Name, "component", "remote"),
 		URLs: generateURLs(conf),
 		client: &http.Client{
 			Timeout: timeout,
+			// Sends the W3C trace context to the remote, on a span of each request
+			Transport: otelhttp.NewTransport(transport,
+				otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+					return "remote " + r.Method + " " + r.URL.Path
+				}),
+				otelhttp.WithSpanOptions(trace.WithAttributes(attribute.String("graviola.remote", conf.Name))),
+			),
 		},
-		now: now,
+		transport:          transport,
+		now:                now,
+		maxQueryRange:      conf.MaxQueryRangeDuration(),
+		maxPointsPerSeries: conf.MaxPointsPerSeries,
+		chunkParallelism:   max(conf.ChunkParallelism, 1),
+		timeWindow:         conf.TimeWindowConf,
 	}
 }
 
+// Name returns the name of the remote, as set on config
+func (rStorage *RemoteStorage) Name() string {
+	return rStorage.name
+}
+
 // Querier
 //
 // Select returns a set of series that matches the given label matchers.
@@ -56,6 +90,10 @@ func NewRemoteStorage(
 // It allows passing hints that can help in optimising select, but it's up to the (remote)
 // implementation how this is used, if used at all.
 func (rStorage *RemoteStorage) Select(ctx context.Context, sortSeries bool, hints *storage.SelectHints, matchers ...*labels.Matcher) storage.SeriesSet {
+	if (hints.Start != 0 || hints.End != 0) && rStorage.outsideTimeWindow(ctx, hints.Start, hints.End) {
+		return &domain.GraviolaSeriesSet{}
+	}
+
 	promQLQuery, err := ToPromQLQuery(matchers)
 	if err != nil {
 		e := fmt.Errorf("error creating query params: %w", err)
@@ -69,27 +107,48 @@ func (rStorage *RemoteStorage) Select(ctx context.Context, sortSeries bool, hint
 	params := url.Values{}
 	params.Set("query", *promQLQuery)
 
-	var urlForQuery string
 	if (hints.End == hints.Start) && (hints.End == 0) {
-		urlForQuery = rStorage.URLs["instant_query"]
+		return rStorage.fetchSeries(ctx, rStorage.URLs["instant_query"], params, sortSeries)
+	}
 
-	} else if hints.End == hints.Start {
-		urlForQuery = rStorage.URLs["instant_query"]
+	if hints.End == hints.Start {
 		params.Set("time", fmt.Sprintf("%d", removeMillisFromUnixTimestamp(hints.Start)))
+		return rStorage.fetchSeries(ctx, rStorage.URLs["instant_query"], params, sortSeries)
+	}
+
+	var step int64
+	if hints.Step == 0 { //TODO: allow a default step to be set by configs
+		step = DefaultStep
 	} else {
-		params.Set("start", fmt.Sprintf("%d", removeMillisFromUnixTimestamp(hints.Start)))
-		params.Set("end", fmt.Sprintf("%d", removeMillisFromUnixTimestamp(hints.End)))
-
-		if hints.Step == 0 { //TODO: allow a default step to be set by configs
-			params.Set("step", fmt.Sprintf("%d", DefaultStep))
-		} else {
-			// The engine turn step into milliseconds, but the API accepts only seconds
-			params.Set("step", fmt.Sprintf("%d", hints.Step/1000))
-		}
+		// The engine turn step into milliseconds, but the API accepts only seconds
+		step = hints.Step / 1000
+	}
 
-		urlForQuery = rStorage.URLs["range_query"]
+	chunks := rStorage.rangeChunks(
+		removeMillisFromUnixTimestamp(hints.Start), removeMillisFromUnixTimestamp(hints.End), step)
+	if len(chunks) == 1 {
+		return rStorage.fetchRange(ctx, *promQLQuery, chunks[0], step, sortSeries)
 	}
 
+	rStorage.logg.Debug("fetching range in chunks", "chunks", len(chunks))
+	return rStorage.fetchRangeInChunks(ctx, *promQLQuery, chunks, step, sortSeries)
+}
+
+func (rStorage *RemoteStorage) fetchRange(
+	ctx context.Context, query string, chunk rangeChunk, step int64, sortSeries bool,
+) *domain.GraviolaSeriesSet {
+	params := url.Values{}
+	params.Set("query", query)
+	params.Set("start", fmt.Sprintf("%d", chunk.start))
+	params.Set("end", fmt.Sprintf("%d", chunk.end))
+	params.Set("step", fmt.Sprintf("%d", step))
+
+	return rStorage.fetchSeries(ctx, rStorage.URLs["range_query"], params, sortSeries)
+}
+
+func (rStorage *RemoteStorage) fetchSeries(
+	ctx context.Context, urlForQuery string, params url.Values, sortSeries bool,
+) *domain.GraviolaSeriesSet {
 	req, err := http.NewRequestWithContext(ctx, http.MethodPost, urlForQuery, strings.NewReader(params.Encode()))
 	if err != nil {
 		e := fmt.Errorf("error creating request: %w", err)
@@ -133,9 +192,18 @@ func (rStorage *RemoteStorage) Select(ctx context.Context, sortSeries bool, hint
 		}
 	}
 
-	if len(responseFromServer.Warnings) > 0 {
-		responseTSData.Annots = *annotations.New()
-		responseTSData.Annots.Add(fmt.Errorf("warnings: %v", responseFromServer.Warnings))
+	if len(responseFromServer.Warnings) > 0 || len(responseFromServer.Infos) > 0 {
+		responseTSData.Annots = remoteAnnotations(responseFromServer)
+	}
+
+	rStorage.recordSeries(ctx, responseTSData)
+
+	err = querylimits.FromContext(ctx).AddSeries(len(responseTSData.Series))
+	if err != nil {
+		return &domain.GraviolaSeriesSet{
+			Erro:   err,
+			Annots: map[string]error{"remote_storage": err},
+		}
 	}
 
 	return responseTSData
@@ -144,8 +212,10 @@ func (rStorage *RemoteStorage) Select(ctx context.Context, sortSeries bool, hint
 // LabelQuerier
 //
 // Close releases the resources of the Querier.
+// It closes the idle connections to the remote. It is called when Graviola stops, after the
+// running queries finished or were cancelled.
 func (rStorage *RemoteStorage) Close() error {
-	//TODO: cancel all requests
+	rStorage.transport.CloseIdleConnections()
 	return nil
 }
 
@@ -161,14 +231,16 @@ func (rStorage *RemoteStorage) LabelValues(
 	_ *storage.LabelHints, //TODO: use hints
 	matchers ...*labels.Matcher,
 ) ([]string, annotations.Annotations, error) {
-
-	params := make([]string, len(matchers))
-	for idx, m := range matchers {
-		params[idx] = "match[]=" + url.QueryEscape(m.String())
+	annots := *annotations.New()
+	if rng, ok := domain.TimeRangeFromContext(ctx); ok && rStorage.outsideTimeWindow(ctx, rng.Start, rng.End) {
+		return []string{}, annots, nil
 	}
 
-	reqParams := strings.Join(params, "&")
-	annots := *annotations.New()
+	params, err := labelRequestParams(ctx, matchers)
+	if err != nil {
+		return []string{}, annots.Add(err), err
+	}
+	reqParams := params.Encode()
 
 	urlForQuery := fmt.Sprintf(rStorage.URLs["label_values"], name)
 	urlForQuery = urlForQuery + "?" + reqParams
@@ -195,11 +267,7 @@ func (rStorage *RemoteStorage) LabelValues(
 		return []string{}, annots.Add(err), err
 	}
 
-	if len(responseFromServer.Warnings) > 0 {
-		for _, w := range responseFromServer.Warnings {
-			annots.Add(errors.New(w))
-		}
-	}
+	domain.MergeAnnotations(&annots, remoteAnnotations(responseFromServer))
 
 	return names, annots, nil
 }
@@ -214,14 +282,16 @@ func (rStorage *RemoteStorage) LabelNames(
 	_ *storage.LabelHints, //TODO: use hints
 	matchers ...*labels.Matcher,
 ) ([]string, annotations.Annotations, error) {
-
-	params := make([]string, len(matchers))
-	for idx, m := range matchers {
-		params[idx] = "match[]=" + url.QueryEscape(m.String())
+	annots := *annotations.New()
+	if rng, ok := domain.TimeRangeFromContext(ctx); ok && rStorage.outsideTimeWindow(ctx, rng.Start, rng.End) {
+		return []string{}, annots, nil
 	}
 
-	reqBody := strings.Join(params, "&")
-	annots := *annotations.New()
+	params, err := labelRequestParams(ctx, matchers)
+	if err != nil {
+		return []string{}, annots.Add(err), err
+	}
+	reqBody := params.Encode()
 
 	req, err := http.NewRequestWithContext(ctx, http.MethodPost, rStorage.URLs["label_names"], strings.NewReader(reqBody))
 	if err != nil {
@@ -245,16 +315,65 @@ func (rStorage *RemoteStorage) LabelNames(
 		return []string{}, annots.Add(err), err
 	}
 
-	if len(responseFromServer.Warnings) > 0 {
-		for _, w := range responseFromServer.Warnings {
-			annots.Add(errors.New(w))
+	domain.MergeAnnotations(&annots, remoteAnnotations(responseFromServer))
+
+	return names, annots, nil
+}
+
+// outsideTimeWindow tells if the range (in millis) is all outside the time window of the remote.
+// The remote is not queried in that case, and it is recorded as skipped.
+func (rStorage *RemoteStorage) outsideTimeWindow(ctx context.Context, start, end int64) bool {
+	if !rStorage.timeWindow.IsSet() {
+		return false
+	}
+
+	windowStart, windowEnd := rStorage.timeWindow.Bounds(rStorage.now())
+	if end >= windowStart && start <= windowEnd {
+		return false
+	}
+
+	rStorage.logg.Debug("skipping remote, the query is outside of its time window",
+		"start", start, "end", end, "window_start", windowStart, "window_end", windowEnd)
+	querystats.FromContext(ctx).RecordRemoteSkipped(rStorage.name, querystats.SkipReasonTimeWindow)
+	o11y.MarkSkipped(ctx)
+	return true
+}
+
+// labelRequestParams builds the params of the label requests. All the matchers go in a single
+// selector, as each match[] param is a different selector and the remote answers with the union
+// of them. The time range is only sent when the querier was created for a bounded one.
+func labelRequestParams(ctx context.Context, matchers []*labels.Matcher) (url.Values, error) {
+	params := url.Values{}
+	if len(matchers) > 0 {
+		selector, err := ToPromQLQuery(matchers)
+		if err != nil {
+			return nil, err
 		}
+		params.Set("match[]", *selector)
 	}
 
-	return names, annots, nil
+	rng, ok := domain.TimeRangeFromContext(ctx)
+	if !ok {
+		return params, nil
+	}
+
+	if rng.Start > timestamp.FromTime(api_v1.MinTime) {
+		params.Set("start", formatTimestamp(rng.Start))
+	}
+	if rng.End < timestamp.FromTime(api_v1.MaxTime) {
+		params.Set("end", formatTimestamp(rng.End))
+	}
+
+	return params, nil
+}
+
+// formatTimestamp formats the timestamp in millis as the seconds accepted by the remotes
+func formatTimestamp(timestampWithMillis int64) string {
+	return strconv.FormatFloat(float64(timestampWithMillis)/1000, 'f', -1, 64)
 }
 
 func (rStorage *RemoteStorage) doRequest(req *http.Request) (*api_v1.Response, error) {
+	start := time.Now()
 	resp, err := rStorage.client.Do(req)
 	if err != nil {
 		e := fmt.Errorf("error making request: %w", err)
@@ -263,7 +382,9 @@ func (rStorage *RemoteStorage) doRequest(req *http.Request) (*api_v1.Response, e
 	}
 	defer resp.Body.Close()
 
-	data, err := io.ReadAll(resp.Body)
+	data, err := io.ReadAll(querylimits.NewReader(resp.Body, querylimits.FromContext(req.Context())))
+	querystats.FromContext(req.Context()).RecordRemoteRequest(rStorage.name, time.Since(start), len(data))
+	o11y.AddResponseBytes(req.Context(), len(data))
 	if err != nil {
 		e := fmt.Errorf("error reading request body: %w", err)
 		rStorage.logg.Error("request body reading", "error", e)
@@ -295,6 +416,14 @@ func (rStorage *RemoteStorage) doRequest(req *http.Request) (*api_v1.Response, e
 	return responseFromServer, nil
 }
 
+func (rStorage *RemoteStorage) recordSeries(ctx context.Context, seriesSet *domain.GraviolaSeriesSet) {
+	samples := 0
+	for _, serie := range seriesSet.Series {
+		samples += len(serie.Datapoints)
+	}
+	querystats.FromContext(ctx).RecordRemoteSeries(rStorage.name, len(seriesSet.Series), samples)
+}
+
 func (rStorage *RemoteStorage) parseLabelStringSlice(data interface{}) ([]string, error) {
 
 	unparsed, err := json.Marshal(data)
@@ -406,6 +535,20 @@ func (rStorage *RemoteStorage) parseTimeSeriesData(data []byte, sorted bool) *do
 	}
 }
 
+// remoteAnnotations turns the warnings and infos of the response into annotations, one for each
+// of them. The source is set by the queriers above this one, as only they know the full path.
+func remoteAnnotations(response *api_v1.Response) annotations.Annotations {
+	annots := *annotations.New()
+	for _, warning := range response.Warnings {
+		domain.AddRemoteAnnotation(&annots, domain.NewRemoteWarning(warning))
+	}
+	for _, info := range response.Infos {
+		domain.AddRemoteAnnotation(&annots, domain.NewRemoteInfo(info))
+	}
+
+	return annots
+}
+
 func generateURLs(conf config.RemoteConfig) map[string]string {
 	result := make(map[string]string)
 
@@ -0,0 +1,259 @@
+package remotestorage_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jademcosta/graviola/internal/mocks"
+	"github.com/jademcosta/graviola/pkg/config"
+	"github.com/jademcosta/graviola/pkg/domain"
+	"github.com/jademcosta/graviola/pkg/remotestorage"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type requestedRange struct {
+	start int64
+	end   int64
+	step  int64
+}
+
+// newRangeRemote answers range queries with two series, with one point on each step (valued
+// with its timestamp), and records the requested ranges
+func newRangeRemote(t *testing.T) (*httptest.Server, func() []requestedRange) {
+	var mu sync.Mutex
+	requested := make([]requestedRange, 0)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(remotestorage.DefaultRangeQueryPath, func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm(), "should parse the form")
+		start, _ := strconv.ParseInt(r.PostForm.Get("start"), 10, 64)
+		end, _ := strconv.ParseInt(r.PostForm.Get("end"), 10, 64)
+		step, _ := strconv.ParseInt(r.PostForm.Get("step"), 10, 64)
+
+		mu.Lock()
+		requested = append(requested, requestedRange{start: start, end: end, step: step})
+		mu.Unlock()
+
+		matrix := model.Matrix{}
+		for _, instance := range []string{"a", "b"} {
+			values := make([]model.SamplePair, 0)
+			for ts := start; ts <= end; ts += step {
+				values = append(values, model.SamplePair{
+					Timestamp: model.Time(ts * 1000), Value: model.SampleValue(ts),
+				})
+			}
+			matrix = append(matrix, &model.SampleStream{
+				Metric: model.Metric{"__name__": "up", "instance": model.LabelValue(instance)},
+				Values: values,
+			})
+		}
+
+		body, err := json.Marshal(map[string]any{
+			"status": "success",
+			"data":   map[string]any{"resultType": "matrix", "result": matrix},
+		})
+		require.NoError(t, err, "should encode the answer")
+		_, err = w.Write(body)
+		require.NoError(t, err, "should write the answer")
+	})
+
+	return httptest.NewServer(mux), func() []requestedRange {
+		mu.Lock()
+		defer mu.Unlock()
+		result := slices.Clone(requested)
+		slices.SortFunc(result, func(a, b requestedRange) int { return int(a.start - b.start) })
+		return result
+	}
+}
+
+func selectRange(
+	t *testing.T, sut *remotestorage.RemoteStorage, start, end int64, step int64,
+) *domain.GraviolaSeriesSet {
+	hints := &storage.SelectHints{Start: start * 1000, End: end * 1000, Step: step * 1000}
+	result := sut.Select(context.Background(), true, hints, labels.MustNewMatcher(labels.MatchEqual, "__name__", "up"))
+
+	seriesSet, ok := result.(*domain.GraviolaSeriesSet)
+	require.True(t, ok, "should return a graviola series set")
+	require.NoError(t, seriesSet.Erro, "should not error")
+	return seriesSet
+}
+
+func TestDoesNotChunkRangesInsideTheLimits(t *testing.T) {
+	remoteSrv, requested := newRangeRemote(t)
+	defer remoteSrv.Close()
+
+	sut := remotestorage.NewRemoteStorage(logg,
+		config.RemoteConfig{Name: "test", Address: remoteSrv.URL, MaxQueryRange: "1h", MaxPointsPerSeries: 61},
+		func() time.Time { return frozenTime }, dummyTimeout)
+
+	selectRange(t, sut, 0, 3600, 60)
+
+	assert.Equal(t, []requestedRange{{start: 0, end: 3600, step: 60}}, requested(),
+		"should send a single request when the range fits the limits")
+}
+
+func TestChunksRangesLongerThanTheMaxQueryRange(t *testing.T) {
+	remoteSrv, requested := newRangeRemote(t)
+	defer remoteSrv.Close()
+
+	sut := remotestorage.NewRemoteStorage(logg,
+		config.RemoteConfig{Name: "test", Address: remoteSrv.URL, MaxQueryRange: "1h"},
+		func() time.Time { return frozenTime }, dummyTimeout)
+
+	result := selectRange(t, sut, 0, 9000, 60)
+
+	assert.Equal(t, []requestedRange{
+		{start: 0, end: 3600, step: 60},
+		{start: 3660, end: 7260, step: 60},
+		{start: 7320, end: 9000, step: 60},
+	}, requested(), "should request chunks no longer than the max query range")
+
+	require.Len(t, result.Series, 2, "should concatenate the series with the same labels")
+	for _, series := range result.Series {
+		require.Len(t, series.Datapoints, 151, "should have the points of all chunks")
+		for idx, point := range series.Datapoints {
+			assert.Equal(t, model.Time(idx*60*1000), point.Timestamp, "should keep the points in order")
+		}
+	}
+	assert.Equal(t, "a", result.Series[0].Lbs.Get("instance"), "should keep the series sorted")
+}
+
+func TestChunksRangesWithMorePointsThanTheMaxPointsPerSeries(t *testing.T) {
+	remoteSrv, requested := newRangeRemote(t)
+	defer remoteSrv.Close()
+
+	sut := remotestorage.NewRemoteStorage(logg,
+		config.RemoteConfig{
+			Name: "test", Address: remoteSrv.URL, MaxQueryRange: "1d", MaxPointsPerSeries: 10, ChunkParallelism: 3,
+		},
+		func() time.Time { return frozenTime }, dummyTimeout)
+
+	result := selectRange(t, sut, 100, 2500, 100)
+
+	assert.Equal(t, []requestedRange{
+		{start: 100, end: 1000, step: 100},
+		{start: 1100, end: 2000, step: 100},
+		{start: 2100, end: 2500, step: 100},
+	}, requested(), "should request chunks with at most the max points per series")
+
+	for _, series := range result.Series {
+		require.Len(t, series.Datapoints, 25, "should have the points of all chunks")
+		assert.True(t, slices.IsSortedFunc(series.Datapoints, func(a, b model.SamplePair) int {
+			return int(a.Timestamp - b.Timestamp)
+		}), "should keep the points in order when chunks are fetched in parallel")
+	}
+}
+
+func TestFailsTheSelectWhenAChunkFails(t *testing.T) {
+	calls := 0
+	var mu sync.Mutex
+	mux := http.NewServeMux()
+	mux.HandleFunc(remotestorage.DefaultRangeQueryPath, func(w http.ResponseWriter, _ *http.Request) {
+		mu.Lock()
+		calls++
+		failing := calls == 2
+		mu.Unlock()
+
+		if failing {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		_, err := w.Write([]byte(`{"status":"success","data":{"resultType":"matrix","result":[]}}`))
+		assert.NoError(t, err, "should write the answer")
+	})
+	remoteSrv := httptest.NewServer(mux)
+	defer remoteSrv.Close()
+
+	sut := remotestorage.NewRemoteStorage(logg,
+		config.RemoteConfig{Name: "test", Address: remoteSrv.URL, MaxQueryRange: "1h"},
+		func() time.Time { return frozenTime }, dummyTimeout)
+
+	hints := &storage.SelectHints{Start: 0, End: 9000 * 1000, Step: 60 * 1000}
+	result := sut.Select(context.Background(), true, hints, labels.MustNewMatcher(labels.MatchEqual, "__name__", "up"))
+
+	assert.Error(t, result.Err(), "should fail when any of the chunks fail")
+}
+
+func TestDoesNotRequestTheRemainingChunksWhenAChunkFails(t *testing.T) {
+	recorder, restore := mocks.RecordSpans()
+	defer restore()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(remotestorage.DefaultRangeQueryPath, func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	})
+	remoteSrv := httptest.NewServer(mux)
+	defer remoteSrv.Close()
+
+	sut := remotestorage.NewRemoteStorage(logg,
+		config.RemoteConfig{Name: "test", Address: remoteSrv.URL, MaxQueryRange: "1h", ChunkParallelism: 1},
+		func() time.Time { return frozenTime }, dummyTimeout)
+
+	hints := &storage.SelectHints{Start: 0, End: 9000 * 1000, Step: 60 * 1000}
+	result := sut.Select(context.Background(), true, hints, labels.MustNewMatcher(labels.MatchEqual, "__name__", "up"))
+
+	require.Error(t, result.Err(), "should fail when any of the chunks fail")
+	assert.NotErrorIs(t, result.Err(), context.Canceled, "should answer with the error of the failed chunk")
+	assert.Len(t, recorder.Ended(), 1, "should not start the requests of the chunks after the failed one")
+}
+
+func TestDoesNotRequestChunksOfCancelledQueries(t *testing.T) {
+	recorder, restore := mocks.RecordSpans()
+	defer restore()
+
+	remoteSrv, requested := newRangeRemote(t)
+	defer remoteSrv.Close()
+
+	sut := remotestorage.NewRemoteStorage(logg,
+		config.RemoteConfig{Name: "test", Address: remoteSrv.URL, MaxQueryRange: "1h"},
+		func() time.Time { return frozenTime }, dummyTimeout)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	hints := &storage.SelectHints{Start: 0, End: 9000 * 1000, Step: 60 * 1000}
+	result := sut.Select(ctx, true, hints, labels.MustNewMatcher(labels.MatchEqual, "__name__", "up"))
+
+	assert.ErrorIs(t, result.Err(), context.Canceled, "should fail with the cancellation of the query")
+	assert.Empty(t, requested(), "should not request any chunk")
+	assert.Empty(t, recorder.Ended(), "should not start the request of any chunk")
+}
+
+func TestAnswersWithTheErrorOfTheFailedChunkWhenTheOthersAreCancelled(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc(remotestorage.DefaultRangeQueryPath, func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm(), "should parse the form")
+		if r.PostForm.Get("start") != "0" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		// The first chunk is still running when a later one fails, so it is cancelled
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	})
+	remoteSrv := httptest.NewServer(mux)
+	defer remoteSrv.Close()
+
+	sut := remotestorage.NewRemoteStorage(logg,
+		config.RemoteConfig{Name: "test", Address: remoteSrv.URL, MaxQueryRange: "1h", ChunkParallelism: 3},
+		func() time.Time { return frozenTime }, dummyTimeout)
+
+	hints := &storage.SelectHints{Start: 0, End: 9000 * 1000, Step: 60 * 1000}
+	result := sut.Select(context.Background(), true, hints, labels.MustNewMatcher(labels.MatchEqual, "__name__", "up"))
+
+	require.Error(t, result.Err(), "should fail when any of the chunks fail")
+	assert.NotErrorIs(t, result.Err(), context.Canceled, "should answer with the error of the failed chunk")
+}
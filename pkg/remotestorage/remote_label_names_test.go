@@ -2,7 +2,6 @@ package remotestorage_test
 
 import (
 	"context"
-	"errors"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
@@ -11,9 +10,13 @@ import (
 	"time"
 
 	"github.com/jademcosta/graviola/pkg/config"
+	"github.com/jademcosta/graviola/pkg/domain"
 	"github.com/jademcosta/graviola/pkg/remotestorage"
 	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/timestamp"
+	"github.com/prometheus/prometheus/promql/parser"
 	"github.com/prometheus/prometheus/util/annotations"
+	api_v1 "github.com/prometheus/prometheus/web/api/v1"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -111,7 +114,7 @@ func TestKnowsHowToDealWithLabelNamesRemoteErrors(t *testing.T) {
 
 func TestLabelNamesParametersAreSentToRemote(t *testing.T) {
 
-	var calledWith string
+	var calledWith url.Values
 	mockRemote := MockRemote{
 		mux: http.NewServeMux(),
 	}
@@ -123,15 +126,15 @@ func TestLabelNamesParametersAreSentToRemote(t *testing.T) {
 		w.WriteHeader(http.StatusOK)
 		err = r.ParseForm()
 		assert.NoError(t, err, "should return no error")
-		calledWith = r.Form.Encode()
+		calledWith = r.Form
 	})
 
 	remoteSrv := httptest.NewServer(mockRemote.mux)
 	defer remoteSrv.Close()
 
 	matchers := []*labels.Matcher{
-		{Type: labels.MatchEqual, Name: "label_filter_1", Value: "a value for here"},
-		{Type: labels.MatchNotEqual, Name: "my_label", Value: "another value"},
+		labels.MustNewMatcher(labels.MatchEqual, "label_filter_1", "a value for here"),
+		labels.MustNewMatcher(labels.MatchNotEqual, "my_label", "another value"),
 	}
 
 	sut := remotestorage.NewRemoteStorage(
@@ -140,12 +143,49 @@ func TestLabelNamesParametersAreSentToRemote(t *testing.T) {
 		func() time.Time { return frozenTime },
 		dummyTimeout,
 	)
-	_, _, err := sut.LabelNames(context.Background(), nil, matchers...)
+	ctx := domain.NewTimeRangeContext(context.Background(), domain.TimeRange{Start: 1000500, End: 2000000})
+	_, _, err := sut.LabelNames(ctx, nil, matchers...)
 	require.NoError(t, err, "should return no error")
 
-	result, err := url.QueryUnescape(calledWith)
+	assert.Equal(t, [][]*labels.Matcher{matchers}, parseMatchParams(t, calledWith),
+		"should send all the matchers on a single selector")
+	assert.Equal(t, "1000.5", calledWith.Get("start"), "should send the start of the time range")
+	assert.Equal(t, "2000", calledWith.Get("end"), "should send the end of the time range")
+}
+
+func TestLabelNamesDoesNotSendAnUnboundedTimeRange(t *testing.T) {
+
+	var calledWith url.Values
+	mockRemote := MockRemote{
+		mux: http.NewServeMux(),
+	}
+
+	mockRemote.mux.HandleFunc(remotestorage.DefaultLabelNamesPath, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		_, err := w.Write([]byte(`{"status":"success","data":["hi"]}`))
+		assert.NoError(t, err, "should return no error")
+
+		err = r.ParseForm()
+		assert.NoError(t, err, "should return no error")
+		calledWith = r.Form
+	})
+
+	remoteSrv := httptest.NewServer(mockRemote.mux)
+	defer remoteSrv.Close()
+
+	sut := remotestorage.NewRemoteStorage(
+		logg,
+		config.RemoteConfig{Name: "test", Address: remoteSrv.URL},
+		func() time.Time { return frozenTime },
+		dummyTimeout,
+	)
+	ctx := domain.NewTimeRangeContext(context.Background(), domain.TimeRange{
+		Start: timestamp.FromTime(api_v1.MinTime), End: timestamp.FromTime(api_v1.MaxTime),
+	})
+	_, _, err := sut.LabelNames(ctx, nil)
 	require.NoError(t, err, "should return no error")
-	assert.Equal(t, generateQueryParams(matchers), result, "query params should match")
+
+	assert.Empty(t, calledWith, "should not send matchers nor a time range")
 }
 
 func TestLabelNamesWarningsAreTurnedIntoAnnotations(t *testing.T) {
@@ -155,14 +195,11 @@ func TestLabelNamesWarningsAreTurnedIntoAnnotations(t *testing.T) {
 	}{
 		{
 			`{"status":"success","data":["__name__"],"warnings":["something went awfuly wrong"]}`,
-			annotations.Annotations(map[string]error{"something went awfuly wrong": errors.New("something went awfuly wrong")}),
+			remoteWarnings("something went awfuly wrong"),
 		},
 		{
 			`{"status":"success","data":["__name__"],"warnings":["something went awfuly wrong", "agaaaain"]}`,
-			annotations.Annotations(map[string]error{
-				"something went awfuly wrong": errors.New("something went awfuly wrong"),
-				"agaaaain":                    errors.New("agaaaain"),
-			}),
+			remoteWarnings("something went awfuly wrong", "agaaaain"),
 		},
 		{
 			`{"status":"success","data":["__name__"],"warnings":[]}`,
@@ -208,17 +245,15 @@ func panicOnError(err error) {
 	}
 }
 
-func generateQueryParams(matchers []*labels.Matcher) string {
-	builder := strings.Builder{}
-
-	for _, matcher := range matchers {
-		_, err := builder.WriteString("match[]=")
-		panicOnError(err)
-		_, err = builder.WriteString(matcher.String())
-		panicOnError(err)
-		_, err = builder.WriteString("&")
-		panicOnError(err)
+// parseMatchParams parses the match[] params the same way Prometheus does, where each one is a
+// different selector
+func parseMatchParams(t *testing.T, params url.Values) [][]*labels.Matcher {
+	selectors := make([][]*labels.Matcher, 0, len(params["match[]"]))
+	for _, param := range params["match[]"] {
+		matchers, err := parser.ParseMetricSelector(param)
+		require.NoError(t, err, "should send a selector Prometheus is able to parse")
+		selectors = append(selectors, matchers)
 	}
 
-	return strings.TrimRight(builder.String(), "&")
+	return selectors
 }
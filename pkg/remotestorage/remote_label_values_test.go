@@ -2,7 +2,6 @@ package remotestorage_test
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
@@ -13,6 +12,7 @@ import (
 
 	"github.com/go-chi/chi/v5"
 	"github.com/jademcosta/graviola/pkg/config"
+	"github.com/jademcosta/graviola/pkg/domain"
 	"github.com/jademcosta/graviola/pkg/remotestorage"
 	"github.com/prometheus/prometheus/model/labels"
 	"github.com/prometheus/prometheus/util/annotations"
@@ -114,7 +114,7 @@ func TestLabelValuesKnowsHowToDealWithRemoteErrors(t *testing.T) {
 
 func TestLabelValuesParametersAreSentToRemote(t *testing.T) {
 
-	var calledWithParams string
+	var calledWithParams url.Values
 	var calledWithLabelName string
 	mux := chi.NewMux()
 
@@ -125,7 +125,7 @@ func TestLabelValuesParametersAreSentToRemote(t *testing.T) {
 		w.WriteHeader(http.StatusOK)
 		err = r.ParseForm()
 		assert.NoError(t, err, "should return no error")
-		calledWithParams = r.Form.Encode()
+		calledWithParams = r.Form
 		calledWithLabelName = chi.URLParam(r, "labelname")
 	})
 
@@ -133,8 +133,8 @@ func TestLabelValuesParametersAreSentToRemote(t *testing.T) {
 	defer remoteSrv.Close()
 
 	matchers := []*labels.Matcher{
-		{Type: labels.MatchEqual, Name: "label_filter_1", Value: "a value for here"},
-		{Type: labels.MatchNotEqual, Name: "my_label", Value: "another value"},
+		labels.MustNewMatcher(labels.MatchEqual, "label_filter_1", "a value for here"),
+		labels.MustNewMatcher(labels.MatchNotEqual, "my_label", "another value"),
 	}
 
 	sut := remotestorage.NewRemoteStorage(
@@ -143,12 +143,14 @@ func TestLabelValuesParametersAreSentToRemote(t *testing.T) {
 		func() time.Time { return frozenTime },
 		dummyTimeout,
 	)
-	_, _, err := sut.LabelValues(context.Background(), "some-random-name", nil, matchers...)
+	ctx := domain.NewTimeRangeContext(context.Background(), domain.TimeRange{Start: 1000000, End: 2000250})
+	_, _, err := sut.LabelValues(ctx, "some-random-name", nil, matchers...)
 	require.NoError(t, err, "should return no error")
 
-	paramsResult, err := url.QueryUnescape(calledWithParams)
-	require.NoError(t, err, "should return no error")
-	assert.Equal(t, generateQueryParams(matchers), paramsResult, "query params should match")
+	assert.Equal(t, [][]*labels.Matcher{matchers}, parseMatchParams(t, calledWithParams),
+		"should send all the matchers on a single selector")
+	assert.Equal(t, "1000", calledWithParams.Get("start"), "should send the start of the time range")
+	assert.Equal(t, "2000.25", calledWithParams.Get("end"), "should send the end of the time range")
 	assert.Equal(t, "some-random-name", calledWithLabelName, "query params should match")
 }
 
@@ -159,14 +161,11 @@ func TestLabelValuesWarningsAreTurnedIntoAnnotations(t *testing.T) {
 	}{
 		{
 			`{"status":"success","data":["localhost:9090"],"warnings":["something went awfuly wrong"]}`,
-			annotations.Annotations(map[string]error{"something went awfuly wrong": errors.New("something went awfuly wrong")}),
+			remoteWarnings("something went awfuly wrong"),
 		},
 		{
 			`{"status":"success","data":["localhost:9090"],"warnings":["something went awfuly wrong", "agaaaain"]}`,
-			annotations.Annotations(map[string]error{
-				"something went awfuly wrong": errors.New("something went awfuly wrong"),
-				"agaaaain":                    errors.New("agaaaain"),
-			}),
+			remoteWarnings("something went awfuly wrong", "agaaaain"),
 		},
 		{
 			`{"status":"success","data":["localhost:9090"],"warnings":[]}`,
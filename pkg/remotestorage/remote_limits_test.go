@@ -0,0 +1,56 @@
+package remotestorage_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jademcosta/graviola/pkg/config"
+	"github.com/jademcosta/graviola/pkg/domain"
+	"github.com/jademcosta/graviola/pkg/querylimits"
+	"github.com/jademcosta/graviola/pkg/remotestorage"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func selectWithLimits(t *testing.T, limits config.QueryLimitsConfig) (*domain.GraviolaSeriesSet, context.Context) {
+	t.Helper()
+	remoteSrv, _ := newRangeRemote(t)
+	t.Cleanup(remoteSrv.Close)
+
+	sut := remotestorage.NewRemoteStorage(logg,
+		config.RemoteConfig{Name: "test", Address: remoteSrv.URL},
+		func() time.Time { return frozenTime }, dummyTimeout)
+
+	ctx, cancelFn := context.WithCancelCause(context.Background())
+	t.Cleanup(func() { cancelFn(nil) })
+	ctx = querylimits.NewContext(ctx, querylimits.NewLimiter(limits, cancelFn))
+
+	hints := &storage.SelectHints{Start: 0, End: 3600 * 1000, Step: 60 * 1000}
+	result := sut.Select(ctx, true, hints, labels.MustNewMatcher(labels.MatchEqual, "__name__", "up"))
+
+	seriesSet, ok := result.(*domain.GraviolaSeriesSet)
+	require.True(t, ok, "should return a graviola series set")
+	return seriesSet, ctx
+}
+
+func TestSelectAccountsTheSeriesFetchedOnTheQueryLimits(t *testing.T) {
+	result, ctx := selectWithLimits(t, config.QueryLimitsConfig{MaxSeries: 2})
+	require.NoError(t, result.Erro, "should not fail when the series are up to the limit")
+	assert.Len(t, result.Series, 2, "should answer with the series")
+
+	result, ctx = selectWithLimits(t, config.QueryLimitsConfig{MaxSeries: 1})
+	assert.ErrorIs(t, result.Erro, querylimits.ErrLimitExceeded, "should fail when there are more series than the limit")
+	assert.ErrorIs(t, context.Cause(ctx), querylimits.ErrLimitExceeded, "should cancel the query")
+}
+
+func TestSelectAccountsTheBytesFetchedOnTheQueryLimits(t *testing.T) {
+	result, _ := selectWithLimits(t, config.QueryLimitsConfig{MaxFetchedBytes: 1024 * 1024})
+	require.NoError(t, result.Erro, "should not fail when the response is smaller than the limit")
+
+	result, ctx := selectWithLimits(t, config.QueryLimitsConfig{MaxFetchedBytes: 100})
+	assert.ErrorIs(t, result.Erro, querylimits.ErrLimitExceeded, "should fail when the response is bigger than the limit")
+	assert.ErrorIs(t, context.Cause(ctx), querylimits.ErrLimitExceeded, "should cancel the query")
+}
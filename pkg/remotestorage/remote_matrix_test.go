@@ -3,7 +3,6 @@ package remotestorage_test
 import (
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"math"
 	"net/http"
@@ -63,7 +62,7 @@ func TestParsesMatrixResponseCorrectlyWithOrderedLabelsAndSeries(t *testing.T) {
 		{
 			cases[1], //TODO: use case 0 too?
 			1,
-			annotations.New().Add(errors.New("warnings: [PromQL info: input to histogram_quantile needed to be fixed for monotonicity (and may give inaccurate results) for metric name \"\" (1:25)]")),
+			remoteWarnings("PromQL info: input to histogram_quantile needed to be fixed for monotonicity (and may give inaccurate results) for metric name \"\" (1:25)"),
 		},
 		{
 			cases[2],
@@ -7,15 +7,18 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/jademcosta/graviola/pkg/config"
 	"github.com/jademcosta/graviola/pkg/domain"
 	"github.com/jademcosta/graviola/pkg/graviolalog"
+	"github.com/jademcosta/graviola/pkg/querystats"
 	"github.com/jademcosta/graviola/pkg/remotestorage"
 	"github.com/prometheus/prometheus/model/labels"
 	"github.com/prometheus/prometheus/storage"
+	"github.com/prometheus/prometheus/util/annotations"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -209,3 +212,97 @@ func TestRespectsTimeout(t *testing.T) {
 	assert.True(t, ok, "should have the expected type")
 	assert.Error(t, gSeriesSet.Erro, "should have returned an error due to timeout")
 }
+
+func TestWarningsAndInfosAreTurnedIntoSeparateAnnotations(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc(remotestorage.DefaultInstantQueryPath, func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		_, err := w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[]},"warnings":["warn 1","warn 2"],"infos":["info 1"]}`))
+		panicOnError(err)
+	})
+
+	remoteSrv := httptest.NewServer(mux)
+	defer remoteSrv.Close()
+
+	sut := remotestorage.NewRemoteStorage(
+		logg,
+		config.RemoteConfig{Name: "test", Address: remoteSrv.URL},
+		func() time.Time { return frozenTime },
+		dummyTimeout,
+	)
+
+	result := sut.Select(context.Background(), true, &storage.SelectHints{},
+		labels.MustNewMatcher(labels.MatchEqual, "labelName", "labelVal"))
+	require.NoError(t, result.Err(), "should return no error")
+
+	expected := remoteWarnings("warn 1", "warn 2")
+	domain.AddRemoteAnnotation(&expected, domain.NewRemoteInfo("info 1"))
+	assert.Equal(t, expected, result.Warnings(), "should have one annotation per warning and info")
+
+	warnings, infos := result.Warnings().AsStrings("", 0, 0)
+	assert.ElementsMatch(t, []string{"warn 1", "warn 2"}, warnings, "should keep the warnings as warnings")
+	assert.Equal(t, []string{"info 1"}, infos, "should keep the infos as infos")
+}
+
+func remoteWarnings(messages ...string) annotations.Annotations {
+	annots := *annotations.New()
+	for _, message := range messages {
+		domain.AddRemoteAnnotation(&annots, domain.NewRemoteWarning(message))
+	}
+	return annots
+}
+
+func TestRemotesAreNotQueriedOutsideOfTheirTimeWindow(t *testing.T) {
+	var requests atomic.Int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusOK)
+		if r.URL.Path == remotestorage.DefaultLabelNamesPath {
+			_, err := w.Write([]byte(`{"status":"success","data":["__name__"]}`))
+			panicOnError(err)
+			return
+		}
+		_, err := w.Write([]byte(defaultVectorAnswer))
+		panicOnError(err)
+	})
+
+	remoteSrv := httptest.NewServer(mux)
+	defer remoteSrv.Close()
+
+	sut := remotestorage.NewRemoteStorage(
+		logg,
+		config.RemoteConfig{
+			Name: "recent", Address: remoteSrv.URL, TimeWindowConf: config.TimeWindowConfig{Start: "now-6h"},
+		},
+		func() time.Time { return frozenTime },
+		dummyTimeout,
+	)
+	collector := querystats.NewCollector()
+	ctx := querystats.NewContext(context.Background(), collector)
+	matcher := labels.MustNewMatcher(labels.MatchEqual, "__name__", "up")
+
+	oldHints := &storage.SelectHints{
+		Start: frozenTime.Add(-48 * time.Hour).UnixMilli(), End: frozenTime.Add(-24 * time.Hour).UnixMilli(),
+	}
+	result := sut.Select(ctx, true, oldHints, matcher)
+	require.NoError(t, result.Err(), "should not fail queries outside of the time window")
+	assert.False(t, result.Next(), "should answer empty outside of the time window")
+
+	oldRange := domain.TimeRange{Start: oldHints.Start, End: oldHints.End}
+	names, _, err := sut.LabelNames(domain.NewTimeRangeContext(ctx, oldRange), nil)
+	require.NoError(t, err, "should not fail label requests outside of the time window")
+	assert.Empty(t, names, "should answer empty label requests outside of the time window")
+
+	assert.Equal(t, int32(0), requests.Load(), "should not query the remote outside of its time window")
+	assert.Equal(t, []querystats.RemoteStats{
+		{Name: "recent", Skipped: true, SkipReason: querystats.SkipReasonTimeWindow},
+	}, collector.Summary().Remotes, "should record the remote as skipped")
+
+	recentHints := &storage.SelectHints{
+		Start: frozenTime.Add(-24 * time.Hour).UnixMilli(), End: frozenTime.UnixMilli(), Step: 60000,
+	}
+	result = sut.Select(ctx, true, recentHints, matcher)
+	require.NoError(t, result.Err(), "should not fail")
+	assert.Equal(t, int32(1), requests.Load(), "should query the remote when the range touches its time window")
+}
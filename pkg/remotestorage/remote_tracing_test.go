@@ -0,0 +1,49 @@
+package remotestorage_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jademcosta/graviola/internal/mocks"
+	"github.com/jademcosta/graviola/pkg/config"
+	"github.com/jademcosta/graviola/pkg/remotestorage"
+	"github.com/jademcosta/graviola/pkg/tracing"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSendsTheTraceContextToTheRemote(t *testing.T) {
+	recorder, restore := mocks.RecordSpans()
+	defer restore()
+
+	var traceparent string
+	mockRemote := MockRemote{mux: http.NewServeMux()}
+	mockRemote.mux.HandleFunc(remotestorage.DefaultInstantQueryPath, func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+		_, err := w.Write([]byte(defaultVectorAnswer))
+		panicOnError(err)
+	})
+	remoteSrv := httptest.NewServer(mockRemote.mux)
+	defer remoteSrv.Close()
+
+	sut := remotestorage.NewRemoteStorage(
+		logg, config.RemoteConfig{Name: "test", Address: remoteSrv.URL}, func() time.Time { return frozenTime },
+		dummyTimeout)
+
+	ctx, span := tracing.Start(context.Background(), "parent")
+	result := sut.Select(ctx, false, &storage.SelectHints{}, labels.MustNewMatcher(labels.MatchEqual, "job", "x"))
+	require.NoError(t, result.Err(), "should query the remote")
+	span.End()
+
+	require.NotEmpty(t, traceparent, "should send the traceparent header")
+	assert.Contains(t, traceparent, tracing.TraceID(ctx), "should send the trace of the query")
+
+	remoteSpan := mocks.SpanNamed(recorder, "remote POST "+remotestorage.DefaultInstantQueryPath)
+	require.NotNil(t, remoteSpan, "should record a span of the request to the remote")
+	assert.Equal(t, span.SpanContext().SpanID(), remoteSpan.Parent().SpanID(), "should be a child of the query span")
+}
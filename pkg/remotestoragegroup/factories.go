@@ -1,29 +1,65 @@
 package remotestoragegroup
 
 import (
+	"log/slog"
+	"strings"
+
 	"github.com/jademcosta/graviola/pkg/config"
 	"github.com/jademcosta/graviola/pkg/remotestoragegroup/mergestrategy"
 	"github.com/jademcosta/graviola/pkg/remotestoragegroup/queryfailurestrategy"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/storage"
 )
 
-func QueryFailureStrategyFactory(strategyName string) OnQueryFailureStrategy {
-	switch strategyName {
+func QueryFailureStrategyFactory(groupConf config.RemoteGroupsConfig) OnQueryFailureStrategy {
+	switch strings.ToLower(groupConf.OnQueryFailStrategy) {
 	case config.StrategyFailAll:
 		return &queryfailurestrategy.FailAllStrategy{}
 	case config.StrategyPartialResponse:
 		return &queryfailurestrategy.PartialResponseStrategy{}
+	case config.StrategyQuorum:
+		return queryfailurestrategy.NewQuorumStrategy(
+			groupConf.QuorumConf.MinSuccessfulFor(len(groupConf.Servers)))
 	default:
 		panic("unrecognized failure strategy")
 	}
 }
 
-func MergeStrategyFactory(strategyName string) MergeStrategy {
-	switch strategyName {
+func MergeStrategyFactory(conf config.MergeStrategyConfig, metricz *prometheus.Registry) MergeStrategy {
+	switch conf.Strategy {
 	case config.MergeStrategyAlwaysMerge:
-		return mergestrategy.NewAlwaysMergeStrategy()
+		return mergestrategy.NewAlwaysMergeStrategy(metricz)
 	case config.MergeStrategyKeepBiggest:
-		return mergestrategy.NewKeepBiggestMergeStrategy()
+		return mergestrategy.NewKeepBiggestMergeStrategy(metricz)
+	case config.MergeStrategyReconcile:
+		return mergestrategy.NewReconcileMergeStrategy(metricz, conf.Function, conf.DivergenceThreshold)
 	default:
 		panic("unrecognized merge strategy")
 	}
 }
+
+func GroupReaderFactory(
+	logg *slog.Logger, metricz *prometheus.Registry, groupConf config.RemoteGroupsConfig,
+	queriers []storage.Querier, mergeStrategy MergeStrategy,
+) GroupReader {
+	readMode := strings.ToLower(groupConf.ReadMode)
+	if readMode == "" {
+		readMode = config.DefaultReadMode
+	}
+
+	var reader GroupReader
+	switch readMode {
+	case config.ReadModeAll:
+		reader = NewMergeQuerier(queriers, mergeStrategy)
+	case config.ReadModeFailover:
+		reader = NewFailoverQuerier(logg, queriers, groupConf.FailoverConf.AttemptTimeoutDuration())
+	case config.ReadModeRoundRobin:
+		reader = NewRoundRobinQuerier(queriers, groupConf.RoundRobinConf.Sticky)
+	case config.ReadModeFastest:
+		reader = NewFastestQuerier(queriers)
+	default:
+		panic("unrecognized read mode")
+	}
+
+	return NewReplicaMetricsReader(metricz, groupConf.Name, readMode, reader)
+}
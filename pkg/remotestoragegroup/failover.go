@@ -0,0 +1,169 @@
+package remotestoragegroup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/jademcosta/graviola/pkg/domain"
+	"github.com/jademcosta/graviola/pkg/querystats"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/prometheus/prometheus/util/annotations"
+)
+
+// FailoverQuerier sends each query to a single querier, in the order they were given. The next
+// querier is only called when the previous one errored or took longer than the attempt timeout.
+// When a querier answers successfully, only its outcome is returned (the failover is informed as
+// a warning annotation instead). When all of them fail, the outcomes of all of them are returned.
+type FailoverQuerier struct {
+	logg           *slog.Logger
+	queriers       []storage.Querier
+	names          []string
+	attemptTimeout time.Duration
+}
+
+// NewFailoverQuerier creates a FailoverQuerier. An attemptTimeout of zero means each querier
+// is waited for as long as the query context allows.
+func NewFailoverQuerier(
+	logg *slog.Logger, queriers []storage.Querier, attemptTimeout time.Duration,
+) *FailoverQuerier {
+	return &FailoverQuerier{
+		logg:           logg.With("component", "failover"),
+		queriers:       queriers,
+		names:          querierNames(queriers),
+		attemptTimeout: attemptTimeout,
+	}
+}
+
+// GroupReader
+func (fq *FailoverQuerier) SelectWithOutcomes(
+	ctx context.Context, sortSeries bool, hints *storage.SelectHints, matchers ...*labels.Matcher,
+) (storage.SeriesSet, []domain.QuerierOutcome) {
+	if len(fq.queriers) == 0 {
+		return storage.NoopSeriesSet(), []domain.QuerierOutcome{}
+	}
+
+	failed := make([]domain.QuerierOutcome, 0)
+
+	for idx, querier := range fq.queriers {
+		attemptCtx, cancelFn := fq.attemptContext(ctx)
+		response := attributeSeriesSet(querier.Select(attemptCtx, sortSeries, hints, matchers...), fq.names[idx])
+		err := response.Err()
+		cancelFn()
+
+		if err == nil {
+			if len(failed) > 0 {
+				response = withWarning(response, fq.failoverWarning(failed, fq.names[idx]))
+			}
+			recordSkipped(ctx, fq.names[idx+1:], querystats.SkipReasonFailover)
+			return response, []domain.QuerierOutcome{{Name: fq.names[idx]}}
+		}
+
+		failed = append(failed, domain.QuerierOutcome{Name: fq.names[idx], Err: err})
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	errs := make([]error, 0, len(failed))
+	for _, outcome := range failed {
+		errs = append(errs, outcome.Err)
+	}
+
+	return &domain.GraviolaSeriesSet{Erro: errors.Join(errs...)}, failed
+}
+
+// GroupReader
+func (fq *FailoverQuerier) LabelValuesWithOutcomes(
+	ctx context.Context, name string, hints *storage.LabelHints, matchers ...*labels.Matcher,
+) ([]string, annotations.Annotations, []domain.QuerierOutcome, error) {
+	return fq.failoverLabels(ctx, func(attemptCtx context.Context, qr storage.Querier) ([]string, annotations.Annotations, error) {
+		return qr.LabelValues(attemptCtx, name, hints, matchers...)
+	})
+}
+
+// GroupReader
+func (fq *FailoverQuerier) LabelNamesWithOutcomes(
+	ctx context.Context, hints *storage.LabelHints, matchers ...*labels.Matcher,
+) ([]string, annotations.Annotations, []domain.QuerierOutcome, error) {
+	return fq.failoverLabels(ctx, func(attemptCtx context.Context, qr storage.Querier) ([]string, annotations.Annotations, error) {
+		return qr.LabelNames(attemptCtx, hints, matchers...)
+	})
+}
+
+// LabelQuerier
+// Close releases the resources of the Querier.
+func (fq *FailoverQuerier) Close() error {
+	return closeAll(fq.queriers)
+}
+
+func (fq *FailoverQuerier) failoverLabels(
+	ctx context.Context,
+	query func(context.Context, storage.Querier) ([]string, annotations.Annotations, error),
+) ([]string, annotations.Annotations, []domain.QuerierOutcome, error) {
+
+	if len(fq.queriers) == 0 {
+		return []string{}, map[string]error{}, []domain.QuerierOutcome{}, nil
+	}
+
+	failed := make([]domain.QuerierOutcome, 0)
+	annots := annotations.New()
+
+	for idx, querier := range fq.queriers {
+		attemptCtx, cancelFn := fq.attemptContext(ctx)
+		values, annotsResponse, err := query(attemptCtx, querier)
+		cancelFn()
+
+		if err == nil {
+			domain.MergeAnnotations(annots, domain.AttributeAnnotations(annotsResponse, fq.names[idx]))
+			if len(failed) > 0 {
+				annots.Add(fq.failoverWarning(failed, fq.names[idx]))
+			}
+			recordSkipped(ctx, fq.names[idx+1:], querystats.SkipReasonFailover)
+			return dedupe(values), *annots, []domain.QuerierOutcome{{Name: fq.names[idx]}}, nil
+		}
+
+		annots.Add(err)
+		failed = append(failed, domain.QuerierOutcome{Name: fq.names[idx], Err: err})
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	errs := make([]error, 0, len(failed))
+	for _, outcome := range failed {
+		errs = append(errs, outcome.Err)
+	}
+
+	return []string{}, *annots, failed, errors.Join(errs...)
+}
+
+func (fq *FailoverQuerier) attemptContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if fq.attemptTimeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, fq.attemptTimeout)
+}
+
+func (fq *FailoverQuerier) failoverWarning(failed []domain.QuerierOutcome, answeredBy string) error {
+	failedNames := domain.FailedQuerierNames(failed)
+	fq.logg.Warn("failover happened", "failed", failedNames, "answered_by", answeredBy)
+
+	return fmt.Errorf("failover: %s failed to answer, the answer came from %s",
+		strings.Join(failedNames, ", "), answeredBy)
+}
+
+// withWarning adds the warning to the series set when it is possible to do so
+func withWarning(seriesSet storage.SeriesSet, warning error) storage.SeriesSet {
+	parsedSet, ok := seriesSet.(*domain.GraviolaSeriesSet)
+	if !ok {
+		return seriesSet
+	}
+
+	parsedSet.Annots.Add(warning)
+	return parsedSet
+}
@@ -0,0 +1,128 @@
+package remotestoragegroup_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jademcosta/graviola/internal/mocks"
+	"github.com/jademcosta/graviola/pkg/domain"
+	"github.com/jademcosta/graviola/pkg/querystats"
+	"github.com/jademcosta/graviola/pkg/remotestoragegroup"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFailoverOnlyCallsThePrimaryWhenItSucceeds(t *testing.T) {
+	primary := &mocks.RemoteStorageMock{SeriesSet: &domain.GraviolaSeriesSet{
+		Series: []*domain.GraviolaSeries{
+			{Lbs: labels.FromStrings("label1", "val1"),
+				Datapoints: []model.SamplePair{{Timestamp: 5819, Value: 5.9}}},
+		},
+	}}
+	secondary := &mocks.RemoteStorageMock{SeriesSet: &domain.GraviolaSeriesSet{}}
+
+	sut := remotestoragegroup.NewFailoverQuerier(logg, []storage.Querier{primary, secondary}, 0)
+
+	collector := querystats.NewCollector()
+	response, outcomes := sut.SelectWithOutcomes(
+		querystats.NewContext(context.Background(), collector), true, &storage.SelectHints{})
+	require.NoError(t, response.Err(), "should not error")
+	assert.Empty(t, response.Warnings(), "should not warn when no failover happened")
+	assert.Equal(t, []domain.QuerierOutcome{{Name: "querier #0"}}, outcomes, "should only inform the primary outcome")
+	assert.Equal(t, []querystats.RemoteStats{
+		{Name: "querier #1", Skipped: true, SkipReason: querystats.SkipReasonFailover},
+	}, collector.Summary().Remotes, "should record the secondary as skipped")
+
+	names, _, outcomes, err := sut.LabelNamesWithOutcomes(context.Background(), nil)
+	require.NoError(t, err, "should not error")
+	assert.Equal(t, []string{"label1"}, names, "should return the primary label names")
+	assert.Len(t, outcomes, 1, "should only inform the primary outcome")
+
+	assert.Len(t, primary.CalledWithHints, 1, "should have called the primary")
+	assert.Empty(t, secondary.CalledWithHints, "should not have called the secondary")
+	assert.Empty(t, secondary.CalledWithContexts, "should not have called the secondary for label queries")
+}
+
+func TestFailoverCallsTheSecondaryWhenThePrimaryFails(t *testing.T) {
+	primaryErr := errors.New("primary is down")
+	primary := &mocks.RemoteStorageMock{
+		SelectFn: func(_ context.Context, _ bool, _ *storage.SelectHints, _ ...*labels.Matcher) storage.SeriesSet {
+			return &domain.GraviolaSeriesSet{Erro: primaryErr}
+		},
+		Error: primaryErr,
+	}
+	secondary := &mocks.RemoteStorageMock{SeriesSet: &domain.GraviolaSeriesSet{
+		Series: []*domain.GraviolaSeries{
+			{Lbs: labels.FromStrings("label2", "val2"),
+				Datapoints: []model.SamplePair{{Timestamp: 5819, Value: 5.9}}},
+		},
+	}}
+
+	sut := remotestoragegroup.NewFailoverQuerier(logg, []storage.Querier{primary, secondary}, 0)
+
+	response, outcomes := sut.SelectWithOutcomes(context.Background(), true, &storage.SelectHints{})
+	require.NoError(t, response.Err(), "should not error")
+	assert.True(t, response.Next(), "should return the secondary series")
+	assert.Equal(t, labels.FromStrings("label2", "val2"), response.At().Labels(), "should return the secondary series")
+	assert.Equal(t, []domain.QuerierOutcome{{Name: "querier #1"}}, outcomes, "should inform the outcome of the one that answered")
+	require.Len(t, response.Warnings(), 1, "should warn that the failover happened")
+	for annotation := range response.Warnings() {
+		assert.Contains(t, annotation, "failover", "should warn that the failover happened")
+		assert.Contains(t, annotation, "querier #0", "should name the querier that failed")
+	}
+
+	values, annots, outcomes, err := sut.LabelValuesWithOutcomes(context.Background(), "label2", nil)
+	require.NoError(t, err, "should not error")
+	assert.Equal(t, []string{"val2"}, values, "should return the secondary label values")
+	assert.Len(t, outcomes, 1, "should inform the outcome of the one that answered")
+	assert.NotEmpty(t, annots, "should warn that the failover happened")
+}
+
+func TestFailoverCallsTheSecondaryWhenThePrimaryTimesOut(t *testing.T) {
+	primary := &mocks.RemoteStorageMock{
+		SelectFn: func(ctx context.Context, _ bool, _ *storage.SelectHints, _ ...*labels.Matcher) storage.SeriesSet {
+			<-ctx.Done()
+			return &domain.GraviolaSeriesSet{Erro: ctx.Err()}
+		},
+	}
+	secondary := &mocks.RemoteStorageMock{SeriesSet: &domain.GraviolaSeriesSet{}}
+
+	sut := remotestoragegroup.NewFailoverQuerier(logg, []storage.Querier{primary, secondary}, 50*time.Millisecond)
+
+	response, outcomes := sut.SelectWithOutcomes(context.Background(), true, &storage.SelectHints{})
+	require.NoError(t, response.Err(), "should not error")
+	assert.Equal(t, []domain.QuerierOutcome{{Name: "querier #1"}}, outcomes, "should inform the outcome of the one that answered")
+	require.Len(t, secondary.CalledWithContexts, 1, "should have called the secondary")
+	require.ErrorIs(t, primary.CalledWithContexts[0].Err(), context.DeadlineExceeded,
+		"the primary attempt should have timed out")
+}
+
+func TestFailoverErrorsWhenAllQueriersFail(t *testing.T) {
+	err1 := errors.New("primary is down")
+	err2 := errors.New("secondary is down")
+	failingQuerier := func(err error) *mocks.RemoteStorageMock {
+		return &mocks.RemoteStorageMock{
+			SelectFn: func(_ context.Context, _ bool, _ *storage.SelectHints, _ ...*labels.Matcher) storage.SeriesSet {
+				return &domain.GraviolaSeriesSet{Erro: err}
+			},
+			Error: err,
+		}
+	}
+
+	sut := remotestoragegroup.NewFailoverQuerier(logg, []storage.Querier{failingQuerier(err1), failingQuerier(err2)}, 0)
+
+	response, outcomes := sut.SelectWithOutcomes(context.Background(), true, &storage.SelectHints{})
+	require.ErrorIs(t, response.Err(), err1, "should return the errors of all queriers")
+	require.ErrorIs(t, response.Err(), err2, "should return the errors of all queriers")
+	assert.Equal(t, []string{"querier #0", "querier #1"}, domain.FailedQuerierNames(outcomes),
+		"should inform the outcome of all queriers")
+
+	_, _, outcomes, err := sut.LabelNamesWithOutcomes(context.Background(), nil)
+	require.ErrorIs(t, err, err2, "should return the errors of all queriers")
+	assert.Len(t, outcomes, 2, "should inform the outcome of all queriers")
+}
@@ -0,0 +1,134 @@
+package remotestoragegroup
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jademcosta/graviola/pkg/domain"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/prometheus/prometheus/util/annotations"
+)
+
+type fastestSelectResponse struct {
+	idx       int
+	seriesSet storage.SeriesSet
+}
+
+type fastestLabelResponse struct {
+	idx int
+	labelResponse
+}
+
+// FastestQuerier sends each query to all queriers at the same time, and answers with the first
+// successful response. The queries still running on the other queriers are canceled.
+// When a querier answers successfully, only its outcome is returned. When all of them fail,
+// the outcomes of all of them are returned.
+type FastestQuerier struct {
+	queriers []storage.Querier
+	names    []string
+}
+
+func NewFastestQuerier(queriers []storage.Querier) *FastestQuerier {
+	return &FastestQuerier{
+		queriers: queriers,
+		names:    querierNames(queriers),
+	}
+}
+
+// GroupReader
+func (fq *FastestQuerier) SelectWithOutcomes(
+	ctx context.Context, sortSeries bool, hints *storage.SelectHints, matchers ...*labels.Matcher,
+) (storage.SeriesSet, []domain.QuerierOutcome) {
+	if len(fq.queriers) == 0 {
+		return storage.NoopSeriesSet(), []domain.QuerierOutcome{}
+	}
+
+	raceCtx, cancelFn := context.WithCancel(ctx)
+	defer cancelFn()
+
+	// Buffered, so the queriers that lost the race don't block forever
+	responses := make(chan fastestSelectResponse, len(fq.queriers))
+	for idx, querier := range fq.queriers {
+		go func(idx int, qr storage.Querier) {
+			responses <- fastestSelectResponse{idx: idx, seriesSet: qr.Select(raceCtx, sortSeries, hints, matchers...)}
+		}(idx, querier)
+	}
+
+	outcomes := make([]domain.QuerierOutcome, len(fq.queriers))
+	errs := make([]error, len(fq.queriers))
+	for range fq.queriers {
+		response := <-responses
+		err := response.seriesSet.Err()
+		if err == nil {
+			return attributeSeriesSet(response.seriesSet, fq.names[response.idx]), []domain.QuerierOutcome{{Name: fq.names[response.idx]}}
+		}
+
+		outcomes[response.idx] = domain.QuerierOutcome{Name: fq.names[response.idx], Err: err}
+		errs[response.idx] = err
+	}
+
+	return &domain.GraviolaSeriesSet{Erro: errors.Join(errs...)}, outcomes
+}
+
+// GroupReader
+func (fq *FastestQuerier) LabelValuesWithOutcomes(
+	ctx context.Context, name string, hints *storage.LabelHints, matchers ...*labels.Matcher,
+) ([]string, annotations.Annotations, []domain.QuerierOutcome, error) {
+	return fq.fastestLabels(ctx, func(raceCtx context.Context, qr storage.Querier) ([]string, annotations.Annotations, error) {
+		return qr.LabelValues(raceCtx, name, hints, matchers...)
+	})
+}
+
+// GroupReader
+func (fq *FastestQuerier) LabelNamesWithOutcomes(
+	ctx context.Context, hints *storage.LabelHints, matchers ...*labels.Matcher,
+) ([]string, annotations.Annotations, []domain.QuerierOutcome, error) {
+	return fq.fastestLabels(ctx, func(raceCtx context.Context, qr storage.Querier) ([]string, annotations.Annotations, error) {
+		return qr.LabelNames(raceCtx, hints, matchers...)
+	})
+}
+
+// LabelQuerier
+// Close releases the resources of the Querier.
+func (fq *FastestQuerier) Close() error {
+	return closeAll(fq.queriers)
+}
+
+func (fq *FastestQuerier) fastestLabels(
+	ctx context.Context,
+	query func(context.Context, storage.Querier) ([]string, annotations.Annotations, error),
+) ([]string, annotations.Annotations, []domain.QuerierOutcome, error) {
+
+	if len(fq.queriers) == 0 {
+		return []string{}, map[string]error{}, []domain.QuerierOutcome{}, nil
+	}
+
+	raceCtx, cancelFn := context.WithCancel(ctx)
+	defer cancelFn()
+
+	responses := make(chan fastestLabelResponse, len(fq.queriers))
+	for idx, querier := range fq.queriers {
+		go func(idx int, qr storage.Querier) {
+			values, annots, err := query(raceCtx, qr)
+			responses <- fastestLabelResponse{idx: idx, labelResponse: labelResponse{values: values, annots: annots, err: err}}
+		}(idx, querier)
+	}
+
+	outcomes := make([]domain.QuerierOutcome, len(fq.queriers))
+	errs := make([]error, len(fq.queriers))
+	annots := annotations.New()
+	for range fq.queriers {
+		response := <-responses
+		if response.err == nil {
+			domain.MergeAnnotations(annots, domain.AttributeAnnotations(response.annots, fq.names[response.idx]))
+			return dedupe(response.values), *annots, []domain.QuerierOutcome{{Name: fq.names[response.idx]}}, nil
+		}
+
+		annots.Add(response.err)
+		outcomes[response.idx] = domain.QuerierOutcome{Name: fq.names[response.idx], Err: response.err}
+		errs[response.idx] = response.err
+	}
+
+	return []string{}, *annots, outcomes, errors.Join(errs...)
+}
@@ -0,0 +1,100 @@
+package remotestoragegroup_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jademcosta/graviola/internal/mocks"
+	"github.com/jademcosta/graviola/pkg/domain"
+	"github.com/jademcosta/graviola/pkg/remotestoragegroup"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFastestAnswersWithTheFirstSuccessAndCancelsTheOthers(t *testing.T) {
+	slowCanceled := make(chan error, 1)
+	slow := &mocks.RemoteStorageMock{
+		SelectFn: func(ctx context.Context, _ bool, _ *storage.SelectHints, _ ...*labels.Matcher) storage.SeriesSet {
+			<-ctx.Done()
+			slowCanceled <- ctx.Err()
+			return &domain.GraviolaSeriesSet{Erro: ctx.Err()}
+		},
+	}
+	fast := &mocks.RemoteStorageMock{SeriesSet: &domain.GraviolaSeriesSet{
+		Series: []*domain.GraviolaSeries{
+			{Lbs: labels.FromStrings("label1", "val1"),
+				Datapoints: []model.SamplePair{{Timestamp: 5819, Value: 5.9}}},
+		},
+	}}
+
+	sut := remotestoragegroup.NewFastestQuerier([]storage.Querier{slow, fast})
+
+	response, outcomes := sut.SelectWithOutcomes(context.Background(), true, &storage.SelectHints{})
+	require.NoError(t, response.Err(), "should not error")
+	assert.True(t, response.Next(), "should return the series of the fastest querier")
+	assert.Equal(t, []domain.QuerierOutcome{{Name: "querier #1"}}, outcomes,
+		"should only inform the outcome of the one that answered")
+	require.ErrorIs(t, <-slowCanceled, context.Canceled, "should cancel the slow querier")
+}
+
+func TestFastestIgnoresFailuresWhenSomeQuerierSucceeds(t *testing.T) {
+	failing := &mocks.RemoteStorageMock{Error: errors.New("querier is down")}
+	working := &mocks.RemoteStorageMock{SeriesSet: &domain.GraviolaSeriesSet{
+		Series: []*domain.GraviolaSeries{
+			{Lbs: labels.FromStrings("label1", "val1"),
+				Datapoints: []model.SamplePair{{Timestamp: 5819, Value: 5.9}}},
+		},
+	}}
+
+	sut := remotestoragegroup.NewFastestQuerier([]storage.Querier{failing, working})
+
+	values, _, outcomes, err := sut.LabelValuesWithOutcomes(context.Background(), "label1", nil)
+	require.NoError(t, err, "should not error")
+	assert.Equal(t, []string{"val1"}, values, "should return the values of the working querier")
+	assert.Equal(t, []domain.QuerierOutcome{{Name: "querier #1"}}, outcomes,
+		"should only inform the outcome of the one that answered")
+}
+
+func TestFastestErrorsWhenAllQueriersFail(t *testing.T) {
+	err1 := errors.New("querier 1 is down")
+	err2 := errors.New("querier 2 is down")
+	failingQuerier := func(err error) *mocks.RemoteStorageMock {
+		return &mocks.RemoteStorageMock{
+			SelectFn: func(_ context.Context, _ bool, _ *storage.SelectHints, _ ...*labels.Matcher) storage.SeriesSet {
+				return &domain.GraviolaSeriesSet{Erro: err}
+			},
+			Error: err,
+		}
+	}
+
+	sut := remotestoragegroup.NewFastestQuerier([]storage.Querier{failingQuerier(err1), failingQuerier(err2)})
+
+	response, outcomes := sut.SelectWithOutcomes(context.Background(), true, &storage.SelectHints{})
+	require.ErrorIs(t, response.Err(), err1, "should return the errors of all queriers")
+	require.ErrorIs(t, response.Err(), err2, "should return the errors of all queriers")
+	assert.Equal(t, []string{"querier #0", "querier #1"}, domain.FailedQuerierNames(outcomes),
+		"should inform the outcome of all queriers, in order")
+
+	_, _, outcomes, err := sut.LabelNamesWithOutcomes(context.Background(), nil)
+	require.ErrorIs(t, err, err1, "should return the errors of all queriers")
+	assert.Len(t, outcomes, 2, "should inform the outcome of all queriers")
+}
+
+func TestFastestDedupesTheLabelValues(t *testing.T) {
+	querier := &mocks.RemoteStorageMock{SeriesSet: &domain.GraviolaSeriesSet{
+		Series: []*domain.GraviolaSeries{
+			{Lbs: labels.FromStrings("label1", "val1", "label2", "a")},
+			{Lbs: labels.FromStrings("label1", "val1", "label2", "b")},
+		},
+	}}
+
+	sut := remotestoragegroup.NewFastestQuerier([]storage.Querier{querier})
+
+	values, _, _, err := sut.LabelValuesWithOutcomes(context.Background(), "label1", nil)
+	require.NoError(t, err, "should not error")
+	assert.Equal(t, []string{"val1"}, values, "should not return repeated values")
+}
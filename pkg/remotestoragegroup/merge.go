@@ -3,11 +3,17 @@ package remotestoragegroup
 import (
 	"context"
 	"errors"
+	"fmt"
 	"sync"
+	"time"
 
+	"github.com/jademcosta/graviola/pkg/domain"
+	"github.com/jademcosta/graviola/pkg/querystats"
+	"github.com/jademcosta/graviola/pkg/tracing"
 	"github.com/prometheus/prometheus/model/labels"
 	"github.com/prometheus/prometheus/storage"
 	"github.com/prometheus/prometheus/util/annotations"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 type labelResponse struct {
@@ -22,9 +28,14 @@ type MergeStrategy interface {
 
 type MergeQuerier struct {
 	queriers        []storage.Querier
+	names           []string
 	seriesSetMerger MergeStrategy
 }
 
+type namedQuerier interface {
+	Name() string
+}
+
 func NewMergeQuerier(queriers []storage.Querier, seriesSetMerger MergeStrategy) *MergeQuerier {
 	if seriesSetMerger == nil {
 		panic("the merge strategy cannot be nil when creating a MergeQuerier")
@@ -32,6 +43,7 @@ func NewMergeQuerier(queriers []storage.Querier, seriesSetMerger MergeStrategy)
 
 	return &MergeQuerier{
 		queriers:        queriers,
+		names:           querierNames(queriers),
 		seriesSetMerger: seriesSetMerger,
 	}
 }
@@ -41,39 +53,53 @@ func NewMergeQuerier(queriers []storage.Querier, seriesSetMerger MergeStrategy)
 // Caller can specify if it requires returned series to be sorted. Prefer not requiring sorting for better performance.
 // It allows passing hints that can help in optimising select, but it's up to implementation how this is used if used at all.
 func (mq *MergeQuerier) Select(ctx context.Context, sortSeries bool, hints *storage.SelectHints, matchers ...*labels.Matcher) storage.SeriesSet {
+	response, _ := mq.SelectWithOutcomes(ctx, sortSeries, hints, matchers...)
+	return response
+}
+
+// SelectWithOutcomes works like Select, but it also returns the outcome of each querier, in the
+// same order the queriers were given.
+func (mq *MergeQuerier) SelectWithOutcomes(
+	ctx context.Context, sortSeries bool, hints *storage.SelectHints, matchers ...*labels.Matcher,
+) (storage.SeriesSet, []domain.QuerierOutcome) {
 	if len(mq.queriers) == 0 {
-		return storage.NoopSeriesSet()
+		return storage.NoopSeriesSet(), []domain.QuerierOutcome{}
 	}
 
 	if len(mq.queriers) == 1 {
-		return mq.queriers[0].Select(ctx, sortSeries, hints, matchers...)
+		response := attributeSeriesSet(mq.queriers[0].Select(ctx, sortSeries, hints, matchers...), mq.names[0])
+		return response, []domain.QuerierOutcome{{Name: mq.names[0], Err: response.Err()}}
 	}
 
-	seriesSets := make([]storage.SeriesSet, 0, len(mq.queriers))
+	// Each response is kept at the same index of its querier, so merge strategies can rely on
+	// the order the queriers were configured
+	seriesSets := make([]storage.SeriesSet, len(mq.queriers))
 
 	var wg sync.WaitGroup
-	seriesSetChan := make(chan storage.SeriesSet)
 
-	for _, querier := range mq.queriers {
+	for idx, querier := range mq.queriers {
 		wg.Add(1)
-		go func(qr storage.Querier) {
+		go func(idx int, qr storage.Querier) {
 			defer wg.Done()
 
-			seriesSetChan <- qr.Select(ctx, true, hints, matchers...)
-		}(querier)
+			seriesSets[idx] = attributeSeriesSet(qr.Select(ctx, true, hints, matchers...), mq.names[idx])
+		}(idx, querier)
 	}
 
-	go func() {
-		wg.Wait()
-		close(seriesSetChan)
-	}()
+	wg.Wait()
 
-	for r := range seriesSetChan {
-		seriesSets = append(seriesSets, r)
+	outcomes := make([]domain.QuerierOutcome, 0, len(seriesSets))
+	for idx, seriesSet := range seriesSets {
+		outcomes = append(outcomes, domain.QuerierOutcome{Name: mq.names[idx], Err: seriesSet.Err()})
 	}
 
+	_, span := tracing.Start(ctx, "group.merge", attribute.Int("graviola.series_sets", len(seriesSets)))
+	mergeStart := time.Now()
 	response := mq.seriesSetMerger.Merge(seriesSets)
-	return response
+	querystats.FromContext(ctx).RecordMerge(time.Since(mergeStart))
+	span.End()
+
+	return response, outcomes
 }
 
 // LabelQuerier
@@ -101,62 +127,18 @@ func (mq *MergeQuerier) Close() error {
 func (mq *MergeQuerier) LabelValues(
 	ctx context.Context, name string, hints *storage.LabelHints, matchers ...*labels.Matcher,
 ) ([]string, annotations.Annotations, error) {
+	values, annots, _, err := mq.LabelValuesWithOutcomes(ctx, name, hints, matchers...)
+	return values, annots, err
+}
 
-	if len(mq.queriers) == 0 {
-		return []string{}, map[string]error{}, nil
-	}
-
-	if len(mq.queriers) == 1 {
-		values, annots, err := mq.queriers[0].LabelValues(ctx, name, hints, matchers...)
-		if err != nil {
-			return values, annots, err
-		}
-		return dedupe(values), annots, err
-	}
-
-	errs := make([]error, 0)
-	annots := annotations.New()
-
-	var wg sync.WaitGroup
-	valuesChan := make(chan *labelResponse)
-
-	wg.Add(len(mq.queriers))
-	for _, querier := range mq.queriers {
-		go func(qr storage.Querier) {
-
-			defer wg.Done()
-			values, annotationsResponse, err := qr.LabelValues(ctx, name, hints, matchers...)
-
-			valuesChan <- &labelResponse{
-				values: values,
-				annots: annotationsResponse,
-				err:    err,
-			}
-		}(querier)
-	}
-
-	go func() {
-		wg.Wait()
-		close(valuesChan)
-	}()
-
-	values := make([]string, 0)
-	for lblResp := range valuesChan {
-		annots.Merge(lblResp.annots)
-		if lblResp.err != nil {
-			errs = append(errs, lblResp.err)
-			annots.Add(lblResp.err)
-			continue
-		}
-
-		values = append(values, lblResp.values...)
-	}
-
-	var err error
-	if len(errs) > 0 {
-		err = errors.Join(errs...)
-	}
-	return dedupe(values), *annots, err
+// LabelValuesWithOutcomes works like LabelValues, but it also returns the outcome of each
+// querier, in the same order the queriers were given.
+func (mq *MergeQuerier) LabelValuesWithOutcomes(
+	ctx context.Context, name string, hints *storage.LabelHints, matchers ...*labels.Matcher,
+) ([]string, annotations.Annotations, []domain.QuerierOutcome, error) {
+	return mq.mergeLabels(func(qr storage.Querier) ([]string, annotations.Annotations, error) {
+		return qr.LabelValues(ctx, name, hints, matchers...)
+	})
 }
 
 // LabelQuerier
@@ -166,47 +148,66 @@ func (mq *MergeQuerier) LabelValues(
 func (mq *MergeQuerier) LabelNames(
 	ctx context.Context, hints *storage.LabelHints, matchers ...*labels.Matcher,
 ) ([]string, annotations.Annotations, error) {
+	values, annots, _, err := mq.LabelNamesWithOutcomes(ctx, hints, matchers...)
+	return values, annots, err
+}
+
+// LabelNamesWithOutcomes works like LabelNames, but it also returns the outcome of each
+// querier, in the same order the queriers were given.
+func (mq *MergeQuerier) LabelNamesWithOutcomes(
+	ctx context.Context, hints *storage.LabelHints, matchers ...*labels.Matcher,
+) ([]string, annotations.Annotations, []domain.QuerierOutcome, error) {
+	return mq.mergeLabels(func(qr storage.Querier) ([]string, annotations.Annotations, error) {
+		return qr.LabelNames(ctx, hints, matchers...)
+	})
+}
+
+func (mq *MergeQuerier) mergeLabels(
+	query func(storage.Querier) ([]string, annotations.Annotations, error),
+) ([]string, annotations.Annotations, []domain.QuerierOutcome, error) {
+
 	if len(mq.queriers) == 0 {
-		return []string{}, map[string]error{}, nil
+		return []string{}, map[string]error{}, []domain.QuerierOutcome{}, nil
 	}
 
 	if len(mq.queriers) == 1 {
-		vals, annots, err := mq.queriers[0].LabelNames(ctx, hints, matchers...)
+		values, annots, err := query(mq.queriers[0])
+		annots = domain.AttributeAnnotations(annots, mq.names[0])
+		outcomes := []domain.QuerierOutcome{{Name: mq.names[0], Err: err}}
 		if err != nil {
-			return vals, annots, err
+			return values, annots, outcomes, err
 		}
-		return dedupe(vals), annots, err
+		return dedupe(values), annots, outcomes, err
 	}
 
-	errs := make([]error, 0)
-	annots := annotations.New()
+	responses := make([]*labelResponse, len(mq.queriers))
 
 	var wg sync.WaitGroup
-	valuesChan := make(chan *labelResponse)
-
 	wg.Add(len(mq.queriers))
-	for _, querier := range mq.queriers {
-		go func(qr storage.Querier) {
-
+	for idx, querier := range mq.queriers {
+		go func(idx int, qr storage.Querier) {
 			defer wg.Done()
-			values, annotationsResponse, err := qr.LabelNames(ctx, hints, matchers...)
+			values, annotationsResponse, err := query(qr)
 
-			valuesChan <- &labelResponse{
+			responses[idx] = &labelResponse{
 				values: values,
 				annots: annotationsResponse,
 				err:    err,
 			}
-		}(querier)
+		}(idx, querier)
 	}
 
-	go func() {
-		wg.Wait()
-		close(valuesChan)
-	}()
+	wg.Wait()
+
+	errs := make([]error, 0)
+	annots := annotations.New()
+	outcomes := make([]domain.QuerierOutcome, 0, len(responses))
 
 	values := make([]string, 0)
-	for lblResp := range valuesChan {
-		annots.Merge(lblResp.annots)
+	for idx, lblResp := range responses {
+		outcomes = append(outcomes, domain.QuerierOutcome{Name: mq.names[idx], Err: lblResp.err})
+
+		domain.MergeAnnotations(annots, domain.AttributeAnnotations(lblResp.annots, mq.names[idx]))
 		if lblResp.err != nil {
 			errs = append(errs, lblResp.err)
 			annots.Add(lblResp.err)
@@ -220,7 +221,7 @@ func (mq *MergeQuerier) LabelNames(
 	if len(errs) > 0 {
 		err = errors.Join(errs...)
 	}
-	return dedupe(values), *annots, err
+	return dedupe(values), *annots, outcomes, err
 }
 
 func dedupe(values []string) []string {
@@ -235,3 +236,53 @@ func dedupe(values []string) []string {
 	}
 	return deduped
 }
+
+// closeAll closes all the queriers, returning the errors of all of them
+func closeAll(queriers []storage.Querier) error {
+	errs := make([]error, 0)
+	for _, querier := range queriers {
+		err := querier.Close()
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// attributeSeriesSet sets the querier name as the source of the remote annotations on the
+// series set, when it is possible to do so
+func attributeSeriesSet(seriesSet storage.SeriesSet, name string) storage.SeriesSet {
+	parsedSet, ok := seriesSet.(*domain.GraviolaSeriesSet)
+	if !ok {
+		return seriesSet
+	}
+
+	parsedSet.Annots = domain.AttributeAnnotations(parsedSet.Annots, name)
+	return parsedSet
+}
+
+// recordSkipped registers on the stats of the query that the queriers were not contacted
+func recordSkipped(ctx context.Context, names []string, reason string) {
+	collector := querystats.FromContext(ctx)
+	for _, name := range names {
+		collector.RecordRemoteSkipped(name, reason)
+	}
+}
+
+func querierNames(queriers []storage.Querier) []string {
+	names := make([]string, 0, len(queriers))
+	for idx, querier := range queriers {
+		names = append(names, querierName(idx, querier))
+	}
+	return names
+}
+
+// querierName returns the name of the querier when it is able to inform it, or its position
+// inside the group otherwise.
+func querierName(idx int, querier storage.Querier) string {
+	if named, ok := querier.(namedQuerier); ok {
+		return named.Name()
+	}
+	return fmt.Sprintf("querier #%d", idx)
+}
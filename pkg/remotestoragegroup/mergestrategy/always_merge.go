@@ -3,7 +3,9 @@ package mergestrategy
 import (
 	"slices"
 
+	"github.com/jademcosta/graviola/pkg/config"
 	"github.com/jademcosta/graviola/pkg/domain"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/common/model"
 	"github.com/prometheus/prometheus/model/labels"
 	"github.com/prometheus/prometheus/storage"
@@ -13,7 +15,8 @@ import (
 // then it will keep the first entry found
 type AlwaysMergeStrategy struct{}
 
-func NewAlwaysMergeStrategy() *AlwaysMergeStrategy {
+func NewAlwaysMergeStrategy(metricz *prometheus.Registry) *AlwaysMergeStrategy {
+	registerDroppedSamplesMetric(metricz)
 	return &AlwaysMergeStrategy{}
 }
 
@@ -57,6 +60,7 @@ func (merger *AlwaysMergeStrategy) Merge(seriesSets []storage.SeriesSet) storage
 		mergedSeries = append(mergedSeries, currentSeries)
 	}
 
+	dropped := 0
 	for _, serie := range mergedSeries {
 
 		if serie != nil && len(serie.Datapoints) > 0 {
@@ -69,9 +73,10 @@ func (merger *AlwaysMergeStrategy) Merge(seriesSets []storage.SeriesSet) storage
 				return 0
 			})
 
-			removeDuplicatedTimestamps(serie)
+			dropped += removeDuplicatedTimestamps(serie)
 		}
 	}
+	countDroppedSamples(config.MergeStrategyAlwaysMerge, dropped)
 
 	annots := mergeAnnotations(seriesSets)
 	erro := joinErrors(seriesSets)
@@ -83,7 +88,9 @@ func (merger *AlwaysMergeStrategy) Merge(seriesSets []storage.SeriesSet) storage
 	}
 }
 
-func removeDuplicatedTimestamps(serie *domain.GraviolaSeries) {
+// removeDuplicatedTimestamps keeps only the first datapoint of each timestamp, returning how many
+// were removed
+func removeDuplicatedTimestamps(serie *domain.GraviolaSeries) int {
 	curTimestamp := model.Time(0)
 	dedupedDatapoints := make([]model.SamplePair, 0, len(serie.Datapoints))
 
@@ -100,5 +107,7 @@ func removeDuplicatedTimestamps(serie *domain.GraviolaSeries) {
 		}
 	}
 
+	dropped := len(serie.Datapoints) - len(dedupedDatapoints)
 	serie.Datapoints = dedupedDatapoints
+	return dropped
 }
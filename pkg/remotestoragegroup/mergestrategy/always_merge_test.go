@@ -25,7 +25,7 @@ func TestTheAlwaysMergeMergeMethod(t *testing.T) {
 			{Series: series1},
 		}
 
-		sut := mergestrategy.NewAlwaysMergeStrategy()
+		sut := mergestrategy.NewAlwaysMergeStrategy(nil)
 
 		resp := sut.Merge(cast(seriesSet))
 
@@ -63,7 +63,7 @@ func TestTheAlwaysMergeMergeMethod(t *testing.T) {
 			{Series: series1},
 		}
 
-		sut := mergestrategy.NewAlwaysMergeStrategy()
+		sut := mergestrategy.NewAlwaysMergeStrategy(nil)
 
 		resp := sut.Merge(cast(seriesSet))
 
@@ -102,7 +102,7 @@ func TestTheAlwaysMergeMergeMethod(t *testing.T) {
 			{Series: series2},
 		}
 
-		sut := mergestrategy.NewAlwaysMergeStrategy()
+		sut := mergestrategy.NewAlwaysMergeStrategy(nil)
 
 		resp := sut.Merge(cast(seriesSet))
 
@@ -139,7 +139,7 @@ func TestTheAlwaysMergeMergeMethod(t *testing.T) {
 			{Series: series2},
 		}
 
-		sut := mergestrategy.NewAlwaysMergeStrategy()
+		sut := mergestrategy.NewAlwaysMergeStrategy(nil)
 
 		resp := sut.Merge(cast(seriesSet))
 
@@ -190,7 +190,7 @@ func TestTheAlwaysMergeMergeMethod(t *testing.T) {
 			{Series: series4},
 		}
 
-		sut := mergestrategy.NewAlwaysMergeStrategy()
+		sut := mergestrategy.NewAlwaysMergeStrategy(nil)
 
 		resp := sut.Merge(cast(seriesSet))
 
@@ -223,7 +223,7 @@ func TestTheAlwaysMergeMergeMethod(t *testing.T) {
 			{Annots: annotations.New().Add(err2)},
 		}
 
-		sut := mergestrategy.NewAlwaysMergeStrategy()
+		sut := mergestrategy.NewAlwaysMergeStrategy(nil)
 
 		resp := sut.Merge(cast(seriesSet))
 
@@ -253,7 +253,7 @@ func TestTheAlwaysMergeMergeMethod(t *testing.T) {
 			},
 		}
 
-		sut := mergestrategy.NewAlwaysMergeStrategy()
+		sut := mergestrategy.NewAlwaysMergeStrategy(nil)
 
 		resp := sut.Merge(cast(seriesSet))
 		require.Error(t, resp.Err(), "should return an error")
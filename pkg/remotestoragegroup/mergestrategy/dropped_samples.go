@@ -0,0 +1,32 @@
+package mergestrategy
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var runOnceDroppedSamplesO11y sync.Once
+var droppedSamplesTotal *prometheus.CounterVec
+
+func registerDroppedSamplesMetric(metricz *prometheus.Registry) {
+	runOnceDroppedSamplesO11y.Do(func() {
+		droppedSamplesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "graviola",
+			Subsystem: "merge",
+			Name:      "dropped_samples_total",
+			Help:      "Counter of samples dropped by the merge strategies because another source answered the same series (or the same timestamp of it).",
+		},
+			[]string{"strategy"})
+
+		if metricz != nil {
+			metricz.MustRegister(droppedSamplesTotal)
+		}
+	})
+}
+
+func countDroppedSamples(strategy string, dropped int) {
+	if dropped > 0 {
+		droppedSamplesTotal.WithLabelValues(strategy).Add(float64(dropped))
+	}
+}
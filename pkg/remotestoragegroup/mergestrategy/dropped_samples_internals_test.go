@@ -0,0 +1,48 @@
+package mergestrategy
+
+import (
+	"testing"
+
+	"github.com/jademcosta/graviola/pkg/config"
+	"github.com/jademcosta/graviola/pkg/domain"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func duplicatedSeriesSets() []storage.SeriesSet {
+	return []storage.SeriesSet{
+		&domain.GraviolaSeriesSet{Series: []*domain.GraviolaSeries{
+			{Lbs: labels.FromStrings("job", "a"), Datapoints: []model.SamplePair{{Timestamp: 10, Value: 1}, {Timestamp: 20, Value: 2}}},
+		}},
+		&domain.GraviolaSeriesSet{Series: []*domain.GraviolaSeries{
+			{Lbs: labels.FromStrings("job", "a"), Datapoints: []model.SamplePair{
+				{Timestamp: 10, Value: 1}, {Timestamp: 20, Value: 2}, {Timestamp: 30, Value: 3}}},
+			{Lbs: labels.FromStrings("job", "b"), Datapoints: []model.SamplePair{{Timestamp: 10, Value: 1}}},
+		}},
+	}
+}
+
+func TestMergeStrategiesCountTheDroppedSamples(t *testing.T) {
+	testCases := []struct {
+		strategy string
+		sut      interface {
+			Merge([]storage.SeriesSet) storage.SeriesSet
+		}
+		dropped float64
+	}{
+		{config.MergeStrategyAlwaysMerge, NewAlwaysMergeStrategy(nil), 2},
+		{config.MergeStrategyKeepBiggest, NewKeepBiggestMergeStrategy(nil), 2},
+		{config.MergeStrategyReconcile, NewReconcileMergeStrategy(nil, config.ReconcileFunctionMax, 0), 2},
+	}
+
+	for _, tc := range testCases {
+		before := testutil.ToFloat64(droppedSamplesTotal.WithLabelValues(tc.strategy))
+		tc.sut.Merge(duplicatedSeriesSets())
+
+		assert.Equal(t, tc.dropped, testutil.ToFloat64(droppedSamplesTotal.WithLabelValues(tc.strategy))-before,
+			"%s should count the samples dropped as duplicates", tc.strategy)
+	}
+}
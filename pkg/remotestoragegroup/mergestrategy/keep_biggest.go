@@ -3,7 +3,9 @@ package mergestrategy
 import (
 	"slices"
 
+	"github.com/jademcosta/graviola/pkg/config"
 	"github.com/jademcosta/graviola/pkg/domain"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/prometheus/model/labels"
 	"github.com/prometheus/prometheus/storage"
 )
@@ -13,7 +15,8 @@ import (
 // the first one on the ordering is kept.
 type KeepBiggestMergeStrategy struct{}
 
-func NewKeepBiggestMergeStrategy() *KeepBiggestMergeStrategy {
+func NewKeepBiggestMergeStrategy(metricz *prometheus.Registry) *KeepBiggestMergeStrategy {
+	registerDroppedSamplesMetric(metricz)
 	return &KeepBiggestMergeStrategy{}
 }
 
@@ -36,6 +39,7 @@ func (merger *KeepBiggestMergeStrategy) Merge(seriesSets []storage.SeriesSet) st
 	}
 	mergedSeries := make([]*domain.GraviolaSeries, 0, len(graviolaSeries))
 
+	dropped := 0
 	var currentSeries *domain.GraviolaSeries
 	for _, serie := range graviolaSeries {
 		serie := serie
@@ -47,7 +51,10 @@ func (merger *KeepBiggestMergeStrategy) Merge(seriesSets []storage.SeriesSet) st
 
 		if labels.Equal(currentSeries.Lbs, serie.Lbs) {
 			if len(serie.Datapoints) > len(currentSeries.Datapoints) {
+				dropped += len(currentSeries.Datapoints)
 				currentSeries = serie
+			} else {
+				dropped += len(serie.Datapoints)
 			}
 		} else {
 			mergedSeries = append(mergedSeries, currentSeries)
@@ -59,6 +66,8 @@ func (merger *KeepBiggestMergeStrategy) Merge(seriesSets []storage.SeriesSet) st
 		mergedSeries = append(mergedSeries, currentSeries)
 	}
 
+	countDroppedSamples(config.MergeStrategyKeepBiggest, dropped)
+
 	annots := mergeAnnotations(seriesSets)
 	erro := joinErrors(seriesSets)
 
@@ -25,7 +25,7 @@ func TestTheMergeMethod(t *testing.T) {
 			{Series: series1},
 		}
 
-		sut := mergestrategy.NewKeepBiggestMergeStrategy()
+		sut := mergestrategy.NewKeepBiggestMergeStrategy(nil)
 
 		resp := sut.Merge(cast(seriesSet))
 
@@ -64,7 +64,7 @@ func TestTheMergeMethod(t *testing.T) {
 			{Series: series1},
 		}
 
-		sut := mergestrategy.NewKeepBiggestMergeStrategy()
+		sut := mergestrategy.NewKeepBiggestMergeStrategy(nil)
 
 		resp := sut.Merge(cast(seriesSet))
 
@@ -104,7 +104,7 @@ func TestTheMergeMethod(t *testing.T) {
 			{Series: series2},
 		}
 
-		sut := mergestrategy.NewKeepBiggestMergeStrategy()
+		sut := mergestrategy.NewKeepBiggestMergeStrategy(nil)
 
 		resp := sut.Merge(cast(seriesSet))
 
@@ -142,7 +142,7 @@ func TestTheMergeMethod(t *testing.T) {
 			{Series: series2},
 		}
 
-		sut := mergestrategy.NewKeepBiggestMergeStrategy()
+		sut := mergestrategy.NewKeepBiggestMergeStrategy(nil)
 
 		resp := sut.Merge(cast(seriesSet))
 
@@ -194,7 +194,7 @@ func TestTheMergeMethod(t *testing.T) {
 			{Series: series4},
 		}
 
-		sut := mergestrategy.NewKeepBiggestMergeStrategy()
+		sut := mergestrategy.NewKeepBiggestMergeStrategy(nil)
 
 		resp := sut.Merge(cast(seriesSet))
 
@@ -228,7 +228,7 @@ func TestTheMergeMethod(t *testing.T) {
 			{Annots: annotations.New().Add(err2)},
 		}
 
-		sut := mergestrategy.NewKeepBiggestMergeStrategy()
+		sut := mergestrategy.NewKeepBiggestMergeStrategy(nil)
 
 		resp := sut.Merge(cast(seriesSet))
 
@@ -258,7 +258,7 @@ func TestTheMergeMethod(t *testing.T) {
 			},
 		}
 
-		sut := mergestrategy.NewKeepBiggestMergeStrategy()
+		sut := mergestrategy.NewKeepBiggestMergeStrategy(nil)
 
 		resp := sut.Merge(cast(seriesSet))
 		require.Error(t, resp.Err(), "should return an error")
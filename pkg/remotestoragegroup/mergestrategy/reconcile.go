@@ -0,0 +1,222 @@
+package mergestrategy
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"slices"
+	"sync"
+
+	"github.com/jademcosta/graviola/pkg/config"
+	"github.com/jademcosta/graviola/pkg/domain"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/storage"
+)
+
+var runOnceReconcileO11y sync.Once
+var divergentSamplesTotal *prometheus.CounterVec
+
+// ReconcileFunc picks the final value of a timestamp that has conflicting values. The values
+// are in the same order of the series sets given to Merge.
+type ReconcileFunc func(values []model.SampleValue) model.SampleValue
+
+// A merge strategy where all series are merged together, like the AlwaysMergeStrategy, but
+// when 2 or more datapoints share the same timestamp the kept value is decided by a reconcile
+// function (min, max, avg, first or last). The first and last functions follow the order of the
+// series sets given to Merge, which is the order the remotes/groups were configured.
+// When a divergence threshold is set, conflicting values that differ (relatively) more than it
+// are reported as a warning annotation and on a metric.
+type ReconcileMergeStrategy struct {
+	functionName        string
+	reconcile           ReconcileFunc
+	divergenceThreshold float64
+}
+
+func NewReconcileMergeStrategy(
+	metricz *prometheus.Registry, functionName string, divergenceThreshold float64,
+) *ReconcileMergeStrategy {
+	registerReconcileMetrics(metricz)
+	registerDroppedSamplesMetric(metricz)
+
+	return &ReconcileMergeStrategy{
+		functionName:        functionName,
+		reconcile:           reconcileFuncFor(functionName),
+		divergenceThreshold: divergenceThreshold,
+	}
+}
+
+// The series inside each seriesSet need to be ordered for this to work
+func (merger *ReconcileMergeStrategy) Merge(seriesSets []storage.SeriesSet) storage.SeriesSet {
+	if len(seriesSets) == 0 {
+		return storage.NoopSeriesSet()
+	}
+
+	if len(seriesSets) == 1 {
+		return seriesSets[0]
+	}
+
+	annots := mergeAnnotations(seriesSets)
+	erro := joinErrors(seriesSets)
+
+	graviolaSeries := keepOnlyGraviolaSeries(seriesSets)
+
+	// Stable, so series with the same labels keep the order of the sets they came from
+	slices.SortStableFunc(graviolaSeries, func(a, b *domain.GraviolaSeries) int {
+		return labels.Compare(a.Lbs, b.Lbs)
+	})
+
+	mergedSeries := make([]*domain.GraviolaSeries, 0, len(graviolaSeries))
+
+	for start := 0; start < len(graviolaSeries); {
+		end := start + 1
+		for end < len(graviolaSeries) && labels.Equal(graviolaSeries[start].Lbs, graviolaSeries[end].Lbs) {
+			end++
+		}
+
+		serie, divergences, maxDivergence := merger.mergeSameSeries(graviolaSeries[start:end])
+		mergedSeries = append(mergedSeries, serie)
+
+		if divergences > 0 {
+			divergentSamplesTotal.WithLabelValues(merger.functionName).Add(float64(divergences))
+			annots.Add(fmt.Errorf(
+				"merge: %d sample(s) of series %s have values diverging more than %g between sources (max divergence %g)",
+				divergences, serie.Lbs.String(), merger.divergenceThreshold, maxDivergence))
+		}
+
+		start = end
+	}
+
+	return &domain.GraviolaSeriesSet{
+		Series: mergedSeries,
+		Annots: *annots,
+		Erro:   erro,
+	}
+}
+
+// mergeSameSeries merges series that share the same labels, returning the merged series, how
+// many timestamps diverged above the threshold and the biggest divergence found.
+func (merger *ReconcileMergeStrategy) mergeSameSeries(
+	series []*domain.GraviolaSeries,
+) (*domain.GraviolaSeries, int, float64) {
+
+	if len(series) == 1 {
+		return series[0], 0, 0
+	}
+
+	totalDatapoints := 0
+	for _, serie := range series {
+		totalDatapoints += len(serie.Datapoints)
+	}
+
+	allDatapoints := make([]model.SamplePair, 0, totalDatapoints)
+	for _, serie := range series {
+		allDatapoints = append(allDatapoints, serie.Datapoints...)
+	}
+
+	// Stable, so datapoints with the same timestamp keep the order of the sources
+	slices.SortStableFunc(allDatapoints, func(a, b model.SamplePair) int {
+		return cmp.Compare(a.Timestamp, b.Timestamp)
+	})
+
+	merged := make([]model.SamplePair, 0, len(allDatapoints))
+	values := make([]model.SampleValue, 0, len(series))
+	divergences := 0
+	maxDivergence := 0.0
+
+	for start := 0; start < len(allDatapoints); {
+		end := start + 1
+		for end < len(allDatapoints) && allDatapoints[start].Timestamp == allDatapoints[end].Timestamp {
+			end++
+		}
+
+		values = values[:0]
+		for _, datapoint := range allDatapoints[start:end] {
+			values = append(values, datapoint.Value)
+		}
+
+		if len(values) > 1 && merger.divergenceThreshold > 0 {
+			divergence := relativeDivergence(values)
+			if divergence > merger.divergenceThreshold {
+				divergences++
+				maxDivergence = math.Max(maxDivergence, divergence)
+			}
+		}
+
+		merged = append(merged, model.SamplePair{
+			Timestamp: allDatapoints[start].Timestamp,
+			Value:     merger.reconcile(values),
+		})
+
+		start = end
+	}
+
+	countDroppedSamples(config.MergeStrategyReconcile, len(allDatapoints)-len(merged))
+
+	return &domain.GraviolaSeries{
+		Lbs:        series[0].Lbs,
+		Datapoints: merged,
+	}, divergences, maxDivergence
+}
+
+// relativeDivergence returns the difference between the biggest and smallest values relative
+// to the biggest absolute value among them.
+func relativeDivergence(values []model.SampleValue) float64 {
+	minVal := float64(slices.Min(values))
+	maxVal := float64(slices.Max(values))
+
+	if minVal == maxVal {
+		return 0
+	}
+
+	reference := math.Max(math.Abs(minVal), math.Abs(maxVal))
+	return (maxVal - minVal) / reference
+}
+
+func reconcileFuncFor(functionName string) ReconcileFunc {
+	switch functionName {
+	case config.ReconcileFunctionMin:
+		return func(values []model.SampleValue) model.SampleValue {
+			return slices.Min(values)
+		}
+	case config.ReconcileFunctionMax:
+		return func(values []model.SampleValue) model.SampleValue {
+			return slices.Max(values)
+		}
+	case config.ReconcileFunctionAvg:
+		return func(values []model.SampleValue) model.SampleValue {
+			sum := 0.0
+			for _, val := range values {
+				sum += float64(val)
+			}
+			return model.SampleValue(sum / float64(len(values)))
+		}
+	case config.ReconcileFunctionFirst:
+		return func(values []model.SampleValue) model.SampleValue {
+			return values[0]
+		}
+	case config.ReconcileFunctionLast:
+		return func(values []model.SampleValue) model.SampleValue {
+			return values[len(values)-1]
+		}
+	default:
+		panic("unrecognized reconcile function")
+	}
+}
+
+func registerReconcileMetrics(metricz *prometheus.Registry) {
+	runOnceReconcileO11y.Do(func() {
+		divergentSamplesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "graviola",
+			Subsystem: "merge",
+			Name:      "divergent_samples_total",
+			Help:      "Counter of timestamps where the values returned by different sources diverged more than the configured threshold.",
+		},
+			[]string{"function"})
+
+		if metricz != nil {
+			metricz.MustRegister(divergentSamplesTotal)
+		}
+	})
+}
@@ -0,0 +1,135 @@
+package mergestrategy_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/jademcosta/graviola/pkg/config"
+	"github.com/jademcosta/graviola/pkg/domain"
+	"github.com/jademcosta/graviola/pkg/remotestoragegroup/mergestrategy"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTheReconcileMergeMethod(t *testing.T) {
+
+	t.Run("with no sets", func(t *testing.T) {
+		sut := mergestrategy.NewReconcileMergeStrategy(nil, config.ReconcileFunctionMax, 0)
+
+		resp := sut.Merge([]storage.SeriesSet{})
+		assert.Equal(t, storage.NoopSeriesSet(), resp, "should return a noop series set")
+	})
+
+	t.Run("with a single set", func(t *testing.T) {
+		seriesSet := []*domain.GraviolaSeriesSet{
+			{Series: []*domain.GraviolaSeries{
+				{Lbs: labels.FromStrings("x", "value1"),
+					Datapoints: []model.SamplePair{{Timestamp: 1703379256017, Value: 1.1}}},
+			}},
+		}
+
+		sut := mergestrategy.NewReconcileMergeStrategy(nil, config.ReconcileFunctionMax, 0)
+
+		resp := sut.Merge(cast(seriesSet))
+		assert.Equal(t, seriesSet[0], resp, "should return the same set")
+	})
+
+	testCases := []struct {
+		function string
+		expected []model.SamplePair
+	}{
+		{config.ReconcileFunctionMin, []model.SamplePair{
+			{Timestamp: 1703379256017, Value: 1.0}, {Timestamp: 1703379286017, Value: 2.0}, {Timestamp: 1703379316017, Value: 5.0}}},
+		{config.ReconcileFunctionMax, []model.SamplePair{
+			{Timestamp: 1703379256017, Value: 4.0}, {Timestamp: 1703379286017, Value: 8.0}, {Timestamp: 1703379316017, Value: 5.0}}},
+		{config.ReconcileFunctionAvg, []model.SamplePair{
+			{Timestamp: 1703379256017, Value: 2.5}, {Timestamp: 1703379286017, Value: 5.0}, {Timestamp: 1703379316017, Value: 5.0}}},
+		{config.ReconcileFunctionFirst, []model.SamplePair{
+			{Timestamp: 1703379256017, Value: 4.0}, {Timestamp: 1703379286017, Value: 2.0}, {Timestamp: 1703379316017, Value: 5.0}}},
+		{config.ReconcileFunctionLast, []model.SamplePair{
+			{Timestamp: 1703379256017, Value: 1.0}, {Timestamp: 1703379286017, Value: 8.0}, {Timestamp: 1703379316017, Value: 5.0}}},
+	}
+
+	for _, tc := range testCases {
+		t.Run("reconciles conflicting values with "+tc.function, func(t *testing.T) {
+			seriesSet := []*domain.GraviolaSeriesSet{
+				{Series: []*domain.GraviolaSeries{
+					{Lbs: labels.FromStrings("x", "value1"),
+						Datapoints: []model.SamplePair{{Timestamp: 1703379256017, Value: 4.0}, {Timestamp: 1703379286017, Value: 2.0}}},
+				}},
+				{Series: []*domain.GraviolaSeries{
+					{Lbs: labels.FromStrings("x", "value1"),
+						Datapoints: []model.SamplePair{{Timestamp: 1703379256017, Value: 1.0}, {Timestamp: 1703379286017, Value: 8.0}}},
+					{Lbs: labels.FromStrings("a", "value1"),
+						Datapoints: []model.SamplePair{{Timestamp: 1703379256017, Value: 3.0}}},
+				}},
+				{Series: []*domain.GraviolaSeries{
+					{Lbs: labels.FromStrings("x", "value1"),
+						Datapoints: []model.SamplePair{{Timestamp: 1703379316017, Value: 5.0}}},
+				}},
+			}
+
+			sut := mergestrategy.NewReconcileMergeStrategy(nil, tc.function, 0)
+
+			resp := sut.Merge(cast(seriesSet))
+			parsedSet, ok := resp.(*domain.GraviolaSeriesSet)
+			require.True(t, ok, "parsing should work")
+
+			require.Len(t, parsedSet.Series, 2, "should have merged the series with the same labels")
+			assert.Equal(t, labels.FromStrings("a", "value1"), parsedSet.Series[0].Lbs, "should keep series ordered")
+			assert.Equal(t, []model.SamplePair{{Timestamp: 1703379256017, Value: 3.0}}, parsedSet.Series[0].Datapoints,
+				"series without conflicts should be kept as they are")
+			assert.Equal(t, labels.FromStrings("x", "value1"), parsedSet.Series[1].Lbs, "should keep series ordered")
+			assert.Equal(t, tc.expected, parsedSet.Series[1].Datapoints, "should reconcile values with %s", tc.function)
+			assert.Empty(t, parsedSet.Annots, "should not add annotations when divergence is disabled")
+		})
+	}
+
+	t.Run("adds an annotation when values diverge more than the threshold", func(t *testing.T) {
+		seriesSet := []*domain.GraviolaSeriesSet{
+			{Series: []*domain.GraviolaSeries{
+				{Lbs: labels.FromStrings("x", "value1"),
+					Datapoints: []model.SamplePair{{Timestamp: 1703379256017, Value: 100.0}, {Timestamp: 1703379286017, Value: 100.0}}},
+				{Lbs: labels.FromStrings("y", "value1"),
+					Datapoints: []model.SamplePair{{Timestamp: 1703379256017, Value: 100.0}}},
+			}},
+			{Series: []*domain.GraviolaSeries{
+				{Lbs: labels.FromStrings("x", "value1"),
+					Datapoints: []model.SamplePair{{Timestamp: 1703379256017, Value: 105.0}, {Timestamp: 1703379286017, Value: 150.0}}},
+				{Lbs: labels.FromStrings("y", "value1"),
+					Datapoints: []model.SamplePair{{Timestamp: 1703379256017, Value: 101.0}}},
+			}},
+		}
+
+		sut := mergestrategy.NewReconcileMergeStrategy(nil, config.ReconcileFunctionMax, 0.1)
+
+		resp := sut.Merge(cast(seriesSet))
+		parsedSet, ok := resp.(*domain.GraviolaSeriesSet)
+		require.True(t, ok, "parsing should work")
+
+		require.Len(t, parsedSet.Annots, 1, "should add a single annotation for the diverging series")
+		for annotation := range parsedSet.Annots {
+			assert.Contains(t, annotation, `{x="value1"}`, "should name the series that diverged")
+			assert.Contains(t, annotation, "1 sample(s)", "should only count the divergence above the threshold")
+		}
+	})
+
+	t.Run("keeps the errors of the sets", func(t *testing.T) {
+		err1 := errors.New("some error")
+		seriesSet := []*domain.GraviolaSeriesSet{
+			{Erro: err1},
+			{Series: []*domain.GraviolaSeries{
+				{Lbs: labels.FromStrings("x", "value1"),
+					Datapoints: []model.SamplePair{{Timestamp: 1703379256017, Value: 1.1}}},
+			}},
+		}
+
+		sut := mergestrategy.NewReconcileMergeStrategy(nil, config.ReconcileFunctionAvg, 0)
+
+		resp := sut.Merge(cast(seriesSet))
+		require.ErrorIs(t, resp.Err(), err1, "should return the errors of the sets")
+	})
+}
@@ -26,7 +26,7 @@ func mergeAnnotations(seriesSets []storage.SeriesSet) *annotations.Annotations {
 
 	for _, seriesSet := range seriesSets {
 		if seriesSet.Warnings() != nil {
-			mergedAnnots.Merge(seriesSet.Warnings())
+			domain.MergeAnnotations(mergedAnnots, seriesSet.Warnings())
 		}
 	}
 
@@ -1,15 +1,21 @@
 package queryfailurestrategy
 
-import "github.com/prometheus/prometheus/storage"
+import (
+	"github.com/jademcosta/graviola/pkg/domain"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/prometheus/prometheus/util/annotations"
+)
 
 type FailAllStrategy struct{}
 
 // OnQueryFailureStrategy
-func (fAllStrategy *FailAllStrategy) ForSeriesSet(sSets storage.SeriesSet) storage.SeriesSet {
+func (fAllStrategy *FailAllStrategy) ForSeriesSet(sSets storage.SeriesSet, _ []domain.QuerierOutcome) storage.SeriesSet {
 	return sSets
 }
 
 // OnQueryFailureStrategy
-func (fAllStrategy *FailAllStrategy) ForLabels(lbls []string, err error) ([]string, error) {
-	return lbls, err
+func (fAllStrategy *FailAllStrategy) ForLabels(
+	lbls []string, annots annotations.Annotations, outcomes []domain.QuerierOutcome,
+) ([]string, annotations.Annotations, error) {
+	return lbls, annots, joinOutcomeErrors(outcomes)
 }
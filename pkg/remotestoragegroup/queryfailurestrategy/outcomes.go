@@ -0,0 +1,22 @@
+package queryfailurestrategy
+
+import (
+	"errors"
+
+	"github.com/jademcosta/graviola/pkg/domain"
+)
+
+func joinOutcomeErrors(outcomes []domain.QuerierOutcome) error {
+	errs := make([]error, 0)
+	for _, outcome := range outcomes {
+		if outcome.Failed() {
+			errs = append(errs, outcome.Err)
+		}
+	}
+
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+
+	return nil
+}
@@ -1,14 +1,20 @@
 package queryfailurestrategy
 
 import (
+	"fmt"
+	"strings"
+
 	"github.com/jademcosta/graviola/pkg/domain"
 	"github.com/prometheus/prometheus/storage"
+	"github.com/prometheus/prometheus/util/annotations"
 )
 
 type PartialResponseStrategy struct{}
 
 // OnQueryFailureStrategy
-func (fAllStrategy *PartialResponseStrategy) ForSeriesSet(sSets storage.SeriesSet) storage.SeriesSet {
+func (fAllStrategy *PartialResponseStrategy) ForSeriesSet(
+	sSets storage.SeriesSet, outcomes []domain.QuerierOutcome,
+) storage.SeriesSet {
 	if sSets.Err() == nil {
 		return sSets
 	}
@@ -26,21 +32,37 @@ func (fAllStrategy *PartialResponseStrategy) ForSeriesSet(sSets storage.SeriesSe
 		return sSets
 	}
 
+	parsedSet.Annots.Add(partialResponseWarning(outcomes, parsedSet.Erro))
 	parsedSet.Erro = nil
 	return parsedSet
 }
 
 // OnQueryFailureStrategy
-func (fAllStrategy *PartialResponseStrategy) ForLabels(lbls []string, err error) ([]string, error) {
+func (fAllStrategy *PartialResponseStrategy) ForLabels(
+	lbls []string, annots annotations.Annotations, outcomes []domain.QuerierOutcome,
+) ([]string, annotations.Annotations, error) {
+	err := joinOutcomeErrors(outcomes)
 	if err == nil { //No error, keep everything
-		return lbls, nil
+		return lbls, annots, nil
 	}
 
 	if len(lbls) == 0 { //This error needs to be reported in case it exists
-		return lbls, err
+		return lbls, annots, err
+	}
+
+	//Ignore errors, as there's a partial response
+	return lbls, annots.Add(partialResponseWarning(outcomes, err)), nil
+}
+
+// partialResponseWarning names the queriers that failed, so the user knows which data is missing
+func partialResponseWarning(outcomes []domain.QuerierOutcome, err error) error {
+	failedNames := domain.FailedQuerierNames(outcomes)
+	if len(failedNames) == 0 {
+		return domain.NewPartialResponseWarning(fmt.Errorf("partial response: %w", err))
 	}
 
-	return lbls, nil //Ignore errors, as there's a partial response
+	return domain.NewPartialResponseWarning(
+		fmt.Errorf("partial response, these failed to answer: %s: %w", strings.Join(failedNames, ", "), err))
 }
 
 func isThereDataInAnySeries(series []*domain.GraviolaSeries) bool {
@@ -19,7 +19,7 @@ func TestForLabels(t *testing.T) {
 		t.Parallel()
 
 		lbls := []string{"a", "a", "b", "c"}
-		lblsResponse, err := sut.ForLabels(lbls, nil)
+		lblsResponse, _, err := sut.ForLabels(lbls, nil, nil)
 		require.NoError(t, err, "should return no error")
 		assert.Equal(t, lbls, lblsResponse, "should return all labels")
 		assert.Equal(t, &lbls, &lblsResponse, "should return the same labels")
@@ -29,7 +29,7 @@ func TestForLabels(t *testing.T) {
 		t.Parallel()
 
 		lbls := []string{}
-		lblsResponse, err := sut.ForLabels(lbls, nil)
+		lblsResponse, _, err := sut.ForLabels(lbls, nil, nil)
 		require.NoError(t, err, "should return no error")
 		assert.Equal(t, lbls, lblsResponse, "should return all labels")
 		assert.Equal(t, &lbls, &lblsResponse, "should return the same labels")
@@ -37,13 +37,13 @@ func TestForLabels(t *testing.T) {
 
 		error1 := errors.New("some err")
 
-		lblsResponse, err = sut.ForLabels(lbls, error1)
-		assert.Equal(t, error1, err, "should return the same error")
+		lblsResponse, _, err = sut.ForLabels(lbls, nil, []domain.QuerierOutcome{{Name: "remote 1", Err: error1}})
+		require.ErrorIs(t, err, error1, "should return the same error")
 		assert.Equal(t, lbls, lblsResponse, "should return all labels")
 		assert.Equal(t, &lbls, &lblsResponse, "should return the same labels")
 		assert.Empty(t, lblsResponse, "should return the same labels")
 
-		lblsResponse, err = sut.ForLabels(nil, nil)
+		lblsResponse, _, err = sut.ForLabels(nil, nil, nil)
 		require.NoError(t, err, "should return no error")
 		assert.Nil(t, lblsResponse, "should return all labels")
 		assert.Empty(t, lblsResponse, "should return the same labels")
@@ -55,10 +55,17 @@ func TestForLabels(t *testing.T) {
 		lbls := []string{"a"}
 		error1 := errors.New("some error")
 
-		lblsResponse, err := sut.ForLabels(lbls, error1)
+		lblsResponse, annots, err := sut.ForLabels(lbls, nil,
+			[]domain.QuerierOutcome{{Name: "remote 1", Err: error1}, {Name: "remote 2"}})
 		require.NoError(t, err, "should return no error")
 		assert.Equal(t, lbls, lblsResponse, "should return all labels")
 		assert.Equal(t, &lbls, &lblsResponse, "should return the same labels")
+		require.Len(t, annots, 1, "should add a warning")
+		for annotation := range annots {
+			assert.Contains(t, annotation, "remote 1", "should name the failed remote")
+			assert.NotContains(t, annotation, "remote 2", "should not name the successful remote")
+		}
+		assert.True(t, domain.HasPartialResponseWarning(annots), "should mark the warning as a partial response")
 	})
 }
 
@@ -75,7 +82,7 @@ func TestForSeriesSet(t *testing.T) {
 			},
 		}
 
-		response := sut.ForSeriesSet(sSet)
+		response := sut.ForSeriesSet(sSet, nil)
 		require.NoError(t, response.Err(), "should return no error")
 		assert.Equal(t, sSet, response, "should return all seriesSet data")
 	})
@@ -87,7 +94,7 @@ func TestForSeriesSet(t *testing.T) {
 			Erro: nil,
 		}
 
-		response := sut.ForSeriesSet(sSet)
+		response := sut.ForSeriesSet(sSet, nil)
 		require.NoError(t, response.Err(), "should return no error")
 		assert.Equal(t, sSet, response, "should return the same series set")
 		assert.False(t, response.Next(), "should return the same series set")
@@ -97,7 +104,7 @@ func TestForSeriesSet(t *testing.T) {
 			Series: []*domain.GraviolaSeries{},
 		}
 
-		response = sut.ForSeriesSet(sSet)
+		response = sut.ForSeriesSet(sSet, nil)
 		require.NoError(t, response.Err(), "should return no error")
 		assert.Equal(t, sSet, response, "should return the same series set")
 		assert.False(t, response.Next(), "should return the same series set")
@@ -109,7 +116,7 @@ func TestForSeriesSet(t *testing.T) {
 			Series: []*domain.GraviolaSeries{},
 		}
 
-		response = sut.ForSeriesSet(sSet)
+		response = sut.ForSeriesSet(sSet, nil)
 		require.Error(t, response.Err(), "should return the same error")
 		assert.Equal(t, sSet, response, "should return the same series set")
 		assert.False(t, response.Next(), "should return the same series set")
@@ -129,9 +136,15 @@ func TestForSeriesSet(t *testing.T) {
 			},
 		}
 
-		response := sut.ForSeriesSet(sSet)
+		response := sut.ForSeriesSet(sSet,
+			[]domain.QuerierOutcome{{Name: "remote 1"}, {Name: "remote 2", Err: error1}})
 		require.NoError(t, response.Err(), "should return no error")
 		assert.Equal(t, sSet, response, "should return the same series set (it is a pointer)")
 		assert.True(t, response.Next(), "should return the same series set")
+		require.Len(t, response.Warnings(), 1, "should add a warning")
+		for annotation := range response.Warnings() {
+			assert.Contains(t, annotation, "remote 2", "should name the failed remote")
+			assert.Contains(t, annotation, error1.Error(), "should inform the error")
+		}
 	})
 }
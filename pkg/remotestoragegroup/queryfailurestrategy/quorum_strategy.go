@@ -0,0 +1,85 @@
+package queryfailurestrategy
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jademcosta/graviola/pkg/domain"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/prometheus/prometheus/util/annotations"
+)
+
+// A strategy where the group answers successfully as long as a minimum number of its queriers
+// answered without error. Failed queriers are reported as a warning annotation. When the
+// quorum is not reached, the whole query fails.
+type QuorumStrategy struct {
+	minSuccessful int
+}
+
+func NewQuorumStrategy(minSuccessful int) *QuorumStrategy {
+	if minSuccessful < 1 {
+		panic("minSuccessful < 1 is not allowed")
+	}
+
+	return &QuorumStrategy{minSuccessful: minSuccessful}
+}
+
+// OnQueryFailureStrategy
+func (quorum *QuorumStrategy) ForSeriesSet(
+	sSets storage.SeriesSet, outcomes []domain.QuerierOutcome,
+) storage.SeriesSet {
+	failedNames := domain.FailedQuerierNames(outcomes)
+	if len(failedNames) == 0 {
+		return sSets
+	}
+
+	parsedSet, isGraviolaSet := sSets.(*domain.GraviolaSeriesSet)
+
+	if !quorum.reached(outcomes, failedNames) {
+		err := quorum.notReachedError(outcomes, failedNames)
+		if isGraviolaSet {
+			parsedSet.Erro = err
+			return parsedSet
+		}
+		return storage.ErrSeriesSet(err)
+	}
+
+	if !isGraviolaSet {
+		return sSets
+	}
+
+	parsedSet.Erro = nil
+	parsedSet.Annots.Add(quorum.failedWarning(outcomes, failedNames))
+	return parsedSet
+}
+
+// OnQueryFailureStrategy
+func (quorum *QuorumStrategy) ForLabels(
+	lbls []string, annots annotations.Annotations, outcomes []domain.QuerierOutcome,
+) ([]string, annotations.Annotations, error) {
+	failedNames := domain.FailedQuerierNames(outcomes)
+	if len(failedNames) == 0 {
+		return lbls, annots, nil
+	}
+
+	if !quorum.reached(outcomes, failedNames) {
+		return lbls, annots, quorum.notReachedError(outcomes, failedNames)
+	}
+
+	return lbls, annots.Add(quorum.failedWarning(outcomes, failedNames)), nil
+}
+
+func (quorum *QuorumStrategy) reached(outcomes []domain.QuerierOutcome, failedNames []string) bool {
+	return len(outcomes)-len(failedNames) >= quorum.minSuccessful
+}
+
+func (quorum *QuorumStrategy) notReachedError(outcomes []domain.QuerierOutcome, failedNames []string) error {
+	return fmt.Errorf("quorum not reached, %d of %d answered successfully but %d are needed (failed: %s): %w",
+		len(outcomes)-len(failedNames), len(outcomes), quorum.minSuccessful, strings.Join(failedNames, ", "),
+		joinOutcomeErrors(outcomes))
+}
+
+func (quorum *QuorumStrategy) failedWarning(outcomes []domain.QuerierOutcome, failedNames []string) error {
+	return domain.NewPartialResponseWarning(fmt.Errorf("quorum reached with %d of %d, but these failed to answer: %s",
+		len(outcomes)-len(failedNames), len(outcomes), strings.Join(failedNames, ", ")))
+}
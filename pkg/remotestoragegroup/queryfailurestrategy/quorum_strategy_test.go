@@ -0,0 +1,130 @@
+package queryfailurestrategy_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jademcosta/graviola/pkg/domain"
+	"github.com/jademcosta/graviola/pkg/remotestoragegroup/queryfailurestrategy"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuorumPanicsIfMinSuccessfulIsLessThanOne(t *testing.T) {
+	assert.Panics(t, func() { queryfailurestrategy.NewQuorumStrategy(0) },
+		"should panic if min successful is < 1")
+}
+
+func TestQuorumForLabels(t *testing.T) {
+	error1 := errors.New("some error")
+	error2 := errors.New("some other error")
+
+	t.Run("does nothing when no querier failed", func(t *testing.T) {
+		t.Parallel()
+
+		sut := queryfailurestrategy.NewQuorumStrategy(2)
+		lbls := []string{"a", "b"}
+
+		lblsResponse, annots, err := sut.ForLabels(lbls, nil,
+			[]domain.QuerierOutcome{{Name: "r1"}, {Name: "r2"}, {Name: "r3"}})
+		require.NoError(t, err, "should return no error")
+		assert.Equal(t, lbls, lblsResponse, "should return all labels")
+		assert.Empty(t, annots, "should not add annotations")
+	})
+
+	t.Run("succeeds with a warning naming the failed queriers when the quorum is reached", func(t *testing.T) {
+		t.Parallel()
+
+		sut := queryfailurestrategy.NewQuorumStrategy(2)
+		lbls := []string{"a", "b"}
+
+		lblsResponse, annots, err := sut.ForLabels(lbls, nil,
+			[]domain.QuerierOutcome{{Name: "r1"}, {Name: "r2", Err: error1}, {Name: "r3"}})
+		require.NoError(t, err, "should return no error")
+		assert.Equal(t, lbls, lblsResponse, "should return all labels")
+		require.Len(t, annots, 1, "should add a warning")
+		for annotation := range annots {
+			assert.Contains(t, annotation, "r2", "should name the failed querier")
+			assert.NotContains(t, annotation, "r1", "should not name the successful querier")
+		}
+		assert.True(t, domain.HasPartialResponseWarning(annots), "should mark the warning as a partial response")
+	})
+
+	t.Run("fails when the quorum is not reached", func(t *testing.T) {
+		t.Parallel()
+
+		sut := queryfailurestrategy.NewQuorumStrategy(2)
+
+		_, _, err := sut.ForLabels([]string{"a"}, nil,
+			[]domain.QuerierOutcome{{Name: "r1", Err: error1}, {Name: "r2", Err: error2}, {Name: "r3"}})
+		require.Error(t, err, "should return an error")
+		require.ErrorIs(t, err, error1, "should wrap the errors of the queriers")
+		require.ErrorIs(t, err, error2, "should wrap the errors of the queriers")
+		assert.Contains(t, err.Error(), "r1, r2", "should name the failed queriers")
+	})
+}
+
+func TestQuorumForSeriesSet(t *testing.T) {
+	error1 := errors.New("some error")
+
+	t.Run("does nothing when no querier failed", func(t *testing.T) {
+		t.Parallel()
+
+		sut := queryfailurestrategy.NewQuorumStrategy(1)
+		sSet := &domain.GraviolaSeriesSet{}
+
+		response := sut.ForSeriesSet(sSet, []domain.QuerierOutcome{{Name: "r1"}, {Name: "r2"}})
+		require.NoError(t, response.Err(), "should return no error")
+		assert.Equal(t, sSet, response, "should return the same series set")
+		assert.Empty(t, response.Warnings(), "should not add annotations")
+	})
+
+	t.Run("removes the error and adds a warning when the quorum is reached", func(t *testing.T) {
+		t.Parallel()
+
+		sut := queryfailurestrategy.NewQuorumStrategy(2)
+		sSet := &domain.GraviolaSeriesSet{
+			Erro: error1,
+			Series: []*domain.GraviolaSeries{{
+				Datapoints: []model.SamplePair{
+					{Timestamp: model.Time(time.Now().Unix()), Value: 10},
+				}},
+			},
+		}
+
+		response := sut.ForSeriesSet(sSet,
+			[]domain.QuerierOutcome{{Name: "r1"}, {Name: "r2"}, {Name: "r3", Err: error1}})
+		require.NoError(t, response.Err(), "should return no error")
+		assert.True(t, response.Next(), "should keep the series")
+		require.Len(t, response.Warnings(), 1, "should add a warning")
+		for annotation := range response.Warnings() {
+			assert.Contains(t, annotation, "r3", "should name the failed querier")
+		}
+	})
+
+	t.Run("fails when the quorum is not reached, even with an empty error", func(t *testing.T) {
+		t.Parallel()
+
+		sut := queryfailurestrategy.NewQuorumStrategy(2)
+		sSet := &domain.GraviolaSeriesSet{}
+
+		response := sut.ForSeriesSet(sSet,
+			[]domain.QuerierOutcome{{Name: "r1"}, {Name: "r2", Err: error1}, {Name: "r3", Err: error1}})
+		require.ErrorIs(t, response.Err(), error1, "should return an error")
+		assert.Contains(t, response.Err().Error(), "r2, r3", "should name the failed queriers")
+	})
+
+	t.Run("works with non graviola series sets", func(t *testing.T) {
+		t.Parallel()
+
+		sut := queryfailurestrategy.NewQuorumStrategy(2)
+
+		response := sut.ForSeriesSet(storage.ErrSeriesSet(error1),
+			[]domain.QuerierOutcome{{Name: "r1", Err: error1}, {Name: "r2"}})
+		require.ErrorIs(t, response.Err(), error1, "should return an error")
+		assert.Contains(t, response.Err().Error(), "quorum", "should inform the quorum was not reached")
+	})
+}
@@ -3,38 +3,67 @@ package remotestoragegroup
 import (
 	"context"
 	"log/slog"
+	"time"
 
+	"github.com/jademcosta/graviola/pkg/domain"
+	"github.com/jademcosta/graviola/pkg/querystats"
+	"github.com/jademcosta/graviola/pkg/tracing"
 	"github.com/prometheus/prometheus/model/labels"
 	"github.com/prometheus/prometheus/storage"
 	"github.com/prometheus/prometheus/util/annotations"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// OnQueryFailureStrategy decides what happens with the response of a group when some of its
+// queriers fail. It receives the merged response along with the outcome of each querier.
 type OnQueryFailureStrategy interface {
-	ForSeriesSet(storage.SeriesSet) storage.SeriesSet
-	ForLabels([]string, error) ([]string, error)
+	ForSeriesSet(storage.SeriesSet, []domain.QuerierOutcome) storage.SeriesSet
+	ForLabels([]string, annotations.Annotations, []domain.QuerierOutcome) ([]string, annotations.Annotations, error)
+}
+
+// GroupReader sends the queries of a group to its queriers. It decides which queriers are
+// called and how their answers are combined, returning the combined answer along with the
+// outcome of the queriers that were called.
+type GroupReader interface {
+	SelectWithOutcomes(
+		ctx context.Context, sortSeries bool, hints *storage.SelectHints, matchers ...*labels.Matcher,
+	) (storage.SeriesSet, []domain.QuerierOutcome)
+	LabelValuesWithOutcomes(
+		ctx context.Context, name string, hints *storage.LabelHints, matchers ...*labels.Matcher,
+	) ([]string, annotations.Annotations, []domain.QuerierOutcome, error)
+	LabelNamesWithOutcomes(
+		ctx context.Context, hints *storage.LabelHints, matchers ...*labels.Matcher,
+	) ([]string, annotations.Annotations, []domain.QuerierOutcome, error)
+	Close() error
 }
 
 // A group (array) of remote storage queriers. It should be possible to use it interchangeably
 // where a simpler Remote is used.
 type RemoteGroup struct {
-	Name              string
-	remoteStorages    []storage.Querier
-	remoteStoragesLen int
-	onQueryFailure    OnQueryFailureStrategy
-	logg              *slog.Logger
-	seriesSetMerger   MergeStrategy
+	Name           string
+	reader         GroupReader
+	onQueryFailure OnQueryFailureStrategy
+	logg           *slog.Logger
 }
 
+// NewRemoteGroup creates a group that sends every query to all of its remotes, merging the
+// answers with the given merge strategy.
 func NewRemoteGroup(logg *slog.Logger, name string, remoteStorages []storage.Querier,
 	onQueryFailure OnQueryFailureStrategy, mergeStrategy MergeStrategy) *RemoteGroup {
 
+	return NewRemoteGroupWithReader(logg, name, NewMergeQuerier(remoteStorages, mergeStrategy), onQueryFailure)
+}
+
+// NewRemoteGroupWithReader creates a group where the reader decides which remotes are queried.
+func NewRemoteGroupWithReader(logg *slog.Logger, name string, reader GroupReader,
+	onQueryFailure OnQueryFailureStrategy) *RemoteGroup {
+
 	return &RemoteGroup{
-		Name:              name,
-		remoteStorages:    remoteStorages,
-		remoteStoragesLen: len(remoteStorages),
-		logg:              logg.With("name", name, "component", "group"),
-		seriesSetMerger:   mergeStrategy,
-		onQueryFailure:    onQueryFailure,
+		Name:           name,
+		reader:         reader,
+		logg:           logg.With("name", name, "component", "group"),
+		onQueryFailure: onQueryFailure,
 	}
 }
 
@@ -45,19 +74,22 @@ func NewRemoteGroup(logg *slog.Logger, name string, remoteStorages []storage.Que
 func (rGroup *RemoteGroup) Select(
 	ctx context.Context, sortSeries bool, hints *storage.SelectHints, matchers ...*labels.Matcher,
 ) storage.SeriesSet {
-	mergeQuerier := NewMergeQuerier(rGroup.remoteStorages, rGroup.seriesSetMerger)
+	ctx, span := tracing.Start(ctx, "group.select", attribute.String("graviola.group", rGroup.Name))
+	defer span.End()
+
+	start := time.Now()
+	response, outcomes := rGroup.reader.SelectWithOutcomes(ctx, sortSeries, hints, matchers...)
+	querystats.FromContext(ctx).RecordGroupSelect(rGroup.Name, time.Since(start))
 
-	response := mergeQuerier.Select(ctx, sortSeries, hints, matchers...)
-	return rGroup.onQueryFailure.ForSeriesSet(response)
+	response = rGroup.onQueryFailure.ForSeriesSet(response, outcomes)
+	recordFailureStrategyDecision(span, outcomes, response.Err())
+	return response
 }
 
 // LabelQuerier
 // Close releases the resources of the Querier.
 func (rGroup *RemoteGroup) Close() error {
-	mergeQuerier := NewMergeQuerier(rGroup.remoteStorages, rGroup.seriesSetMerger)
-
-	response := mergeQuerier.Close()
-	return response
+	return rGroup.reader.Close()
 }
 
 // LabelQuerier
@@ -68,11 +100,8 @@ func (rGroup *RemoteGroup) Close() error {
 func (rGroup *RemoteGroup) LabelValues(
 	ctx context.Context, name string, hints *storage.LabelHints, matchers ...*labels.Matcher,
 ) ([]string, annotations.Annotations, error) {
-	mergeQuerier := NewMergeQuerier(rGroup.remoteStorages, rGroup.seriesSetMerger)
-
-	vals, annots, err := mergeQuerier.LabelValues(ctx, name, hints, matchers...)
-	vals, err = rGroup.onQueryFailure.ForLabels(vals, err)
-	return vals, annots, err
+	vals, annots, outcomes, _ := rGroup.reader.LabelValuesWithOutcomes(ctx, name, hints, matchers...)
+	return rGroup.onQueryFailure.ForLabels(vals, annots, outcomes)
 }
 
 // LabelQuerier
@@ -82,10 +111,32 @@ func (rGroup *RemoteGroup) LabelValues(
 func (rGroup *RemoteGroup) LabelNames(
 	ctx context.Context, hints *storage.LabelHints, matchers ...*labels.Matcher,
 ) ([]string, annotations.Annotations, error) {
-	mergeQuerier := NewMergeQuerier(rGroup.remoteStorages, rGroup.seriesSetMerger)
+	vals, annots, outcomes, _ := rGroup.reader.LabelNamesWithOutcomes(ctx, hints, matchers...)
+	return rGroup.onQueryFailure.ForLabels(vals, annots, outcomes)
+}
 
-	vals, annots, err := mergeQuerier.LabelNames(ctx, hints, matchers...)
-	vals, err = rGroup.onQueryFailure.ForLabels(vals, err)
+// recordFailureStrategyDecision adds to the span how many queriers failed and whether the
+// failure strategy answered with an error
+func recordFailureStrategyDecision(span trace.Span, outcomes []domain.QuerierOutcome, err error) {
+	failed := 0
+	for _, outcome := range outcomes {
+		if outcome.Err != nil {
+			failed++
+		}
+	}
+
+	decision := "success"
+	switch {
+	case err != nil:
+		decision = "failure"
+	case failed > 0:
+		decision = "partial_response"
+	}
 
-	return vals, annots, err
+	span.AddEvent("failure strategy decision", trace.WithAttributes(
+		attribute.Int("graviola.queriers", len(outcomes)),
+		attribute.Int("graviola.queriers_failed", failed),
+		attribute.String("graviola.decision", decision),
+	))
+	tracing.RecordError(span, err)
 }
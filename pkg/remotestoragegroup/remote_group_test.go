@@ -2,6 +2,7 @@ package remotestoragegroup_test
 
 import (
 	"context"
+	"errors"
 	"log/slog"
 	"math/rand"
 	"reflect"
@@ -18,14 +19,16 @@ import (
 	"github.com/prometheus/common/model"
 	"github.com/prometheus/prometheus/model/labels"
 	"github.com/prometheus/prometheus/storage"
+	"github.com/prometheus/prometheus/util/annotations"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 var logg *slog.Logger = graviolalog.NewLogger(config.LogConfig{Level: "error"})
 
 var defaultFailStrategy = &queryfailurestrategy.FailAllStrategy{}
-var defaultMergeStrategy = remotestoragegroup.MergeStrategyFactory(config.DefaultMergeStrategyType)
+var defaultMergeStrategy = remotestoragegroup.MergeStrategyFactory(config.MergeStrategyConfig{Strategy: config.DefaultMergeStrategyType}, nil)
 
 func TestCloseIsSentToRemotes(t *testing.T) {
 	mockStorage1 := &mocks.RemoteStorageMock{}
@@ -472,3 +475,121 @@ func TestConcurrentLabelValues(t *testing.T) {
 
 	assert.Equal(t, goroutinesTotal, counterOfResults, "all requests should have a return")
 }
+
+func TestQuorumFailureStrategyReceivesTheOutcomeOfEachRemote(t *testing.T) {
+	remoteErr := errors.New("remote is down")
+
+	healthyRemote := func() *mocks.RemoteStorageMock {
+		return &mocks.RemoteStorageMock{
+			SeriesSet: &domain.GraviolaSeriesSet{
+				Series: []*domain.GraviolaSeries{
+					{Lbs: labels.FromStrings("label1", "val1"),
+						Datapoints: []model.SamplePair{{Timestamp: 5819, Value: 5.9}}},
+				},
+			},
+		}
+	}
+
+	failingRemote := &mocks.RemoteStorageMock{
+		SelectFn: func(_ context.Context, _ bool, _ *storage.SelectHints, _ ...*labels.Matcher) storage.SeriesSet {
+			return &domain.GraviolaSeriesSet{Erro: remoteErr}
+		},
+		Error: remoteErr,
+	}
+
+	sut := remotestoragegroup.NewRemoteGroup(logg, "any name",
+		[]storage.Querier{healthyRemote(), failingRemote, healthyRemote()},
+		queryfailurestrategy.NewQuorumStrategy(2), defaultMergeStrategy)
+
+	response := sut.Select(context.Background(), true, &storage.SelectHints{})
+	require.NoError(t, response.Err(), "should not error when the quorum is reached")
+	require.Len(t, response.Warnings(), 1, "should warn about the failed remote")
+	for annotation := range response.Warnings() {
+		assert.Contains(t, annotation, "querier #1", "should name the failed remote by its position")
+	}
+
+	names, annots, err := sut.LabelNames(context.Background(), nil)
+	require.NoError(t, err, "should not error when the quorum is reached")
+	assert.Equal(t, []string{"label1"}, names, "should return the label names of the successful remotes")
+	assert.NotEmpty(t, annots, "should warn about the failed remote")
+
+	sut = remotestoragegroup.NewRemoteGroup(logg, "any name",
+		[]storage.Querier{healthyRemote(), failingRemote, failingRemote},
+		queryfailurestrategy.NewQuorumStrategy(2), defaultMergeStrategy)
+
+	response = sut.Select(context.Background(), true, &storage.SelectHints{})
+	require.ErrorIs(t, response.Err(), remoteErr, "should error when the quorum is not reached")
+
+	_, _, err = sut.LabelValues(context.Background(), "label1", nil)
+	require.ErrorIs(t, err, remoteErr, "should error when the quorum is not reached")
+}
+
+func TestRemoteAnnotationsAreAttributedToTheRemoteThatSentThem(t *testing.T) {
+	remoteAnnots := *annotations.New()
+	domain.AddRemoteAnnotation(&remoteAnnots, domain.NewRemoteWarning("same warning"))
+
+	newRemote := func() *mocks.RemoteStorageMock {
+		return &mocks.RemoteStorageMock{
+			SelectFn: func(_ context.Context, _ bool, _ *storage.SelectHints, _ ...*labels.Matcher) storage.SeriesSet {
+				return &domain.GraviolaSeriesSet{Annots: remoteAnnots}
+			},
+			Annots: remoteAnnots,
+		}
+	}
+
+	sut := remotestoragegroup.NewRemoteGroup(logg, "any group", []storage.Querier{newRemote(), newRemote()},
+		defaultFailStrategy, defaultMergeStrategy)
+
+	response := sut.Select(context.Background(), true, &storage.SelectHints{})
+	require.NoError(t, response.Err(), "should not error")
+	warnings, _ := response.Warnings().AsStrings("", 0, 0)
+	assert.Equal(t, []string{"querier #0, querier #1: same warning"}, warnings,
+		"should keep a single warning naming both remotes")
+
+	_, annots, err := sut.LabelNames(context.Background(), nil)
+	require.NoError(t, err, "should not error")
+	warnings, _ = annots.AsStrings("", 0, 0)
+	assert.Equal(t, []string{"querier #0, querier #1: same warning"}, warnings,
+		"should keep a single warning naming both remotes")
+}
+
+func TestSelectsAreTraced(t *testing.T) {
+	recorder, restore := mocks.RecordSpans()
+	defer restore()
+
+	remoteErr := errors.New("remote is down")
+	healthyRemote := &mocks.RemoteStorageMock{
+		SeriesSet: &domain.GraviolaSeriesSet{
+			Series: []*domain.GraviolaSeries{
+				{Lbs: labels.FromStrings("label1", "val1"),
+					Datapoints: []model.SamplePair{{Timestamp: 5819, Value: 5.9}}},
+			},
+		},
+	}
+	failingRemote := &mocks.RemoteStorageMock{
+		SelectFn: func(_ context.Context, _ bool, _ *storage.SelectHints, _ ...*labels.Matcher) storage.SeriesSet {
+			return &domain.GraviolaSeriesSet{Erro: remoteErr}
+		},
+	}
+
+	sut := remotestoragegroup.NewRemoteGroup(logg, "traced group",
+		[]storage.Querier{healthyRemote, failingRemote}, &queryfailurestrategy.PartialResponseStrategy{},
+		defaultMergeStrategy)
+	response := sut.Select(context.Background(), true, &storage.SelectHints{})
+	require.NoError(t, response.Err(), "should answer with a partial response")
+
+	groupSpan := mocks.SpanNamed(recorder, "group.select")
+	require.NotNil(t, groupSpan, "should record a span of the select")
+	assert.Contains(t, groupSpan.Attributes(), attribute.String("graviola.group", "traced group"),
+		"should have the name of the group")
+	require.Len(t, groupSpan.Events(), 1, "should record the decision of the failure strategy")
+	assert.Contains(t, groupSpan.Events()[0].Attributes, attribute.String("graviola.decision", "partial_response"),
+		"should record that the answer is partial")
+	assert.Contains(t, groupSpan.Events()[0].Attributes, attribute.Int("graviola.queriers_failed", 1),
+		"should record how many queriers failed")
+
+	mergeSpan := mocks.SpanNamed(recorder, "group.merge")
+	require.NotNil(t, mergeSpan, "should record a span of the merge")
+	assert.Equal(t, groupSpan.SpanContext().SpanID(), mergeSpan.Parent().SpanID(),
+		"should be a child of the select span")
+}
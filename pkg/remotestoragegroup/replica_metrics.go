@@ -0,0 +1,84 @@
+package remotestoragegroup
+
+import (
+	"context"
+	"sync"
+
+	"github.com/jademcosta/graviola/pkg/domain"
+	"github.com/jademcosta/graviola/pkg/o11y"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/prometheus/prometheus/util/annotations"
+)
+
+var runOnceReadModeO11y sync.Once
+var replicaServedTotal *prometheus.CounterVec
+
+// ReplicaMetricsReader wraps a GroupReader and records which queriers (replicas) of the group
+// answered each query successfully, based on the outcomes the wrapped reader returns.
+type ReplicaMetricsReader struct {
+	next      GroupReader
+	groupName string
+	readMode  string
+}
+
+func NewReplicaMetricsReader(
+	metricz *prometheus.Registry, groupName string, readMode string, next GroupReader,
+) *ReplicaMetricsReader {
+	runOnceReadModeO11y.Do(func() {
+		replicaServedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "graviola",
+			Subsystem: "group",
+			Name:      "replica_served_total",
+			Help:      "Counter of queries answered successfully by each remote of a group.",
+		},
+			[]string{"group_name", "read_mode", "replica", "operation"})
+
+		if metricz != nil {
+			metricz.MustRegister(replicaServedTotal)
+		}
+	})
+
+	return &ReplicaMetricsReader{next: next, groupName: groupName, readMode: readMode}
+}
+
+// GroupReader
+func (rmr *ReplicaMetricsReader) SelectWithOutcomes(
+	ctx context.Context, sortSeries bool, hints *storage.SelectHints, matchers ...*labels.Matcher,
+) (storage.SeriesSet, []domain.QuerierOutcome) {
+	seriesSet, outcomes := rmr.next.SelectWithOutcomes(ctx, sortSeries, hints, matchers...)
+	rmr.served(outcomes, o11y.OperationSelect)
+	return seriesSet, outcomes
+}
+
+// GroupReader
+func (rmr *ReplicaMetricsReader) LabelValuesWithOutcomes(
+	ctx context.Context, name string, hints *storage.LabelHints, matchers ...*labels.Matcher,
+) ([]string, annotations.Annotations, []domain.QuerierOutcome, error) {
+	values, annots, outcomes, err := rmr.next.LabelValuesWithOutcomes(ctx, name, hints, matchers...)
+	rmr.served(outcomes, o11y.OperationLabelValues)
+	return values, annots, outcomes, err
+}
+
+// GroupReader
+func (rmr *ReplicaMetricsReader) LabelNamesWithOutcomes(
+	ctx context.Context, hints *storage.LabelHints, matchers ...*labels.Matcher,
+) ([]string, annotations.Annotations, []domain.QuerierOutcome, error) {
+	names, annots, outcomes, err := rmr.next.LabelNamesWithOutcomes(ctx, hints, matchers...)
+	rmr.served(outcomes, o11y.OperationLabelNames)
+	return names, annots, outcomes, err
+}
+
+// GroupReader
+func (rmr *ReplicaMetricsReader) Close() error {
+	return rmr.next.Close()
+}
+
+func (rmr *ReplicaMetricsReader) served(outcomes []domain.QuerierOutcome, operation string) {
+	for _, outcome := range outcomes {
+		if !outcome.Failed() {
+			replicaServedTotal.WithLabelValues(rmr.groupName, rmr.readMode, outcome.Name, operation).Inc()
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package remotestoragegroup
+
+import (
+	"context"
+	"hash/fnv"
+	"slices"
+	"sync"
+	"sync/atomic"
+
+	"github.com/jademcosta/graviola/pkg/clientinfo"
+	"github.com/jademcosta/graviola/pkg/domain"
+	"github.com/jademcosta/graviola/pkg/querystats"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/prometheus/prometheus/util/annotations"
+)
+
+type picksContextKey struct{}
+
+// RoundRobinQuerier sends each query to a single querier, rotating between them. When sticky is
+// enabled, queries of the same client are always sent to the same querier, so that dashboards
+// don't change between refreshes because of small differences between replicas. Queries that
+// have no client info fall back to the rotation.
+// A query can make many selects and label requests. When its context has Picks, all of them go to
+// the querier picked by the first one, so its series don't come from different replicas.
+type RoundRobinQuerier struct {
+	queriers []storage.Querier
+	names    []string
+	sticky   bool
+	next     atomic.Uint64
+}
+
+// Picks are the queriers picked by the round robin queriers for a single query
+type Picks struct {
+	mu    sync.Mutex
+	picks map[*RoundRobinQuerier]int
+}
+
+func NewPicks() *Picks {
+	return &Picks{picks: make(map[*RoundRobinQuerier]int)}
+}
+
+// NewPicksContext returns a context on which the round robin queriers keep using the queriers
+// they already picked
+func NewPicksContext(ctx context.Context, picks *Picks) context.Context {
+	return context.WithValue(ctx, picksContextKey{}, picks)
+}
+
+func NewRoundRobinQuerier(queriers []storage.Querier, sticky bool) *RoundRobinQuerier {
+	return &RoundRobinQuerier{
+		queriers: queriers,
+		names:    querierNames(queriers),
+		sticky:   sticky,
+	}
+}
+
+// GroupReader
+func (rrq *RoundRobinQuerier) SelectWithOutcomes(
+	ctx context.Context, sortSeries bool, hints *storage.SelectHints, matchers ...*labels.Matcher,
+) (storage.SeriesSet, []domain.QuerierOutcome) {
+	if len(rrq.queriers) == 0 {
+		return storage.NoopSeriesSet(), []domain.QuerierOutcome{}
+	}
+
+	idx := rrq.pick(ctx)
+	rrq.recordNotPicked(ctx, idx)
+	response := attributeSeriesSet(rrq.queriers[idx].Select(ctx, sortSeries, hints, matchers...), rrq.names[idx])
+	return response, []domain.QuerierOutcome{{Name: rrq.names[idx], Err: response.Err()}}
+}
+
+// GroupReader
+func (rrq *RoundRobinQuerier) LabelValuesWithOutcomes(
+	ctx context.Context, name string, hints *storage.LabelHints, matchers ...*labels.Matcher,
+) ([]string, annotations.Annotations, []domain.QuerierOutcome, error) {
+	if len(rrq.queriers) == 0 {
+		return []string{}, map[string]error{}, []domain.QuerierOutcome{}, nil
+	}
+
+	idx := rrq.pick(ctx)
+	rrq.recordNotPicked(ctx, idx)
+	values, annots, err := rrq.queriers[idx].LabelValues(ctx, name, hints, matchers...)
+	outcomes := []domain.QuerierOutcome{{Name: rrq.names[idx], Err: err}}
+	return values, domain.AttributeAnnotations(annots, rrq.names[idx]), outcomes, err
+}
+
+// GroupReader
+func (rrq *RoundRobinQuerier) LabelNamesWithOutcomes(
+	ctx context.Context, hints *storage.LabelHints, matchers ...*labels.Matcher,
+) ([]string, annotations.Annotations, []domain.QuerierOutcome, error) {
+	if len(rrq.queriers) == 0 {
+		return []string{}, map[string]error{}, []domain.QuerierOutcome{}, nil
+	}
+
+	idx := rrq.pick(ctx)
+	rrq.recordNotPicked(ctx, idx)
+	names, annots, err := rrq.queriers[idx].LabelNames(ctx, hints, matchers...)
+	outcomes := []domain.QuerierOutcome{{Name: rrq.names[idx], Err: err}}
+	return names, domain.AttributeAnnotations(annots, rrq.names[idx]), outcomes, err
+}
+
+// LabelQuerier
+// Close releases the resources of the Querier.
+func (rrq *RoundRobinQuerier) Close() error {
+	return closeAll(rrq.queriers)
+}
+
+// recordNotPicked registers on the stats of the query that the queriers other than the picked
+// one were not contacted
+func (rrq *RoundRobinQuerier) recordNotPicked(ctx context.Context, picked int) {
+	notPicked := append(slices.Clone(rrq.names[:picked]), rrq.names[picked+1:]...)
+	recordSkipped(ctx, notPicked, querystats.SkipReasonRoundRobin)
+}
+
+// pick returns the index of the querier that should answer the query, which is the one already
+// picked for it when the context has the picks of the query
+func (rrq *RoundRobinQuerier) pick(ctx context.Context) int {
+	picks, ok := ctx.Value(picksContextKey{}).(*Picks)
+	if !ok {
+		return rrq.nextPick(ctx)
+	}
+
+	picks.mu.Lock()
+	defer picks.mu.Unlock()
+
+	idx, picked := picks.picks[rrq]
+	if !picked {
+		idx = rrq.nextPick(ctx)
+		picks.picks[rrq] = idx
+	}
+	return idx
+}
+
+func (rrq *RoundRobinQuerier) nextPick(ctx context.Context) int {
+	if rrq.sticky {
+		info, ok := clientinfo.FromContext(ctx)
+		if ok && info.Key() != "" {
+			hash := fnv.New64a()
+			_, _ = hash.Write([]byte(info.Key()))
+			return int(hash.Sum64() % uint64(len(rrq.queriers)))
+		}
+	}
+
+	return int((rrq.next.Add(1) - 1) % uint64(len(rrq.queriers)))
+}
@@ -0,0 +1,94 @@
+package remotestoragegroup_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jademcosta/graviola/internal/mocks"
+	"github.com/jademcosta/graviola/pkg/clientinfo"
+	"github.com/jademcosta/graviola/pkg/domain"
+	"github.com/jademcosta/graviola/pkg/querystats"
+	"github.com/jademcosta/graviola/pkg/remotestoragegroup"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoundRobinRotatesBetweenQueriers(t *testing.T) {
+	querier1 := &mocks.RemoteStorageMock{SeriesSet: &domain.GraviolaSeriesSet{}}
+	querier2 := &mocks.RemoteStorageMock{SeriesSet: &domain.GraviolaSeriesSet{}}
+
+	sut := remotestoragegroup.NewRoundRobinQuerier([]storage.Querier{querier1, querier2}, false)
+
+	for range 4 {
+		response, outcomes := sut.SelectWithOutcomes(context.Background(), true, &storage.SelectHints{})
+		require.NoError(t, response.Err(), "should not error")
+		assert.Len(t, outcomes, 1, "should only inform the outcome of the querier that was called")
+	}
+
+	collector := querystats.NewCollector()
+	_, _, outcomes, err := sut.LabelNamesWithOutcomes(querystats.NewContext(context.Background(), collector), nil)
+	require.NoError(t, err, "should not error")
+	assert.Equal(t, []domain.QuerierOutcome{{Name: "querier #0"}}, outcomes, "should continue the rotation")
+	assert.Equal(t, []querystats.RemoteStats{
+		{Name: "querier #1", Skipped: true, SkipReason: querystats.SkipReasonRoundRobin},
+	}, collector.Summary().Remotes, "should record the querier not picked as skipped")
+
+	assert.Len(t, querier1.CalledWithHints, 2, "should call each querier half of the time")
+	assert.Len(t, querier2.CalledWithHints, 2, "should call each querier half of the time")
+}
+
+func TestRoundRobinStickyAlwaysSendsTheSameClientToTheSameQuerier(t *testing.T) {
+	querier1 := &mocks.RemoteStorageMock{SeriesSet: &domain.GraviolaSeriesSet{}}
+	querier2 := &mocks.RemoteStorageMock{SeriesSet: &domain.GraviolaSeriesSet{}}
+
+	sut := remotestoragegroup.NewRoundRobinQuerier([]storage.Querier{querier1, querier2}, true)
+	ctx := clientinfo.NewContext(context.Background(), clientinfo.Info{Address: "10.0.0.1"})
+
+	var firstOutcomes []domain.QuerierOutcome
+	for idx := range 4 {
+		_, outcomes := sut.SelectWithOutcomes(ctx, true, &storage.SelectHints{})
+		if idx == 0 {
+			firstOutcomes = outcomes
+		}
+		assert.Equal(t, firstOutcomes, outcomes, "should always call the same querier for the same client")
+	}
+
+	assert.Len(t, append(querier1.CalledWithHints, querier2.CalledWithHints...), 4, "should have answered all queries")
+	assert.True(t, len(querier1.CalledWithHints) == 0 || len(querier2.CalledWithHints) == 0,
+		"should have called a single querier")
+}
+
+func TestRoundRobinReturnsTheErrorOfTheCalledQuerier(t *testing.T) {
+	errQuerier := errors.New("querier is down")
+	querier := &mocks.RemoteStorageMock{Error: errQuerier}
+
+	sut := remotestoragegroup.NewRoundRobinQuerier([]storage.Querier{querier}, false)
+
+	_, _, outcomes, err := sut.LabelValuesWithOutcomes(context.Background(), "any", nil)
+	require.ErrorIs(t, err, errQuerier, "should return the querier error")
+	assert.Equal(t, []domain.QuerierOutcome{{Name: "querier #0", Err: errQuerier}}, outcomes,
+		"should inform the failed outcome")
+}
+
+func TestRoundRobinUsesTheSameQuerierForAllTheRequestsOfAQuery(t *testing.T) {
+	querier1 := &mocks.RemoteStorageMock{SeriesSet: &domain.GraviolaSeriesSet{}}
+	querier2 := &mocks.RemoteStorageMock{SeriesSet: &domain.GraviolaSeriesSet{}}
+
+	sut := remotestoragegroup.NewRoundRobinQuerier([]storage.Querier{querier1, querier2}, false)
+	ctx := remotestoragegroup.NewPicksContext(context.Background(), remotestoragegroup.NewPicks())
+
+	for range 3 {
+		_, outcomes := sut.SelectWithOutcomes(ctx, true, &storage.SelectHints{})
+		assert.Equal(t, []domain.QuerierOutcome{{Name: "querier #0"}}, outcomes,
+			"should call the querier picked by the first select of the query")
+	}
+	_, _, outcomes, err := sut.LabelNamesWithOutcomes(ctx, nil)
+	require.NoError(t, err, "should not error")
+	assert.Equal(t, []domain.QuerierOutcome{{Name: "querier #0"}}, outcomes,
+		"should call the querier picked by the first select of the query")
+
+	_, outcomes = sut.SelectWithOutcomes(context.Background(), true, &storage.SelectHints{})
+	assert.Equal(t, []domain.QuerierOutcome{{Name: "querier #1"}}, outcomes, "should rotate on the next query")
+}
@@ -0,0 +1,215 @@
+package resultscache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/jademcosta/graviola/pkg/authz"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/promql/parser"
+	"github.com/prometheus/prometheus/storage"
+)
+
+const (
+	resultHit        = "hit"
+	resultPartialHit = "partial_hit"
+	resultMiss       = "miss"
+)
+
+var errNotCacheable = errors.New("result cannot be cached")
+
+var runOnceEngineO11y sync.Once
+var cacheRequestsTotal *prometheus.CounterVec
+
+// Accountant is implemented by the engines that account the queries they execute, with a
+// concurrency slot, limits and an entry on the query log. When the wrapped engine is one, the
+// queries answered only by the cache are accounted by it too, so they are visible like the others.
+type Accountant interface {
+	Account(query promql.Query) promql.Query
+}
+
+// CachingEngine keeps the results of range queries, so the same query (with the same step)
+// doesn't need to fetch again the data it already fetched. Only the missing head or tail of
+// the requested range is sent to the wrapped engine. The most recent data (inside the
+// maxFreshness window) is never cached, as remotes might still be receiving it.
+// The start and end of the range queries that can be cached are aligned to the step, so they
+// can be reused.
+// Instant queries are sent straight to the wrapped engine.
+type CachingEngine struct {
+	logg         *slog.Logger
+	next         promql.QueryEngine
+	backend      Backend
+	maxFreshness time.Duration
+	now          func() time.Time
+}
+
+func NewCachingEngine(
+	logg *slog.Logger, metricz *prometheus.Registry, next promql.QueryEngine, backend Backend,
+	maxFreshness time.Duration, now func() time.Time,
+) *CachingEngine {
+	runOnceEngineO11y.Do(func() {
+		cacheRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "graviola",
+			Subsystem: "results_cache",
+			Name:      "requests_total",
+			Help:      "Counter of range queries that looked for results on the cache, by result (hit, partial_hit or miss).",
+		},
+			[]string{"result"})
+
+		if metricz != nil {
+			metricz.MustRegister(cacheRequestsTotal)
+		}
+	})
+
+	return &CachingEngine{
+		logg:         logg.With("component", "results_cache"),
+		next:         next,
+		backend:      backend,
+		maxFreshness: maxFreshness,
+		now:          now,
+	}
+}
+
+// QueryEngine
+func (engine *CachingEngine) NewInstantQuery(
+	ctx context.Context, queryable storage.Queryable, opts promql.QueryOpts, qs string, ts time.Time,
+) (promql.Query, error) {
+	return engine.next.NewInstantQuery(ctx, queryable, opts, qs, ts)
+}
+
+// QueryEngine
+func (engine *CachingEngine) NewRangeQuery(
+	ctx context.Context, queryable storage.Queryable, opts promql.QueryOpts, qs string, start, end time.Time,
+	interval time.Duration,
+) (promql.Query, error) {
+	step := interval.Milliseconds()
+	if step <= 0 {
+		return engine.next.NewRangeQuery(ctx, queryable, opts, qs, start, end, interval)
+	}
+
+	// The errors of invalid queries are left for the wrapped engine to tell
+	expr, err := parser.ParseExpr(qs)
+	if err != nil || !isCacheable(expr) {
+		return engine.next.NewRangeQuery(ctx, queryable, opts, qs, start, end, interval)
+	}
+
+	alignedStart := alignToStep(start.UnixMilli(), step)
+	alignedEnd := alignToStep(end.UnixMilli(), step)
+
+	fullQuery, err := engine.next.NewRangeQuery(
+		ctx, queryable, opts, qs, time.UnixMilli(alignedStart), time.UnixMilli(alignedEnd), interval)
+	if err != nil {
+		return nil, err
+	}
+
+	return &cachedRangeQuery{
+		engine:    engine,
+		fullQuery: fullQuery,
+		queryable: queryable,
+		opts:      opts,
+		qs:        qs,
+		start:     alignedStart,
+		end:       alignedEnd,
+		step:      step,
+		key:       cacheKey(ctx, qs, step, opts),
+	}, nil
+}
+
+// fetchExtents returns the cached extents of the key. When the policy of the client has a max
+// lookback, the parts of the extents older than it are left out, as they were cached when that
+// data could still be read.
+func (engine *CachingEngine) fetchExtents(ctx context.Context, key string, step int64) []extent {
+	data, ok := engine.backend.Fetch(ctx, key)
+	if !ok {
+		return []extent{}
+	}
+
+	extents, err := decodeExtents(data)
+	if err != nil {
+		engine.logg.Warn("unable to decode cached results", "error", err)
+		return []extent{}
+	}
+
+	if policy := authz.FromContext(ctx); policy != nil && policy.MaxLookback > 0 {
+		minAllowed := engine.now().Add(-policy.MaxLookback).UnixMilli()
+		extents = trimExtents(extents, alignToStep(minAllowed+step-1, step))
+	}
+
+	return extents
+}
+
+// storeExtent saves the new extent, replacing the ones it covers. Only the part of the extent
+// that is older than the max freshness is saved.
+func (engine *CachingEngine) storeExtent(ctx context.Context, key string, extents []extent, newExt extent, step int64) {
+	cacheableEnd := alignToStep(engine.now().Add(-engine.maxFreshness).UnixMilli(), step)
+	if newExt.Start > cacheableEnd {
+		return
+	}
+
+	if newExt.End > cacheableEnd {
+		newExt = newExtent(newExt.toMatrix(newExt.Start, cacheableEnd), newExt.Start, cacheableEnd)
+	}
+
+	updated := make([]extent, 0, len(extents)+1)
+	for _, ext := range extents {
+		if ext.Start >= newExt.Start && ext.End <= newExt.End {
+			continue
+		}
+		updated = append(updated, ext)
+	}
+	updated = append(updated, newExt)
+
+	data, err := encodeExtents(updated)
+	if err != nil {
+		engine.logg.Warn("unable to encode results to cache", "error", err)
+		return
+	}
+
+	engine.backend.Store(ctx, key, data)
+}
+
+// cacheKey identifies the results of the query. Clients with different authorization policies
+// see different data for the same query, so the policy is part of the key.
+func cacheKey(ctx context.Context, qs string, step int64, opts promql.QueryOpts) string {
+	var lookbackDelta time.Duration
+	if opts != nil {
+		lookbackDelta = opts.LookbackDelta()
+	}
+
+	key := fmt.Sprintf("%s:%d:%d", qs, step, lookbackDelta.Milliseconds())
+	if policy := authz.FromContext(ctx); policy != nil {
+		key = "policy=" + policy.Name + ":" + key
+	}
+
+	return key
+}
+
+func alignToStep(timestamp int64, step int64) int64 {
+	return timestamp - (timestamp % step)
+}
+
+// isCacheable checks that the query doesn't use the @ modifier, as its result changes
+// depending on the start and end of the query.
+func isCacheable(expr parser.Expr) bool {
+	cacheable := true
+	parser.Inspect(expr, func(node parser.Node, _ []parser.Node) error {
+		switch n := node.(type) {
+		case *parser.VectorSelector:
+			if n.Timestamp != nil || n.StartOrEnd != 0 {
+				cacheable = false
+			}
+		case *parser.SubqueryExpr:
+			if n.Timestamp != nil || n.StartOrEnd != 0 {
+				cacheable = false
+			}
+		}
+		return nil
+	})
+
+	return cacheable
+}
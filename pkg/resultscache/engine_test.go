@@ -0,0 +1,267 @@
+package resultscache_test
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jademcosta/graviola/pkg/authz"
+	"github.com/jademcosta/graviola/pkg/config"
+	"github.com/jademcosta/graviola/pkg/graviolalog"
+	"github.com/jademcosta/graviola/pkg/resultscache"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/promql/parser"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/prometheus/prometheus/util/stats"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var logg *slog.Logger = graviolalog.NewLogger(config.LogConfig{Level: "error"})
+
+const step = time.Minute
+
+type executedRange struct {
+	start time.Time
+	end   time.Time
+}
+
+// fakeEngine answers range queries with a single series, where the value of each point is its
+// timestamp in seconds
+type fakeEngine struct {
+	executed []executedRange
+	mu       sync.Mutex
+}
+
+func (eng *fakeEngine) NewInstantQuery(
+	_ context.Context, _ storage.Queryable, _ promql.QueryOpts, _ string, _ time.Time,
+) (promql.Query, error) {
+	panic("not used")
+}
+
+func (eng *fakeEngine) NewRangeQuery(
+	_ context.Context, _ storage.Queryable, _ promql.QueryOpts, qs string, start, end time.Time,
+	interval time.Duration,
+) (promql.Query, error) {
+	expr, err := parser.ParseExpr(qs)
+	if err != nil {
+		return nil, err
+	}
+	return &fakeQuery{engine: eng, expr: expr, start: start, end: end, interval: interval}, nil
+}
+
+func (eng *fakeEngine) executedRanges() []executedRange {
+	eng.mu.Lock()
+	defer eng.mu.Unlock()
+	return eng.executed
+}
+
+type fakeQuery struct {
+	engine   *fakeEngine
+	expr     parser.Expr
+	start    time.Time
+	end      time.Time
+	interval time.Duration
+}
+
+func (query *fakeQuery) Exec(_ context.Context) *promql.Result {
+	query.engine.mu.Lock()
+	query.engine.executed = append(query.engine.executed, executedRange{start: query.start, end: query.end})
+	query.engine.mu.Unlock()
+
+	points := make([]promql.FPoint, 0)
+	for ts := query.start; !ts.After(query.end); ts = ts.Add(query.interval) {
+		points = append(points, promql.FPoint{T: ts.UnixMilli(), F: float64(ts.Unix())})
+	}
+
+	return &promql.Result{Value: promql.Matrix{{Metric: labels.FromStrings("__name__", "up"), Floats: points}}}
+}
+
+func (query *fakeQuery) Close()                      {}
+func (query *fakeQuery) Statement() parser.Statement { return &parser.EvalStmt{Expr: query.expr} }
+func (query *fakeQuery) Stats() *stats.Statistics    { return nil }
+func (query *fakeQuery) Cancel()                     {}
+func (query *fakeQuery) String() string              { return query.expr.String() }
+
+func newSut(inner *fakeEngine, maxFreshness time.Duration, now time.Time) *resultscache.CachingEngine {
+	return resultscache.NewCachingEngine(logg, nil, inner, resultscache.NewInMemoryLRUBackend(nil, 1024*1024),
+		maxFreshness, func() time.Time { return now })
+}
+
+func execRange(t *testing.T, sut promql.QueryEngine, qs string, start, end time.Time) promql.Matrix {
+	query, err := sut.NewRangeQuery(context.Background(), nil, nil, qs, start, end, step)
+	require.NoError(t, err, "should create the query")
+	defer query.Close()
+
+	result := query.Exec(context.Background())
+	require.NoError(t, result.Err, "should execute the query")
+	matrix, err := result.Matrix()
+	require.NoError(t, err, "should return a matrix")
+	return matrix
+}
+
+func TestCachingEngineAnswersRepeatedQueriesFromTheCache(t *testing.T) {
+	inner := &fakeEngine{}
+	sut := newSut(inner, 0, time.Unix(3600, 0))
+
+	start := time.Unix(0, 0)
+	end := time.Unix(600, 0)
+	first := execRange(t, sut, "up", start, end)
+	second := execRange(t, sut, "up", start, end)
+
+	assert.Equal(t, first, second, "should return the same result")
+	assert.Len(t, inner.executedRanges(), 1, "should only execute the query once")
+	require.Len(t, second, 1, "should return the series")
+	assert.Len(t, second[0].Floats, 11, "should return all the points")
+}
+
+func TestCachingEngineOnlyFetchesTheMissingTail(t *testing.T) {
+	inner := &fakeEngine{}
+	sut := newSut(inner, 0, time.Unix(3600, 0))
+
+	execRange(t, sut, "up", time.Unix(0, 0), time.Unix(600, 0))
+	result := execRange(t, sut, "up", time.Unix(300, 0), time.Unix(1200, 0))
+
+	require.Len(t, inner.executedRanges(), 2, "should execute the query for the missing part")
+	assert.Equal(t, executedRange{start: time.Unix(660, 0), end: time.Unix(1200, 0)}, inner.executedRanges()[1],
+		"should only fetch the missing tail")
+
+	require.Len(t, result, 1, "should return the series")
+	assert.Len(t, result[0].Floats, 16, "should return the cached and the fetched points")
+	for idx, point := range result[0].Floats {
+		assert.Equal(t, float64(300+idx*60), point.F, "should return the points in order")
+	}
+
+	execRange(t, sut, "up", time.Unix(0, 0), time.Unix(1200, 0))
+	assert.Len(t, inner.executedRanges(), 2, "should have cached the merged extent")
+}
+
+func TestCachingEngineDoesNotCacheFreshData(t *testing.T) {
+	inner := &fakeEngine{}
+	sut := newSut(inner, 2*time.Minute, time.Unix(600, 0))
+
+	execRange(t, sut, "up", time.Unix(0, 0), time.Unix(600, 0))
+	result := execRange(t, sut, "up", time.Unix(0, 0), time.Unix(600, 0))
+
+	require.Len(t, inner.executedRanges(), 2, "should execute the query again for the fresh data")
+	assert.Equal(t, executedRange{start: time.Unix(540, 0), end: time.Unix(600, 0)}, inner.executedRanges()[1],
+		"should only fetch the data inside the max freshness window")
+	assert.Len(t, result[0].Floats, 11, "should return all the points")
+}
+
+func TestCachingEngineAlignsTheRangeToTheStep(t *testing.T) {
+	inner := &fakeEngine{}
+	sut := newSut(inner, 0, time.Unix(3600, 0))
+
+	execRange(t, sut, "up", time.Unix(10, 0), time.Unix(610, 0))
+
+	assert.Equal(t, executedRange{start: time.Unix(0, 0), end: time.Unix(600, 0)}, inner.executedRanges()[0],
+		"should align the start and end to the step")
+}
+
+func TestCachingEngineDoesNotCacheQueriesWithTheAtModifier(t *testing.T) {
+	inner := &fakeEngine{}
+	sut := newSut(inner, 0, time.Unix(3600, 0))
+
+	execRange(t, sut, "up @ 100", time.Unix(0, 0), time.Unix(600, 0))
+	execRange(t, sut, "up @ 100", time.Unix(0, 0), time.Unix(600, 0))
+
+	assert.Len(t, inner.executedRanges(), 2, "should not cache the query")
+}
+
+func TestCachingEngineKeepsDifferentStepsApart(t *testing.T) {
+	inner := &fakeEngine{}
+	sut := newSut(inner, 0, time.Unix(3600, 0))
+
+	execRange(t, sut, "up", time.Unix(0, 0), time.Unix(600, 0))
+
+	query, err := sut.NewRangeQuery(context.Background(), nil, nil, "up", time.Unix(0, 0), time.Unix(600, 0), 2*step)
+	require.NoError(t, err, "should create the query")
+	result := query.Exec(context.Background())
+	require.NoError(t, result.Err, "should execute the query")
+
+	assert.Len(t, inner.executedRanges(), 2, "should not reuse the results of another step")
+}
+
+func TestCachingEngineKeepsDifferentAuthorizationPoliciesApart(t *testing.T) {
+	inner := &fakeEngine{}
+	sut := newSut(inner, 0, time.Unix(3600, 0))
+
+	start := time.Unix(0, 0)
+	end := time.Unix(600, 0)
+	for _, policyName := range []string{"payments", "checkout", "payments"} {
+		ctx := authz.NewContext(context.Background(), &authz.Policy{Name: policyName})
+		query, err := sut.NewRangeQuery(ctx, nil, nil, "up", start, end, step)
+		require.NoError(t, err, "should create the query")
+		result := query.Exec(ctx)
+		require.NoError(t, result.Err, "should execute the query")
+		query.Close()
+	}
+
+	assert.Len(t, inner.executedRanges(), 2,
+		"should not answer a client with the cached results of another authorization policy")
+}
+
+func TestCachingEngineDoesNotAnswerFromTheCacheWhatIsOlderThanTheMaxLookback(t *testing.T) {
+	inner := &fakeEngine{}
+	now := time.Unix(3600, 0)
+	sut := resultscache.NewCachingEngine(logg, nil, inner, resultscache.NewInMemoryLRUBackend(nil, 1024*1024),
+		0, func() time.Time { return now })
+
+	ctx := authz.NewContext(context.Background(), &authz.Policy{Name: "payments", MaxLookback: time.Hour})
+	for _, current := range []time.Time{time.Unix(3600, 0), time.Unix(3900, 0)} {
+		now = current
+		query, err := sut.NewRangeQuery(ctx, nil, nil, "up", time.Unix(0, 0), time.Unix(600, 0), step)
+		require.NoError(t, err, "should create the query")
+		result := query.Exec(ctx)
+		require.NoError(t, result.Err, "should execute the query")
+		query.Close()
+	}
+
+	require.Len(t, inner.executedRanges(), 2, "should execute the query again for the data beyond the lookback")
+	assert.Equal(t, executedRange{start: time.Unix(0, 0), end: time.Unix(240, 0)}, inner.executedRanges()[1],
+		"should not use the cached points older than the max lookback")
+}
+
+func TestCachingEngineOnlyAlignsTheQueriesItCaches(t *testing.T) {
+	inner := &fakeEngine{}
+	sut := newSut(inner, 0, time.Unix(3600, 0))
+
+	execRange(t, sut, "up @ 100", time.Unix(10, 0), time.Unix(610, 0))
+
+	assert.Equal(t, executedRange{start: time.Unix(10, 0), end: time.Unix(610, 0)}, inner.executedRanges()[0],
+		"should not change the range of queries that are not cached")
+}
+
+// accountingEngine records the queries it was asked to account
+type accountingEngine struct {
+	*fakeEngine
+	accounted []promql.Query
+}
+
+func (eng *accountingEngine) Account(query promql.Query) promql.Query {
+	eng.accounted = append(eng.accounted, query)
+	return query
+}
+
+func TestCachingEngineHitsAreAccountedByTheWrappedEngine(t *testing.T) {
+	inner := &accountingEngine{fakeEngine: &fakeEngine{}}
+	sut := resultscache.NewCachingEngine(logg, nil, inner, resultscache.NewInMemoryLRUBackend(nil, 1024*1024),
+		0, func() time.Time { return time.Unix(3600, 0) })
+
+	execRange(t, sut, "up", time.Unix(0, 0), time.Unix(600, 0))
+	assert.Empty(t, inner.accounted, "should leave the misses to the wrapped engine")
+
+	query, err := sut.NewRangeQuery(context.Background(), nil, nil, "up", time.Unix(0, 0), time.Unix(600, 0), step)
+	require.NoError(t, err, "should create the query")
+	defer query.Close()
+	require.NoError(t, query.Exec(context.Background()).Err, "should execute the query")
+
+	assert.Len(t, inner.executedRanges(), 1, "should answer from the cache")
+	assert.Len(t, inner.accounted, 1, "should have the hit accounted by the wrapped engine")
+	require.NotNil(t, query.Stats(), "should have stats of the hit")
+	assert.Equal(t, int64(11), query.Stats().Samples.TotalSamples, "should count the samples answered by the cache")
+}
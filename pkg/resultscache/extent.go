@@ -0,0 +1,128 @@
+package resultscache
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/gob"
+	"slices"
+	"sort"
+
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql"
+)
+
+// extent is the result of a range query between Start and End (both inclusive, in millis).
+type extent struct {
+	Start  int64
+	End    int64
+	Series []cachedSeries
+}
+
+type cachedSeries struct {
+	Labels map[string]string
+	Points []promql.FPoint
+}
+
+func encodeExtents(extents []extent) ([]byte, error) {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(extents)
+	return buf.Bytes(), err
+}
+
+func decodeExtents(data []byte) ([]extent, error) {
+	extents := make([]extent, 0)
+	err := gob.NewDecoder(bytes.NewReader(data)).Decode(&extents)
+	return extents, err
+}
+
+// newExtent copies the points of the matrix between start and end into a new extent. The
+// points are copied because the engine reuses them after the query is closed.
+func newExtent(matrix promql.Matrix, start, end int64) extent {
+	ext := extent{Start: start, End: end, Series: make([]cachedSeries, 0, len(matrix))}
+	for _, series := range matrix {
+		points := pointsBetween(series.Floats, start, end)
+		if len(points) == 0 {
+			continue
+		}
+		ext.Series = append(ext.Series, cachedSeries{Labels: series.Metric.Map(), Points: points})
+	}
+
+	return ext
+}
+
+// toMatrix returns the points of the extent between start and end
+func (ext extent) toMatrix(start, end int64) promql.Matrix {
+	matrix := make(promql.Matrix, 0, len(ext.Series))
+	for _, series := range ext.Series {
+		points := pointsBetween(series.Points, start, end)
+		if len(points) == 0 {
+			continue
+		}
+		matrix = append(matrix, promql.Series{Metric: labels.FromMap(series.Labels), Floats: points})
+	}
+
+	return matrix
+}
+
+// trimExtents removes the points older than start from the extents, leaving out the extents
+// that have no points after it
+func trimExtents(extents []extent, start int64) []extent {
+	trimmed := make([]extent, 0, len(extents))
+	for _, ext := range extents {
+		if ext.End < start {
+			continue
+		}
+		if ext.Start < start {
+			ext = newExtent(ext.toMatrix(start, ext.End), start, ext.End)
+		}
+		trimmed = append(trimmed, ext)
+	}
+
+	return trimmed
+}
+
+func (ext extent) overlap(start, end int64) int64 {
+	return min(ext.End, end) - max(ext.Start, start)
+}
+
+// mergeMatrixes joins the series with the same labels, keeping the points sorted by time. When
+// the same timestamp exists on more than one matrix, the first one is kept.
+func mergeMatrixes(matrixes ...promql.Matrix) promql.Matrix {
+	merged := make(map[string]*promql.Series)
+	for _, matrix := range matrixes {
+		for _, series := range matrix {
+			key := series.Metric.String()
+			existing, ok := merged[key]
+			if !ok {
+				merged[key] = &promql.Series{Metric: series.Metric, Floats: slices.Clone(series.Floats)}
+				continue
+			}
+			existing.Floats = append(existing.Floats, series.Floats...)
+		}
+	}
+
+	result := make(promql.Matrix, 0, len(merged))
+	for _, series := range merged {
+		slices.SortStableFunc(series.Floats, func(a, b promql.FPoint) int {
+			return cmp.Compare(a.T, b.T)
+		})
+		series.Floats = slices.CompactFunc(series.Floats, func(a, b promql.FPoint) bool {
+			return a.T == b.T
+		})
+		result = append(result, *series)
+	}
+
+	sort.Sort(result)
+	return result
+}
+
+func pointsBetween(points []promql.FPoint, start, end int64) []promql.FPoint {
+	selected := make([]promql.FPoint, 0, len(points))
+	for _, point := range points {
+		if point.T >= start && point.T <= end {
+			selected = append(selected, point)
+		}
+	}
+
+	return selected
+}
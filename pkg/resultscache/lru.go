@@ -0,0 +1,113 @@
+package resultscache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Backend is where the cached results are kept. It allows shared backends (like memcached or
+// redis) to be added, so many Graviola instances can share the same cache.
+// Implementations must be safe for concurrent use.
+type Backend interface {
+	Fetch(ctx context.Context, key string) ([]byte, bool)
+	Store(ctx context.Context, key string, value []byte)
+}
+
+var runOnceLRUO11y sync.Once
+var lruSizeBytes prometheus.Gauge
+var lruEvictionsTotal prometheus.Counter
+
+type lruEntry struct {
+	key   string
+	value []byte
+}
+
+// InMemoryLRUBackend keeps the entries in memory, up to a maximum size in bytes. When a new
+// entry doesn't fit, the least recently used ones are evicted.
+type InMemoryLRUBackend struct {
+	maxSizeBytes int
+	sizeBytes    int
+	entries      *list.List
+	index        map[string]*list.Element
+	mu           sync.Mutex
+}
+
+func NewInMemoryLRUBackend(metricz *prometheus.Registry, maxSizeBytes int) *InMemoryLRUBackend {
+	runOnceLRUO11y.Do(func() {
+		lruSizeBytes = prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "graviola",
+			Subsystem: "results_cache",
+			Name:      "size_bytes",
+			Help:      "The size of the entries kept on the in-memory results cache.",
+		})
+		lruEvictionsTotal = prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: "graviola",
+			Subsystem: "results_cache",
+			Name:      "evictions_total",
+			Help:      "Counter of entries evicted from the in-memory results cache to free space.",
+		})
+
+		if metricz != nil {
+			metricz.MustRegister(lruSizeBytes, lruEvictionsTotal)
+		}
+	})
+
+	return &InMemoryLRUBackend{
+		maxSizeBytes: maxSizeBytes,
+		entries:      list.New(),
+		index:        make(map[string]*list.Element),
+	}
+}
+
+// Backend
+func (lru *InMemoryLRUBackend) Fetch(_ context.Context, key string) ([]byte, bool) {
+	lru.mu.Lock()
+	defer lru.mu.Unlock()
+
+	elem, ok := lru.index[key]
+	if !ok {
+		return nil, false
+	}
+
+	lru.entries.MoveToFront(elem)
+	return elem.Value.(*lruEntry).value, true
+}
+
+// Backend
+// Store ignores the values that are bigger than the whole cache.
+func (lru *InMemoryLRUBackend) Store(_ context.Context, key string, value []byte) {
+	lru.mu.Lock()
+	defer lru.mu.Unlock()
+
+	if entrySize(key, value) > lru.maxSizeBytes {
+		return
+	}
+
+	elem, ok := lru.index[key]
+	if ok {
+		lru.remove(elem)
+	}
+
+	lru.index[key] = lru.entries.PushFront(&lruEntry{key: key, value: value})
+	lru.sizeBytes += entrySize(key, value)
+
+	for lru.sizeBytes > lru.maxSizeBytes {
+		lru.remove(lru.entries.Back())
+		lruEvictionsTotal.Inc()
+	}
+
+	lruSizeBytes.Set(float64(lru.sizeBytes))
+}
+
+func (lru *InMemoryLRUBackend) remove(elem *list.Element) {
+	entry := lru.entries.Remove(elem).(*lruEntry)
+	delete(lru.index, entry.key)
+	lru.sizeBytes -= entrySize(entry.key, entry.value)
+}
+
+func entrySize(key string, value []byte) int {
+	return len(key) + len(value)
+}
@@ -0,0 +1,56 @@
+package resultscache_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jademcosta/graviola/pkg/resultscache"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInMemoryLRUBackendEvictsTheLeastRecentlyUsed(t *testing.T) {
+	ctx := context.Background()
+	// Each entry has 1 byte of key and 4 bytes of value
+	sut := resultscache.NewInMemoryLRUBackend(nil, 10)
+
+	sut.Store(ctx, "a", []byte("aaaa"))
+	sut.Store(ctx, "b", []byte("bbbb"))
+	_, ok := sut.Fetch(ctx, "a")
+	assert.True(t, ok, "should have the entry")
+
+	sut.Store(ctx, "c", []byte("cccc"))
+
+	_, ok = sut.Fetch(ctx, "b")
+	assert.False(t, ok, "should have evicted the least recently used entry")
+	value, ok := sut.Fetch(ctx, "a")
+	assert.True(t, ok, "should keep the recently used entry")
+	assert.Equal(t, []byte("aaaa"), value, "should return the stored value")
+	_, ok = sut.Fetch(ctx, "c")
+	assert.True(t, ok, "should keep the new entry")
+}
+
+func TestInMemoryLRUBackendReplacesEntries(t *testing.T) {
+	ctx := context.Background()
+	sut := resultscache.NewInMemoryLRUBackend(nil, 10)
+
+	sut.Store(ctx, "a", []byte("aaaa"))
+	sut.Store(ctx, "a", []byte("AAAA"))
+	sut.Store(ctx, "b", []byte("bbbb"))
+
+	value, ok := sut.Fetch(ctx, "a")
+	assert.True(t, ok, "should not count the replaced entry size twice")
+	assert.Equal(t, []byte("AAAA"), value, "should return the new value")
+}
+
+func TestInMemoryLRUBackendIgnoresEntriesBiggerThanTheCache(t *testing.T) {
+	ctx := context.Background()
+	sut := resultscache.NewInMemoryLRUBackend(nil, 10)
+
+	sut.Store(ctx, "a", []byte("aaaa"))
+	sut.Store(ctx, "b", []byte("this is too big"))
+
+	_, ok := sut.Fetch(ctx, "b")
+	assert.False(t, ok, "should not store the entry")
+	_, ok = sut.Fetch(ctx, "a")
+	assert.True(t, ok, "should not evict other entries")
+}
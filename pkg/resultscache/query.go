@@ -0,0 +1,289 @@
+package resultscache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/promql/parser"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/prometheus/prometheus/util/annotations"
+	"github.com/prometheus/prometheus/util/stats"
+)
+
+// cachedRangeQuery answers with the cached results when they exist, only sending to the
+// wrapped engine the parts of the range that are not cached yet.
+type cachedRangeQuery struct {
+	engine    *CachingEngine
+	fullQuery promql.Query
+	queryable storage.Queryable
+	opts      promql.QueryOpts
+	qs        string
+	start     int64
+	end       int64
+	step      int64
+	key       string
+
+	mu       sync.Mutex
+	cancelFn context.CancelFunc
+	// stats are the ones of the hit or partial hit, nil when the query was sent in full to the
+	// wrapped engine
+	stats *stats.Statistics
+}
+
+type timeRange struct {
+	start int64
+	end   int64
+}
+
+// Query
+func (query *cachedRangeQuery) Exec(ctx context.Context) *promql.Result {
+	ctx, cancelFn := context.WithCancel(ctx)
+	defer cancelFn()
+	query.mu.Lock()
+	query.cancelFn = cancelFn
+	query.mu.Unlock()
+
+	extents := query.engine.fetchExtents(ctx, query.key, query.step)
+	cachedIdx := query.bestExtent(extents)
+	if cachedIdx < 0 {
+		cacheRequestsTotal.WithLabelValues(resultMiss).Inc()
+		return query.execFullQuery(ctx, extents)
+	}
+
+	cached := extents[cachedIdx]
+	missing := query.missingRanges(cached)
+	if len(missing) == 0 {
+		cacheRequestsTotal.WithLabelValues(resultHit).Inc()
+		return query.execHit(ctx, cached.toMatrix(query.start, query.end))
+	}
+
+	cacheRequestsTotal.WithLabelValues(resultPartialHit).Inc()
+	query.setStats(&stats.Statistics{Timers: stats.NewQueryTimers(), Samples: stats.NewQuerySamples(false)})
+
+	fetched := make([]promql.Matrix, 0, len(missing))
+	warnings := annotations.New()
+	for _, rng := range missing {
+		matrix, rangeWarnings, err := query.execRange(ctx, rng)
+		if errors.Is(err, errNotCacheable) {
+			query.setStats(nil)
+			return query.fullQuery.Exec(ctx)
+		}
+		if err != nil {
+			return &promql.Result{Err: err, Warnings: *warnings}
+		}
+		warnings.Merge(rangeWarnings)
+		fetched = append(fetched, matrix)
+	}
+
+	// Responses with warnings might be partial, so they are not cached
+	if len(*warnings) == 0 {
+		merged := mergeMatrixes(append([]promql.Matrix{cached.toMatrix(cached.Start, cached.End)}, fetched...)...)
+		query.engine.storeExtent(ctx, query.key, extents,
+			newExtent(merged, min(cached.Start, query.start), max(cached.End, query.end)), query.step)
+	}
+
+	result := mergeMatrixes(append([]promql.Matrix{cached.toMatrix(query.start, query.end)}, fetched...)...)
+	return &promql.Result{Value: result, Warnings: *warnings}
+}
+
+// Query
+func (query *cachedRangeQuery) Close() {
+	query.fullQuery.Close()
+}
+
+// Query
+func (query *cachedRangeQuery) Statement() parser.Statement {
+	return query.fullQuery.Statement()
+}
+
+// Query
+func (query *cachedRangeQuery) Stats() *stats.Statistics {
+	query.mu.Lock()
+	defer query.mu.Unlock()
+	if query.stats != nil {
+		return query.stats
+	}
+
+	return query.fullQuery.Stats()
+}
+
+// Query
+func (query *cachedRangeQuery) Cancel() {
+	query.fullQuery.Cancel()
+
+	query.mu.Lock()
+	defer query.mu.Unlock()
+	if query.cancelFn != nil {
+		query.cancelFn()
+	}
+}
+
+// Query
+func (query *cachedRangeQuery) String() string {
+	return query.qs
+}
+
+// execHit answers with the cached results. When the wrapped engine is an Accountant, the hit is
+// accounted by it, so it is visible like the queries it executes.
+func (query *cachedRangeQuery) execHit(ctx context.Context, matrix promql.Matrix) *promql.Result {
+	hit := newHitQuery(query.fullQuery.Statement(), query.qs, matrix)
+	query.setStats(hit.Stats())
+
+	if accountant, ok := query.engine.next.(Accountant); ok {
+		return accountant.Account(hit).Exec(ctx)
+	}
+	return hit.Exec(ctx)
+}
+
+func (query *cachedRangeQuery) setStats(queryStats *stats.Statistics) {
+	query.mu.Lock()
+	defer query.mu.Unlock()
+	query.stats = queryStats
+}
+
+// addStats adds the samples of a query sent to the wrapped engine to the stats of the partial hit
+func (query *cachedRangeQuery) addStats(rangeStats *stats.Statistics) {
+	if rangeStats == nil || rangeStats.Samples == nil {
+		return
+	}
+
+	query.mu.Lock()
+	defer query.mu.Unlock()
+	query.stats.Samples.TotalSamples += rangeStats.Samples.TotalSamples
+	query.stats.Samples.PeakSamples = max(query.stats.Samples.PeakSamples, rangeStats.Samples.PeakSamples)
+}
+
+func (query *cachedRangeQuery) execFullQuery(ctx context.Context, extents []extent) *promql.Result {
+	result := query.fullQuery.Exec(ctx)
+	if result.Err != nil || len(result.Warnings) > 0 {
+		return result
+	}
+
+	matrix, ok := result.Value.(promql.Matrix)
+	if ok && !hasHistograms(matrix) {
+		query.engine.storeExtent(ctx, query.key, extents, newExtent(matrix, query.start, query.end), query.step)
+	}
+
+	return result
+}
+
+// execRange runs the query on the wrapped engine, only for the given range
+func (query *cachedRangeQuery) execRange(
+	ctx context.Context, rng timeRange,
+) (promql.Matrix, annotations.Annotations, error) {
+	rangeQuery, err := query.engine.next.NewRangeQuery(ctx, query.queryable, query.opts, query.qs,
+		time.UnixMilli(rng.start), time.UnixMilli(rng.end), time.Duration(query.step)*time.Millisecond)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rangeQuery.Close()
+
+	result := rangeQuery.Exec(ctx)
+	query.addStats(rangeQuery.Stats())
+	if result.Err != nil {
+		return nil, result.Warnings, result.Err
+	}
+
+	matrix, ok := result.Value.(promql.Matrix)
+	if !ok || hasHistograms(matrix) {
+		return nil, nil, errNotCacheable
+	}
+
+	// The points are copied, as the engine reuses them after the query is closed
+	return newExtent(matrix, rng.start, rng.end).toMatrix(rng.start, rng.end), result.Warnings, nil
+}
+
+// bestExtent returns the index of the extent that has most points in common with the query,
+// or -1 if none of them has.
+func (query *cachedRangeQuery) bestExtent(extents []extent) int {
+	bestIdx := -1
+	var bestOverlap int64 = -1
+	for idx, ext := range extents {
+		overlap := ext.overlap(query.start, query.end)
+		if overlap > bestOverlap {
+			bestIdx = idx
+			bestOverlap = overlap
+		}
+	}
+
+	return bestIdx
+}
+
+func (query *cachedRangeQuery) missingRanges(cached extent) []timeRange {
+	missing := make([]timeRange, 0, 2)
+	if query.start < cached.Start {
+		missing = append(missing, timeRange{start: query.start, end: cached.Start - query.step})
+	}
+	if query.end > cached.End {
+		missing = append(missing, timeRange{start: cached.End + query.step, end: query.end})
+	}
+
+	return missing
+}
+
+func hasHistograms(matrix promql.Matrix) bool {
+	for _, series := range matrix {
+		if len(series.Histograms) > 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// hitQuery is a query answered by the cache alone
+type hitQuery struct {
+	statement parser.Statement
+	qs        string
+	matrix    promql.Matrix
+	stats     *stats.Statistics
+}
+
+func newHitQuery(statement parser.Statement, qs string, matrix promql.Matrix) *hitQuery {
+	samples := stats.NewQuerySamples(false)
+	for _, series := range matrix {
+		samples.TotalSamples += int64(len(series.Floats))
+	}
+	samples.PeakSamples = int(samples.TotalSamples)
+
+	return &hitQuery{
+		statement: statement,
+		qs:        qs,
+		matrix:    matrix,
+		stats:     &stats.Statistics{Timers: stats.NewQueryTimers(), Samples: samples},
+	}
+}
+
+// Query
+func (query *hitQuery) Exec(ctx context.Context) *promql.Result {
+	if err := ctx.Err(); err != nil {
+		return &promql.Result{Err: err}
+	}
+
+	return &promql.Result{Value: query.matrix}
+}
+
+// Query
+func (query *hitQuery) Close() {}
+
+// Query
+func (query *hitQuery) Statement() parser.Statement {
+	return query.statement
+}
+
+// Query
+func (query *hitQuery) Stats() *stats.Statistics {
+	return query.stats
+}
+
+// Query
+// The results are already in memory, so there's nothing to cancel.
+func (query *hitQuery) Cancel() {}
+
+// Query
+func (query *hitQuery) String() string {
+	return query.qs
+}
@@ -0,0 +1,51 @@
+package storageproxy
+
+import (
+	"context"
+
+	"github.com/jademcosta/graviola/pkg/domain"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/prometheus/prometheus/util/annotations"
+)
+
+// MaxRemoteAnnotationsPerKind is how many warnings (and infos) coming from remotes are kept on
+// a single answer. The Prometheus API has its own limit of 10, so this one is kept lower, to
+// leave room for the annotations created by the engine.
+const MaxRemoteAnnotationsPerKind = 5
+
+// annotationsLimiter caps the amount of remote annotations returned by the querier it wraps
+type annotationsLimiter struct {
+	storage.Querier
+}
+
+// Querier
+func (limiter *annotationsLimiter) Select(
+	ctx context.Context, sortSeries bool, hints *storage.SelectHints, matchers ...*labels.Matcher,
+) storage.SeriesSet {
+	seriesSet := limiter.Querier.Select(ctx, sortSeries, hints, matchers...)
+
+	parsedSet, ok := seriesSet.(*domain.GraviolaSeriesSet)
+	if !ok {
+		return seriesSet
+	}
+
+	parsedSet.Annots = domain.LimitRemoteAnnotations(parsedSet.Annots, MaxRemoteAnnotationsPerKind)
+	return parsedSet
+}
+
+// LabelQuerier
+func (limiter *annotationsLimiter) LabelValues(
+	ctx context.Context, name string, hints *storage.LabelHints, matchers ...*labels.Matcher,
+) ([]string, annotations.Annotations, error) {
+	values, annots, err := limiter.Querier.LabelValues(ctx, name, hints, matchers...)
+	return values, domain.LimitRemoteAnnotations(annots, MaxRemoteAnnotationsPerKind), err
+}
+
+// LabelQuerier
+func (limiter *annotationsLimiter) LabelNames(
+	ctx context.Context, hints *storage.LabelHints, matchers ...*labels.Matcher,
+) ([]string, annotations.Annotations, error) {
+	names, annots, err := limiter.Querier.LabelNames(ctx, hints, matchers...)
+	return names, domain.LimitRemoteAnnotations(annots, MaxRemoteAnnotationsPerKind), err
+}
@@ -0,0 +1,179 @@
+package storageproxy
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/jademcosta/graviola/pkg/authz"
+	"github.com/jademcosta/graviola/pkg/domain"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/prometheus/prometheus/util/annotations"
+)
+
+type namedQuerier interface {
+	Name() string
+}
+
+// authorizedQuerier only lets the client see what its policy allows. The policy matchers are
+// added to every request, so they apply whatever the PromQL sent, and no request reads data
+// older than the max lookback.
+type authorizedQuerier struct {
+	storage.Querier
+	policies *authz.Policies
+}
+
+// Querier
+func (querier *authorizedQuerier) Select(
+	ctx context.Context, sortSeries bool, hints *storage.SelectHints, matchers ...*labels.Matcher,
+) storage.SeriesSet {
+	policy, err := querier.policies.ForContext(ctx)
+	if err != nil {
+		return storage.ErrSeriesSet(err)
+	}
+
+	if policy.MaxLookback > 0 && hints != nil {
+		minAllowed := querier.minAllowed(policy)
+		if hints.End < minAllowed {
+			return storage.EmptySeriesSet()
+		}
+
+		if hints.Start < minAllowed {
+			clamped := *hints
+			clamped.Start = minAllowed
+			hints = &clamped
+		}
+	}
+
+	return querier.Querier.Select(
+		authz.NewContext(ctx, policy), sortSeries, hints, withPolicyMatchers(policy, matchers)...)
+}
+
+// LabelQuerier
+func (querier *authorizedQuerier) LabelValues(
+	ctx context.Context, name string, hints *storage.LabelHints, matchers ...*labels.Matcher,
+) ([]string, annotations.Annotations, error) {
+	policy, err := querier.policies.ForContext(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ctx, ok := querier.clampTimeRange(ctx, policy)
+	if !ok {
+		return []string{}, nil, nil
+	}
+
+	return querier.Querier.LabelValues(
+		authz.NewContext(ctx, policy), name, hints, withPolicyMatchers(policy, matchers)...)
+}
+
+// LabelQuerier
+func (querier *authorizedQuerier) LabelNames(
+	ctx context.Context, hints *storage.LabelHints, matchers ...*labels.Matcher,
+) ([]string, annotations.Annotations, error) {
+	policy, err := querier.policies.ForContext(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ctx, ok := querier.clampTimeRange(ctx, policy)
+	if !ok {
+		return []string{}, nil, nil
+	}
+
+	return querier.Querier.LabelNames(
+		authz.NewContext(ctx, policy), hints, withPolicyMatchers(policy, matchers)...)
+}
+
+// clampTimeRange makes the time range of the label requests start at the max lookback of the
+// policy. It returns false when all the range is older than it.
+func (querier *authorizedQuerier) clampTimeRange(
+	ctx context.Context, policy *authz.Policy,
+) (context.Context, bool) {
+	if policy.MaxLookback <= 0 {
+		return ctx, true
+	}
+
+	minAllowed := querier.minAllowed(policy)
+	rng, ok := domain.TimeRangeFromContext(ctx)
+	if !ok {
+		rng = domain.TimeRange{Start: minAllowed, End: time.Now().UnixMilli()}
+	}
+
+	if rng.End < minAllowed {
+		return ctx, false
+	}
+
+	rng.Start = max(rng.Start, minAllowed)
+	return domain.NewTimeRangeContext(ctx, rng), true
+}
+
+func (querier *authorizedQuerier) minAllowed(policy *authz.Policy) int64 {
+	return time.Now().Add(-policy.MaxLookback).UnixMilli()
+}
+
+func withPolicyMatchers(policy *authz.Policy, matchers []*labels.Matcher) []*labels.Matcher {
+	if len(policy.Matchers) == 0 {
+		return matchers
+	}
+
+	return append(slices.Clone(matchers), policy.Matchers...)
+}
+
+// authorizedGroup answers empty when the policy of the client doesn't allow the group
+type authorizedGroup struct {
+	storage.Querier
+	name string
+}
+
+func (group *authorizedGroup) Name() string {
+	return group.name
+}
+
+// Querier
+func (group *authorizedGroup) Select(
+	ctx context.Context, sortSeries bool, hints *storage.SelectHints, matchers ...*labels.Matcher,
+) storage.SeriesSet {
+	if !authz.FromContext(ctx).AllowsStorageGroup(group.name) {
+		return storage.EmptySeriesSet()
+	}
+
+	return group.Querier.Select(ctx, sortSeries, hints, matchers...)
+}
+
+// LabelQuerier
+func (group *authorizedGroup) LabelValues(
+	ctx context.Context, name string, hints *storage.LabelHints, matchers ...*labels.Matcher,
+) ([]string, annotations.Annotations, error) {
+	if !authz.FromContext(ctx).AllowsStorageGroup(group.name) {
+		return []string{}, nil, nil
+	}
+
+	return group.Querier.LabelValues(ctx, name, hints, matchers...)
+}
+
+// LabelQuerier
+func (group *authorizedGroup) LabelNames(
+	ctx context.Context, hints *storage.LabelHints, matchers ...*labels.Matcher,
+) ([]string, annotations.Annotations, error) {
+	if !authz.FromContext(ctx).AllowsStorageGroup(group.name) {
+		return []string{}, nil, nil
+	}
+
+	return group.Querier.LabelNames(ctx, hints, matchers...)
+}
+
+// authorizedGroups wraps each group so it is only queried when the policy of the client allows
+func authorizedGroups(groups []storage.Querier) []storage.Querier {
+	wrapped := make([]storage.Querier, 0, len(groups))
+	for _, group := range groups {
+		name := ""
+		if named, ok := group.(namedQuerier); ok {
+			name = named.Name()
+		}
+		wrapped = append(wrapped, &authorizedGroup{Querier: group, name: name})
+	}
+
+	return wrapped
+}
@@ -0,0 +1,31 @@
+package storageproxy
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jademcosta/graviola/pkg/querylimits"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/prometheus/prometheus/util/annotations"
+)
+
+// labelValuesLimiter fails the label values requests that answer with too many values
+type labelValuesLimiter struct {
+	storage.Querier
+	maxValues int
+}
+
+// LabelQuerier
+func (limiter *labelValuesLimiter) LabelValues(
+	ctx context.Context, name string, hints *storage.LabelHints, matchers ...*labels.Matcher,
+) ([]string, annotations.Annotations, error) {
+	values, annots, err := limiter.Querier.LabelValues(ctx, name, hints, matchers...)
+	if err == nil && len(values) > limiter.maxValues {
+		err = fmt.Errorf("%w: the label values response has more than %d values (max_label_values)",
+			querylimits.ErrLimitExceeded, limiter.maxValues)
+		return nil, annots, err
+	}
+
+	return values, annots, err
+}
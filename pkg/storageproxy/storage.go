@@ -1,39 +1,146 @@
 package storageproxy
 
 import (
+	"context"
 	"errors"
 	"log/slog"
+	"sync/atomic"
 
+	"github.com/jademcosta/graviola/pkg/authz"
+	"github.com/jademcosta/graviola/pkg/domain"
 	"github.com/jademcosta/graviola/pkg/remotestoragegroup"
 	"github.com/jademcosta/graviola/pkg/remotestoragegroup/queryfailurestrategy"
+	"github.com/prometheus/prometheus/model/labels"
 	"github.com/prometheus/prometheus/storage"
+	"github.com/prometheus/prometheus/util/annotations"
 )
 
 // GraviolaStorage is a wrapper around a list of groups. It implements the same interface of a
 // Prometheus "Queryable". So, it acts like a "storage" of data
 type GraviolaStorage struct {
-	logger    *slog.Logger
-	rootGroup *remotestoragegroup.RemoteGroup
+	logger         *slog.Logger
+	maxLabelValues int
+	policies       *authz.Policies
+	rootGroup      atomic.Pointer[rootGroup]
 }
 
+// rootGroup is the tree of groups and remotes queried, which is replaced when the config is
+// reloaded
+type rootGroup struct {
+	storage.Querier
+}
+
+// NewGraviolaStorage creates the storage that queries all the groups. Label values requests
+// answering with more than maxLabelValues fail, unless it is zero. When policies is not nil,
+// each client only sees the groups and series its policy allows.
 func NewGraviolaStorage(
 	logger *slog.Logger, groups []storage.Querier, mergeStrategy remotestoragegroup.MergeStrategy,
+	maxLabelValues int, policies *authz.Policies,
 ) *GraviolaStorage {
-	return &GraviolaStorage{
-		logger: logger,
+	gravStorage := &GraviolaStorage{
+		logger:         logger,
+		maxLabelValues: maxLabelValues,
+		policies:       policies,
+	}
+	gravStorage.rootGroup.Store(gravStorage.newRootGroup(groups, mergeStrategy))
+
+	return gravStorage
+}
+
+// ReplaceGroups makes the new queries use the groups given. The queries already running finish
+// on the old groups, which are closed.
+func (gravStorage *GraviolaStorage) ReplaceGroups(
+	groups []storage.Querier, mergeStrategy remotestoragegroup.MergeStrategy,
+) error {
+	old := gravStorage.rootGroup.Swap(gravStorage.newRootGroup(groups, mergeStrategy))
+
+	// Only the idle connections are closed, the ones of the running queries are closed when
+	// they become idle
+	return old.Close()
+}
+
+func (gravStorage *GraviolaStorage) newRootGroup(
+	groups []storage.Querier, mergeStrategy remotestoragegroup.MergeStrategy,
+) *rootGroup {
+	if gravStorage.policies != nil {
+		groups = authorizedGroups(groups)
+	}
+
+	var root storage.Querier = &annotationsLimiter{
 		//TODO: should this fail strategy be the default? Allow to configure it
-		rootGroup: remotestoragegroup.NewRemoteGroup(
-			logger, "root", groups,
+		Querier: remotestoragegroup.NewRemoteGroup(
+			gravStorage.logger, "root", groups,
 			&queryfailurestrategy.FailAllStrategy{},
 			mergeStrategy,
 		),
 	}
+	if gravStorage.maxLabelValues > 0 {
+		root = &labelValuesLimiter{Querier: root, maxValues: gravStorage.maxLabelValues}
+	}
+
+	return &rootGroup{Querier: root}
 }
 
 // Queryable
 // mint, maxt int64
-func (gravStorage *GraviolaStorage) Querier(_, _ int64) (storage.Querier, error) {
-	return gravStorage.rootGroup, nil
+func (gravStorage *GraviolaStorage) Querier(mint, maxt int64) (storage.Querier, error) {
+	var querier storage.Querier = gravStorage.rootGroup.Load()
+	if gravStorage.policies != nil {
+		querier = &authorizedQuerier{Querier: querier, policies: gravStorage.policies}
+	}
+
+	return &sharedQuerier{
+		Querier:   querier,
+		timeRange: domain.TimeRange{Start: mint, End: maxt},
+		picks:     remotestoragegroup.NewPicks(),
+	}, nil
+}
+
+// Close releases the resources of the groups and their remotes, like their connections. It is
+// called when Graviola stops.
+func (gravStorage *GraviolaStorage) Close() error {
+	return gravStorage.rootGroup.Load().Close()
+}
+
+// sharedQuerier is the querier handed to each query. The groups are shared by all the queries,
+// so closing it when a query finishes does nothing; they are closed when they are replaced or by
+// GraviolaStorage.Close. The label requests take the time range of the querier on the context,
+// as they don't have it otherwise. All the requests of the querier take the same picks, so the
+// round robin groups answer a query from a single replica.
+type sharedQuerier struct {
+	storage.Querier
+	timeRange domain.TimeRange
+	picks     *remotestoragegroup.Picks
+}
+
+// Querier
+func (querier *sharedQuerier) Select(
+	ctx context.Context, sortSeries bool, hints *storage.SelectHints, matchers ...*labels.Matcher,
+) storage.SeriesSet {
+	return querier.Querier.Select(remotestoragegroup.NewPicksContext(ctx, querier.picks), sortSeries, hints,
+		matchers...)
+}
+
+// LabelQuerier
+func (querier *sharedQuerier) LabelValues(
+	ctx context.Context, name string, hints *storage.LabelHints, matchers ...*labels.Matcher,
+) ([]string, annotations.Annotations, error) {
+	ctx = remotestoragegroup.NewPicksContext(ctx, querier.picks)
+	return querier.Querier.LabelValues(
+		domain.NewTimeRangeContext(ctx, querier.timeRange), name, hints, matchers...)
+}
+
+// LabelQuerier
+func (querier *sharedQuerier) LabelNames(
+	ctx context.Context, hints *storage.LabelHints, matchers ...*labels.Matcher,
+) ([]string, annotations.Annotations, error) {
+	ctx = remotestoragegroup.NewPicksContext(ctx, querier.picks)
+	return querier.Querier.LabelNames(domain.NewTimeRangeContext(ctx, querier.timeRange), hints, matchers...)
+}
+
+// Querier
+func (*sharedQuerier) Close() error {
+	return nil
 }
 
 // ChunkQueryable
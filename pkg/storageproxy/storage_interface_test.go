@@ -13,10 +13,10 @@ import (
 func TestGraviolaStorageComplyWithStorageSampleAndChunkQueryable(_ *testing.T) {
 	logger := graviolalog.NewNoopLogger()
 	groups := []storage.Querier{}
-	mergeStrategy := remotestoragegroup.MergeStrategyFactory(config.MergeStrategyAlwaysMerge)
+	mergeStrategy := remotestoragegroup.MergeStrategyFactory(config.MergeStrategyConfig{Strategy: config.MergeStrategyAlwaysMerge}, nil)
 
 	dummyFunc := func(_ storage.SampleAndChunkQueryable) {}
 
-	sut := storageproxy.NewGraviolaStorage(logger, groups, mergeStrategy)
+	sut := storageproxy.NewGraviolaStorage(logger, groups, mergeStrategy, 0, nil)
 	dummyFunc(sut)
 }
@@ -7,13 +7,19 @@ import (
 	"slices"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/jademcosta/graviola/internal/mocks"
+	"github.com/jademcosta/graviola/pkg/authz"
+	"github.com/jademcosta/graviola/pkg/clientinfo"
 	"github.com/jademcosta/graviola/pkg/config"
 	"github.com/jademcosta/graviola/pkg/domain"
 	"github.com/jademcosta/graviola/pkg/graviolalog"
+	"github.com/jademcosta/graviola/pkg/o11y"
+	"github.com/jademcosta/graviola/pkg/querylimits"
 	"github.com/jademcosta/graviola/pkg/remotestoragegroup"
 	"github.com/jademcosta/graviola/pkg/storageproxy"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/common/model"
 	"github.com/prometheus/prometheus/model/labels"
 	"github.com/prometheus/prometheus/storage"
@@ -25,7 +31,7 @@ const anyMinTime = int64(0)
 const anyMaxTime = int64(1)
 
 var logg = graviolalog.NewLogger(config.LogConfig{Level: "error"})
-var defaultMergeStrategy = remotestoragegroup.MergeStrategyFactory(config.DefaultMergeStrategyType)
+var defaultMergeStrategy = remotestoragegroup.MergeStrategyFactory(config.MergeStrategyConfig{Strategy: config.DefaultMergeStrategyType}, nil)
 
 func TestSelect(t *testing.T) {
 	mockStorage1 := &mocks.RemoteStorageMock{
@@ -45,7 +51,7 @@ func TestSelect(t *testing.T) {
 		},
 	}
 
-	sut := storageproxy.NewGraviolaStorage(logg, []storage.Querier{mockStorage1, mockStorage2}, defaultMergeStrategy)
+	sut := storageproxy.NewGraviolaStorage(logg, []storage.Querier{mockStorage1, mockStorage2}, defaultMergeStrategy, 0, nil)
 
 	querier, err := sut.Querier(anyMinTime, anyMaxTime)
 	require.NoError(t, err, "should return no error")
@@ -108,7 +114,7 @@ func TestConcurrentSelects(t *testing.T) {
 		},
 	}
 
-	sut := storageproxy.NewGraviolaStorage(logg, []storage.Querier{mockStorage1, mockStorage2}, defaultMergeStrategy)
+	sut := storageproxy.NewGraviolaStorage(logg, []storage.Querier{mockStorage1, mockStorage2}, defaultMergeStrategy, 0, nil)
 
 	querier, err := sut.Querier(anyMinTime, anyMaxTime)
 	require.NoError(t, err, "should return no error")
@@ -229,7 +235,7 @@ func TestConcurrentSelectsWithDifferentAnswers(t *testing.T) {
 		},
 	}
 
-	sut := storageproxy.NewGraviolaStorage(logg, []storage.Querier{mockStorage1, mockStorage2}, defaultMergeStrategy)
+	sut := storageproxy.NewGraviolaStorage(logg, []storage.Querier{mockStorage1, mockStorage2}, defaultMergeStrategy, 0, nil)
 
 	querier, err := sut.Querier(0, 6000)
 	require.NoError(t, err, "should not return error")
@@ -277,3 +283,142 @@ func TestConcurrentSelectsWithDifferentAnswers(t *testing.T) {
 
 	assert.Equal(t, goroutinesTotal, counterOfResults, "should have returned all results")
 }
+
+func TestLabelValuesFailsWhenAnsweringWithTooManyValues(t *testing.T) {
+	mockStorage := &mocks.RemoteStorageMock{
+		SeriesSet: &domain.GraviolaSeriesSet{
+			Series: []*domain.GraviolaSeries{
+				{Lbs: labels.FromStrings("label1", "val1")},
+				{Lbs: labels.FromStrings("label1", "val2")},
+				{Lbs: labels.FromStrings("label1", "val3")},
+			},
+		},
+	}
+
+	sut := storageproxy.NewGraviolaStorage(logg, []storage.Querier{mockStorage}, defaultMergeStrategy, 3, nil)
+	querier, err := sut.Querier(anyMinTime, anyMaxTime)
+	require.NoError(t, err, "should return no error")
+
+	values, _, err := querier.LabelValues(context.Background(), "label1", nil)
+	require.NoError(t, err, "should not fail when the values are up to the limit")
+	assert.Len(t, values, 3, "should answer with all values")
+
+	sut = storageproxy.NewGraviolaStorage(logg, []storage.Querier{mockStorage}, defaultMergeStrategy, 2, nil)
+	querier, err = sut.Querier(anyMinTime, anyMaxTime)
+	require.NoError(t, err, "should return no error")
+
+	_, _, err = querier.LabelValues(context.Background(), "label1", nil)
+	assert.ErrorIs(t, err, querylimits.ErrLimitExceeded, "should fail when there are more values than the limit")
+}
+
+func TestEnforcesTheAuthorizationPolicyOfTheClient(t *testing.T) {
+	policies, err := authz.NewPolicies(config.AuthorizationConfig{
+		Enabled: true,
+		Policies: []config.AuthorizationPolicyConfig{{
+			Name:          "payments",
+			Identities:    []string{"payments-grafana"},
+			StorageGroups: []string{"main"},
+			Matchers:      []string{`team="payments"`},
+			MaxLookback:   "1h",
+		}},
+	})
+	require.NoError(t, err, "should create the policies")
+
+	seriesSet := &domain.GraviolaSeriesSet{Series: []*domain.GraviolaSeries{
+		{Lbs: labels.FromStrings("team", "payments")},
+	}}
+	mainGroup := &mocks.RemoteStorageMock{SeriesSet: seriesSet}
+	otherGroup := &mocks.RemoteStorageMock{SeriesSet: seriesSet}
+	registry := prometheus.NewRegistry()
+	sut := storageproxy.NewGraviolaStorage(logg, []storage.Querier{
+		o11y.NewQuerierO11y(registry, "main", "group", mainGroup),
+		o11y.NewQuerierO11y(registry, "other", "group", otherGroup),
+	}, defaultMergeStrategy, 0, policies)
+
+	now := time.Now()
+	querier, err := sut.Querier(now.Add(-24*time.Hour).UnixMilli(), now.UnixMilli())
+	require.NoError(t, err, "should return no error")
+
+	ctx := clientinfo.NewContext(context.Background(), clientinfo.Info{Principal: "payments-grafana"})
+	hints := &storage.SelectHints{Start: now.Add(-24 * time.Hour).UnixMilli(), End: now.UnixMilli()}
+	result := querier.Select(ctx, true, hints, labels.MustNewMatcher(labels.MatchEqual, "__name__", "up"))
+	require.NoError(t, result.Err(), "should answer the client with a policy")
+
+	require.Len(t, mainGroup.CalledWithMatchers, 1, "should query the groups allowed by the policy")
+	assert.Empty(t, otherGroup.CalledWithMatchers, "should not query the groups not allowed by the policy")
+	assert.Equal(t, []*labels.Matcher{
+		labels.MustNewMatcher(labels.MatchEqual, "__name__", "up"),
+		labels.MustNewMatcher(labels.MatchEqual, "team", "payments"),
+	}, mainGroup.CalledWithMatchers[0], "should add the matchers of the policy")
+	assert.GreaterOrEqual(t, mainGroup.CalledWithHints[0].Start, now.Add(-time.Hour).UnixMilli(),
+		"should not read data older than the max lookback")
+
+	_, _, err = querier.LabelNames(ctx, nil)
+	require.NoError(t, err, "should answer label requests of the client with a policy")
+	rng, ok := domain.TimeRangeFromContext(mainGroup.CalledWithContexts[1])
+	require.True(t, ok, "should send the time range of the querier to the label requests")
+	assert.GreaterOrEqual(t, rng.Start, now.Add(-time.Hour).UnixMilli(),
+		"should not read labels older than the max lookback")
+	assert.Equal(t, now.UnixMilli(), rng.End, "should keep the end of the querier")
+
+	result = querier.Select(context.Background(), true, hints, labels.MustNewMatcher(labels.MatchEqual, "__name__", "up"))
+	assert.ErrorIs(t, result.Err(), authz.ErrForbidden, "should not answer clients without a policy")
+	_, _, err = querier.LabelValues(context.Background(), "team", nil)
+	assert.ErrorIs(t, err, authz.ErrForbidden, "should not answer clients without a policy")
+
+	oldQuerier, err := sut.Querier(now.Add(-48*time.Hour).UnixMilli(), now.Add(-24*time.Hour).UnixMilli())
+	require.NoError(t, err, "should return no error")
+	values, _, err := oldQuerier.LabelValues(ctx, "team", nil)
+	require.NoError(t, err, "should not fail label requests older than the max lookback")
+	assert.Empty(t, values, "should answer empty label requests older than the max lookback")
+}
+
+func TestTheGroupsAreOnlyClosedWhenTheStorageIs(t *testing.T) {
+	mockStorage1 := &mocks.RemoteStorageMock{SeriesSet: &domain.GraviolaSeriesSet{}}
+	mockStorage2 := &mocks.RemoteStorageMock{SeriesSet: &domain.GraviolaSeriesSet{}}
+
+	sut := storageproxy.NewGraviolaStorage(logg, []storage.Querier{mockStorage1, mockStorage2}, defaultMergeStrategy, 0, nil)
+
+	querier, err := sut.Querier(anyMinTime, anyMaxTime)
+	require.NoError(t, err, "should return no error")
+	require.NoError(t, querier.Close(), "should return no error")
+	assert.Equal(t, 0, mockStorage1.CloseCalled, "should not close the groups when a query finishes")
+
+	require.NoError(t, sut.Close(), "should return no error")
+	assert.Equal(t, 1, mockStorage1.CloseCalled, "should close the groups when the storage is closed")
+	assert.Equal(t, 1, mockStorage2.CloseCalled, "should close the groups when the storage is closed")
+}
+
+func TestReplacedGroupsAreOnlyUsedByTheQueriesAlreadyRunning(t *testing.T) {
+	oldRemote := &mocks.RemoteStorageMock{SeriesSet: &domain.GraviolaSeriesSet{
+		Series: []*domain.GraviolaSeries{
+			{Lbs: labels.FromStrings("remote", "old"), Datapoints: []model.SamplePair{{Timestamp: 1, Value: 1}}},
+		},
+	}}
+	newRemote := &mocks.RemoteStorageMock{SeriesSet: &domain.GraviolaSeriesSet{
+		Series: []*domain.GraviolaSeries{
+			{Lbs: labels.FromStrings("remote", "new"), Datapoints: []model.SamplePair{{Timestamp: 1, Value: 1}}},
+		},
+	}}
+
+	sut := storageproxy.NewGraviolaStorage(logg, []storage.Querier{oldRemote}, defaultMergeStrategy, 0, nil)
+	runningQuerier, err := sut.Querier(anyMinTime, anyMaxTime)
+	require.NoError(t, err, "should return no error")
+
+	require.NoError(t, sut.ReplaceGroups([]storage.Querier{newRemote}, defaultMergeStrategy),
+		"should replace the groups")
+	assert.Equal(t, 1, oldRemote.CloseCalled, "should close the replaced groups")
+
+	matcher := labels.MustNewMatcher(labels.MatchRegexp, "remote", ".+")
+	selectRemoteLabel := func(querier storage.Querier) string {
+		seriesSet := querier.Select(context.Background(), true, nil, matcher)
+		require.True(t, seriesSet.Next(), "should return a series")
+		return seriesSet.At().Labels().Get("remote")
+	}
+
+	assert.Equal(t, "old", selectRemoteLabel(runningQuerier), "should keep the running queries on the old groups")
+
+	newQuerier, err := sut.Querier(anyMinTime, anyMaxTime)
+	require.NoError(t, err, "should return no error")
+	assert.Equal(t, "new", selectRemoteLabel(newQuerier), "should use the new groups on new queries")
+}
@@ -0,0 +1,153 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/jademcosta/graviola/pkg/config"
+	"github.com/prometheus/common/version"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName is the name of the tracer of all the Graviola spans
+const instrumentationName = "github.com/jademcosta/graviola"
+
+// TraceIDHeader is the response header with the ID of the trace of the request
+const TraceIDHeader = "X-Trace-Id"
+
+type nopCloser struct{}
+
+func (nopCloser) Close() error { return nil }
+
+// Setup makes the spans be exported as configured, and the W3C trace context be read from and
+// sent on HTTP headers. It returns the function that flushes the pending spans and stops the
+// exporter. When tracing is disabled spans aren't recorded, and nothing needs to be stopped.
+func Setup(conf config.TracingConfig) (func(context.Context) error, error) {
+	if !conf.Enabled {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	provider, closer, err := NewTracerProvider(context.Background(), conf)
+	if err != nil {
+		return nil, err
+	}
+
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{}, propagation.Baggage{}))
+
+	return func(ctx context.Context) error {
+		return errors.Join(provider.Shutdown(ctx), closer.Close())
+	}, nil
+}
+
+// NewTracerProvider creates the provider of the tracers, along with what should be closed
+// after it is shut down (the file of the spans, when they are exported to one)
+func NewTracerProvider(
+	ctx context.Context, conf config.TracingConfig,
+) (*sdktrace.TracerProvider, io.Closer, error) {
+	exporter, closer, err := newExporter(ctx, conf)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error creating the tracing exporter: %w", err)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(
+		attribute.String("service.name", conf.ServiceName),
+		attribute.String("service.version", version.Version),
+	))
+	if err != nil {
+		return nil, nil, fmt.Errorf("error creating the tracing resource: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(conf.SamplingRatio))),
+	)
+
+	return provider, closer, nil
+}
+
+func newExporter(ctx context.Context, conf config.TracingConfig) (sdktrace.SpanExporter, io.Closer, error) {
+	switch conf.Exporter {
+	case config.TracingExporterStdout:
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+		return exporter, nopCloser{}, err
+	case config.TracingExporterFile:
+		file, err := os.OpenFile(conf.FilePath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(file))
+		if err != nil {
+			file.Close()
+			return nil, nil, err
+		}
+		return exporter, file, nil
+	}
+
+	otlpConf := conf.OTLPConf
+	if otlpConf.Protocol == config.TracingOTLPProtocolHTTP {
+		opts := []otlptracehttp.Option{
+			otlptracehttp.WithEndpoint(otlpConf.Endpoint),
+			otlptracehttp.WithHeaders(otlpConf.Headers),
+			otlptracehttp.WithTimeout(otlpConf.TimeoutDuration()),
+		}
+		if otlpConf.Insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+
+		exporter, err := otlptracehttp.New(ctx, opts...)
+		return exporter, nopCloser{}, err
+	}
+
+	opts := []otlptracegrpc.Option{
+		otlptracegrpc.WithEndpoint(otlpConf.Endpoint),
+		otlptracegrpc.WithHeaders(otlpConf.Headers),
+		otlptracegrpc.WithTimeout(otlpConf.TimeoutDuration()),
+	}
+	if otlpConf.Insecure {
+		opts = append(opts, otlptracegrpc.WithInsecure())
+	}
+
+	exporter, err := otlptracegrpc.New(ctx, opts...)
+	return exporter, nopCloser{}, err
+}
+
+// Start starts a span, child of the span on the context (if any)
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// RecordError marks the span as failed, when there's an error
+func RecordError(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
+
+// TraceID returns the ID of the trace on the context, or an empty string when there's none
+func TraceID(ctx context.Context) string {
+	spanContext := trace.SpanContextFromContext(ctx)
+	if !spanContext.HasTraceID() {
+		return ""
+	}
+
+	return spanContext.TraceID().String()
+}
@@ -0,0 +1,41 @@
+package tracing_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jademcosta/graviola/pkg/config"
+	"github.com/jademcosta/graviola/pkg/tracing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportsTheSpansToAFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "spans.json")
+
+	stop, err := tracing.Setup(config.TracingConfig{
+		Enabled: true, Exporter: config.TracingExporterFile, FilePath: path,
+	}.FillDefaults())
+	require.NoError(t, err, "should set up tracing")
+
+	ctx, span := tracing.Start(context.Background(), "some.operation")
+	assert.NotEmpty(t, tracing.TraceID(ctx), "should have the ID of the trace on the context")
+	span.End()
+
+	require.NoError(t, stop(context.Background()), "should flush the spans when stopping")
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err, "should have created the file")
+	assert.Contains(t, string(content), `"Name":"some.operation"`, "should have written the span")
+	assert.Contains(t, string(content), `"Value":"graviola"`, "should have written the service name")
+}
+
+func TestDisabledTracingDoesNothing(t *testing.T) {
+	stop, err := tracing.Setup(config.TracingConfig{Enabled: false})
+	require.NoError(t, err, "should not fail when disabled")
+	require.NoError(t, stop(context.Background()), "should not fail to stop when disabled")
+
+	assert.Empty(t, tracing.TraceID(context.Background()), "should have no trace ID without a span")
+}